@@ -2,24 +2,57 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/ruqqq/simple-ai-gateway/internal/api"
+	"github.com/ruqqq/simple-ai-gateway/internal/archive"
+	"github.com/ruqqq/simple-ai-gateway/internal/auth"
+	"github.com/ruqqq/simple-ai-gateway/internal/certs"
+	"github.com/ruqqq/simple-ai-gateway/internal/chaos"
 	"github.com/ruqqq/simple-ai-gateway/internal/config"
 	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/export"
+	"github.com/ruqqq/simple-ai-gateway/internal/federation"
+	"github.com/ruqqq/simple-ai-gateway/internal/finetune"
+	"github.com/ruqqq/simple-ai-gateway/internal/guardrail"
+	"github.com/ruqqq/simple-ai-gateway/internal/keypool"
+	"github.com/ruqqq/simple-ai-gateway/internal/logging"
+	"github.com/ruqqq/simple-ai-gateway/internal/metrics"
+	"github.com/ruqqq/simple-ai-gateway/internal/pricing"
 	"github.com/ruqqq/simple-ai-gateway/internal/provider"
 	"github.com/ruqqq/simple-ai-gateway/internal/proxy"
+	"github.com/ruqqq/simple-ai-gateway/internal/ratelimit"
+	"github.com/ruqqq/simple-ai-gateway/internal/restart"
+	"github.com/ruqqq/simple-ai-gateway/internal/retention"
+	"github.com/ruqqq/simple-ai-gateway/internal/router"
+	"github.com/ruqqq/simple-ai-gateway/internal/s3"
+	"github.com/ruqqq/simple-ai-gateway/internal/sink"
 	"github.com/ruqqq/simple-ai-gateway/internal/storage"
+	"github.com/ruqqq/simple-ai-gateway/internal/tools"
 	"github.com/ruqqq/simple-ai-gateway/internal/ui"
+	"github.com/ruqqq/simple-ai-gateway/internal/version"
 )
 
+// Version is set at build time (see Makefile)
+var Version = "dev"
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(runVerify(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -27,46 +60,580 @@ func main() {
 		os.Exit(1)
 	}
 
-	fmt.Printf("Starting Simple AI Gateway\n")
-	fmt.Printf("  Port: %d\n", cfg.Port)
-	fmt.Printf("  Database: %s\n", cfg.DBPath)
-	fmt.Printf("  File Storage: %s\n", cfg.FileStoragePath)
+	if err := logging.Setup(os.Stdout, cfg.LogLevel, cfg.LogFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to configure logging: %v\n", err)
+		os.Exit(1)
+	}
+
+	buildInfo := version.Build(Version)
+	slog.Info("starting Simple AI Gateway",
+		"version", buildInfo.Version,
+		"port", cfg.Port,
+		"database", cfg.DBPath,
+		"file_storage", cfg.FileStoragePath,
+		"routes_file", cfg.RoutesFile,
+	)
+
+	// Listeners passed by the process this one replaces, on SIGUSR2
+	handover, err := restart.New()
+	if err != nil {
+		slog.Error("failed to inherit listeners", "error", err)
+		os.Exit(1)
+	}
+	if handover.Inherited() {
+		slog.Info("taking over listeners from the previous process", "parent_pid", os.Getppid())
+	}
 
 	// Initialize database
 	db, err := database.New(cfg.DBPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to initialize database: %v\n", err)
+		slog.Error("failed to initialize database", "error", err)
 		os.Exit(1)
 	}
 	defer db.Close()
+	if !db.FullTextSearch() {
+		slog.Warn("SQLite was built without FTS5 (build with -tags sqlite_fts5): request searches scan the bodies")
+	}
+	db.SetBodyCompression(cfg.CompressBodiesOverKB * 1024)
+	if cfg.WriteQueueSize > 0 {
+		if cfg.WriteQueueFull != "block" && cfg.WriteQueueFull != "drop" {
+			slog.Error("invalid WRITE_QUEUE_FULL, expected block or drop", "value", cfg.WriteQueueFull)
+			os.Exit(1)
+		}
+		db.StartWriteBehind(database.WriteBehindOptions{
+			QueueSize: cfg.WriteQueueSize,
+			DropFull:  cfg.WriteQueueFull == "drop",
+		})
+	}
+	if cfg.SamplingRules != "" {
+		rules, err := database.ParseSamplingRules(cfg.SamplingRules)
+		if err != nil {
+			slog.Error("invalid SAMPLING_RULES", "error", err)
+			os.Exit(1)
+		}
+		db.SetSampling(rules)
+		slog.Info("storage sampling enabled", "rules", cfg.SamplingRules)
+	}
 
-	// Initialize file storage
-	fs, err := storage.New(cfg.FileStoragePath)
+	// Mask credentials before requests and responses are stored
+	redactOpts := guardrail.RedactorOptions{
+		Credentials: cfg.RedactCredentials,
+		Headers:     strings.Split(cfg.RedactHeaders, ","),
+		Secrets:     cfg.RedactSecrets,
+	}
+	if cfg.RedactPatternsFile != "" {
+		if redactOpts.Patterns, err = guardrail.LoadPatterns(cfg.RedactPatternsFile); err != nil {
+			slog.Error("invalid REDACT_PATTERNS_FILE", "error", err)
+			os.Exit(1)
+		}
+	}
+	redactor, err := guardrail.NewRedactor(redactOpts)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to initialize file storage: %v\n", err)
+		slog.Error("invalid REDACT_PATTERNS_FILE", "error", err)
 		os.Exit(1)
 	}
+	if redactor != nil {
+		db.SetRedactor(redactor)
+	} else {
+		slog.Warn("redaction is off: credential headers are stored as sent")
+	}
 
-	// Initialize providers
-	providers := []provider.Provider{
-		provider.NewOpenAIProvider(),
-		provider.NewReplicateProvider(),
+	// Initialize file storage: a local directory, or a bucket shared by
+	// every instance
+	var fs storage.FileStorage
+	if cfg.FileStorageBucket != "" {
+		// Retention deletes unknown objects under the prefix as orphans
+		if cfg.FileStorageBucket == cfg.ArchiveBucket && strings.HasPrefix(cfg.ArchivePrefix, cfg.FileStoragePrefix) {
+			slog.Error("FILE_STORAGE_PREFIX must not contain ARCHIVE_PREFIX in a shared bucket", "file_storage_prefix", cfg.FileStoragePrefix, "archive_prefix", cfg.ArchivePrefix)
+			os.Exit(1)
+		}
+		store, err := s3.New(s3.Options{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.FileStorageBucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			SessionToken:    cfg.S3SessionToken,
+		})
+		if err != nil {
+			slog.Error("invalid file storage bucket", "error", err)
+			os.Exit(1)
+		}
+		bucket := storage.NewS3(store, storage.S3Options{
+			Prefix:          cfg.FileStoragePrefix,
+			SignedURLExpiry: time.Duration(cfg.FileSignedURLSeconds) * time.Second,
+		})
+		bucket.SetThumbnailSize(cfg.ThumbnailSize)
+		fs = bucket
+		slog.Info("storing files in object storage", "bucket", cfg.FileStorageBucket, "prefix", cfg.FileStoragePrefix, "signed_url_seconds", cfg.FileSignedURLSeconds)
+	} else {
+		local, err := storage.New(cfg.FileStoragePath)
+		if err != nil {
+			slog.Error("failed to initialize file storage", "error", err)
+			os.Exit(1)
+		}
+		local.SetThumbnailSize(cfg.ThumbnailSize)
+		fs = local
+	}
+
+	// Initialize providers (built-ins plus any registered via plugins.go)
+	providers := provider.Registered()
+
+	// Inject gateway-side API keys for providers that support it, pooling them
+	// when a provider has several
+	switch cfg.APIKeyStrategy {
+	case keypool.RoundRobin, keypool.LeastLimited:
+	default:
+		slog.Error("invalid API_KEY_STRATEGY (expected round-robin or least-limited)", "value", cfg.APIKeyStrategy)
+		os.Exit(1)
+	}
+	keyPools := make(map[string]*keypool.Pool)
+	for _, p := range providers {
+		if injector, ok := p.(provider.APIKeyInjector); ok {
+			keys := cfg.ProviderAPIKeys(p.Name())
+			if len(keys) == 0 {
+				continue
+			}
+			injector.SetAPIKey(keys[0])
+			if len(keys) > 1 {
+				keyPools[p.Name()] = keypool.New(keys, keypool.Options{
+					Strategy: cfg.APIKeyStrategy,
+					Cooldown: time.Duration(cfg.APIKeyCooldown) * time.Second,
+				})
+			}
+			slog.Info("API key configured", "provider", p.Name(), "keys", len(keys))
+		}
+	}
+
+	// Configure the canned responses of the built-in mock provider, and which
+	// Replicate output files are stored
+	for _, p := range providers {
+		if mock, ok := p.(*provider.MockProvider); ok {
+			mock.SetOptions(provider.MockOptions{Response: cfg.MockResponse, TokensPerSecond: cfg.MockTokensPerSecond})
+		}
+		if replicate, ok := p.(*provider.ReplicateProvider); ok {
+			replicate.SetOutputOptions(provider.OutputOptions{
+				ContentTypes: strings.Split(cfg.OutputContentTypes, ","),
+				MaxBytes:     int64(cfg.OutputMaxMB) << 20,
+			})
+		}
+	}
+
+	// Load routing rules (optional)
+	var rules []*router.Rule
+	if cfg.RoutesFile != "" {
+		rules, err = router.LoadRules(cfg.RoutesFile)
+		if err != nil {
+			slog.Error("failed to load routing rules", "error", err)
+			os.Exit(1)
+		}
+	}
+	rt := router.New(providers, rules)
+
+	// Load tools resolved at the gateway (optional)
+	var gatewayTools tools.Set
+	if cfg.ToolsFile != "" {
+		gatewayTools, err = tools.Load(cfg.ToolsFile)
+		if err != nil {
+			slog.Error("failed to load tools", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Load model prices used to estimate cost, overriding the built-in table
+	prices := pricing.Default()
+	if cfg.PricingFile != "" {
+		overrides, err := pricing.LoadTable(cfg.PricingFile)
+		if err != nil {
+			slog.Error("failed to load pricing", "error", err)
+			os.Exit(1)
+		}
+		prices = prices.Merge(overrides)
+	}
+
+	// Collect per-provider rate limits
+	providerLimits := make(map[string]ratelimit.Limits)
+	for _, p := range providers {
+		rpm, tpm := cfg.ProviderRateLimits(p.Name())
+		if limits := (ratelimit.Limits{RPM: rpm, TPM: tpm}); !limits.IsZero() {
+			providerLimits[p.Name()] = limits
+			slog.Info("rate limit configured", "provider", p.Name(), "rpm", rpm, "tpm", tpm)
+		}
+	}
+
+	// Collect per-provider upstream timeouts
+	timeouts := make(map[string]proxy.Timeouts)
+	for _, p := range providers {
+		t := cfg.ProviderTimeouts(p.Name())
+		timeouts[p.Name()] = proxy.Timeouts{
+			Connect:     time.Duration(t.Connect) * time.Second,
+			Read:        time.Duration(t.Read) * time.Second,
+			Total:       time.Duration(t.Total) * time.Second,
+			StreamRead:  time.Duration(t.StreamRead) * time.Second,
+			StreamTotal: time.Duration(t.StreamTotal) * time.Second,
+		}
+	}
+
+	// Collect how each provider is reached: proxies and trusted CAs
+	networks := make(map[string]proxy.Network)
+	for _, p := range providers {
+		n := cfg.ProviderNetwork(p.Name())
+		network, err := proxy.NewNetwork(n.Proxy, n.CAFile, n.TLSSkipVerify)
+		if err != nil {
+			slog.Error("invalid upstream network settings", "provider", p.Name(), "error", err)
+			os.Exit(1)
+		}
+		if network.Proxy != nil {
+			slog.Info("upstream proxy configured", "provider", p.Name(), "proxy", network.Proxy.Redacted())
+		}
+		if network.TLSSkipVerify {
+			slog.Warn("TLS certificate verification disabled", "provider", p.Name())
+		}
+		networks[p.Name()] = network
 	}
 
 	// Initialize SSE broadcaster
-	broadcaster := api.NewSSEBroadcaster()
+	switch cfg.SSESlowConsumerPolicy {
+	case api.SlowConsumerDrop, api.SlowConsumerDisconnect, api.SlowConsumerCoalesce:
+	default:
+		slog.Error("invalid SSE_SLOW_CONSUMER_POLICY (expected drop, disconnect or coalesce)", "value", cfg.SSESlowConsumerPolicy)
+		os.Exit(1)
+	}
+	broadcaster := api.NewSSEBroadcaster(api.BroadcasterOptions{
+		BroadcastBuffer:    cfg.SSEBroadcastBuffer,
+		ClientBuffer:       cfg.SSEClientBuffer,
+		SlowConsumerPolicy: cfg.SSESlowConsumerPolicy,
+		HeartbeatInterval:  time.Duration(cfg.SSEHeartbeatInterval) * time.Second,
+	})
 	// Note: broadcaster.Close() is called explicitly during shutdown, not deferred
+	for name, s := range openSinks("EVENT_SINKS", cfg.EventSinks) {
+		broadcaster.AddSink(name, s)
+		slog.Info("event sink configured", "sink", name)
+	}
 
 	// Create API handler
 	apiHandler := api.NewHandler(db, fs, broadcaster)
+	apiHandler.SetRouter(rt)
 
 	// Create shutdown context for graceful termination
 	shutdownCtx, shutdownCancel := context.WithCancel(context.Background())
 	defer shutdownCancel()
 
 	// Create proxy handler with shutdown context
-	proxyHandler := proxy.New(db, fs, providers, broadcaster, apiHandler)
+	proxyHandler := proxy.New(db, fs, rt, broadcaster, apiHandler)
 	proxyHandler.SetShutdownContext(shutdownCtx)
+	proxyHandler.SetFollowRedirects(cfg.FollowRedirects)
+	if len(gatewayTools) > 0 {
+		proxyHandler.SetTools(gatewayTools, cfg.ToolMaxRounds)
+		slog.Info("gateway tools enabled", "tools", len(gatewayTools), "max_rounds", cfg.ToolMaxRounds)
+	}
+	for name, pool := range keyPools {
+		proxyHandler.SetAPIKeyPool(name, pool)
+	}
+	if cfg.RetryMaxAttempts > 1 {
+		var statuses []int
+		for _, field := range strings.Split(cfg.RetryOnStatus, ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			status, err := strconv.Atoi(field)
+			if err != nil {
+				slog.Error("invalid RETRY_ON_STATUS entry", "value", field)
+				os.Exit(1)
+			}
+			statuses = append(statuses, status)
+		}
+		proxyHandler.SetRetryPolicy(proxy.RetryPolicy{
+			MaxAttempts: cfg.RetryMaxAttempts,
+			Backoff:     time.Duration(cfg.RetryBackoffMs) * time.Millisecond,
+			MaxBackoff:  time.Duration(cfg.RetryMaxBackoffMs) * time.Millisecond,
+			Statuses:    statuses,
+		})
+		slog.Info("upstream retries enabled", "max_attempts", cfg.RetryMaxAttempts, "statuses", cfg.RetryOnStatus)
+	}
+	proxyHandler.SetRequireVirtualKey(cfg.RequireVirtualKey)
+	switch cfg.OverrideHeaders {
+	case proxy.OverridesOff, proxy.OverridesKeys, proxy.OverridesAll:
+		proxyHandler.SetOverrideHeaders(cfg.OverrideHeaders)
+	default:
+		slog.Error("invalid OVERRIDE_HEADERS (expected off, keys or all)", "value", cfg.OverrideHeaders)
+		os.Exit(1)
+	}
+	proxyHandler.SetStreamUsageInjection(cfg.InjectStreamUsage, cfg.StripInjectedUsage)
+	proxyHandler.SetChunkRecording(cfg.RecordChunks)
+	proxyHandler.SetMaxBufferedBody(int64(cfg.MaxResponseBufferMB) << 20)
+	proxyHandler.SetPredictionPolling(time.Duration(cfg.PredictionPollInterval)*time.Second, time.Duration(cfg.PredictionPollTimeout)*time.Second)
+	if cfg.ReplicateWebhookSecret != "" {
+		if err := proxyHandler.SetReplicateWebhookSecret(cfg.ReplicateWebhookSecret); err != nil {
+			slog.Error("invalid REPLICATE_WEBHOOK_SECRET", "error", err)
+			os.Exit(1)
+		}
+	}
+	proxyHandler.SetTimeouts(timeouts)
+	proxyHandler.SetNetworks(networks)
+	proxyHandler.SetTransport(proxy.TransportOptions{
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:     time.Duration(cfg.IdleConnTimeout) * time.Second,
+		HTTP2:               cfg.UpstreamHTTP2,
+	})
+	proxyHandler.SetRateLimits(providerLimits, ratelimit.Limits{RPM: cfg.KeyRateLimitRPM, TPM: cfg.KeyRateLimitTPM})
+	if cfg.AdaptiveConcurrency {
+		proxyHandler.SetAdaptiveConcurrency(ratelimit.AdaptiveOptions{
+			Initial:   cfg.ConcurrencyInitial,
+			Min:       cfg.ConcurrencyMin,
+			Max:       cfg.ConcurrencyMax,
+			Tolerance: cfg.ConcurrencyTolerance,
+		}, time.Duration(cfg.ConcurrencyMaxWait)*time.Second)
+		slog.Info("adaptive concurrency enabled", "initial", cfg.ConcurrencyInitial, "min", cfg.ConcurrencyMin, "max", cfg.ConcurrencyMax)
+	}
+	proxyHandler.SetPricing(prices)
+	proxyHandler.SetBudgets(
+		proxy.Budget{DailyUSD: cfg.BudgetDailyUSD, MonthlyUSD: cfg.BudgetMonthlyUSD},
+		proxy.Budget{DailyUSD: cfg.KeyBudgetDailyUSD, MonthlyUSD: cfg.KeyBudgetMonthlyUSD},
+	)
+	switch cfg.SecretScan {
+	case proxy.SecretScanOff, proxy.SecretScanFlag, proxy.SecretScanBlock:
+		proxyHandler.SetSecretScanning(cfg.SecretScan)
+	default:
+		slog.Error("invalid SECRET_SCAN (expected off, flag or block)", "value", cfg.SecretScan)
+		os.Exit(1)
+	}
+	switch cfg.Moderation {
+	case proxy.ModerationOff:
+	case proxy.ModerationFlag, proxy.ModerationBlock:
+		// OpenAI's endpoint is called with the gateway's own OpenAI key unless one is given
+		apiKey := cfg.ModerationAPIKey
+		if apiKey == "" && cfg.ModerationURL == "" {
+			if keys := cfg.ProviderAPIKeys("openai"); len(keys) > 0 {
+				apiKey = keys[0]
+			}
+		}
+		moderator := guardrail.NewModerator(guardrail.ModerationOptions{
+			URL:     cfg.ModerationURL,
+			APIKey:  apiKey,
+			Model:   cfg.ModerationModel,
+			Timeout: time.Duration(cfg.ModerationTimeout) * time.Second,
+		})
+		proxyHandler.SetModeration(moderator, cfg.Moderation, cfg.ModerationFailClosed)
+		slog.Info("content moderation enabled", "mode", cfg.Moderation, "url", moderator.URL(), "fail_closed", cfg.ModerationFailClosed)
+	default:
+		slog.Error("invalid MODERATION (expected off, flag or block)", "value", cfg.Moderation)
+		os.Exit(1)
+	}
+	switch cfg.Guardrails {
+	case proxy.GuardrailsOff:
+	case proxy.GuardrailsScore, proxy.GuardrailsBlock:
+		if cfg.GuardrailsThreshold <= 0 || cfg.GuardrailsThreshold > 1 {
+			slog.Error("invalid GUARDRAILS_THRESHOLD (expected a number above 0, at most 1)", "value", cfg.GuardrailsThreshold)
+			os.Exit(1)
+		}
+		rules := guardrail.DefaultRiskRules
+		if cfg.GuardrailsRulesFile != "" {
+			loaded, err := guardrail.LoadRiskRules(cfg.GuardrailsRulesFile)
+			if err != nil {
+				slog.Error("failed to load guardrail rules", "error", err)
+				os.Exit(1)
+			}
+			rules = loaded
+		}
+		scorer, err := guardrail.NewRiskScorer(guardrail.RiskScorerOptions{
+			Rules:         rules,
+			ClassifierURL: cfg.GuardrailsClassifier,
+			APIKey:        cfg.GuardrailsClassifierKey,
+			Timeout:       time.Duration(cfg.GuardrailsTimeout) * time.Second,
+		})
+		if err != nil {
+			slog.Error("invalid guardrail rules", "error", err)
+			os.Exit(1)
+		}
+		proxyHandler.SetGuardrails(scorer, cfg.Guardrails, cfg.GuardrailsThreshold)
+		slog.Info("prompt injection guardrails enabled", "mode", cfg.Guardrails, "rules", len(rules), "threshold", cfg.GuardrailsThreshold, "classifier", cfg.GuardrailsClassifier != "")
+	default:
+		slog.Error("invalid GUARDRAILS (expected off, score or block)", "value", cfg.Guardrails)
+		os.Exit(1)
+	}
+	if cfg.PlaybackProviders != "" {
+		if cfg.PlaybackMiss != proxy.PlaybackMissError && cfg.PlaybackMiss != proxy.PlaybackMissForward {
+			slog.Error("invalid PLAYBACK_MISS (expected error or forward)", "value", cfg.PlaybackMiss)
+			os.Exit(1)
+		}
+		proxyHandler.SetPlayback(strings.Split(cfg.PlaybackProviders, ","), cfg.PlaybackMiss)
+		slog.Info("playback enabled", "providers", cfg.PlaybackProviders, "on_miss", cfg.PlaybackMiss)
+	}
+	if cfg.CacheTTL > 0 {
+		proxyHandler.SetResponseCache(strings.Split(cfg.CacheProviders, ","), time.Duration(cfg.CacheTTL)*time.Second)
+		slog.Info("response cache enabled", "providers", cfg.CacheProviders, "ttl_seconds", cfg.CacheTTL)
+		if cfg.CacheStaleTTL > 0 {
+			proxyHandler.SetStaleWhileRevalidate(time.Duration(cfg.CacheStaleTTL) * time.Second)
+			slog.Info("stale-while-revalidate enabled", "stale_ttl_seconds", cfg.CacheStaleTTL)
+		}
+	}
+	// Interception can also be turned on at runtime with PUT /api/intercept
+	proxyHandler.SetResponseInterception(strings.Split(cfg.InterceptResponses, ","), time.Duration(cfg.InterceptTimeout)*time.Second)
+	if cfg.InterceptResponses != "" {
+		slog.Info("response interception enabled", "providers", cfg.InterceptResponses, "timeout_seconds", cfg.InterceptTimeout)
+	}
+	if cfg.ChaosFile != "" {
+		rules, err := chaos.LoadRules(cfg.ChaosFile)
+		if err != nil {
+			slog.Error("failed to load chaos rules", "error", err)
+			os.Exit(1)
+		}
+		proxyHandler.SetChaosRules(rules)
+		slog.Warn("fault injection enabled: matching requests will be delayed or fail on purpose", "rules", len(rules))
+	}
+	switch cfg.PauseMode {
+	case proxy.PauseQueue, proxy.PauseReject:
+	default:
+		slog.Error("invalid PAUSE_MODE (expected queue or reject)", "value", cfg.PauseMode)
+		os.Exit(1)
+	}
+	proxyHandler.SetPauseOptions(cfg.PauseMode, time.Duration(cfg.PauseMaxWait)*time.Second)
+	if cfg.WatchdogThreshold > 0 || cfg.WatchdogCancelAfter > 0 {
+		proxyHandler.SetWatchdog(proxy.WatchdogOptions{
+			Threshold:   time.Duration(cfg.WatchdogThreshold) * time.Second,
+			CancelAfter: time.Duration(cfg.WatchdogCancelAfter) * time.Second,
+			WebhookURL:  cfg.WatchdogWebhookURL,
+		})
+		slog.Info("watchdog enabled", "threshold_seconds", cfg.WatchdogThreshold, "cancel_after_seconds", cfg.WatchdogCancelAfter)
+		go proxyHandler.RunWatchdog(shutdownCtx)
+	}
+	if cfg.FineTuneMonitor {
+		fineTunes := finetune.New(db, rt.Providers(), finetune.Options{
+			Interval:   time.Duration(cfg.FineTunePollInterval) * time.Second,
+			WebhookURL: cfg.FineTuneWebhookURL,
+			OnUpdate:   apiHandler.BroadcastFineTuneUpdated,
+		})
+		proxyHandler.SetFineTuneMonitor(fineTunes)
+		slog.Info("fine-tune monitoring enabled", "poll_interval_seconds", cfg.FineTunePollInterval)
+		go fineTunes.Run(shutdownCtx)
+	}
+	var exporter *export.Exporter
+	if sinks := openSinks("EXPORT_SINKS", cfg.ExportSinks); len(sinks) > 0 {
+		exporter = export.New(sinks, cfg.ExportChunks)
+		proxyHandler.SetExporter(exporter)
+		for name := range sinks {
+			slog.Info("traffic export configured", "sink", name, "chunks", cfg.ExportChunks)
+		}
+	}
+	var updateChecker *version.Checker
+	if cfg.UpdateCheck {
+		updateChecker = version.NewChecker(buildInfo.Version, version.CheckerOptions{
+			Repository: cfg.UpdateCheckRepository,
+			Interval:   time.Duration(cfg.UpdateCheckInterval) * time.Second,
+			OnUpdate:   apiHandler.BroadcastUpdateAvailable,
+		})
+		slog.Info("update checks enabled", "repository", cfg.UpdateCheckRepository, "interval_seconds", cfg.UpdateCheckInterval)
+		go updateChecker.Run(shutdownCtx)
+	}
+	apiHandler.SetVersion(buildInfo, updateChecker)
+	apiHandler.SetStatusSource(proxyHandler)
+	apiHandler.SetInterceptor(proxyHandler)
+	apiHandler.SetChaosController(proxyHandler)
+	apiHandler.SetPauseController(proxyHandler)
+
+	// Retention limits (optional); purges can also be run through the API
+	janitor := retention.New(db, fs, retention.Options{
+		Interval:       time.Duration(cfg.RetentionInterval) * time.Second,
+		MaxAge:         time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+		MaxDBBytes:     int64(cfg.MaxDBSizeMB) << 20,
+		MaxFilesBytes:  int64(cfg.MaxFilesGB * (1 << 30)),
+		FileQuotaBytes: int64(cfg.FileQuotaGB * (1 << 30)),
+		OnPurge:        apiHandler.BroadcastPurged,
+	})
+	apiHandler.SetJanitor(janitor)
+	if opts := janitor.Options(); opts.Enabled() {
+		slog.Info("retention enabled", "days", cfg.RetentionDays, "max_db_size_mb", cfg.MaxDBSizeMB, "max_files_gb", cfg.MaxFilesGB, "file_quota_gb", cfg.FileQuotaGB, "interval_seconds", cfg.RetentionInterval)
+		go janitor.Run(shutdownCtx)
+	}
+
+	// Archival of old requests to object storage (optional); archived
+	// requests are restored when they are opened
+	if cfg.ArchiveBucket != "" {
+		store, err := s3.New(s3.Options{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.ArchiveBucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			SessionToken:    cfg.S3SessionToken,
+		})
+		if err != nil {
+			slog.Error("invalid archive storage", "error", err)
+			os.Exit(1)
+		}
+		archiver := archive.New(db, fs, store, archive.Options{
+			Interval: time.Duration(cfg.ArchiveInterval) * time.Second,
+			After:    time.Duration(cfg.ArchiveAfterDays) * 24 * time.Hour,
+			Prefix:   cfg.ArchivePrefix,
+		})
+		apiHandler.SetArchiver(archiver)
+		slog.Info("archiving enabled", "bucket", cfg.ArchiveBucket, "prefix", cfg.ArchivePrefix, "after_days", cfg.ArchiveAfterDays, "interval_seconds", cfg.ArchiveInterval)
+		if cfg.ArchiveAfterDays > 0 {
+			go archiver.Run(shutdownCtx)
+		}
+	}
+	apiHandler.SetProxy(http.HandlerFunc(proxyHandler.Handle))
+
+	// Prometheus metrics
+	registry := metrics.NewRegistry()
+	proxyHandler.SetMetrics(registry)
+	registry.NewGaugeFunc("aigw_sse_clients", "Connected live event stream clients.", func() float64 {
+		return float64(broadcaster.ClientCount())
+	})
+	registry.NewCounterFunc("aigw_sse_dropped_events_total", "Live events dropped for slow clients.", func() float64 {
+		return float64(broadcaster.DroppedEvents())
+	})
+	registry.NewCounterFunc("aigw_sink_dropped_events_total", "Events dropped because an event sink's queue was full.", func() float64 {
+		return float64(broadcaster.SinkDroppedEvents())
+	})
+	registry.NewCounterFunc("aigw_sink_failed_events_total", "Events an event sink failed to publish.", func() float64 {
+		return float64(broadcaster.SinkFailedEvents())
+	})
+	if exporter != nil {
+		registry.NewCounterFunc("aigw_export_dropped_total", "Exported records and chunks dropped because a sink's queue was full.", func() float64 {
+			return float64(exporter.Dropped())
+		})
+		registry.NewCounterFunc("aigw_export_failed_total", "Exported records and chunks a sink failed to publish.", func() float64 {
+			return float64(exporter.Failed())
+		})
+	}
+	registry.NewCounterFunc("aigw_db_write_errors_total", "Failed database writes of requests, responses and files.", func() float64 {
+		return float64(db.WriteErrorCount())
+	})
+	registry.NewGaugeFunc("aigw_db_write_queue_depth", "Request and response writes waiting to be stored.", func() float64 {
+		return float64(db.WriteQueueDepth())
+	})
+	registry.NewCounterFunc("aigw_db_write_dropped_total", "Request and response writes discarded because the write queue was full.", func() float64 {
+		return float64(db.DroppedWrites())
+	})
+	apiHandler.SetIngestToken(cfg.FederationIngestToken)
+
+	// Forward recorded traffic to a federation aggregator (optional)
+	if cfg.FederationURL != "" {
+		syncer := federation.NewSyncer(db, fs, federation.Options{
+			URL:          cfg.FederationURL,
+			Token:        cfg.FederationToken,
+			Source:       cfg.FederationSource,
+			Interval:     time.Duration(cfg.FederationInterval) * time.Second,
+			IncludeFiles: cfg.FederationIncludeFiles,
+		})
+		slog.Info("federation enabled", "url", cfg.FederationURL, "source", syncer.Source())
+		go syncer.Run(shutdownCtx)
+	}
+
+	// Management login (optional)
+	authenticator, err := newAuthenticator(cfg)
+	if err != nil {
+		slog.Error("failed to configure authentication", "error", err)
+		os.Exit(1)
+	}
+	protect := func(next http.Handler) http.Handler { return next }
+	if authenticator != nil {
+		protect = authenticator.Middleware
+		slog.Info("management login enabled", "providers", strings.Join(authenticator.ProviderNames(), ","), "tokens", authenticator.TokenCount(), "basic_users", authenticator.BasicUserCount())
+	}
 
 	// Create router
 	r := chi.NewRouter()
@@ -76,20 +643,76 @@ func main() {
 
 	// API routes
 	r.Route("/api", func(r chi.Router) {
-		r.Get("/requests", apiHandler.ListRequests)
-		r.Get("/requests/{id}", apiHandler.GetRequest)
-		r.Get("/files/*", apiHandler.GetFile)
-		r.Get("/events", apiHandler.GetEvents)
-		r.Get("/stats", apiHandler.GetStats)
+		// Federation ingest is authenticated by its own token
+		r.Post("/ingest", apiHandler.Ingest)
+
+		r.Group(func(r chi.Router) {
+			r.Use(protect)
+			r.Get("/requests", apiHandler.ListRequests)
+			r.Delete("/requests", apiHandler.PurgeRequests)
+			r.Get("/requests/{id}", apiHandler.GetRequest)
+			r.Patch("/requests/{id}", apiHandler.UpdateRequest)
+			r.Delete("/requests/{id}", apiHandler.DeleteRequest)
+			r.Get("/requests/{id}/diff/{otherId}", apiHandler.DiffRequests)
+			r.Get("/requests/{id}/output", apiHandler.GetOutput)
+			r.Get("/requests/{id}/bundle", apiHandler.GetRequestBundle)
+			r.Get("/requests/{id}/chunks", apiHandler.GetChunks)
+			r.Patch("/requests/{id}/tags", apiHandler.UpdateTags)
+			r.Get("/requests/{id}/notes", apiHandler.ListNotes)
+			r.Post("/requests/{id}/notes", apiHandler.AddNote)
+			r.Get("/chaos", apiHandler.GetChaos)
+			r.Put("/chaos", apiHandler.SetChaos)
+			r.Get("/proxy/pause", apiHandler.GetPause)
+			r.Post("/proxy/pause", apiHandler.PauseProxy)
+			r.Post("/proxy/resume", apiHandler.ResumeProxy)
+			r.Get("/maintenance", apiHandler.GetMaintenance)
+			r.Post("/maintenance/purge", apiHandler.PurgeNow)
+			r.Post("/maintenance/archive", apiHandler.ArchiveNow)
+			r.Get("/intercept", apiHandler.GetIntercept)
+			r.Put("/intercept", apiHandler.SetIntercept)
+			r.Get("/intercept/responses", apiHandler.ListHeldResponses)
+			r.Post("/intercept/responses/{id}/release", apiHandler.ReleaseResponse)
+			r.Post("/export", apiHandler.ExportBundle)
+			r.Get("/export/finetune", apiHandler.ExportFineTuneDataset)
+			r.Post("/import", apiHandler.ImportTraffic)
+			r.Get("/files/*", apiHandler.GetFile)
+			r.Get("/storage/stats", apiHandler.GetStorageStats)
+			r.Get("/events", apiHandler.GetEvents)
+			r.Get("/stats", apiHandler.GetStats)
+			r.Get("/status", apiHandler.GetStatus)
+			r.Get("/version", apiHandler.GetVersion)
+			r.Get("/costs", apiHandler.GetCosts)
+			r.Get("/warnings", apiHandler.GetWarnings)
+			r.Get("/routes", apiHandler.ListRoutes)
+			r.Post("/routes/match", apiHandler.MatchRoute)
+			r.Post("/compose", apiHandler.Compose)
+			r.Get("/keys", apiHandler.ListKeys)
+			r.Post("/keys", apiHandler.CreateKey)
+			r.Get("/keys/{id}", apiHandler.GetKey)
+			r.Patch("/keys/{id}", apiHandler.UpdateKey)
+			r.Delete("/keys/{id}", apiHandler.RevokeKey)
+			r.Get("/fine-tunes", apiHandler.ListFineTunes)
+			r.Get("/fine-tunes/{id}", apiHandler.GetFineTune)
+		})
 	})
 
+	// Login routes
+	if authenticator != nil {
+		r.Get("/auth/me", authenticator.Me)
+		if len(authenticator.ProviderNames()) > 0 {
+			r.Get("/auth/login", authenticator.Login)
+			r.Get("/auth/callback/*", authenticator.Callback)
+			r.HandleFunc("/auth/logout", authenticator.Logout)
+		}
+	}
+
 	// UI routes
 	uiFS, err := ui.NewFileServer()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load UI files: %v\n", err)
+		slog.Error("failed to load UI files", "error", err)
 		os.Exit(1)
 	}
-	r.Handle("/ui/*", http.StripPrefix("/ui", uiFS))
+	r.Handle("/ui/*", protect(http.StripPrefix("/ui", uiFS)))
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/ui/", http.StatusMovedPermanently)
 	})
@@ -101,33 +724,105 @@ func main() {
 		fmt.Fprintf(w, `{"status":"ok"}`)
 	})
 
+	// Metrics endpoint, unless served on its own port
+	var metricsServer *http.Server
+	if cfg.MetricsPort == 0 {
+		r.Handle("/metrics", registry.Handler())
+	} else {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", registry.Handler())
+		metricsServer = &http.Server{
+			Addr:    fmt.Sprintf(":%d", cfg.MetricsPort),
+			Handler: metricsMux,
+		}
+		metricsListener, err := handover.Listen("metrics", metricsServer.Addr)
+		if err != nil {
+			slog.Error("failed to listen for metrics", "addr", metricsServer.Addr, "error", err)
+			os.Exit(1)
+		}
+		go func() {
+			slog.Info("metrics listening", "addr", metricsServer.Addr)
+			if err := metricsServer.Serve(metricsListener); err != nil && err != http.ErrServerClosed {
+				slog.Error("metrics server error", "error", err)
+			}
+		}()
+	}
+
+	// Replicate prediction webhooks, authenticated by their signature
+	r.Post("/replicate/webhook", proxyHandler.HandleReplicateWebhook)
+
 	// Proxy all other requests
 	r.HandleFunc("/*", proxyHandler.Handle)
 
 	// Start server in a goroutine
 	addr := fmt.Sprintf(":%d", cfg.Port)
+	tlsConfig, acme, err := newTLSConfig(cfg)
+	if err != nil {
+		slog.Error("invalid TLS settings", "error", err)
+		os.Exit(1)
+	}
+	if cfg.TLSClientCAFile != "" {
+		proxyHandler.SetRequireClientCert(true)
+		slog.Info("client certificates required for proxy traffic", "ca_file", cfg.TLSClientCAFile)
+	}
 	server := &http.Server{
-		Addr:    addr,
-		Handler: r,
+		Addr:      addr,
+		Handler:   r,
+		TLSConfig: tlsConfig,
 	}
 
+	listener, err := handover.Listen("main", addr)
+	if err != nil {
+		slog.Error("failed to listen", "addr", addr, "error", err)
+		os.Exit(1)
+	}
 	go func() {
-		fmt.Printf("Server listening on %s\n", addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
+		slog.Info("server listening", "addr", addr, "tls", tlsConfig != nil)
+		serve := server.Serve
+		if tlsConfig != nil {
+			// Certificates come from tlsConfig.GetCertificate
+			serve = func(l net.Listener) error { return server.ServeTLS(l, "", "") }
+		}
+		if err := serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("server error", "error", err)
 		}
 	}()
+	if acme != nil {
+		go acme.Run(shutdownCtx)
+	}
+	handover.Ready()
 
-	// Handle graceful shutdown
+	// Handle graceful shutdown, or an in-place upgrade on SIGUSR2
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR2)
 
-	<-sigChan
-	fmt.Println("\nShutting down server...")
+	upgraded := false
+	for sig := range sigChan {
+		if sig != syscall.SIGUSR2 {
+			break
+		}
+		if upgraded = startUpgrade(handover, cfg); upgraded {
+			break
+		}
+	}
+	slog.Info("shutting down server")
 
 	// 1. Close SSE broadcaster first (disconnect all SSE clients immediately)
 	broadcaster.Close()
 
+	// After an upgrade the new process accepts connections: stop accepting
+	// and let in-flight requests, streams included, finish before anything
+	// is cancelled
+	if upgraded {
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), time.Duration(cfg.UpgradeDrainTimeout)*time.Second)
+		if err := server.Shutdown(drainCtx); err != nil {
+			slog.Warn("timeout draining connections for upgrade", "error", err)
+		}
+		// Hijacked (WebSocket) connections aren't tracked by the server
+		proxyHandler.WaitForInflightRequests(drainCtx)
+		drainCancel()
+	}
+
 	// 2. Signal proxy handler to abort new provider requests and in-flight ones if timeout exceeded
 	shutdownCancel()
 
@@ -137,18 +832,196 @@ func main() {
 	defer timeoutCancel()
 	proxyHandler.WaitForInflightRequests(timeoutCtx)
 
+	// Publish the traffic records still queued for export
+	if exporter != nil {
+		exportCtx, exportCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		exporter.Close(exportCtx)
+		exportCancel()
+	}
+
 	// 4. Force close the server (don't wait for other HTTP connections like keep-alive)
 	if err := server.Close(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error closing server: %v\n", err)
+		slog.Error("error closing server", "error", err)
+	}
+	if metricsServer != nil {
+		metricsServer.Close()
+	}
+
+	slog.Info("server stopped")
+}
+
+// startUpgrade starts a new gateway process from the binary on disk and hands
+// it the listeners. It returns false, with this process still serving, if the
+// new process couldn't be started or didn't become ready.
+func startUpgrade(handover *restart.Handover, cfg *config.Config) bool {
+	slog.Info("upgrading in place: starting new process")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.UpgradeReadyTimeout)*time.Second)
+	defer cancel()
+	process, err := handover.Upgrade(ctx)
+	if err != nil {
+		slog.Error("in-place upgrade failed, still serving", "error", err)
+		return false
+	}
+	slog.Info("new process is ready, draining in-flight requests", "pid", process.Pid, "drain_timeout_seconds", cfg.UpgradeDrainTimeout)
+	return true
+}
+
+// newTLSConfig builds the listener's TLS settings from TLS_CERT_FILE and
+// TLS_KEY_FILE, or ACME_DOMAINS for certificates from Let's Encrypt (or
+// another ACME CA), plus the CAs client certificates are verified against
+// from TLS_CLIENT_CA_FILE. It returns nil when TLS isn't configured; the
+// manager is nil unless ACME is used.
+func newTLSConfig(cfg *config.Config) (*tls.Config, *certs.ACMEManager, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.TLSClientCAFile != "" {
+		if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" && cfg.ACMEDomains == "" {
+			return nil, nil, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE or ACME_DOMAINS")
+		}
+		pool, err := certs.LoadClientCAs(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Verified when presented; proxy requests without one are rejected
+		// by the handler so the management API still works from browsers
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	switch {
+	case cfg.TLSCertFile != "" || cfg.TLSKeyFile != "":
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return nil, nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		}
+		if cfg.ACMEDomains != "" {
+			return nil, nil, fmt.Errorf("ACME_DOMAINS can't be combined with TLS_CERT_FILE")
+		}
+		source, err := certs.NewFileSource(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsConfig.GetCertificate = source.GetCertificate
+		return tlsConfig, nil, nil
+
+	case cfg.ACMEDomains != "":
+		var domains []string
+		for _, domain := range strings.Split(cfg.ACMEDomains, ",") {
+			if domain = strings.TrimSpace(domain); domain != "" {
+				domains = append(domains, domain)
+			}
+		}
+		manager, err := certs.NewACMEManager(certs.ACMEOptions{
+			Domains:      domains,
+			Email:        cfg.ACMEEmail,
+			CacheDir:     cfg.ACMECacheDir,
+			DirectoryURL: cfg.ACMEDirectoryURL,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if cfg.Port != 443 {
+			slog.Warn("ACME validation connects to port 443; forward it to the gateway", "port", cfg.Port)
+		}
+		tlsConfig.GetCertificate = manager.GetCertificate
+		tlsConfig.NextProtos = manager.NextProtos()
+		return tlsConfig, manager, nil
+	}
+	return nil, nil, nil
+}
+
+// newAuthenticator builds the management login from the AUTH_* settings. It
+// returns nil when neither a login provider nor static credentials are
+// configured.
+func newAuthenticator(cfg *config.Config) (*auth.Authenticator, error) {
+	var providers []auth.Provider
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if cfg.AuthGoogleClientID != "" {
+		p, err := auth.NewOIDCProvider(ctx, "google", "https://accounts.google.com", cfg.AuthGoogleClientID, cfg.AuthGoogleClientSecret)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	if cfg.AuthGitHubClientID != "" {
+		providers = append(providers, auth.NewGitHubProvider(cfg.AuthGitHubClientID, cfg.AuthGitHubClientSecret))
+	}
+	if cfg.AuthOIDCIssuer != "" {
+		p, err := auth.NewOIDCProvider(ctx, cfg.AuthOIDCName, cfg.AuthOIDCIssuer, cfg.AuthOIDCClientID, cfg.AuthOIDCClientSecret)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+
+	var tokens []string
+	for _, token := range strings.Split(cfg.AuthTokens, ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	basicUsers := make(map[string]string)
+	for _, entry := range strings.Split(cfg.AuthBasicUsers, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			user, password, _ := strings.Cut(entry, ":")
+			basicUsers[user] = password
+		}
+	}
+	if len(providers) == 0 && len(tokens) == 0 && len(basicUsers) == 0 {
+		return nil, nil
 	}
 
-	fmt.Println("Server stopped")
+	var allowed []string
+	for _, entry := range strings.Split(cfg.AuthAllowedUsers, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			allowed = append(allowed, entry)
+		}
+	}
+	if len(allowed) == 0 && len(providers) > 0 {
+		slog.Warn("AUTH_ALLOWED_USERS is empty; any account the login providers accept can use the management API")
+	}
+
+	return auth.New(auth.Options{
+		Providers:     providers,
+		BaseURL:       cfg.AuthBaseURL,
+		SessionSecret: []byte(cfg.AuthSessionSecret),
+		SessionTTL:    time.Duration(cfg.AuthSessionTTL) * time.Hour,
+		AllowedUsers:  allowed,
+		Tokens:        tokens,
+		BasicUsers:    basicUsers,
+	})
 }
 
-// loggingMiddleware logs incoming requests
+// loggingMiddleware logs incoming requests and tags them with a correlation ID,
+// taken from the client's X-Request-ID header or generated, that is added to
+// every log line written while serving the request
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Printf("[IN] %s %s\n", r.Method, r.RequestURI)
-		next.ServeHTTP(w, r)
+		correlationID := r.Header.Get("X-Request-ID")
+		if correlationID == "" || len(correlationID) > 128 {
+			correlationID = uuid.NewString()
+		}
+		w.Header().Set("X-Request-ID", correlationID)
+
+		ctx := logging.With(r.Context(), "correlation_id", correlationID)
+		slog.InfoContext(ctx, "incoming request", "method", r.Method, "uri", r.RequestURI)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// openSinks opens the comma-separated sink URLs of a setting, keyed by their
+// redacted URL. It exits on an invalid URL.
+func openSinks(setting, urls string) map[string]sink.Sink {
+	sinks := make(map[string]sink.Sink)
+	for _, sinkURL := range strings.Split(urls, ",") {
+		if strings.TrimSpace(sinkURL) == "" {
+			continue
+		}
+		s, err := sink.Open(sinkURL)
+		if err != nil {
+			slog.Error("invalid "+setting+" entry", "error", err)
+			os.Exit(1)
+		}
+		sinks[sink.Redact(sinkURL)] = s
+	}
+	return sinks
+}
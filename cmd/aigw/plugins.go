@@ -0,0 +1,8 @@
+package main
+
+// Additional providers are compiled into the gateway by blank-importing their
+// packages here. Each package registers itself via plugin.Register in init.
+//
+//	import (
+//		_ "github.com/acme/aigw-anthropic"
+//	)
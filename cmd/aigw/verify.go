@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/config"
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/verify"
+)
+
+// Exit codes of aigw verify
+const (
+	verifyPassed   = 0
+	verifyViolated = 1
+	verifyFailed   = 2 // The rules or the database couldn't be read
+)
+
+// runVerify implements `aigw verify [-db path] [-format text|json] rules.yaml`:
+// it evaluates the assertions of a rules file against the recorded traffic and
+// returns the process exit code
+func runVerify(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: aigw verify [-db path] [-format text|json] rules.yaml")
+		flags.PrintDefaults()
+	}
+	dbPath := flags.String("db", "", "database to check (default: DB_PATH)")
+	format := flags.String("format", "text", "output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return verifyFailed
+	}
+	if flags.NArg() != 1 || (*format != "text" && *format != "json") {
+		flags.Usage()
+		return verifyFailed
+	}
+
+	rules, err := verify.Load(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return verifyFailed
+	}
+
+	if *dbPath == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to load configuration: %v\n", err)
+			return verifyFailed
+		}
+		*dbPath = cfg.DBPath
+	}
+	// Opening a missing database would create an empty one that passes everything
+	if _, err := os.Stat(*dbPath); err != nil {
+		fmt.Fprintf(stderr, "Failed to open database: %v\n", err)
+		return verifyFailed
+	}
+	db, err := database.New(*dbPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to open database: %v\n", err)
+		return verifyFailed
+	}
+	defer db.Close()
+
+	results, err := verify.Run(db, rules, time.Now())
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to verify: %v\n", err)
+		return verifyFailed
+	}
+
+	failed := 0
+	for _, result := range results {
+		if !result.Passed {
+			failed++
+		}
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(map[string]interface{}{
+			"passed":  failed == 0,
+			"failed":  failed,
+			"results": results,
+		})
+	} else {
+		for _, result := range results {
+			status := "PASS"
+			if !result.Passed {
+				status = "FAIL"
+			}
+			fmt.Fprintf(stdout, "%s  %s: %s\n", status, result.Rule, result.Detail)
+			for _, id := range result.Violations {
+				fmt.Fprintf(stdout, "      request %s\n", id)
+			}
+		}
+		fmt.Fprintf(stdout, "%d of %d rules passed\n", len(results)-failed, len(results))
+	}
+
+	if failed > 0 {
+		return verifyViolated
+	}
+	return verifyPassed
+}
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/guardrail"
+	"github.com/ruqqq/simple-ai-gateway/internal/pgp"
+	"github.com/ruqqq/simple-ai-gateway/internal/storage"
+)
+
+// minBundlePassphrase is the shortest passphrase accepted for export bundles
+const minBundlePassphrase = 12
+
+// ExportBundleRequest is the body for POST /api/export. Requests are picked
+// by ID, or else filtered like GET /api/requests.
+type ExportBundleRequest struct {
+	IDs          []string `json:"ids"`
+	Provider     string   `json:"provider"`
+	VirtualKeyID string   `json:"key"`
+	PathPattern  string   `json:"path_pattern"`
+	DateFrom     int64    `json:"date_from"` // Unix seconds
+	DateTo       int64    `json:"date_to"`
+	Limit        int      `json:"limit"` // Filtered exports only: default 100, at most 1000
+	IncludeFiles bool     `json:"include_files"`
+	Passphrase   string   `json:"passphrase"`
+}
+
+// BundleManifest describes the contents of an export bundle
+type BundleManifest struct {
+	CreatedAt time.Time `json:"created_at"`
+	Requests  []string  `json:"requests"`
+	Files     int       `json:"files"`
+	Scrubbed  []string  `json:"scrubbed"` // What was removed before the bundle was written
+}
+
+// BundleRecord is one request in an export bundle, stored as requests/{id}.json
+type BundleRecord struct {
+	Request   *database.Request    `json:"request"`
+	Responses []*database.Response `json:"responses"`       // Oldest first: hops and retried attempts, then the final response
+	Notes     []*database.Note     `json:"notes,omitempty"` // Reviewer notes, oldest first
+}
+
+// ExportBundle handles POST /api/export: a gzipped tar of the selected
+// requests with credentials scrubbed, encrypted with a passphrase as an
+// OpenPGP message (decrypt with `gpg --decrypt`)
+func (h *Handler) ExportBundle(w http.ResponseWriter, r *http.Request) {
+	var req ExportBundleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.Passphrase) < minBundlePassphrase {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("passphrase must be at least %d characters", minBundlePassphrase))
+		return
+	}
+
+	requests, err := h.bundleRequests(&req)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(requests) == 0 {
+		h.writeError(w, http.StatusNotFound, "no requests to export")
+		return
+	}
+
+	archive, err := h.writeBundle(requests, req.IncludeFiles)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	name := "aigw-export-" + time.Now().UTC().Format("20060102-150405") + ".tar.gz"
+	var encrypted bytes.Buffer
+	if err := pgp.EncryptSymmetric(&encrypted, archive, req.Passphrase, name); err != nil {
+		h.writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to encrypt bundle: %v", err))
+		return
+	}
+	slog.InfoContext(r.Context(), "exported encrypted bundle", "requests", len(requests), "bytes", encrypted.Len())
+
+	w.Header().Set("Content-Type", "application/pgp-encrypted")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.gpg"`, name))
+	w.Write(encrypted.Bytes())
+}
+
+// GetRequestBundle handles GET /api/requests/{id}/bundle: a zip of one
+// request with credentials scrubbed, to attach to a bug report. It holds
+// request.json, response.json (the final response, if there is one yet) and
+// the request's stored files, named like downloads from /api/files/*.
+func (h *Handler) GetRequestBundle(w http.ResponseWriter, r *http.Request) {
+	requestID := r.PathValue("id")
+	req, err := h.db.GetRequest(requestID)
+	if err != nil || req == nil {
+		h.writeError(w, http.StatusNotFound, "request not found")
+		return
+	}
+	resp, err := h.db.GetResponseByRequestID(requestID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	files, err := h.db.GetBinaryFilesByRequestID(requestID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	now := time.Now()
+	if err := writeZipJSON(zw, "request.json", scrubRequest(req), now); err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if resp != nil {
+		if err := writeZipJSON(zw, "response.json", scrubResponse(resp), now); err != nil {
+			h.writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	for i, file := range files {
+		content, err := storage.ReadFile(h.fs, file.FilePath)
+		if err != nil {
+			slog.Warn("skipping missing file in request bundle", "file", file.FilePath, "error", err)
+			continue
+		}
+		name := fmt.Sprintf("files/%s-%d%s", requestID, i+1, path.Ext(file.FilePath))
+		if err := writeZipFile(zw, name, content, now); err != nil {
+			h.writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		h.writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to write bundle: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", storage.ContentDisposition("aigw-request-"+requestID+".zip"))
+	w.Write(buf.Bytes())
+}
+
+// writeZipJSON adds a record to a request bundle as indented JSON
+func writeZipJSON(zw *zip.Writer, name string, v interface{}, modTime time.Time) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	return writeZipFile(zw, name, data, modTime)
+}
+
+// writeZipFile adds a file to a request bundle
+func writeZipFile(zw *zip.Writer, name string, data []byte, modTime time.Time) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// bundleRequests returns the requests selected for an export bundle
+func (h *Handler) bundleRequests(req *ExportBundleRequest) ([]*database.Request, error) {
+	if len(req.IDs) > 0 {
+		requests := make([]*database.Request, 0, len(req.IDs))
+		for _, id := range req.IDs {
+			stored, err := h.db.GetRequest(id)
+			if err != nil {
+				return nil, fmt.Errorf("request %s not found", id)
+			}
+			requests = append(requests, stored)
+		}
+		return requests, nil
+	}
+
+	params := &database.ListRequestsParams{
+		Provider:     req.Provider,
+		VirtualKeyID: req.VirtualKeyID,
+		PathPattern:  req.PathPattern,
+		Limit:        100,
+	}
+	if req.DateFrom > 0 {
+		params.DateFrom = time.Unix(req.DateFrom, 0)
+	}
+	if req.DateTo > 0 {
+		params.DateTo = time.Unix(req.DateTo, 0)
+	}
+	if req.Limit > 0 && req.Limit <= 1000 {
+		params.Limit = req.Limit
+	}
+	return h.db.ListRequests(params)
+}
+
+// writeBundle writes the scrubbed records, the manifest and optionally the
+// stored files of the requests to a gzipped tar
+func (h *Handler) writeBundle(requests []*database.Request, includeFiles bool) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	manifest := &BundleManifest{
+		CreatedAt: now,
+		Scrubbed: []string{
+			"credential headers: " + strings.Join(guardrail.CredentialHeaders, ", "),
+			"credentials in bodies found by the secret scanner",
+		},
+	}
+	for _, req := range requests {
+		record := &BundleRecord{Request: scrubRequest(req)}
+		responses, err := h.db.GetResponsesByRequestID(req.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get responses of %s: %w", req.ID, err)
+		}
+		for _, resp := range responses {
+			record.Responses = append(record.Responses, scrubResponse(resp))
+		}
+		if record.Notes, err = h.db.ListNotes(req.ID); err != nil {
+			return nil, fmt.Errorf("failed to get notes of %s: %w", req.ID, err)
+		}
+
+		data, err := json.MarshalIndent(record, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", req.ID, err)
+		}
+		if err := writeTarFile(tw, "requests/"+req.ID+".json", data, now); err != nil {
+			return nil, err
+		}
+		manifest.Requests = append(manifest.Requests, req.ID)
+
+		if !includeFiles {
+			continue
+		}
+		files, err := h.db.GetBinaryFilesByRequestID(req.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get files of %s: %w", req.ID, err)
+		}
+		for _, file := range files {
+			content, err := storage.ReadFile(h.fs, file.FilePath)
+			if err != nil {
+				slog.Warn("skipping missing file in export bundle", "file", file.FilePath, "error", err)
+				continue
+			}
+			if err := writeTarFile(tw, path.Join("files", file.FilePath), content, now); err != nil {
+				return nil, err
+			}
+			manifest.Files++
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := writeTarFile(tw, "manifest.json", data, now); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTarFile adds a regular file to the bundle
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: modTime})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// scrubRequest returns a copy of a request with credentials removed
+func scrubRequest(req *database.Request) *database.Request {
+	scrubbed := *req
+	scrubbed.Headers = scrubHeaders(req.Headers)
+	scrubbed.Body = string(guardrail.ScrubSecrets([]byte(req.Body)))
+	return &scrubbed
+}
+
+// scrubResponse returns a copy of a response with credentials removed
+func scrubResponse(resp *database.Response) *database.Response {
+	scrubbed := *resp
+	scrubbed.Headers = scrubHeaders(resp.Headers)
+	scrubbed.Body = string(guardrail.ScrubSecrets([]byte(resp.Body)))
+	return &scrubbed
+}
+
+// scrubHeaders replaces the values of credential headers
+func scrubHeaders(headers map[string]string) map[string]string {
+	scrubbed := make(map[string]string, len(headers))
+	for key, value := range headers {
+		for _, name := range guardrail.CredentialHeaders {
+			if strings.EqualFold(key, name) {
+				value = "[REDACTED]"
+				break
+			}
+		}
+		scrubbed[key] = value
+	}
+	return scrubbed
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/chaos"
+)
+
+// ChaosController manages the proxy's fault injection rules
+type ChaosController interface {
+	ChaosRules() []*chaos.Rule
+	SetChaosRules(rules []*chaos.Rule) error
+}
+
+// SetChaosController sets the proxy whose fault injection the API manages
+func (h *Handler) SetChaosController(controller ChaosController) {
+	h.chaos = controller
+}
+
+// GetChaos handles GET /api/chaos
+func (h *Handler) GetChaos(w http.ResponseWriter, r *http.Request) {
+	rules := h.chaos.ChaosRules()
+	if rules == nil {
+		rules = []*chaos.Rule{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&chaos.RulesFile{Rules: rules})
+}
+
+// SetChaos handles PUT /api/chaos, replacing the fault injection rules with
+// a document in the CHAOS_FILE format. An empty list turns injection off.
+func (h *Handler) SetChaos(w http.ResponseWriter, r *http.Request) {
+	var file chaos.RulesFile
+	if err := json.NewDecoder(r.Body).Decode(&file); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := h.chaos.SetChaosRules(file.Rules); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.GetChaos(w, r)
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// ChunksResponse lists the chunks a streamed response arrived in
+type ChunksResponse struct {
+	RequestID  string       `json:"request_id"`
+	ResponseID string       `json:"response_id"`
+	Chunks     []*ChunkInfo `json:"chunks"`
+
+	// Latency summary in milliseconds since the request was forwarded to the
+	// provider; absent without chunks
+	FirstChunkMs *float64 `json:"first_chunk_ms,omitempty"`
+	LastChunkMs  *float64 `json:"last_chunk_ms,omitempty"`
+	MeanGapMs    *float64 `json:"mean_gap_ms,omitempty"` // Between consecutive chunks
+	MaxGapMs     *float64 `json:"max_gap_ms,omitempty"`
+}
+
+// ChunkInfo is a stored chunk with the time since the previous one
+type ChunkInfo struct {
+	*database.ResponseChunk
+	GapMs float64 `json:"gap_ms"` // Since the previous chunk (the first: since the request was forwarded)
+}
+
+// GetChunks handles GET /api/requests/{id}/chunks: the chunks of the request's
+// streamed response with their arrival times, recorded when RECORD_CHUNKS is on
+func (h *Handler) GetChunks(w http.ResponseWriter, r *http.Request) {
+	requestID := r.PathValue("id")
+	if _, err := h.db.GetRequest(requestID); err != nil {
+		h.writeError(w, http.StatusNotFound, "request not found")
+		return
+	}
+	resp, err := h.db.GetResponseByRequestID(requestID)
+	if err != nil || resp == nil {
+		h.writeError(w, http.StatusNotFound, "request has no response yet")
+		return
+	}
+	chunks, err := h.db.GetResponseChunks(resp.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result := &ChunksResponse{RequestID: requestID, ResponseID: resp.ID, Chunks: []*ChunkInfo{}}
+	previous, maxGap, gaps := 0.0, 0.0, 0.0
+	for i, chunk := range chunks {
+		gap := math.Round((chunk.OffsetMs-previous)*1000) / 1000
+		previous = chunk.OffsetMs
+		result.Chunks = append(result.Chunks, &ChunkInfo{ResponseChunk: chunk, GapMs: gap})
+		if i > 0 {
+			gaps += gap
+			maxGap = max(maxGap, gap)
+		}
+	}
+	if len(chunks) > 0 {
+		first, last := chunks[0].OffsetMs, chunks[len(chunks)-1].OffsetMs
+		result.FirstChunkMs, result.LastChunkMs = &first, &last
+	}
+	if len(chunks) > 1 {
+		mean := math.Round(gaps/float64(len(chunks)-1)*1000) / 1000
+		result.MeanGapMs, result.MaxGapMs = &mean, &maxGap
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
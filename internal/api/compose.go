@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// RequestIDHeader is set by the proxy on every logged request so callers can
+// look up the captured traffic
+const RequestIDHeader = "X-AIGW-Request-ID"
+
+// ComposeRequest is a request draft to send through the proxy
+type ComposeRequest struct {
+	Provider string            `json:"provider"`
+	Endpoint string            `json:"endpoint"`
+	Method   string            `json:"method"`
+	Headers  map[string]string `json:"headers"`
+	Body     json.RawMessage   `json:"body"`
+}
+
+// ComposeResponse is the outcome of a composed request
+type ComposeResponse struct {
+	RequestID  string            `json:"request_id,omitempty"`
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+}
+
+// SetProxy sets the proxy handler that composed requests are sent through
+func (h *Handler) SetProxy(proxy http.Handler) {
+	h.proxy = proxy
+}
+
+// Compose handles POST /api/compose. The draft goes through the full proxy
+// pipeline, so it is authenticated, routed, rate limited and logged like any
+// client request, and provider keys configured on the gateway are used.
+func (h *Handler) Compose(w http.ResponseWriter, r *http.Request) {
+	if h.proxy == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "proxy not configured")
+		return
+	}
+
+	var req ComposeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Provider == "" {
+		h.writeError(w, http.StatusBadRequest, "missing provider")
+		return
+	}
+	if req.Endpoint == "" {
+		h.writeError(w, http.StatusBadRequest, "missing endpoint")
+		return
+	}
+	if h.router != nil && h.router.Provider(req.Provider) == nil {
+		h.writeError(w, http.StatusBadRequest, "unknown provider: "+req.Provider)
+		return
+	}
+	if req.Method == "" {
+		req.Method = http.MethodPost
+	}
+
+	path := composePath(req.Provider, req.Endpoint)
+	proxyReq, err := http.NewRequestWithContext(r.Context(), strings.ToUpper(req.Method), path, bytes.NewReader(composeBody(req.Body)))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	proxyReq.RemoteAddr = r.RemoteAddr
+	for key, value := range req.Headers {
+		proxyReq.Header.Set(key, value)
+	}
+	if proxyReq.Header.Get("Content-Type") == "" && len(req.Body) > 0 {
+		proxyReq.Header.Set("Content-Type", "application/json")
+	}
+
+	rec := newComposeRecorder()
+	h.proxy.ServeHTTP(rec, proxyReq)
+
+	result := &ComposeResponse{
+		RequestID:  rec.header.Get(RequestIDHeader),
+		StatusCode: rec.status,
+		Headers:    make(map[string]string),
+		Body:       rec.body.String(),
+	}
+	for key, values := range rec.header {
+		if key == http.CanonicalHeaderKey(RequestIDHeader) {
+			continue
+		}
+		result.Headers[key] = strings.Join(values, ", ")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// composePath builds the proxy path for a provider endpoint. The endpoint may
+// be given with or without the provider prefix.
+func composePath(providerName, endpoint string) string {
+	prefix := "/" + providerName
+	if !strings.HasPrefix(endpoint, "/") {
+		endpoint = "/" + endpoint
+	}
+	if endpoint == prefix || strings.HasPrefix(endpoint, prefix+"/") {
+		return endpoint
+	}
+	return prefix + endpoint
+}
+
+// composeBody returns the raw request body. A JSON string is sent as its
+// decoded text so non-JSON payloads can be composed too.
+func composeBody(raw json.RawMessage) []byte {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []byte(text)
+	}
+	return raw
+}
+
+// composeRecorder captures the proxy's response to a composed request
+type composeRecorder struct {
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func newComposeRecorder() *composeRecorder {
+	return &composeRecorder{header: make(http.Header), status: http.StatusOK}
+}
+
+func (c *composeRecorder) Header() http.Header {
+	return c.header
+}
+
+func (c *composeRecorder) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	c.status = status
+}
+
+func (c *composeRecorder) Write(p []byte) (int, error) {
+	c.wroteHeader = true
+	return c.body.Write(p)
+}
+
+// Flush lets streaming responses be captured in full
+func (c *composeRecorder) Flush() {}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// GetCosts handles GET /api/costs, returning estimated cost aggregated by
+// day, provider and model. Accepts provider, key, date_from and date_to
+// (Unix seconds) filters.
+func (h *Handler) GetCosts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	params := &database.CostSummaryParams{
+		Provider:     query.Get("provider"),
+		VirtualKeyID: query.Get("key"),
+	}
+	if ts, err := strconv.ParseInt(query.Get("date_from"), 10, 64); err == nil {
+		params.DateFrom = time.Unix(ts, 0)
+	}
+	if ts, err := strconv.ParseInt(query.Get("date_to"), 10, 64); err == nil {
+		params.DateTo = time.Unix(ts, 0)
+	}
+
+	rows, err := h.db.CostSummary(params)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	total := 0.0
+	for _, row := range rows {
+		total += row.CostUSD
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"costs":          rows,
+		"total_cost_usd": total,
+	})
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// datasetPageSize is how many requests are read at a time for a dataset
+const datasetPageSize = 500
+
+// FineTuneExample is one line of an OpenAI chat fine-tuning dataset
+type FineTuneExample struct {
+	Messages          []json.RawMessage `json:"messages"` // The request's messages, then the assistant's response
+	Tools             json.RawMessage   `json:"tools,omitempty"`
+	ParallelToolCalls *bool             `json:"parallel_tool_calls,omitempty"`
+}
+
+// assistantMessage is the part of a response message kept for fine-tuning
+type assistantMessage struct {
+	Role      string          `json:"role"`
+	Content   *string         `json:"content"`
+	ToolCalls json.RawMessage `json:"tool_calls,omitempty"`
+}
+
+// ExportFineTuneDataset handles GET /api/export/finetune: the chat
+// completions selected like GET /api/requests, as JSONL in OpenAI's chat
+// fine-tuning format. Only requests whose final response has status 200
+// are exported unless status says otherwise; requests that aren't chat
+// completions, or whose response carries no message, are skipped. limit
+// caps the number of examples (default: all).
+func (h *Handler) ExportFineTuneDataset(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("status") == "" {
+		query.Set("status", "200")
+	}
+	var maxExamples int
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		if maxExamples, err = strconv.Atoi(limit); err != nil || maxExamples <= 0 {
+			h.writeError(w, http.StatusBadRequest, "invalid limit (expected a positive number)")
+			return
+		}
+	}
+	query.Del("limit")
+	query.Del("offset")
+	query.Del("cursor")
+
+	params, err := parseListParams(query)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Oldest first, so requests stored during the export don't shift pages
+	params.Sort, params.Ascending, params.Limit = "", true, datasetPageSize
+
+	name := "aigw-finetune-" + time.Now().UTC().Format("20060102-150405") + ".jsonl"
+	w.Header().Set("Content-Type", "application/jsonl")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+
+	out := bufio.NewWriter(w)
+	encoder := json.NewEncoder(out)
+	exported, skipped := 0, 0
+	for {
+		requests, err := h.db.ListRequests(params)
+		if err != nil {
+			// Headers are out once the first page is written
+			if exported+skipped == 0 {
+				h.writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			slog.ErrorContext(r.Context(), "fine-tuning dataset export failed", "error", err)
+			break
+		}
+		ids := make([]string, len(requests))
+		for i, req := range requests {
+			ids[i] = req.ID
+		}
+		responses, err := h.db.GetFinalResponses(ids)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "fine-tuning dataset export failed", "error", err)
+			break
+		}
+
+		for _, req := range requests {
+			example, ok := fineTuneExample(req, responses[req.ID])
+			if !ok {
+				skipped++
+				continue
+			}
+			if err := encoder.Encode(example); err != nil {
+				return
+			}
+			exported++
+			if exported == maxExamples {
+				break
+			}
+		}
+		if len(requests) < datasetPageSize || exported == maxExamples {
+			break
+		}
+		params.Offset += datasetPageSize
+	}
+	out.Flush()
+	slog.InfoContext(r.Context(), "exported fine-tuning dataset", "examples", exported, "skipped", skipped)
+}
+
+// fineTuneExample converts a chat completion and its response into a
+// fine-tuning example. It returns false if the request isn't a chat
+// completion or the response carries no assistant message.
+func fineTuneExample(req *database.Request, resp *database.Response) (*FineTuneExample, bool) {
+	path, _, _ := strings.Cut(req.Endpoint, "?")
+	if resp == nil || req.Method != http.MethodPost || !strings.HasSuffix(strings.TrimSuffix(path, "/"), "/chat/completions") {
+		return nil, false
+	}
+
+	var body struct {
+		Messages          []json.RawMessage `json:"messages"`
+		Tools             json.RawMessage   `json:"tools"`
+		ParallelToolCalls *bool             `json:"parallel_tool_calls"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &body); err != nil || len(body.Messages) == 0 {
+		return nil, false
+	}
+
+	// Streamed responses carry the message assembled when they were stored
+	raw := resp.Message
+	if len(raw) == 0 {
+		var completion struct {
+			Choices []struct {
+				Message json.RawMessage `json:"message"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(resp.Body), &completion); err != nil || len(completion.Choices) == 0 {
+			return nil, false
+		}
+		raw = completion.Choices[0].Message
+	}
+	var message assistantMessage
+	if err := json.Unmarshal(raw, &message); err != nil {
+		return nil, false
+	}
+	if string(message.ToolCalls) == "null" {
+		message.ToolCalls = nil
+	}
+	if (message.Content == nil || *message.Content == "") && len(message.ToolCalls) == 0 {
+		return nil, false
+	}
+	message.Role = "assistant"
+	assistant, err := json.Marshal(&message)
+	if err != nil {
+		return nil, false
+	}
+
+	example := &FineTuneExample{
+		Messages:          append(body.Messages, assistant),
+		ParallelToolCalls: body.ParallelToolCalls,
+	}
+	if string(body.Tools) != "null" {
+		example.Tools = body.Tools
+	}
+	return example, true
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/diff"
+)
+
+// DiffSide summarizes one of the two compared requests
+type DiffSide struct {
+	ID         string `json:"id"`
+	Provider   string `json:"provider"`
+	Endpoint   string `json:"endpoint"`
+	StatusCode int    `json:"status_code,omitempty"`
+}
+
+// DiffResponse is the difference between two requests and their responses
+type DiffResponse struct {
+	Original     *DiffSide    `json:"original"`
+	Other        *DiffSide    `json:"other"`
+	RequestBody  *diff.Result `json:"request_body"`
+	ResponseBody *diff.Result `json:"response_body"`
+}
+
+// DiffRequests handles GET /api/requests/{id}/diff/{otherId}
+func (h *Handler) DiffRequests(w http.ResponseWriter, r *http.Request) {
+	original, originalResp, ok := h.diffSide(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	other, otherResp, ok := h.diffSide(w, r.PathValue("otherId"))
+	if !ok {
+		return
+	}
+
+	result := &DiffResponse{
+		Original:     sideSummary(original, originalResp),
+		Other:        sideSummary(other, otherResp),
+		RequestBody:  diff.Bodies(original.Body, other.Body),
+		ResponseBody: diff.Bodies(responseBody(originalResp), responseBody(otherResp)),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// diffSide loads a request and its final response, writing an error if the request doesn't exist
+func (h *Handler) diffSide(w http.ResponseWriter, id string) (*database.Request, *database.Response, bool) {
+	if id == "" {
+		h.writeError(w, http.StatusBadRequest, "missing request id")
+		return nil, nil, false
+	}
+
+	req, err := h.db.GetRequest(id)
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "request not found: "+id)
+		return nil, nil, false
+	}
+
+	// A request without a response is compared against an empty body
+	resp, _ := h.db.GetResponseByRequestID(id)
+	return req, resp, true
+}
+
+func sideSummary(req *database.Request, resp *database.Response) *DiffSide {
+	side := &DiffSide{ID: req.ID, Provider: req.Provider, Endpoint: req.Endpoint}
+	if resp != nil {
+		side.StatusCode = resp.StatusCode
+	}
+	return side
+}
+
+func responseBody(resp *database.Response) string {
+	if resp == nil {
+		return ""
+	}
+	return resp.Body
+}
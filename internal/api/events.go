@@ -3,34 +3,122 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/sink"
+)
+
+// Slow consumer policies, applied when a client's buffer is full
+const (
+	// SlowConsumerDrop silently drops events for the client
+	SlowConsumerDrop = "drop"
+	// SlowConsumerDisconnect sends the client a slow_consumer error event and disconnects it
+	SlowConsumerDisconnect = "disconnect"
+	// SlowConsumerCoalesce drops events but tells the client how many it missed
+	// with an events_missed notice once it catches up
+	SlowConsumerCoalesce = "coalesce"
 )
 
+// BroadcasterOptions configures an SSEBroadcaster
+type BroadcasterOptions struct {
+	BroadcastBuffer    int           // Events queued for fan-out
+	ClientBuffer       int           // Events queued per client
+	SlowConsumerPolicy string        // One of the SlowConsumer* policies
+	HeartbeatInterval  time.Duration // Idle time before a ": ping" comment is sent; 0 disables
+}
+
+// EventFilter selects the events an SSE client receives; the zero value
+// receives every event
+type EventFilter struct {
+	Types    []string // Event types received; empty for all
+	Provider string   // Only events about requests to this provider; empty for all
+}
+
+// matches reports whether an event about the given provider passes the filter
+func (f EventFilter) matches(event *EventMessage, provider string) bool {
+	if f.Provider != "" && provider != f.Provider {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// maxRequestProviders is how many recent requests the broadcaster remembers
+// the provider of, to attribute later events about them
+const maxRequestProviders = 10000
+
+// sseWriteTimeout bounds writing to an SSE client; a client that stops reading
+// is disconnected once it elapses
+const sseWriteTimeout = 10 * time.Second
+
 // SSEClient represents a connected SSE client
 type SSEClient struct {
-	id   string
-	send chan *EventMessage
-	done chan struct{}
+	id     string
+	filter EventFilter
+	send   chan *EventMessage
+	done   chan struct{}
+
+	missed       atomic.Int64 // Events dropped since the last events_missed notice
+	disconnected atomic.Bool  // Set when disconnected for being too slow
 }
 
-// SSEBroadcaster manages SSE connections and broadcasts events
+// SSEBroadcaster manages SSE connections and broadcasts events to them and
+// to any sinks added with AddSink
 type SSEBroadcaster struct {
-	mu           sync.RWMutex
-	clients      map[string]*SSEClient
-	subscribe    chan *SSEClient
-	unsubscribe  chan *SSEClient
-	broadcast    chan *EventMessage
-	quit         chan struct{}
+	mu          sync.RWMutex
+	clients     map[string]*SSEClient
+	subscribe   chan *SSEClient
+	unsubscribe chan *SSEClient
+	broadcast   chan *EventMessage
+	quit        chan struct{}
+	stopped     chan struct{} // Closed when run returns
+
+	sinks []*sink.Queue
+
+	// Providers of recent requests, oldest first in requestOrder. Only used
+	// by the run goroutine.
+	requestProviders map[string]string
+	requestOrder     []string
+
+	clientBuffer int
+	policy       string
+	heartbeat    time.Duration
+	dropped      atomic.Int64
 }
 
 // NewSSEBroadcaster creates a new SSE broadcaster
-func NewSSEBroadcaster() *SSEBroadcaster {
+func NewSSEBroadcaster(opts BroadcasterOptions) *SSEBroadcaster {
+	if opts.BroadcastBuffer <= 0 {
+		opts.BroadcastBuffer = 100
+	}
+	if opts.ClientBuffer <= 0 {
+		opts.ClientBuffer = 10
+	}
+	if opts.SlowConsumerPolicy == "" {
+		opts.SlowConsumerPolicy = SlowConsumerCoalesce
+	}
+
 	b := &SSEBroadcaster{
-		clients:     make(map[string]*SSEClient),
-		subscribe:   make(chan *SSEClient),
-		unsubscribe: make(chan *SSEClient),
-		broadcast:   make(chan *EventMessage, 100),
-		quit:        make(chan struct{}),
+		clients:          make(map[string]*SSEClient),
+		subscribe:        make(chan *SSEClient),
+		unsubscribe:      make(chan *SSEClient),
+		broadcast:        make(chan *EventMessage, opts.BroadcastBuffer),
+		quit:             make(chan struct{}),
+		stopped:          make(chan struct{}),
+		requestProviders: make(map[string]string),
+		clientBuffer:     opts.ClientBuffer,
+		policy:           opts.SlowConsumerPolicy,
+		heartbeat:        opts.HeartbeatInterval,
 	}
 
 	// Start the broadcaster goroutine
@@ -41,6 +129,7 @@ func NewSSEBroadcaster() *SSEBroadcaster {
 
 // run processes subscribe/unsubscribe/broadcast operations
 func (b *SSEBroadcaster) run() {
+	defer close(b.stopped)
 	for {
 		select {
 		case client := <-b.subscribe:
@@ -57,15 +146,15 @@ func (b *SSEBroadcaster) run() {
 			b.mu.Unlock()
 
 		case event := <-b.broadcast:
-			b.mu.RLock()
+			provider := b.eventProvider(event)
+			b.mu.Lock()
 			for _, client := range b.clients {
-				select {
-				case client.send <- event:
-				default:
-					// Non-blocking send - skip client if channel is full
+				if client.filter.matches(event, provider) {
+					b.deliver(client, event)
 				}
 			}
-			b.mu.RUnlock()
+			b.deliverToSinks(event)
+			b.mu.Unlock()
 
 		case <-b.quit:
 			return
@@ -73,12 +162,70 @@ func (b *SSEBroadcaster) run() {
 	}
 }
 
-// Subscribe creates a new SSE client and subscribes to events
-func (b *SSEBroadcaster) Subscribe(clientID string) *SSEClient {
+// eventProvider returns the provider an event is about, remembering the
+// provider of the request it names for later events without one
+func (b *SSEBroadcaster) eventProvider(event *EventMessage) string {
+	if event.RequestID == "" {
+		return event.Provider
+	}
+	if event.Provider == "" {
+		return b.requestProviders[event.RequestID]
+	}
+	if _, known := b.requestProviders[event.RequestID]; !known {
+		if len(b.requestOrder) >= maxRequestProviders {
+			delete(b.requestProviders, b.requestOrder[0])
+			b.requestOrder = b.requestOrder[1:]
+		}
+		b.requestOrder = append(b.requestOrder, event.RequestID)
+	}
+	b.requestProviders[event.RequestID] = event.Provider
+	return event.Provider
+}
+
+// deliver queues an event for a client without blocking, applying the slow
+// consumer policy if the client's buffer is full. Must be called with b.mu held.
+func (b *SSEBroadcaster) deliver(client *SSEClient, event *EventMessage) {
+	// Tell a coalescing client what it missed before the next event, if both fit
+	if n := client.missed.Load(); n > 0 && cap(client.send)-len(client.send) >= 2 {
+		if client.missed.CompareAndSwap(n, 0) {
+			client.send <- missedEventsNotice(n)
+		}
+	}
+
+	select {
+	case client.send <- event:
+		return
+	default:
+	}
+
+	b.dropped.Add(1)
+	switch b.policy {
+	case SlowConsumerDisconnect:
+		slog.Warn("disconnecting slow SSE client", "client_id", client.id)
+		client.disconnected.Store(true)
+		delete(b.clients, client.id)
+		close(client.send)
+	case SlowConsumerCoalesce:
+		client.missed.Add(1)
+	}
+}
+
+// missedEventsNotice is sent to a coalescing client after it fell behind
+func missedEventsNotice(count int64) *EventMessage {
+	return &EventMessage{
+		Type: "events_missed",
+		Data: map[string]interface{}{"count": count},
+	}
+}
+
+// Subscribe creates a new SSE client and subscribes it to the events that
+// pass filter
+func (b *SSEBroadcaster) Subscribe(clientID string, filter EventFilter) *SSEClient {
 	client := &SSEClient{
-		id:   clientID,
-		send: make(chan *EventMessage, 10),
-		done: make(chan struct{}),
+		id:     clientID,
+		filter: filter,
+		send:   make(chan *EventMessage, b.clientBuffer),
+		done:   make(chan struct{}),
 	}
 
 	b.subscribe <- client
@@ -131,7 +278,23 @@ func FormatSSEMessage(event *EventMessage) (string, error) {
 	return fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, string(data)), nil
 }
 
-// Close closes the broadcaster
+// ClientCount returns the number of connected SSE clients
+func (b *SSEBroadcaster) ClientCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.clients)
+}
+
+// DroppedEvents returns how many client event deliveries were dropped because
+// the client's buffer was full
+func (b *SSEBroadcaster) DroppedEvents() int64 {
+	return b.dropped.Load()
+}
+
+// Close closes the broadcaster, then gives sinks a few seconds to publish
+// the events still queued for them before closing them
 func (b *SSEBroadcaster) Close() {
 	close(b.quit)
+	<-b.stopped
+	b.closeSinks(sinkDrainTimeout)
 }
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// FineTuneDetail is a fine-tuning job with its status history
+type FineTuneDetail struct {
+	*database.FineTuneJob
+	Updates []*database.FineTuneUpdate `json:"updates"`
+}
+
+// ListFineTunes handles GET /api/fine-tunes. With ?active=true only
+// unfinished jobs are listed.
+func (h *Handler) ListFineTunes(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.db.ListFineTuneJobs(r.URL.Query().Get("active") == "true")
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs": jobs,
+	})
+}
+
+// GetFineTune handles GET /api/fine-tunes/{id}
+func (h *Handler) GetFineTune(w http.ResponseWriter, r *http.Request) {
+	job, err := h.db.GetFineTuneJob(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "fine-tune job not found")
+		return
+	}
+
+	updates, err := h.db.ListFineTuneUpdates(job.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&FineTuneDetail{FineTuneJob: job, Updates: updates})
+}
@@ -2,44 +2,152 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/ruqqq/simple-ai-gateway/internal/archive"
 	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/retention"
+	"github.com/ruqqq/simple-ai-gateway/internal/router"
 	"github.com/ruqqq/simple-ai-gateway/internal/storage"
+	"github.com/ruqqq/simple-ai-gateway/internal/version"
 )
 
 // Handler handles API requests
 type Handler struct {
 	db          *database.DB
-	fs          *storage.FileStorage
+	fs          storage.FileStorage
 	broadcaster *SSEBroadcaster
+	router      *router.Router
+	proxy       http.Handler
+
+	statusSource  StatusSource
+	interceptor   Interceptor
+	chaos         ChaosController
+	pause         PauseController
+	janitor       *retention.Janitor
+	archiver      *archive.Archiver
+	startedAt     time.Time
+	ingestToken   string
+	version       version.Info
+	updateChecker *version.Checker
 }
 
 // NewHandler creates a new API handler
-func NewHandler(db *database.DB, fs *storage.FileStorage, broadcaster *SSEBroadcaster) *Handler {
+func NewHandler(db *database.DB, fs storage.FileStorage, broadcaster *SSEBroadcaster) *Handler {
 	return &Handler{
 		db:          db,
 		fs:          fs,
 		broadcaster: broadcaster,
+		startedAt:   time.Now(),
 	}
 }
 
 // ListRequests handles GET /api/requests
 func (h *Handler) ListRequests(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
+	params, err := parseListParams(r.URL.Query())
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	summaries, nextCursor, err := h.db.ListRequestSummaries(params)
+	if errors.Is(err, database.ErrInvalidSort) {
+		h.writeError(w, http.StatusBadRequest, "invalid sort (expected created_at, duration_ms, status_code, cost, tokens or provider)")
+		return
+	}
+	if errors.Is(err, database.ErrInvalidCursor) {
+		h.writeError(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	total, err := h.db.CountRequests(params)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Convert to list items with the final response status, as it was at as_of
+	items := make([]*RequestListItem, 0, len(summaries))
+	for _, summary := range summaries {
+		req := summary.Request
+		item := &RequestListItem{
+			ID:           req.ID,
+			Provider:     req.Provider,
+			Endpoint:     req.Endpoint,
+			Method:       req.Method,
+			VirtualKeyID: req.VirtualKeyID,
+			Source:       req.Source,
+			RiskScore:    req.RiskScore,
+			SessionID:    req.SessionID,
+			Tags:         req.Tags,
+			Starred:      req.Starred,
+			CreatedAt:    req.CreatedAt,
+			DeletedAt:    req.DeletedAt,
+			ImagePath:    summary.ImagePath,
+		}
+
+		if resp := summary.Response; resp != nil {
+			item.Status = resp.StatusCode
+			item.DurationMs = resp.DurationMs
+			item.InputTokens = resp.InputTokens
+			item.OutputTokens = resp.OutputTokens
+			item.CostUSD = resp.CostUSD
+			item.IsError = resp.IsError
+			item.Cancelled = resp.Cancelled
+			item.Timeout = resp.Timeout
+			if resp.ErrorMessage != nil && *resp.ErrorMessage != "" {
+				item.ErrorMessage = *resp.ErrorMessage
+			}
+		}
+
+		items = append(items, item)
+	}
 
+	response := map[string]interface{}{
+		"requests": items,
+		"total":    total,
+	}
+	if nextCursor != "" {
+		response["next_cursor"] = nextCursor
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseListParams parses the filters, sort and paging of a request listing
+func parseListParams(query url.Values) (*database.ListRequestsParams, error) {
 	provider := query.Get("provider")
+	virtualKeyID := query.Get("key")
+	source := query.Get("source")
+	hasSecrets := query.Get("secrets") == "true"
+	flagged := query.Get("flagged") == "true"
+	starred := query.Get("starred") == "true"
+	search := strings.TrimSpace(query.Get("q"))
+	model := query.Get("model")
+	hasFiles := query.Get("has_files") == "true"
+	minRiskStr := query.Get("risk_min")
+	sessionID := query.Get("session")
 	pathPattern := query.Get("path_pattern")
 	dateFromStr := query.Get("date_from")
 	dateToStr := query.Get("date_to")
+	asOfStr := query.Get("as_of")
 	limitStr := query.Get("limit")
 	offsetStr := query.Get("offset")
+	cursor := query.Get("cursor")
+	sortBy := query.Get("sort")
 
 	// Parse timestamps
 	var dateFrom, dateTo time.Time
@@ -56,6 +164,51 @@ func (h *Handler) ListRequests(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// as_of reproduces the list as it was at that time, so it must be exact
+	var asOf time.Time
+	if asOfStr != "" {
+		var err error
+		if asOf, err = parseTimestamp(asOfStr); err != nil {
+			return nil, errors.New("invalid as_of (expected Unix seconds or RFC 3339)")
+		}
+	}
+
+	var minRisk float64
+	if minRiskStr != "" {
+		var err error
+		if minRisk, err = strconv.ParseFloat(minRiskStr, 64); err != nil || minRisk < 0 || minRisk > 1 {
+			return nil, errors.New("invalid risk_min (expected a number from 0 to 1)")
+		}
+	}
+
+	statusMin, statusMax, err := parseStatusFilter(query.Get("status"))
+	if err != nil {
+		return nil, errors.New("invalid status (expected a code like 404, a class like 5xx or a range like 400-499)")
+	}
+
+	var isError *bool
+	if isErrorStr := query.Get("is_error"); isErrorStr != "" {
+		value, err := strconv.ParseBool(isErrorStr)
+		if err != nil {
+			return nil, errors.New("invalid is_error (expected true or false)")
+		}
+		isError = &value
+	}
+
+	tags, err := database.NormalizeTags(query["tag"])
+	if err != nil {
+		return nil, err
+	}
+
+	var ascending bool
+	switch order := query.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		ascending = true
+	default:
+		return nil, errors.New("invalid order (expected asc or desc)")
+	}
+
 	// Parse limit and offset
 	limit := 50
 	offset := 0
@@ -71,49 +224,66 @@ func (h *Handler) ListRequests(w http.ResponseWriter, r *http.Request) {
 	}
 
 	params := &database.ListRequestsParams{
-		Provider:    provider,
-		PathPattern: pathPattern,
-		DateFrom:    dateFrom,
-		DateTo:      dateTo,
-		Limit:       limit,
-		Offset:      offset,
+		Provider:     provider,
+		VirtualKeyID: virtualKeyID,
+		Source:       source,
+		HasSecrets:   hasSecrets,
+		Flagged:      flagged,
+		MinRisk:      minRisk,
+		SessionID:    sessionID,
+		Tags:         tags,
+		Starred:      starred,
+		Search:       search,
+		StatusMin:    statusMin,
+		StatusMax:    statusMax,
+		IsError:      isError,
+		Model:        model,
+		HasFiles:     hasFiles,
+		PathPattern:  pathPattern,
+		DateFrom:     dateFrom,
+		DateTo:       dateTo,
+		AsOf:         asOf,
+		Limit:        limit,
+		Offset:       offset,
+		Sort:         sortBy,
+		Ascending:    ascending,
+		Cursor:       cursor,
 	}
+	return params, nil
+}
 
-	requests, err := h.db.ListRequests(params)
-	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, err.Error())
-		return
+// parseStatusFilter parses a status code (404), class (4xx) or range
+// (400-499) into its bounds; an empty value has none
+func parseStatusFilter(value string) (int, int, error) {
+	if value == "" {
+		return 0, 0, nil
 	}
-
-	// Convert to list items with response status
-	items := make([]*RequestListItem, 0, len(requests))
-	for _, req := range requests {
-		item := &RequestListItem{
-			ID:        req.ID,
-			Provider:  req.Provider,
-			Endpoint:  req.Endpoint,
-			Method:    req.Method,
-			CreatedAt: req.CreatedAt,
-		}
-
-		// Try to get response status code and error information
-		resp, err := h.db.GetResponseByRequestID(req.ID)
-		if err == nil && resp != nil {
-			item.Status = resp.StatusCode
-			item.IsError = resp.IsError
-			if resp.ErrorMessage != nil && *resp.ErrorMessage != "" {
-				item.ErrorMessage = *resp.ErrorMessage
-			}
-		}
-
-		items = append(items, item)
+	value = strings.ToLower(value)
+	if len(value) == 3 && strings.HasSuffix(value, "xx") && value[0] >= '1' && value[0] <= '5' {
+		class := int(value[0]-'0') * 100
+		return class, class + 99, nil
+	}
+	from, to, isRange := strings.Cut(value, "-")
+	low, err := strconv.Atoi(from)
+	if err != nil || low < 100 || low > 599 {
+		return 0, 0, fmt.Errorf("invalid status %q", value)
 	}
+	if !isRange {
+		return low, low, nil
+	}
+	high, err := strconv.Atoi(to)
+	if err != nil || high < low || high > 599 {
+		return 0, 0, fmt.Errorf("invalid status %q", value)
+	}
+	return low, high, nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"requests": items,
-		"total":    len(items),
-	})
+// parseTimestamp parses Unix seconds or an RFC 3339 time
+func parseTimestamp(value string) (time.Time, error) {
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
 }
 
 // GetRequest handles GET /api/requests/:id
@@ -131,6 +301,16 @@ func (h *Handler) GetRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Rehydrate an archived request from object storage; if that fails the
+	// stub is returned with archived_at set
+	if req.ArchivedAt != nil && h.archiver != nil {
+		if err := h.archiver.Restore(r.Context(), requestID); err != nil {
+			slog.WarnContext(r.Context(), "failed to restore archived request", "request_id", requestID, "error", err)
+		} else if restored, err := h.db.GetRequest(requestID); err == nil {
+			req = restored
+		}
+	}
+
 	detail := &RequestDetail{
 		Request: req,
 	}
@@ -138,18 +318,49 @@ func (h *Handler) GetRequest(w http.ResponseWriter, r *http.Request) {
 	// Get response (query by request_id from responses table)
 	rows, err := h.db.GetResponseByRequestID(requestID)
 	if err != nil {
-		fmt.Printf("Warning: failed to get response for request %s: %v\n", requestID, err)
+		slog.WarnContext(r.Context(), "failed to get response", "request_id", requestID, "error", err)
 	}
 	if err == nil && rows != nil {
 		detail.Response = &ResponseDetail{
-			ID:           rows.ID,
-			StatusCode:   rows.StatusCode,
-			Headers:      rows.Headers,
-			Body:         rows.Body,
-			DurationMs:   rows.DurationMs,
-			IsError:      rows.IsError,
-			ErrorMessage: rows.ErrorMessage,
-			CreatedAt:    rows.CreatedAt,
+			ID:              rows.ID,
+			StatusCode:      rows.StatusCode,
+			Headers:         rows.Headers,
+			Body:            rows.Body,
+			DurationMs:      rows.DurationMs,
+			IsError:         rows.IsError,
+			ErrorMessage:    rows.ErrorMessage,
+			Model:           rows.Model,
+			InputTokens:     rows.InputTokens,
+			OutputTokens:    rows.OutputTokens,
+			CachedTokens:    rows.CachedTokens,
+			ReasoningTokens: rows.ReasoningTokens,
+			CostUSD:         rows.CostUSD,
+			Cached:          rows.Cached,
+			Stale:           rows.Stale,
+			Message:         rows.Message,
+			FinishReason:    rows.FinishReason,
+			Warnings:        rows.Warnings,
+			TTFTMs:          rows.TTFTMs,
+			Cancelled:       rows.Cancelled,
+			Timeout:         rows.Timeout,
+			EditedFrom:      rows.EditedFrom,
+			CreatedAt:       rows.CreatedAt,
+		}
+	}
+
+	// Get intermediate redirect hops and retried attempts (every response but the final one)
+	if all, err := h.db.GetResponsesByRequestID(requestID); err == nil && len(all) > 1 {
+		for _, hop := range all[:len(all)-1] {
+			detail.Hops = append(detail.Hops, &ResponseDetail{
+				ID:           hop.ID,
+				StatusCode:   hop.StatusCode,
+				Headers:      hop.Headers,
+				Body:         hop.Body,
+				DurationMs:   hop.DurationMs,
+				IsError:      hop.IsError,
+				ErrorMessage: hop.ErrorMessage,
+				CreatedAt:    hop.CreatedAt,
+			})
 		}
 	}
 
@@ -167,6 +378,22 @@ func (h *Handler) GetRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Get linked requests: gateway follow-ups (e.g. answering resolved tool calls),
+	// mirrored copies and cache revalidations
+	if followUps, err := h.db.ListFollowUps(requestID); err == nil {
+		detail.FollowUps = followUps
+	}
+	if mirrors, err := h.db.ListMirrors(requestID); err == nil {
+		detail.Mirrors = mirrors
+	}
+	if revalidations, err := h.db.ListRevalidations(requestID); err == nil {
+		detail.Revalidations = revalidations
+	}
+
+	if notes, err := h.db.ListNotes(requestID); err == nil {
+		detail.Notes = notes
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(detail)
 }
@@ -185,22 +412,93 @@ func (h *Handler) GetFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fullPath := h.fs.GetFullPath(filePath)
-
-	// Check file exists
-	if _, err := os.Stat(fullPath); err != nil {
-		h.writeError(w, http.StatusNotFound, "file not found")
+	// Thumbnails are served when the image has one, else the image itself
+	if r.URL.Query().Get("thumb") == "1" && h.serveThumbnail(w, r, filePath) {
 		return
 	}
 
-	// Determine content type from file extension
-	ext := filepath.Ext(filePath)
-	contentType := getContentTypeFromExt(ext)
+	// Use the recorded content type, else determine it from the file extension
+	contentType := getContentTypeFromExt(filepath.Ext(filePath))
+	file, index, err := h.db.FindBinaryFile(filePath)
+	if err != nil {
+		slog.Warn("failed to look up stored file", "file", filePath, "error", err)
+	}
+	if file != nil {
+		contentType = file.ContentType
+	}
+
+	// Downloads are named after the request and the file's place in it
+	var downloadName string
+	if r.URL.Query().Get("download") == "1" {
+		downloadName = filepath.Base(filePath)
+		if file != nil && file.RequestID != "" {
+			downloadName = fmt.Sprintf("%s-%d%s", file.RequestID, index, filepath.Ext(filePath))
+		}
+	}
+
+	if err := h.serveFile(w, r, filePath, contentType, downloadName); err != nil {
+		h.writeFileError(w, err, "file not found")
+	}
+}
+
+// serveFile serves a stored file, or redirects to a signed URL for it if
+// storage hands them out. Range requests are answered with the requested
+// parts. With a downloadName, the file is sent as an attachment saved under
+// that name. Nothing is written if it returns an error.
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, filePath, contentType, downloadName string) error {
+	// Served files are the last to be evicted for the file quota
+	if err := h.db.TouchBinaryFile(filePath); err != nil {
+		slog.Warn("failed to record file access", "file", filePath, "error", err)
+	}
+
+	if signer, ok := h.fs.(storage.URLSigner); ok {
+		if signedURL := signer.SignedURL(filePath, downloadName); signedURL != "" {
+			http.Redirect(w, r, signedURL, http.StatusFound)
+			return nil
+		}
+	}
+
+	file, err := h.fs.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
 	if contentType != "" {
 		w.Header().Set("Content-Type", contentType)
 	}
+	if downloadName != "" {
+		w.Header().Set("Content-Disposition", storage.ContentDisposition(downloadName))
+	}
+	http.ServeContent(w, r, filepath.Base(filePath), file.ModTime(), file)
+	return nil
+}
 
-	http.ServeFile(w, r, fullPath)
+// serveThumbnail serves the thumbnail of a stored image through the gateway,
+// reporting false, having written nothing, if there is none
+func (h *Handler) serveThumbnail(w http.ResponseWriter, r *http.Request, filePath string) bool {
+	file, err := h.fs.Open(storage.ThumbnailPath(filePath))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("failed to read thumbnail", "file", filePath, "error", err)
+		}
+		return false
+	}
+	defer file.Close()
+
+	// The content type (JPEG or PNG) is sniffed
+	http.ServeContent(w, r, filepath.Base(filePath)+".thumb", file.ModTime(), file)
+	return true
+}
+
+// writeFileError writes the error of a stored file that couldn't be served
+func (h *Handler) writeFileError(w http.ResponseWriter, err error, notFound string) {
+	if errors.Is(err, os.ErrNotExist) {
+		h.writeError(w, http.StatusNotFound, notFound)
+		return
+	}
+	slog.Error("failed to read stored file", "error", err)
+	h.writeError(w, http.StatusBadGateway, "failed to read stored file")
 }
 
 // GetEvents handles GET /api/events (SSE)
@@ -211,35 +509,82 @@ func (h *Handler) GetEvents(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	// Flush headers
-	flusher, ok := w.(http.Flusher)
-	if !ok {
+	if _, ok := w.(http.Flusher); !ok {
 		h.writeError(w, http.StatusInternalServerError, "streaming not supported")
 		return
 	}
 
-	// Create SSE client
+	// Writes that stall (client stopped reading) fail after a timeout instead of blocking forever
+	rc := http.NewResponseController(w)
+	write := func(msg string) error {
+		rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+		if _, err := fmt.Fprint(w, msg); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	// Create SSE client, receiving only the event types and provider asked for
+	filter := EventFilter{Provider: r.URL.Query().Get("provider")}
+	for _, t := range strings.Split(r.URL.Query().Get("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter.Types = append(filter.Types, t)
+		}
+	}
 	clientID := uuid.New().String()
-	client := h.broadcaster.Subscribe(clientID)
+	client := h.broadcaster.Subscribe(clientID, filter)
 	defer h.broadcaster.Unsubscribe(client)
 
 	// Send initial connection message
 	msg, _ := FormatSSEMessage(&EventMessage{
 		Type: "connected",
 	})
-	fmt.Fprint(w, msg)
-	flusher.Flush()
+	if err := write(msg); err != nil {
+		return
+	}
+
+	// Heartbeat comments keep idle streams alive through proxies and load
+	// balancers, and reveal dead clients as failed writes
+	var heartbeat <-chan time.Time
+	if h.broadcaster.heartbeat > 0 {
+		ticker := time.NewTicker(h.broadcaster.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
 
 	// Stream events to client
 	for {
 		select {
 		case event, ok := <-client.send:
 			if !ok {
+				if client.disconnected.Load() {
+					msg, _ := FormatSSEMessage(&EventMessage{
+						Type: "slow_consumer",
+						Data: map[string]interface{}{"message": "disconnected: client fell too far behind the event stream"},
+					})
+					write(msg)
+				}
 				return
 			}
 			msg, _ := FormatSSEMessage(event)
-			fmt.Fprint(w, msg)
-			flusher.Flush()
+
+			// Once caught up, report events dropped while this client was behind
+			if len(client.send) == 0 {
+				if n := client.missed.Swap(0); n > 0 {
+					notice, _ := FormatSSEMessage(missedEventsNotice(n))
+					msg += notice
+				}
+			}
+			if err := write(msg); err != nil {
+				slog.Debug("SSE client write failed, disconnecting", "client_id", clientID, "error", err)
+				return
+			}
+
+		case <-heartbeat:
+			if err := write(": ping\n\n"); err != nil {
+				slog.Debug("SSE client heartbeat failed, disconnecting", "client_id", clientID, "error", err)
+				return
+			}
 
 		case <-r.Context().Done():
 			return
@@ -249,11 +594,16 @@ func (h *Handler) GetEvents(w http.ResponseWriter, r *http.Request) {
 
 // GetStats handles GET /api/stats
 func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
-	// For now, return basic stats
-	// This would require additional query methods for aggregation
+	dbStats, err := h.db.GetStats()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	stats := &StatsResponse{
-		RequestsByProvider: make(map[string]int),
-		RequestsByStatus:   make(map[int]int),
+		TotalRequests:      dbStats.TotalRequests,
+		RequestsByProvider: dbStats.RequestsByProvider,
+		RequestsByStatus:   dbStats.RequestsByStatus,
+		TTFTByProvider:     dbStats.TTFTByProvider,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -263,16 +613,21 @@ func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
 // BroadcastRequestCreated broadcasts a request created event
 func (h *Handler) BroadcastRequestCreated(req *database.Request) {
 	item := &RequestListItem{
-		ID:        req.ID,
-		Provider:  req.Provider,
-		Endpoint:  req.Endpoint,
-		Method:    req.Method,
-		CreatedAt: req.CreatedAt,
+		ID:           req.ID,
+		Provider:     req.Provider,
+		Endpoint:     req.Endpoint,
+		Method:       req.Method,
+		VirtualKeyID: req.VirtualKeyID,
+		SessionID:    req.SessionID,
+		Tags:         req.Tags,
+		CreatedAt:    req.CreatedAt,
 	}
 
 	event := &EventMessage{
-		Type:    "request_created",
-		Request: item,
+		Type:      "request_created",
+		Request:   item,
+		RequestID: req.ID,
+		Provider:  req.Provider,
 	}
 
 	h.broadcaster.BroadcastEvent(event)
@@ -288,7 +643,148 @@ func (h *Handler) BroadcastResponseCreated(resp *database.Response) {
 			"duration_ms":   resp.DurationMs,
 			"is_error":      resp.IsError,
 			"error_message": resp.ErrorMessage,
+			"cost_usd":      resp.CostUSD,
+			"cancelled":     resp.Cancelled,
+			"timeout":       resp.Timeout,
+		},
+		RequestID: resp.RequestID,
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
+
+// BroadcastSecretDetected broadcasts a secret detected event. Action is
+// "flagged" when the request was forwarded or "blocked" when it was rejected.
+func (h *Handler) BroadcastSecretDetected(requestID, providerName, virtualKeyID, action string, findings []database.SecretFinding) {
+	event := &EventMessage{
+		Type: "secret_detected",
+		Data: map[string]interface{}{
+			"request_id":     requestID,
+			"provider":       providerName,
+			"virtual_key_id": virtualKeyID,
+			"action":         action,
+			"findings":       findings,
+		},
+		RequestID: requestID,
+		Provider:  providerName,
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
+
+// BroadcastModerationFlagged broadcasts a moderation flagged event. Action
+// is "flagged" or "blocked".
+func (h *Handler) BroadcastModerationFlagged(requestID, providerName, virtualKeyID, action string, categories []string) {
+	event := &EventMessage{
+		Type: "moderation_flagged",
+		Data: map[string]interface{}{
+			"request_id":     requestID,
+			"provider":       providerName,
+			"virtual_key_id": virtualKeyID,
+			"action":         action,
+			"categories":     categories,
+		},
+		RequestID: requestID,
+		Provider:  providerName,
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
+
+// BroadcastHighRiskPrompt broadcasts a high risk prompt event. Action is
+// "flagged" or "blocked".
+func (h *Handler) BroadcastHighRiskPrompt(requestID, providerName, virtualKeyID, action string, score float64, rules []string) {
+	event := &EventMessage{
+		Type: "high_risk_prompt",
+		Data: map[string]interface{}{
+			"request_id":     requestID,
+			"provider":       providerName,
+			"virtual_key_id": virtualKeyID,
+			"action":         action,
+			"risk_score":     score,
+			"rules":          rules,
+		},
+		RequestID: requestID,
+		Provider:  providerName,
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
+
+// BroadcastBudgetExceeded broadcasts a budget exceeded event. Scope is "global"
+// or "key", period is "daily" or "monthly".
+func (h *Handler) BroadcastBudgetExceeded(requestID, providerName, scope, virtualKeyID, period string, spentUSD, budgetUSD float64) {
+	event := &EventMessage{
+		Type: "budget_exceeded",
+		Data: map[string]interface{}{
+			"request_id":     requestID,
+			"provider":       providerName,
+			"scope":          scope,
+			"virtual_key_id": virtualKeyID,
+			"period":         period,
+			"spent_usd":      spentUSD,
+			"budget_usd":     budgetUSD,
+		},
+		RequestID: requestID,
+		Provider:  providerName,
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
+
+// BroadcastRequestSlow broadcasts a watchdog alert for a long-running request.
+// Action is "flagged" when it crossed the threshold or "cancelled" when the
+// watchdog aborted it.
+func (h *Handler) BroadcastRequestSlow(requestID, providerName, action string, elapsed time.Duration) {
+	event := &EventMessage{
+		Type: "request_slow",
+		Data: map[string]interface{}{
+			"request_id":      requestID,
+			"provider":        providerName,
+			"action":          action,
+			"elapsed_seconds": int(elapsed.Seconds()),
 		},
+		RequestID: requestID,
+		Provider:  providerName,
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
+
+// BroadcastFineTuneUpdated broadcasts a fine-tuning job that was created or changed status
+func (h *Handler) BroadcastFineTuneUpdated(job *database.FineTuneJob) {
+	event := &EventMessage{
+		Type:      "fine_tune_updated",
+		Data:      job,
+		RequestID: job.RequestID,
+		Provider:  job.Provider,
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
+
+// BroadcastPredictionCompleted broadcasts a prediction that finished
+// upstream after the request that created it was answered
+func (h *Handler) BroadcastPredictionCompleted(requestID, providerName, predictionID, status string) {
+	event := &EventMessage{
+		Type: "prediction_completed",
+		Data: map[string]interface{}{
+			"request_id":    requestID,
+			"prediction_id": predictionID,
+			"status":        status,
+		},
+		RequestID: requestID,
+		Provider:  providerName,
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
+
+// BroadcastUpdateAvailable broadcasts that a newer gateway release was found
+func (h *Handler) BroadcastUpdateAvailable(release *version.Release) {
+	event := &EventMessage{
+		Type: "update_available",
+		Data: release,
 	}
 
 	h.broadcaster.BroadcastEvent(event)
@@ -313,8 +809,13 @@ func getContentTypeFromExt(ext string) string {
 		".pdf":  "application/pdf",
 		".mp3":  "audio/mpeg",
 		".wav":  "audio/wav",
+		".ogg":  "audio/ogg",
+		".flac": "audio/flac",
 		".mp4":  "video/mp4",
 		".mpeg": "video/mpeg",
+		".webm": "video/webm",
+		".mov":  "video/quicktime",
+		".zip":  "application/zip",
 		".txt":  "text/plain",
 		".json": "application/json",
 	}
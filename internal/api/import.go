@@ -0,0 +1,58 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/importer"
+)
+
+// ImportTraffic handles POST /api/import, storing requests from the
+// gateway's JSONL export or a HAR capture. source sets the source of
+// imported requests that have none, and provider the provider of HAR
+// entries. A gzipped body is accepted with Content-Encoding: gzip.
+func (h *Handler) ImportTraffic(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = http.MaxBytesReader(w, r.Body, maxIngestBodySize)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid gzip body")
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	query := r.URL.Query()
+	result, err := importer.Import(h.db, h.fs, body, importer.Options{
+		Source:   query.Get("source"),
+		Provider: query.Get("provider"),
+		Router:   h.router,
+	})
+	if result.Imported > 0 {
+		slog.InfoContext(r.Context(), "imported traffic", "format", result.Format, "requests", result.Imported,
+			"duplicate", result.Duplicate, "files", result.Files)
+		go h.BroadcastRequestsImported(result)
+	}
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// BroadcastRequestsImported broadcasts a requests imported event with the
+// import's counts
+func (h *Handler) BroadcastRequestsImported(result *importer.Result) {
+	event := &EventMessage{
+		Type: "requests_imported",
+		Data: result,
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/federation"
+)
+
+// maxIngestBodySize limits a single federation batch, which may carry file contents
+const maxIngestBodySize = 256 << 20
+
+// SetIngestToken sets the bearer token edge gateways must present to POST /api/ingest.
+// Ingest is refused while no token is set.
+func (h *Handler) SetIngestToken(token string) {
+	h.ingestToken = token
+}
+
+// Ingest handles POST /api/ingest, storing records forwarded by edge gateways
+func (h *Handler) Ingest(w http.ResponseWriter, r *http.Request) {
+	if h.ingestToken == "" {
+		h.writeError(w, http.StatusForbidden, "federation ingest is disabled: set FEDERATION_INGEST_TOKEN")
+		return
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.ingestToken)) != 1 {
+		h.writeError(w, http.StatusUnauthorized, "invalid ingest token")
+		return
+	}
+
+	var batch federation.Batch
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIngestBodySize)).Decode(&batch); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	result, err := federation.Ingest(h.db, h.fs, &batch)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrNotHeld is returned when releasing a response that isn't held
+var ErrNotHeld = errors.New("response is not held")
+
+// HeldResponse is an upstream response held by response interception until
+// it is released
+type HeldResponse struct {
+	RequestID  string            `json:"request_id"`
+	Provider   string            `json:"provider"`
+	Endpoint   string            `json:"endpoint"`
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"` // Decompressed
+	HeldAt     time.Time         `json:"held_at"`
+	ReleaseAt  time.Time         `json:"release_at"` // Released unchanged at this time unless released before
+}
+
+// ResponseEdit changes a held response before it is released. Fields left
+// out are kept as they came from the provider.
+type ResponseEdit struct {
+	StatusCode int               `json:"status_code,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"` // Set these headers; an empty value removes one
+	Body       *string           `json:"body,omitempty"`    // Replaces the body
+}
+
+// Interceptor holds upstream responses for inspection before they reach
+// the client
+type Interceptor interface {
+	InterceptedProviders() []string
+	SetInterceptedProviders(providers []string)
+	HeldResponses() []*HeldResponse
+	ReleaseResponse(requestID string, edit *ResponseEdit) error
+}
+
+// InterceptSettings is the body of GET and PUT /api/intercept
+type InterceptSettings struct {
+	Providers []string `json:"providers"` // Provider names or "*"; empty turns interception off
+}
+
+// SetInterceptor sets the proxy whose held responses the API manages
+func (h *Handler) SetInterceptor(interceptor Interceptor) {
+	h.interceptor = interceptor
+}
+
+// GetIntercept handles GET /api/intercept
+func (h *Handler) GetIntercept(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&InterceptSettings{Providers: h.interceptor.InterceptedProviders()})
+}
+
+// SetIntercept handles PUT /api/intercept. Responses already held stay held
+// when interception is turned off.
+func (h *Handler) SetIntercept(w http.ResponseWriter, r *http.Request) {
+	var settings InterceptSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	h.interceptor.SetInterceptedProviders(settings.Providers)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&InterceptSettings{Providers: h.interceptor.InterceptedProviders()})
+}
+
+// ListHeldResponses handles GET /api/intercept/responses, oldest first
+func (h *Handler) ListHeldResponses(w http.ResponseWriter, r *http.Request) {
+	held := h.interceptor.HeldResponses()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"responses": held,
+		"total":     len(held),
+	})
+}
+
+// ReleaseResponse handles POST /api/intercept/responses/{id}/release, with an
+// optional ResponseEdit as the body
+func (h *Handler) ReleaseResponse(w http.ResponseWriter, r *http.Request) {
+	var edit ResponseEdit
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	if edit.StatusCode != 0 && (edit.StatusCode < 100 || edit.StatusCode > 599) {
+		h.writeError(w, http.StatusBadRequest, "invalid status_code")
+		return
+	}
+
+	if err := h.interceptor.ReleaseResponse(r.PathValue("id"), &edit); err != nil {
+		h.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BroadcastResponseHeld broadcasts a response held event
+func (h *Handler) BroadcastResponseHeld(held *HeldResponse) {
+	event := &EventMessage{
+		Type: "response_held",
+		Data: map[string]interface{}{
+			"request_id":  held.RequestID,
+			"provider":    held.Provider,
+			"endpoint":    held.Endpoint,
+			"status_code": held.StatusCode,
+			"release_at":  held.ReleaseAt,
+		},
+		RequestID: held.RequestID,
+		Provider:  held.Provider,
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
+
+// BroadcastResponseReleased broadcasts a response released event. How is
+// "released", "edited", "timeout" or "client_gone".
+func (h *Handler) BroadcastResponseReleased(requestID, how string) {
+	event := &EventMessage{
+		Type: "response_released",
+		Data: map[string]interface{}{
+			"request_id": requestID,
+			"how":        how,
+		},
+		RequestID: requestID,
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// CreateKeyRequest is the body for POST /api/keys
+type CreateKeyRequest struct {
+	Name string `json:"name"`
+}
+
+// UpdateKeyRequest is the body for PATCH /api/keys/{id}
+type UpdateKeyRequest struct {
+	Name     *string `json:"name"`
+	Disabled *bool   `json:"disabled"`
+	RPMLimit *int    `json:"rpm_limit"` // -1 resets to the gateway default, 0 is unlimited
+	TPMLimit *int    `json:"tpm_limit"`
+	// Budgets in USD: -1 resets to the gateway default, 0 is unlimited
+	DailyBudgetUSD   *float64 `json:"daily_budget_usd"`
+	MonthlyBudgetUSD *float64 `json:"monthly_budget_usd"`
+	AllowOverrides   *bool    `json:"allow_overrides"` // May send X-AIGW-* policy override headers
+}
+
+// CreateKeyResponse includes the plaintext key, which is only ever returned on creation
+type CreateKeyResponse struct {
+	*database.VirtualKey
+	Key string `json:"key"`
+}
+
+// ListKeys handles GET /api/keys
+func (h *Handler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.db.ListVirtualKeys()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": keys,
+	})
+}
+
+// CreateKey handles POST /api/keys
+func (h *Handler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req CreateKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Name == "" {
+		h.writeError(w, http.StatusBadRequest, "missing name")
+		return
+	}
+
+	key, plaintext, err := h.db.CreateVirtualKey(req.Name)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&CreateKeyResponse{VirtualKey: key, Key: plaintext})
+}
+
+// GetKey handles GET /api/keys/{id}
+func (h *Handler) GetKey(w http.ResponseWriter, r *http.Request) {
+	key, err := h.db.GetVirtualKey(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "virtual key not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
+
+// UpdateKey handles PATCH /api/keys/{id}
+func (h *Handler) UpdateKey(w http.ResponseWriter, r *http.Request) {
+	keyID := r.PathValue("id")
+
+	var req UpdateKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	err := h.db.UpdateVirtualKey(keyID, &database.UpdateVirtualKeyInput{
+		Name:             req.Name,
+		Disabled:         req.Disabled,
+		RPMLimit:         req.RPMLimit,
+		TPMLimit:         req.TPMLimit,
+		DailyBudgetUSD:   req.DailyBudgetUSD,
+		MonthlyBudgetUSD: req.MonthlyBudgetUSD,
+		AllowOverrides:   req.AllowOverrides,
+	})
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	key, err := h.db.GetVirtualKey(keyID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
+
+// RevokeKey handles DELETE /api/keys/{id}
+func (h *Handler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	if err := h.db.RevokeVirtualKey(r.PathValue("id")); err != nil {
+		h.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
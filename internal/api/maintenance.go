@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/archive"
+	"github.com/ruqqq/simple-ai-gateway/internal/retention"
+)
+
+// MaintenanceResponse is returned by GET /api/maintenance
+type MaintenanceResponse struct {
+	RetentionDays  float64           `json:"retention_days,omitempty"`
+	MaxDBBytes     int64             `json:"max_db_bytes,omitempty"`
+	MaxFilesBytes  int64             `json:"max_files_bytes,omitempty"`
+	FileQuotaBytes int64             `json:"file_quota_bytes,omitempty"`
+	Scheduled      bool              `json:"scheduled"`            // Purges run on RETENTION_INTERVAL
+	LastPurge      *retention.Report `json:"last_purge,omitempty"` // Latest purge since startup
+	Archive        *ArchiveStatus    `json:"archive,omitempty"`    // With ARCHIVE_BUCKET
+}
+
+// ArchiveStatus describes archival to object storage
+type ArchiveStatus struct {
+	Bucket    string          `json:"bucket"`
+	Prefix    string          `json:"prefix,omitempty"`
+	AfterDays float64         `json:"after_days,omitempty"`
+	Scheduled bool            `json:"scheduled"`          // Runs on ARCHIVE_INTERVAL
+	LastRun   *archive.Report `json:"last_run,omitempty"` // Latest run since startup
+}
+
+// SetJanitor sets the retention janitor run through the API
+func (h *Handler) SetJanitor(janitor *retention.Janitor) {
+	h.janitor = janitor
+}
+
+// SetArchiver sets the archiver that moves old requests to object storage
+// and restores them on demand
+func (h *Handler) SetArchiver(archiver *archive.Archiver) {
+	h.archiver = archiver
+}
+
+// GetMaintenance handles GET /api/maintenance, reporting the retention
+// limits and the latest purge
+func (h *Handler) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	opts := h.janitor.Options()
+	resp := &MaintenanceResponse{
+		RetentionDays:  opts.MaxAge.Hours() / 24,
+		MaxDBBytes:     opts.MaxDBBytes,
+		MaxFilesBytes:  opts.MaxFilesBytes,
+		FileQuotaBytes: opts.FileQuotaBytes,
+		Scheduled:      opts.Enabled(),
+		LastPurge:      h.janitor.LastReport(),
+	}
+	if h.archiver != nil {
+		archiveOpts := h.archiver.Options()
+		resp.Archive = &ArchiveStatus{
+			Bucket:    h.archiver.Bucket(),
+			Prefix:    archiveOpts.Prefix,
+			AfterDays: archiveOpts.After.Hours() / 24,
+			Scheduled: archiveOpts.After > 0,
+			LastRun:   h.archiver.LastReport(),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// PurgeNow handles POST /api/maintenance/purge, applying the retention
+// limits and removing orphaned files right away
+func (h *Handler) PurgeNow(w http.ResponseWriter, r *http.Request) {
+	report := h.janitor.Purge("manual")
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Error != "" {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// ArchiveNow handles POST /api/maintenance/archive, moving the requests past
+// ARCHIVE_AFTER_DAYS to object storage right away
+func (h *Handler) ArchiveNow(w http.ResponseWriter, r *http.Request) {
+	if h.archiver == nil {
+		h.writeError(w, http.StatusNotFound, "archiving is not configured (set ARCHIVE_BUCKET)")
+		return
+	}
+	report := h.archiver.Archive(r.Context(), "manual")
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Error != "" {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// BroadcastPurged broadcasts a requests deleted event after a retention purge
+func (h *Handler) BroadcastPurged(report *retention.Report) {
+	h.BroadcastRequestsDeleted(nil)
+}
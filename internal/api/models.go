@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/ruqqq/simple-ai-gateway/internal/database"
@@ -8,26 +9,55 @@ import (
 
 // RequestListItem represents a request in the list view
 type RequestListItem struct {
-	ID           string    `json:"id"`
-	Provider     string    `json:"provider"`
-	Endpoint     string    `json:"endpoint"`
-	Method       string    `json:"method"`
-	CreatedAt    time.Time `json:"created_at"`
-	Status       int       `json:"status,omitempty"`        // From response if available
-	IsError      bool      `json:"is_error,omitempty"`      // True if response indicates error
-	ErrorMessage string    `json:"error_message,omitempty"` // Error message if available
+	ID           string     `json:"id"`
+	Provider     string     `json:"provider"`
+	Endpoint     string     `json:"endpoint"`
+	Method       string     `json:"method"`
+	VirtualKeyID string     `json:"virtual_key_id,omitempty"`
+	Source       string     `json:"source,omitempty"`     // Edge gateway for federated records
+	RiskScore    *float64   `json:"risk_score,omitempty"` // Prompt injection risk, with guardrails on
+	SessionID    string     `json:"session_id,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	Starred      bool       `json:"starred,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"` // Deleted after the as_of time of the listing
+	Status       int        `json:"status,omitempty"`     // From response if available
+	DurationMs   int        `json:"duration_ms,omitempty"`
+	InputTokens  int        `json:"input_tokens,omitempty"`
+	OutputTokens int        `json:"output_tokens,omitempty"`
+	CostUSD      *float64   `json:"cost_usd,omitempty"`
+	IsError      bool       `json:"is_error,omitempty"`      // True if response indicates error
+	ErrorMessage string     `json:"error_message,omitempty"` // Error message if available
+	Cancelled    bool       `json:"cancelled,omitempty"`     // The client disconnected before the response finished
+	Timeout      string     `json:"timeout,omitempty"`       // Upstream timeout that ended the call
+	ImagePath    string     `json:"image_path,omitempty"`    // First stored image, for a preview via /api/files/{path}?thumb=1
 }
 
 // ResponseDetail represents a response with details
 type ResponseDetail struct {
-	ID           string            `json:"id"`
-	StatusCode   int               `json:"status_code"`
-	Headers      map[string]string `json:"headers"`
-	Body         string            `json:"body"`
-	DurationMs   int               `json:"duration_ms"`
-	IsError      bool              `json:"is_error"`
-	ErrorMessage *string           `json:"error_message,omitempty"`
-	CreatedAt    time.Time         `json:"created_at"`
+	ID              string            `json:"id"`
+	StatusCode      int               `json:"status_code"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	DurationMs      int               `json:"duration_ms"`
+	IsError         bool              `json:"is_error"`
+	ErrorMessage    *string           `json:"error_message,omitempty"`
+	Model           string            `json:"model,omitempty"`
+	InputTokens     int               `json:"input_tokens,omitempty"`
+	OutputTokens    int               `json:"output_tokens,omitempty"`
+	CachedTokens    int               `json:"cached_tokens,omitempty"`
+	ReasoningTokens int               `json:"reasoning_tokens,omitempty"`
+	CostUSD         *float64          `json:"cost_usd,omitempty"`
+	Cached          bool              `json:"cached,omitempty"`
+	Stale           bool              `json:"stale,omitempty"`
+	Message         json.RawMessage   `json:"message,omitempty"` // Assembled message of a streamed response
+	FinishReason    string            `json:"finish_reason,omitempty"`
+	Warnings        json.RawMessage   `json:"warnings,omitempty"`    // Deprecation notices and warnings from the provider
+	TTFTMs          *int              `json:"ttft_ms,omitempty"`     // Streamed responses: time to the first body byte
+	Cancelled       bool              `json:"cancelled,omitempty"`   // The client disconnected before the response finished
+	Timeout         string            `json:"timeout,omitempty"`     // Upstream timeout that ended the call: connect, read, total or watchdog
+	EditedFrom      string            `json:"edited_from,omitempty"` // Upstream response this one replaced while held by response interception
+	CreatedAt       time.Time         `json:"created_at"`
 }
 
 // BinaryFileDetail represents a binary file reference
@@ -40,16 +70,27 @@ type BinaryFileDetail struct {
 
 // RequestDetail represents full request details with response and binary files
 type RequestDetail struct {
-	Request      *database.Request  `json:"request"`
-	Response     *ResponseDetail    `json:"response,omitempty"`
-	BinaryFiles  []*BinaryFileDetail `json:"binary_files,omitempty"`
+	Request       *database.Request   `json:"request"`
+	Response      *ResponseDetail     `json:"response,omitempty"`
+	Hops          []*ResponseDetail   `json:"hops,omitempty"` // Intermediate responses: followed redirects and retried attempts
+	BinaryFiles   []*BinaryFileDetail `json:"binary_files,omitempty"`
+	FollowUps     []string            `json:"follow_ups,omitempty"`    // Requests the gateway sent to continue this one
+	Mirrors       []string            `json:"mirrors,omitempty"`       // Copies mirrored to other providers
+	Revalidations []string            `json:"revalidations,omitempty"` // Background refreshes of the stale cached response it was served
+	Notes         []*database.Note    `json:"notes,omitempty"`         // Reviewer notes, oldest first
 }
 
 // EventMessage represents an SSE event
 type EventMessage struct {
-	Type    string        `json:"type"` // "request_created", "response_created"
+	Type    string           `json:"type"` // "request_created", "response_created", "events_missed", ...
 	Request *RequestListItem `json:"request,omitempty"`
-	Data    interface{}   `json:"data,omitempty"`
+	Data    interface{}      `json:"data,omitempty"`
+
+	// The request and provider the event is about, if any, for subscriptions
+	// filtered by provider. The provider of a request is remembered from its
+	// request_created event, so events about it needn't repeat it.
+	RequestID string `json:"-"`
+	Provider  string `json:"-"`
 }
 
 // ListRequestsRequest represents query parameters for listing requests
@@ -70,9 +111,10 @@ type ListRequestsResponse struct {
 
 // StatsResponse represents statistics about requests
 type StatsResponse struct {
-	TotalRequests      int                 `json:"total_requests"`
-	RequestsByProvider map[string]int      `json:"requests_by_provider"`
-	RequestsByStatus   map[int]int         `json:"requests_by_status"`
+	TotalRequests      int                            `json:"total_requests"`
+	RequestsByProvider map[string]int                 `json:"requests_by_provider"`
+	RequestsByStatus   map[int]int                    `json:"requests_by_status"`
+	TTFTByProvider     map[string]*database.TTFTStats `json:"ttft_by_provider"` // Streamed responses
 }
 
 // ErrorResponse represents an error response
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/auth"
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// maxNoteLength is the longest note accepted, in bytes
+const maxNoteLength = 10000
+
+// AddNoteRequest is the body of POST /api/requests/{id}/notes
+type AddNoteRequest struct {
+	Body string `json:"body"`
+}
+
+// AddNote handles POST /api/requests/{id}/notes. The note's author is the
+// logged-in management user, if login is on.
+func (h *Handler) AddNote(w http.ResponseWriter, r *http.Request) {
+	requestID := r.PathValue("id")
+
+	var req AddNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		h.writeError(w, http.StatusBadRequest, "body is required")
+		return
+	}
+	if len(body) > maxNoteLength {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("body is longer than %d bytes", maxNoteLength))
+		return
+	}
+
+	if _, err := h.db.GetRequest(requestID); err != nil {
+		h.writeError(w, http.StatusNotFound, "request not found")
+		return
+	}
+	var author string
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+		author = identity.String()
+	}
+	note, err := h.db.AddNote(requestID, author, body)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	go h.BroadcastNoteAdded(note)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(note)
+}
+
+// ListNotes handles GET /api/requests/{id}/notes, oldest first
+func (h *Handler) ListNotes(w http.ResponseWriter, r *http.Request) {
+	notes, err := h.db.ListNotes(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if notes == nil {
+		notes = []*database.Note{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notes": notes,
+		"total": len(notes),
+	})
+}
+
+// BroadcastNoteAdded broadcasts a note added event
+func (h *Handler) BroadcastNoteAdded(note *database.Note) {
+	event := &EventMessage{
+		Type: "note_added",
+		Data: map[string]interface{}{
+			"request_id": note.RequestID,
+			"note_id":    note.ID,
+			"author":     note.Author,
+		},
+		RequestID: note.RequestID,
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
@@ -0,0 +1,306 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// textContentType is the content type of reassembled completion text
+const textContentType = "text/plain; charset=utf-8"
+
+// OutputResponse is the JSON form of a request's output, served when the
+// client prefers application/json to the artifact itself
+type OutputResponse struct {
+	RequestID   string `json:"request_id"`
+	ContentType string `json:"content_type"`       // Of the artifact
+	Text        string `json:"text,omitempty"`     // Completion text
+	FileURL     string `json:"file_url,omitempty"` // Stored file, for binary artifacts
+	Size        int64  `json:"size,omitempty"`
+}
+
+// GetOutput handles GET /api/requests/{id}/output: the primary artifact of a
+// request, in order of preference the first stored file (an image or audio),
+// the completion text reassembled from the response (streamed or not), or the
+// response body as it is. The Accept header picks between the artifact and
+// its JSON description; 406 if neither is acceptable.
+func (h *Handler) GetOutput(w http.ResponseWriter, r *http.Request) {
+	requestID := r.PathValue("id")
+	if _, err := h.db.GetRequest(requestID); err != nil {
+		h.writeError(w, http.StatusNotFound, "request not found")
+		return
+	}
+	resp, err := h.db.GetResponseByRequestID(requestID)
+	if err != nil || resp == nil {
+		h.writeError(w, http.StatusNotFound, "request has no response yet")
+		return
+	}
+	if resp.IsError || resp.StatusCode >= 400 {
+		h.writeError(w, http.StatusNotFound, "request has no output: its response is an error")
+		return
+	}
+	w.Header().Set("Vary", "Accept")
+
+	files, err := h.db.GetBinaryFilesByRequestID(requestID)
+	if err == nil && len(files) > 0 {
+		file := files[0]
+		switch negotiate(r.Header.Get("Accept"), file.ContentType, "application/json") {
+		case "application/json":
+			h.writeOutput(w, &OutputResponse{RequestID: requestID, ContentType: file.ContentType, FileURL: "/api/files/" + file.FilePath, Size: file.Size})
+		case "":
+			h.writeError(w, http.StatusNotAcceptable, "output is "+file.ContentType)
+		default:
+			if err := h.serveFile(w, r, file.FilePath, file.ContentType, ""); err != nil {
+				h.writeFileError(w, err, "output file not found")
+			}
+		}
+		return
+	}
+
+	if text, ok := responseText(resp); ok {
+		switch negotiate(r.Header.Get("Accept"), textContentType, "application/json") {
+		case "application/json":
+			h.writeOutput(w, &OutputResponse{RequestID: requestID, ContentType: textContentType, Text: text})
+		case "":
+			h.writeError(w, http.StatusNotAcceptable, "output is "+textContentType)
+		default:
+			w.Header().Set("Content-Type", textContentType)
+			w.Write([]byte(text))
+		}
+		return
+	}
+
+	contentType := resp.Headers["Content-Type"]
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if negotiate(r.Header.Get("Accept"), contentType) == "" {
+		h.writeError(w, http.StatusNotAcceptable, "output is "+contentType)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+	w.Write([]byte(resp.Body))
+}
+
+func (h *Handler) writeOutput(w http.ResponseWriter, output *OutputResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(output)
+}
+
+// negotiate returns the offered content type the Accept header rates highest,
+// the first offer if the header is empty, or "" if none is acceptable. Ties
+// go to the earlier offer.
+func negotiate(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the q-value the most specific matching media range of
+// the Accept header gives contentType, or 0 if none matches
+func acceptQuality(accept, contentType string) float64 {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return 0
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		s := -1
+		switch mediaRange {
+		case mediaType:
+			s = 2
+		case major + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s <= specificity {
+			continue
+		}
+		specificity, quality = s, 1
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+	}
+	return quality
+}
+
+// textPart is a content part carrying text (Anthropic messages, OpenAI
+// Responses API output items)
+type textPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// outputEnvelope covers the response bodies and stream chunks completion
+// text is read from: OpenAI chat and legacy completions, the Responses API,
+// Anthropic messages, Gemini and Replicate predictions
+type outputEnvelope struct {
+	Choices []struct {
+		Message *struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Delta *struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		Text string `json:"text"`
+	} `json:"choices"`
+	Content    []textPart      `json:"content"`
+	Output     json.RawMessage `json:"output"`
+	Candidates []struct {
+		Content struct {
+			Parts []textPart `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+
+	// Stream events: a text string (Responses API) or object (Anthropic)
+	Type  string          `json:"type"`
+	Delta json.RawMessage `json:"delta"`
+}
+
+// responseText returns the completion text of a response, from the message
+// assembled when a streamed response was stored if there is one
+func responseText(resp *database.Response) (string, bool) {
+	var message struct {
+		Content string `json:"content"`
+	}
+	if len(resp.Message) > 0 && json.Unmarshal(resp.Message, &message) == nil && message.Content != "" {
+		return message.Content, true
+	}
+	return completionText(resp.Body)
+}
+
+// completionText reassembles the completion text of a JSON response body or
+// a server-sent event stream. Only the first choice of multi-choice responses
+// is used. It returns false if the body carries no text.
+func completionText(body string) (string, bool) {
+	trimmed := strings.TrimSpace(body)
+	if strings.HasPrefix(trimmed, "{") {
+		var env outputEnvelope
+		if err := json.Unmarshal([]byte(trimmed), &env); err != nil {
+			return "", false
+		}
+		return env.text()
+	}
+
+	var text strings.Builder
+	found := false
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var env outputEnvelope
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &env); err != nil {
+			continue
+		}
+		// Only deltas count: streams that end with the full message would double it
+		if chunk, ok := env.deltaText(); ok && chunk != "" {
+			text.WriteString(chunk)
+			found = true
+		}
+	}
+	return text.String(), found
+}
+
+// text returns the text of a complete response body
+func (env *outputEnvelope) text() (string, bool) {
+	if len(env.Choices) > 0 {
+		// Messages with only tool calls have no text
+		if choice := env.Choices[0]; choice.Message != nil {
+			return choice.Message.Content, choice.Message.Content != ""
+		} else if choice.Text != "" {
+			return choice.Text, true
+		}
+	}
+	if len(env.Candidates) > 0 {
+		return joinText(env.Candidates[0].Content.Parts)
+	}
+	if len(env.Content) > 0 {
+		return joinText(env.Content)
+	}
+	if len(env.Output) == 0 {
+		return "", false
+	}
+
+	// Replicate: a string, or a list of tokens; the Responses API: output items
+	var output string
+	if json.Unmarshal(env.Output, &output) == nil {
+		return output, true
+	}
+	var tokens []string
+	if json.Unmarshal(env.Output, &tokens) == nil {
+		return strings.Join(tokens, ""), len(tokens) > 0
+	}
+	var items []struct {
+		Content []textPart `json:"content"`
+	}
+	if json.Unmarshal(env.Output, &items) == nil {
+		var parts []textPart
+		for _, item := range items {
+			parts = append(parts, item.Content...)
+		}
+		return joinText(parts)
+	}
+	return "", false
+}
+
+// deltaText returns the text a stream chunk adds
+func (env *outputEnvelope) deltaText() (string, bool) {
+	if len(env.Choices) > 0 {
+		if choice := env.Choices[0]; choice.Delta != nil {
+			return choice.Delta.Content, true
+		} else if choice.Text != "" {
+			return choice.Text, true
+		}
+	}
+	if len(env.Candidates) > 0 {
+		return joinText(env.Candidates[0].Content.Parts)
+	}
+	if len(env.Delta) == 0 {
+		return "", false
+	}
+	var delta string
+	if env.Type == "response.output_text.delta" && json.Unmarshal(env.Delta, &delta) == nil {
+		return delta, true
+	}
+	var part textPart
+	if env.Type == "content_block_delta" && json.Unmarshal(env.Delta, &part) == nil && part.Type == "text_delta" {
+		return part.Text, true
+	}
+	return "", false
+}
+
+// joinText concatenates the text parts of a response
+func joinText(parts []textPart) (string, bool) {
+	var text strings.Builder
+	found := false
+	for _, part := range parts {
+		if part.Type == "" || part.Type == "text" || part.Type == "output_text" {
+			text.WriteString(part.Text)
+			found = true
+		}
+	}
+	return text.String(), found
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// PauseState reports whether the proxy is paused
+type PauseState struct {
+	Paused bool       `json:"paused"`
+	Mode   string     `json:"mode,omitempty"`  // "queue" or "reject"
+	Since  *time.Time `json:"since,omitempty"` // When the proxy was paused
+	Queued int        `json:"queued"`          // Requests waiting for resume
+}
+
+// PauseController holds or rejects new proxy requests while paused
+type PauseController interface {
+	PauseStatus() *PauseState
+	Pause(mode string) (*PauseState, error)
+	Resume() *PauseState
+}
+
+// PauseRequest is the optional body of POST /api/proxy/pause
+type PauseRequest struct {
+	Mode string `json:"mode,omitempty"` // "queue" or "reject" (default: PAUSE_MODE)
+}
+
+// SetPauseController sets the proxy paused and resumed through the API
+func (h *Handler) SetPauseController(controller PauseController) {
+	h.pause = controller
+}
+
+// GetPause handles GET /api/proxy/pause
+func (h *Handler) GetPause(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.pause.PauseStatus())
+}
+
+// PauseProxy handles POST /api/proxy/pause. Requests already forwarded
+// upstream are not affected.
+func (h *Handler) PauseProxy(w http.ResponseWriter, r *http.Request) {
+	var req PauseRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	state, err := h.pause.Pause(req.Mode)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// ResumeProxy handles POST /api/proxy/resume, releasing queued requests
+func (h *Handler) ResumeProxy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.pause.Resume())
+}
+
+// BroadcastProxyPaused broadcasts a proxy paused event
+func (h *Handler) BroadcastProxyPaused(mode string) {
+	event := &EventMessage{
+		Type: "proxy_paused",
+		Data: map[string]interface{}{
+			"mode": mode,
+		},
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
+
+// BroadcastProxyResumed broadcasts a proxy resumed event with the number of
+// queued requests released and how long the proxy was paused
+func (h *Handler) BroadcastProxyResumed(released int, pausedFor time.Duration) {
+	event := &EventMessage{
+		Type: "proxy_resumed",
+		Data: map[string]interface{}{
+			"released":       released,
+			"paused_seconds": int64(pausedFor.Seconds()),
+		},
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// DeleteRequest handles DELETE /api/requests/{id}, removing the request with
+// its responses and stored files. With soft=true the request is only marked
+// deleted and kept for as_of listings.
+func (h *Handler) DeleteRequest(w http.ResponseWriter, r *http.Request) {
+	requestID := r.PathValue("id")
+	if _, err := h.db.GetRequest(requestID); err != nil {
+		h.writeError(w, http.StatusNotFound, "request not found")
+		return
+	}
+
+	if r.URL.Query().Get("soft") == "true" {
+		if _, err := h.db.MarkRequestsDeleted([]string{requestID}, time.Now()); err != nil {
+			h.writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		go h.BroadcastRequestsDeleted([]string{requestID})
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	result, err := h.purge(&database.PurgeFilter{IDs: []string{requestID}})
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// PurgeRequests handles DELETE /api/requests, removing the requests matching
+// the provider, older_than and before filters with their responses and
+// stored files. Without any filter, all=true is required.
+func (h *Handler) PurgeRequests(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := &database.PurgeFilter{Provider: query.Get("provider")}
+
+	if olderThan := query.Get("older_than"); olderThan != "" {
+		age, err := parseAge(olderThan)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid older_than (expected a duration like 30d or 12h)")
+			return
+		}
+		filter.Before = time.Now().Add(-age)
+	}
+	if before := query.Get("before"); before != "" {
+		ts, err := parseTimestamp(before)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid before (expected Unix seconds or RFC 3339)")
+			return
+		}
+		if filter.Before.IsZero() || ts.Before(filter.Before) {
+			filter.Before = ts
+		}
+	}
+	if filter.Provider == "" && filter.Before.IsZero() && query.Get("all") != "true" {
+		h.writeError(w, http.StatusBadRequest, "refusing to delete every request without all=true")
+		return
+	}
+
+	result, err := h.purge(filter)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// purge removes the selected requests and their stored files
+func (h *Handler) purge(filter *database.PurgeFilter) (*database.PurgeResult, error) {
+	result, err := h.db.PurgeRequests(filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range result.FilePaths {
+		if err := h.fs.DeleteFile(file); err != nil {
+			slog.Warn("failed to delete purged file", "file", file, "error", err)
+		}
+	}
+	if result.Requests > 0 {
+		slog.Info("purged requests", "requests", result.Requests, "responses", result.Responses, "files", result.Files)
+		go h.BroadcastRequestsDeleted(filter.IDs)
+	}
+	return result, nil
+}
+
+// parseAge parses a Go duration, or a number of days like 30d
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q", value)
+	}
+	return age, nil
+}
+
+// BroadcastRequestsDeleted broadcasts a requests deleted event with the IDs
+// of the deleted requests, or none after a bulk purge
+func (h *Handler) BroadcastRequestsDeleted(ids []string) {
+	event := &EventMessage{
+		Type: "requests_deleted",
+		Data: map[string]interface{}{
+			"request_ids": ids,
+		},
+	}
+
+	h.broadcaster.BroadcastEvent(event)
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/router"
+)
+
+// RouteMatchRequest describes a hypothetical request to evaluate against the routing rules
+type RouteMatchRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Model   string            `json:"model"`
+	Key     string            `json:"key"`
+	Headers map[string]string `json:"headers"`
+}
+
+// RouteMatchResponse describes which rule and provider a request would be routed to
+type RouteMatchResponse struct {
+	Matched  bool           `json:"matched"`
+	Provider string         `json:"provider,omitempty"`
+	Path     string         `json:"path,omitempty"`
+	Rule     *router.Rule   `json:"rule,omitempty"`
+	Target   *router.Target `json:"target,omitempty"`
+	Mirror   string         `json:"mirror,omitempty"` // Provider a copy would be mirrored to
+	Error    string         `json:"error,omitempty"`
+}
+
+// SetRouter sets the router used by the routing inspection endpoints
+func (h *Handler) SetRouter(rt *router.Router) {
+	h.router = rt
+}
+
+// ListRoutes handles GET /api/routes
+func (h *Handler) ListRoutes(w http.ResponseWriter, r *http.Request) {
+	if h.router == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "router not configured")
+		return
+	}
+
+	providers := make([]string, 0, len(h.router.Providers()))
+	for _, p := range h.router.Providers() {
+		providers = append(providers, p.Name())
+	}
+
+	rules := h.router.Rules()
+	if rules == nil {
+		rules = []*router.Rule{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules":     rules,
+		"providers": providers,
+	})
+}
+
+// MatchRoute handles POST /api/routes/match
+func (h *Handler) MatchRoute(w http.ResponseWriter, r *http.Request) {
+	if h.router == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "router not configured")
+		return
+	}
+
+	var req RouteMatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Path == "" {
+		h.writeError(w, http.StatusBadRequest, "missing path")
+		return
+	}
+	if req.Method == "" {
+		req.Method = http.MethodPost
+	}
+
+	headers := http.Header{}
+	for key, value := range req.Headers {
+		headers.Set(key, value)
+	}
+
+	result := &RouteMatchResponse{}
+	decision, err := h.router.Match(&router.MatchInput{
+		Method:  req.Method,
+		Path:    req.Path,
+		Model:   req.Model,
+		Key:     req.Key,
+		Headers: headers,
+	})
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Matched = true
+		result.Provider = decision.Provider.Name()
+		result.Path = decision.Path
+		result.Rule = decision.Rule
+		result.Target = decision.Target
+		if decision.Mirror != nil {
+			result.Mirror = decision.Mirror.Provider.Name()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/sink"
+)
+
+const (
+	// sinkBuffer is how many events are queued per sink before new ones are dropped
+	sinkBuffer = 1000
+	// sinkDrainTimeout bounds publishing the queued events on shutdown
+	sinkDrainTimeout = 5 * time.Second
+)
+
+// AddSink forwards every broadcast event to s, published as its JSON encoding
+// with the event type as topic. Each sink publishes from its own queue, so a
+// slow or unreachable sink never holds up SSE clients or other sinks; events
+// are dropped while the queue is full. Sinks should be added at startup,
+// before events are broadcast.
+func (b *SSEBroadcaster) AddSink(name string, s sink.Sink) {
+	q := sink.NewQueue(name, s, sinkBuffer)
+
+	b.mu.Lock()
+	b.sinks = append(b.sinks, q)
+	b.mu.Unlock()
+}
+
+// deliverToSinks queues an event for every sink. Must be called with b.mu held.
+func (b *SSEBroadcaster) deliverToSinks(event *EventMessage) {
+	if len(b.sinks) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("failed to encode event for sinks", "type", event.Type, "error", err)
+		return
+	}
+	for _, q := range b.sinks {
+		q.Enqueue(event.Type, payload)
+	}
+}
+
+// closeSinks waits up to timeout for the queued events to be published and
+// closes the sinks
+func (b *SSEBroadcaster) closeSinks(timeout time.Duration) {
+	b.mu.Lock()
+	sinks := b.sinks
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, q := range sinks {
+		if err := q.Close(ctx); err != nil {
+			slog.Warn("failed to close event sink", "error", err)
+		}
+	}
+}
+
+// SinkDroppedEvents returns how many events were dropped because a sink's
+// queue was full
+func (b *SSEBroadcaster) SinkDroppedEvents() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var n int64
+	for _, q := range b.sinks {
+		n += q.Dropped()
+	}
+	return n
+}
+
+// SinkFailedEvents returns how many events a sink failed to publish
+func (b *SSEBroadcaster) SinkFailedEvents() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var n int64
+	for _, q := range b.sinks {
+		n += q.Failed()
+	}
+	return n
+}
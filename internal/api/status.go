@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/keypool"
+)
+
+// StatusSource reports instantaneous proxy load
+type StatusSource interface {
+	InflightCount() int
+	InflightByProvider() map[string]int
+	ConcurrencyLimits() map[string]int          // nil unless adaptive concurrency is enabled
+	APIKeyStats() map[string][]keypool.KeyStats // nil unless a provider has several API keys
+}
+
+// StatusResponse represents the instantaneous gateway load
+type StatusResponse struct {
+	InFlight           int                           `json:"in_flight"`
+	InFlightByProvider map[string]int                `json:"in_flight_by_provider"`
+	ConcurrencyLimits  map[string]int                `json:"concurrency_limits,omitempty"`
+	APIKeys            map[string][]keypool.KeyStats `json:"api_keys,omitempty"`
+	SSEClients         int                           `json:"sse_clients"`
+	SSEDroppedEvents   int64                         `json:"sse_dropped_events"`
+	UptimeSeconds      int64                         `json:"uptime_seconds"`
+}
+
+// SetStatusSource sets the source of in-flight gauges for GET /api/status
+func (h *Handler) SetStatusSource(src StatusSource) {
+	h.statusSource = src
+}
+
+// GetStatus handles GET /api/status
+func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	status := &StatusResponse{
+		InFlightByProvider: make(map[string]int),
+		SSEClients:         h.broadcaster.ClientCount(),
+		SSEDroppedEvents:   h.broadcaster.DroppedEvents(),
+		UptimeSeconds:      int64(time.Since(h.startedAt).Seconds()),
+	}
+
+	if h.statusSource != nil {
+		status.InFlight = h.statusSource.InflightCount()
+		status.InFlightByProvider = h.statusSource.InflightByProvider()
+		status.ConcurrencyLimits = h.statusSource.ConcurrencyLimits()
+		status.APIKeys = h.statusSource.APIKeyStats()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
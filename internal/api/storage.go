@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// StorageStatsResponse is returned by GET /api/storage/stats
+type StorageStatsResponse struct {
+	Files      int                            `json:"files"`
+	Bytes      int64                          `json:"bytes"`
+	QuotaBytes int64                          `json:"quota_bytes,omitempty"` // FILE_QUOTA_GB
+	ByProvider map[string]*database.FileUsage `json:"by_provider"`
+	ByDay      map[string]*database.FileUsage `json:"by_day"` // UTC days, YYYY-MM-DD
+}
+
+// GetStorageStats handles GET /api/storage/stats, reporting the stored
+// binary files on record
+func (h *Handler) GetStorageStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.db.GetStorageStats()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp := &StorageStatsResponse{
+		Files:      stats.Files,
+		Bytes:      stats.Bytes,
+		QuotaBytes: h.janitor.Options().FileQuotaBytes,
+		ByProvider: stats.ByProvider,
+		ByDay:      stats.ByDay,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
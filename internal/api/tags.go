@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// UpdateRequestRequest is the body of PATCH /api/requests/{id}; nil fields
+// are left unchanged
+type UpdateRequestRequest struct {
+	Starred *bool `json:"starred,omitempty"`
+}
+
+// UpdateRequest handles PATCH /api/requests/{id}, returning the updated request
+func (h *Handler) UpdateRequest(w http.ResponseWriter, r *http.Request) {
+	requestID := r.PathValue("id")
+
+	var req UpdateRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Starred != nil {
+		if err := h.db.SetRequestStarred(requestID, *req.Starred); err != nil {
+			h.writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+	}
+
+	stored, err := h.db.GetRequest(requestID)
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "request not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stored)
+}
+
+// UpdateTagsRequest is the body of PATCH /api/requests/{id}/tags
+type UpdateTagsRequest struct {
+	Add    []string `json:"add,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+// UpdateTags handles PATCH /api/requests/{id}/tags, returning the request's
+// tags after the change
+func (h *Handler) UpdateTags(w http.ResponseWriter, r *http.Request) {
+	requestID := r.PathValue("id")
+
+	var req UpdateTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	add, err := database.NormalizeTags(req.Add)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	remove, _ := database.NormalizeTags(req.Remove)
+
+	if _, err := h.db.GetRequest(requestID); err != nil {
+		h.writeError(w, http.StatusNotFound, "request not found")
+		return
+	}
+	tags, err := h.db.UpdateRequestTags(requestID, add, remove)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"request_id": requestID,
+		"tags":       tags,
+	})
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/version"
+)
+
+// VersionResponse represents the gateway build and the latest update check
+type VersionResponse struct {
+	version.Info
+	Update *version.Update `json:"update,omitempty"` // Absent unless update checks are enabled
+}
+
+// SetVersion sets the build information and optional update checker for
+// GET /api/version
+func (h *Handler) SetVersion(info version.Info, checker *version.Checker) {
+	h.version = info
+	h.updateChecker = checker
+}
+
+// GetVersion handles GET /api/version
+func (h *Handler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	resp := &VersionResponse{Info: h.version}
+	if h.updateChecker != nil {
+		resp.Update = h.updateChecker.Last()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
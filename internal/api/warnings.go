@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// GetWarnings handles GET /api/warnings, returning the deprecation notices
+// and warnings providers attached to responses, aggregated by provider, model
+// and warning. Accepts provider, model, date_from and date_to (Unix seconds)
+// filters.
+func (h *Handler) GetWarnings(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	params := &database.WarningSummaryParams{
+		Provider: query.Get("provider"),
+		Model:    query.Get("model"),
+	}
+	if ts, err := strconv.ParseInt(query.Get("date_from"), 10, 64); err == nil {
+		params.DateFrom = time.Unix(ts, 0)
+	}
+	if ts, err := strconv.ParseInt(query.Get("date_to"), 10, 64); err == nil {
+		params.DateTo = time.Unix(ts, 0)
+	}
+
+	rows, err := h.db.WarningSummary(params)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"warnings": rows,
+	})
+}
@@ -0,0 +1,314 @@
+// Package archive moves aged traffic to S3-compatible object storage,
+// leaving stub rows in the database that are restored on demand.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/federation"
+	"github.com/ruqqq/simple-ai-gateway/internal/s3"
+	"github.com/ruqqq/simple-ai-gateway/internal/storage"
+)
+
+const (
+	// batchSize is how many requests are looked up at a time while archiving
+	batchSize = 100
+
+	// restoreGrace is how long a restored request stays in full before it
+	// is archived again
+	restoreGrace = 24 * time.Hour
+)
+
+// errUnreadableFile is returned for requests with a stored file that exists
+// but couldn't be read; they are left in place for a later run
+var errUnreadableFile = errors.New("stored file couldn't be read")
+
+// Options configures an Archiver
+type Options struct {
+	Interval time.Duration // Time between scheduled runs
+	After    time.Duration // Requests older than this are archived; 0 only restores archived requests
+	Prefix   string        // Prepended to object keys
+}
+
+// Report describes what an archive run moved
+type Report struct {
+	Trigger    string    `json:"trigger"` // "schedule" or "manual"
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Requests   int       `json:"requests"`
+	Files      int       `json:"files"`
+	Skipped    int       `json:"skipped,omitempty"` // Requests left in place because a file couldn't be read
+	Bytes      int64     `json:"bytes"`             // Compressed size of the uploaded records
+	Error      string    `json:"error,omitempty"`
+}
+
+// Archiver uploads each aged request with its responses and stored files as
+// a gzipped JSON record (the federation record format), then drops its
+// payloads locally
+type Archiver struct {
+	db    *database.DB
+	fs    storage.FileStorage
+	store *s3.Client
+	opts  Options
+
+	mu        sync.Mutex // Held while archiving
+	restoreMu sync.Mutex // Held while restoring
+	last      *Report
+}
+
+// New creates an archiver
+func New(db *database.DB, fs storage.FileStorage, store *s3.Client, opts Options) *Archiver {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	return &Archiver{db: db, fs: fs, store: store, opts: opts}
+}
+
+// Options returns the archiver's settings
+func (a *Archiver) Options() Options {
+	return a.opts
+}
+
+// Bucket returns the bucket records are archived to
+func (a *Archiver) Bucket() string {
+	return a.store.Bucket()
+}
+
+// LastReport returns the report of the latest run, or nil before the first
+func (a *Archiver) LastReport() *Report {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.last
+}
+
+// Run archives on every interval until ctx is done, starting right away
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		a.Archive(ctx, "schedule")
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Archive moves the requests older than the configured age to object
+// storage now. A run that fails part way reports what it moved before the
+// error.
+func (a *Archiver) Archive(ctx context.Context, trigger string) *Report {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	report := &Report{Trigger: trigger, StartedAt: time.Now().UTC()}
+	if a.opts.After > 0 {
+		if err := a.archive(ctx, report); err != nil {
+			slog.Error("archiving failed", "error", err)
+			report.Error = err.Error()
+		}
+	}
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+	a.last = report
+
+	if report.Requests > 0 {
+		slog.Info("archived requests", "trigger", trigger, "requests", report.Requests, "files", report.Files, "bytes", report.Bytes)
+	}
+	return report
+}
+
+func (a *Archiver) archive(ctx context.Context, report *Report) error {
+	now := time.Now()
+	skipped := make(map[string]bool)
+	for ctx.Err() == nil {
+		// Skipped requests are still archivable, so they come back first
+		requests, err := a.db.ListArchivable(now.Add(-a.opts.After), now.Add(-restoreGrace), batchSize+len(skipped))
+		if err != nil {
+			return err
+		}
+		tried := 0
+		for _, req := range requests {
+			if skipped[req.ID] {
+				continue
+			}
+			tried++
+			err := a.archiveRequest(ctx, req, report)
+			if errors.Is(err, errUnreadableFile) {
+				slog.Warn("not archiving request", "request_id", req.ID, "error", err)
+				skipped[req.ID] = true
+				report.Skipped++
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("request %s: %w", req.ID, err)
+			}
+		}
+		if tried == 0 {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+// archiveRequest uploads a request's record, unless it was archived before
+// and restored since, and drops its payloads and files locally
+func (a *Archiver) archiveRequest(ctx context.Context, req *database.Request, report *Report) error {
+	key := req.ArchiveKey
+	if key == "" {
+		record, err := a.buildRecord(req)
+		if err != nil {
+			return err
+		}
+		data, err := encodeRecord(record)
+		if err != nil {
+			return err
+		}
+		key = a.opts.Prefix + req.CreatedAt.UTC().Format("2006/01/02") + "/" + req.ID + ".json.gz"
+		if err := a.store.Put(ctx, key, "application/gzip", data); err != nil {
+			return err
+		}
+		report.Bytes += int64(len(data))
+	}
+
+	files, err := a.db.MarkArchived(req.ID, key)
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		if err := a.fs.DeleteFile(path); err != nil {
+			slog.Warn("failed to delete archived file", "file", path, "error", err)
+		}
+	}
+	report.Requests++
+	report.Files += len(files)
+	return nil
+}
+
+// buildRecord collects the responses, recorded stream chunks and files of a
+// request. Files that no longer exist are left out; any other read failure
+// returns errUnreadableFile, since archiving deletes the file.
+func (a *Archiver) buildRecord(req *database.Request) (*federation.Record, error) {
+	responses, err := a.db.GetResponsesByRequestID(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	record := &federation.Record{Request: req, Responses: responses}
+	for _, resp := range responses {
+		chunks, err := a.db.GetResponseChunks(resp.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunks) > 0 {
+			if record.Chunks == nil {
+				record.Chunks = make(map[string][]*database.ResponseChunk)
+			}
+			record.Chunks[resp.ID] = chunks
+		}
+	}
+
+	files, err := a.db.GetBinaryFilesByRequestID(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		content, err := storage.ReadFile(a.fs, file.FilePath)
+		if errors.Is(err, os.ErrNotExist) {
+			slog.Warn("archiving request without missing file", "request_id", req.ID, "file", file.FilePath)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errUnreadableFile, file.FilePath, err)
+		}
+		record.Files = append(record.Files, &federation.File{BinaryFile: file, Content: content})
+	}
+	return record, nil
+}
+
+// Restore puts an archived request's payloads and files back from object
+// storage. Restoring a request that is no longer archived does nothing.
+func (a *Archiver) Restore(ctx context.Context, requestID string) error {
+	a.restoreMu.Lock()
+	defer a.restoreMu.Unlock()
+
+	req, err := a.db.GetRequest(requestID)
+	if err != nil {
+		return err
+	}
+	if req.ArchivedAt == nil {
+		return nil
+	}
+
+	data, err := a.store.Get(ctx, req.ArchiveKey)
+	if err != nil {
+		return err
+	}
+	record, err := decodeRecord(data)
+	if err != nil {
+		return fmt.Errorf("archived record %s: %w", req.ArchiveKey, err)
+	}
+	if record.Request == nil || record.Request.ID != req.ID {
+		return fmt.Errorf("archived record %s does not hold request %s", req.ArchiveKey, req.ID)
+	}
+
+	var files []*database.BinaryFile
+	for _, file := range record.Files {
+		if file.BinaryFile == nil {
+			continue
+		}
+		filePath, size, err := a.fs.SaveFile(req.Provider, file.ContentType, bytes.NewReader(file.Content))
+		if err != nil {
+			return fmt.Errorf("failed to save restored file: %w", err)
+		}
+		file.FilePath, file.Size = filePath, size
+		files = append(files, file.BinaryFile)
+	}
+
+	if err := a.db.RestoreArchived(record.Request, record.Responses, record.Chunks, files); err != nil {
+		for _, file := range files {
+			a.fs.DeleteFile(file.FilePath)
+		}
+		return err
+	}
+	slog.Info("restored archived request", "request_id", req.ID, "key", req.ArchiveKey, "files", len(files))
+	return nil
+}
+
+// encodeRecord returns a record as gzipped JSON
+func encodeRecord(record *federation.Record) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(record); err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress record: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeRecord reads a record written by encodeRecord
+func decodeRecord(data []byte) (*federation.Record, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip: %w", err)
+	}
+	defer gz.Close()
+
+	var record federation.Record
+	if err := json.NewDecoder(gz).Decode(&record); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return &record, nil
+}
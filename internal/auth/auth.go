@@ -0,0 +1,426 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	sessionCookie = "aigw_session"
+	stateCookie   = "aigw_oauth_state"
+	stateTTL      = 10 * time.Minute
+	basicRealm    = `Basic realm="Simple AI Gateway", charset="UTF-8"`
+)
+
+// Options configures an Authenticator
+type Options struct {
+	Providers     []Provider
+	BaseURL       string        // External URL of the gateway, used for callback URLs
+	SessionSecret []byte        // HMAC key for session cookies; random if empty
+	SessionTTL    time.Duration // Session lifetime
+	AllowedUsers  []string      // Emails, @domains, usernames or provider:subject; empty allows everyone
+
+	// Static credentials for scripts and setups without an identity provider,
+	// sent with each request instead of logging in
+	Tokens     []string          // Accepted as Authorization: Bearer <token>
+	BasicUsers map[string]string // Username to password, or sha256:<hex digest> of it
+}
+
+// Authenticator protects the management API and UI with OAuth2/OIDC logins
+// and signed session cookies, static bearer tokens or basic auth
+type Authenticator struct {
+	providers map[string]Provider
+	order     []string
+	opts      Options
+	tokens    [][sha256.Size]byte // Digests, so comparisons take the same time for every token
+}
+
+type contextKey struct{}
+
+// New creates an authenticator
+func New(opts Options) (*Authenticator, error) {
+	if len(opts.Providers) == 0 && len(opts.Tokens) == 0 && len(opts.BasicUsers) == 0 {
+		return nil, fmt.Errorf("no login providers or credentials configured")
+	}
+	for user, password := range opts.BasicUsers {
+		if user == "" || strings.Contains(user, ":") || password == "" {
+			return nil, fmt.Errorf("invalid basic auth user %q: expected user:password", user)
+		}
+		if digest, ok := strings.CutPrefix(password, "sha256:"); ok {
+			if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("invalid sha256 password digest for basic auth user %q", user)
+			}
+		}
+	}
+	if len(opts.SessionSecret) == 0 {
+		opts.SessionSecret = make([]byte, 32)
+		if _, err := rand.Read(opts.SessionSecret); err != nil {
+			return nil, fmt.Errorf("failed to generate session secret: %w", err)
+		}
+	}
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = 24 * time.Hour
+	}
+	opts.BaseURL = strings.TrimSuffix(opts.BaseURL, "/")
+
+	a := &Authenticator{providers: make(map[string]Provider), opts: opts}
+	for _, token := range opts.Tokens {
+		a.tokens = append(a.tokens, sha256.Sum256([]byte(token)))
+	}
+	for _, p := range opts.Providers {
+		if _, exists := a.providers[p.Name()]; exists {
+			return nil, fmt.Errorf("duplicate login provider %q", p.Name())
+		}
+		a.providers[p.Name()] = p
+		a.order = append(a.order, p.Name())
+	}
+	return a, nil
+}
+
+// ProviderNames returns the configured login providers in order
+func (a *Authenticator) ProviderNames() []string {
+	return a.order
+}
+
+// TokenCount returns the number of accepted bearer tokens
+func (a *Authenticator) TokenCount() int {
+	return len(a.tokens)
+}
+
+// BasicUserCount returns the number of basic auth users
+func (a *Authenticator) BasicUserCount() int {
+	return len(a.opts.BasicUsers)
+}
+
+// IdentityFromContext returns the user authenticated by the middleware, if any
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(contextKey{}).(*Identity)
+	return identity
+}
+
+// Middleware rejects requests without a valid session or credentials. API
+// calls get a 401; browser page loads are redirected to the login page, or
+// asked for basic auth when there is no login provider.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if identity := a.authenticate(r); identity != nil {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, identity)))
+			return
+		}
+		if r.Header.Get("Authorization") != "" {
+			slog.WarnContext(r.Context(), "invalid management credentials", "remote_addr", r.RemoteAddr, "path", r.URL.Path)
+		}
+
+		page := !strings.HasPrefix(r.URL.Path, "/api/") && strings.Contains(r.Header.Get("Accept"), "text/html")
+		if page && len(a.order) > 0 {
+			http.Redirect(w, r, "/auth/login?return="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+			return
+		}
+		// The browser prompt only makes sense where there's no login page
+		if len(a.opts.BasicUsers) > 0 && len(a.order) == 0 {
+			w.Header().Set("WWW-Authenticate", basicRealm)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "authentication required"})
+	})
+}
+
+// Login handles GET /auth/login. With a single provider (or ?provider=) the
+// user is sent straight to it; otherwise a provider chooser is shown.
+func (a *Authenticator) Login(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("provider")
+	if name == "" && len(a.order) == 1 {
+		name = a.order[0]
+	}
+	returnTo := safeReturn(r.URL.Query().Get("return"))
+
+	if name == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		loginPage.Execute(w, map[string]interface{}{"Providers": a.order, "Return": returnTo})
+		return
+	}
+
+	p, ok := a.providers[name]
+	if !ok {
+		http.Error(w, "unknown login provider", http.StatusBadRequest)
+		return
+	}
+
+	state := randomToken()
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    a.sign([]byte(state + "\n" + returnTo)),
+		Path:     "/auth/",
+		MaxAge:   int(stateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   a.secure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, p.AuthCodeURL(state, a.callbackURL(r, name)), http.StatusFound)
+}
+
+// Callback handles GET /auth/callback/{provider}
+func (a *Authenticator) Callback(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/auth/callback/")
+	p, ok := a.providers[name]
+	if !ok {
+		http.Error(w, "unknown login provider", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		http.Error(w, "login failed: "+errCode, http.StatusUnauthorized)
+		return
+	}
+
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil {
+		http.Error(w, "login expired, please try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/auth/", MaxAge: -1})
+
+	payload, ok := a.verify(cookie.Value)
+	state, returnTo, _ := strings.Cut(string(payload), "\n")
+	if !ok || state == "" || !hmac.Equal([]byte(state), []byte(query.Get("state"))) {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := p.Exchange(r.Context(), query.Get("code"), a.callbackURL(r, name))
+	if err != nil {
+		slog.WarnContext(r.Context(), "login failed", "auth_provider", name, "error", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	if !a.allowed(identity) {
+		slog.WarnContext(r.Context(), "login denied", "auth_provider", name, "user", identity.String())
+		http.Error(w, "user is not allowed to access this gateway", http.StatusForbidden)
+		return
+	}
+
+	if err := a.startSession(w, r, identity); err != nil {
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "login", "auth_provider", name, "user", identity.String())
+	http.Redirect(w, r, safeReturn(returnTo), http.StatusFound)
+}
+
+// Logout handles /auth/logout
+func (a *Authenticator) Logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/auth/login", http.StatusFound)
+}
+
+// Me handles GET /auth/me
+func (a *Authenticator) Me(w http.ResponseWriter, r *http.Request) {
+	identity := a.authenticate(r)
+	w.Header().Set("Content-Type", "application/json")
+	if identity == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "authentication required"})
+		return
+	}
+	json.NewEncoder(w).Encode(identity)
+}
+
+// sessionData is the signed content of the session cookie
+type sessionData struct {
+	Identity  *Identity `json:"identity"`
+	ExpiresAt int64     `json:"exp"`
+}
+
+func (a *Authenticator) startSession(w http.ResponseWriter, r *http.Request, identity *Identity) error {
+	data, err := json.Marshal(&sessionData{Identity: identity, ExpiresAt: time.Now().Add(a.opts.SessionTTL).Unix()})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    a.sign(data),
+		Path:     "/",
+		MaxAge:   int(a.opts.SessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   a.secure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// authenticate returns the identity of the session or the credentials sent
+// with the request, or nil if there are none or they are invalid
+func (a *Authenticator) authenticate(r *http.Request) *Identity {
+	if identity := a.session(r); identity != nil {
+		return identity
+	}
+
+	header := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok && len(a.tokens) > 0 {
+		digest := sha256.Sum256([]byte(strings.TrimSpace(token)))
+		for _, accepted := range a.tokens {
+			if hmac.Equal(digest[:], accepted[:]) {
+				// Identified by a fingerprint, never the token itself
+				return &Identity{Provider: "token", Subject: hex.EncodeToString(accepted[:4])}
+			}
+		}
+		return nil
+	}
+	if user, password, ok := r.BasicAuth(); ok && a.checkPassword(user, password) {
+		return &Identity{Provider: "basic", Subject: user, Username: user}
+	}
+	return nil
+}
+
+// checkPassword compares a basic auth password with the configured one in
+// constant time
+func (a *Authenticator) checkPassword(user, password string) bool {
+	expected, ok := a.opts.BasicUsers[user]
+	if !ok {
+		return false
+	}
+	if digest, ok := strings.CutPrefix(expected, "sha256:"); ok {
+		sum := sha256.Sum256([]byte(password))
+		return hmac.Equal([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(digest)))
+	}
+	return hmac.Equal([]byte(password), []byte(expected))
+}
+
+// session returns the identity of a valid, unexpired session cookie
+func (a *Authenticator) session(r *http.Request) *Identity {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+	payload, ok := a.verify(cookie.Value)
+	if !ok {
+		return nil
+	}
+
+	var data sessionData
+	if err := json.Unmarshal(payload, &data); err != nil || data.Identity == nil {
+		return nil
+	}
+	if time.Now().Unix() > data.ExpiresAt {
+		return nil
+	}
+	// Re-check so removing a user from the allowlist ends their session
+	if !a.allowed(data.Identity) {
+		return nil
+	}
+	return data.Identity
+}
+
+// allowed reports whether an identity matches the allowlist
+func (a *Authenticator) allowed(identity *Identity) bool {
+	if len(a.opts.AllowedUsers) == 0 {
+		return true
+	}
+
+	email := strings.ToLower(identity.Email)
+	for _, entry := range a.opts.AllowedUsers {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case strings.HasPrefix(entry, "@"):
+			if email != "" && strings.HasSuffix(email, entry) {
+				return true
+			}
+		case entry == email,
+			entry == strings.ToLower(identity.Username),
+			entry == strings.ToLower(identity.Provider+":"+identity.Subject):
+			return true
+		}
+	}
+	return false
+}
+
+// sign returns payload.signature, both base64url encoded
+func (a *Authenticator) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, a.opts.SessionSecret)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (a *Authenticator) verify(value string) ([]byte, bool) {
+	encodedPayload, encodedSig, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return nil, false
+	}
+
+	mac := hmac.New(sha256.New, a.opts.SessionSecret)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, false
+	}
+	return payload, true
+}
+
+// callbackURL returns the redirect URI registered with the provider
+func (a *Authenticator) callbackURL(r *http.Request, name string) string {
+	base := a.opts.BaseURL
+	if base == "" {
+		scheme := "http"
+		if a.secure(r) {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + "/auth/callback/" + name
+}
+
+func (a *Authenticator) secure(r *http.Request) bool {
+	if a.opts.BaseURL != "" {
+		return strings.HasPrefix(a.opts.BaseURL, "https://")
+	}
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// safeReturn only allows redirects to local paths
+func safeReturn(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/ui/"
+	}
+	return path
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Sign in - Simple AI Gateway</title></head>
+<body style="font-family: sans-serif; max-width: 320px; margin: 80px auto;">
+<h2>Simple AI Gateway</h2>
+<p>Sign in with:</p>
+<ul>
+{{range .Providers}}<li><a href="/auth/login?provider={{.}}&amp;return={{$.Return}}">{{.}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Identity is an authenticated user of the management surface
+type Identity struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"sub"`
+	Email    string `json:"email,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// String returns the most readable identifier of the user
+func (id *Identity) String() string {
+	switch {
+	case id.Email != "":
+		return id.Email
+	case id.Username != "":
+		return id.Username
+	default:
+		return id.Provider + ":" + id.Subject
+	}
+}
+
+// Provider is an OAuth2 login provider
+type Provider interface {
+	// Name identifies the provider in login and callback URLs
+	Name() string
+
+	// AuthCodeURL returns the URL the user is sent to for login
+	AuthCodeURL(state, redirectURL string) string
+
+	// Exchange trades an authorization code for the user's identity
+	Exchange(ctx context.Context, code, redirectURL string) (*Identity, error)
+}
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// OIDCProvider authenticates against an OpenID Connect issuer. The identity is
+// read from the issuer's userinfo endpoint with the access token, so no ID
+// token signature verification is needed.
+type OIDCProvider struct {
+	name         string
+	clientID     string
+	clientSecret string
+	scopes       []string
+
+	authURL     string
+	tokenURL    string
+	userInfoURL string
+}
+
+// NewOIDCProvider discovers the issuer's endpoints from
+// {issuer}/.well-known/openid-configuration
+func NewOIDCProvider(ctx context.Context, name, issuer, clientID, clientSecret string) (*OIDCProvider, error) {
+	discoveryURL := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+
+	var doc struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	if err := getJSON(ctx, discoveryURL, "", &doc); err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC issuer %s: %w", issuer, err)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.UserInfoEndpoint == "" {
+		return nil, fmt.Errorf("OIDC issuer %s is missing authorization, token or userinfo endpoint", issuer)
+	}
+
+	return &OIDCProvider{
+		name:         name,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       []string{"openid", "email", "profile"},
+		authURL:      doc.AuthorizationEndpoint,
+		tokenURL:     doc.TokenEndpoint,
+		userInfoURL:  doc.UserInfoEndpoint,
+	}, nil
+}
+
+// Name returns the provider name
+func (p *OIDCProvider) Name() string {
+	return p.name
+}
+
+// AuthCodeURL returns the issuer's authorization URL
+func (p *OIDCProvider) AuthCodeURL(state, redirectURL string) string {
+	return authCodeURL(p.authURL, p.clientID, redirectURL, state, p.scopes)
+}
+
+// Exchange trades the code for an access token and reads the userinfo endpoint
+func (p *OIDCProvider) Exchange(ctx context.Context, code, redirectURL string) (*Identity, error) {
+	token, err := exchangeCode(ctx, p.tokenURL, p.clientID, p.clientSecret, code, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		Subject           string `json:"sub"`
+		Email             string `json:"email"`
+		EmailVerified     *bool  `json:"email_verified"`
+		Name              string `json:"name"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err := getJSON(ctx, p.userInfoURL, token, &info); err != nil {
+		return nil, fmt.Errorf("failed to read userinfo: %w", err)
+	}
+	if info.Subject == "" {
+		return nil, fmt.Errorf("userinfo response has no subject")
+	}
+
+	identity := &Identity{Provider: p.name, Subject: info.Subject, Name: info.Name, Username: info.PreferredUsername}
+	// Only trust the email if the issuer doesn't say it is unverified
+	if info.EmailVerified == nil || *info.EmailVerified {
+		identity.Email = info.Email
+	}
+	return identity, nil
+}
+
+// GitHubProvider authenticates with GitHub OAuth apps
+type GitHubProvider struct {
+	clientID     string
+	clientSecret string
+}
+
+// NewGitHubProvider creates a GitHub login provider
+func NewGitHubProvider(clientID, clientSecret string) *GitHubProvider {
+	return &GitHubProvider{clientID: clientID, clientSecret: clientSecret}
+}
+
+// Name returns "github"
+func (p *GitHubProvider) Name() string {
+	return "github"
+}
+
+// AuthCodeURL returns GitHub's authorization URL
+func (p *GitHubProvider) AuthCodeURL(state, redirectURL string) string {
+	return authCodeURL("https://github.com/login/oauth/authorize", p.clientID, redirectURL, state, []string{"read:user", "user:email"})
+}
+
+// Exchange trades the code for an access token and reads the GitHub user
+func (p *GitHubProvider) Exchange(ctx context.Context, code, redirectURL string) (*Identity, error) {
+	token, err := exchangeCode(ctx, "https://github.com/login/oauth/access_token", p.clientID, p.clientSecret, code, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, "https://api.github.com/user", token, &user); err != nil {
+		return nil, fmt.Errorf("failed to read GitHub user: %w", err)
+	}
+
+	identity := &Identity{Provider: "github", Subject: strconv.FormatInt(user.ID, 10), Name: user.Name, Username: user.Login}
+
+	// The profile email may be hidden; use the verified primary address instead
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, "https://api.github.com/user/emails", token, &emails); err == nil {
+		for _, e := range emails {
+			if e.Primary && e.Verified {
+				identity.Email = e.Email
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+func authCodeURL(endpoint, clientID, redirectURL, state string, scopes []string) string {
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {clientID},
+		"redirect_uri":  {redirectURL},
+		"scope":         {strings.Join(scopes, " ")},
+		"state":         {state},
+	}
+	sep := "?"
+	if strings.Contains(endpoint, "?") {
+		sep = "&"
+	}
+	return endpoint + sep + params.Encode()
+}
+
+// exchangeCode performs the authorization code grant and returns the access token
+func exchangeCode(ctx context.Context, tokenURL, clientID, clientSecret, code, redirectURL string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("invalid token response (%d)", resp.StatusCode)
+	}
+	if token.Error != "" {
+		return "", fmt.Errorf("token request rejected: %s %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+	return token.AccessToken, nil
+}
+
+func getJSON(ctx context.Context, endpoint, accessToken string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,129 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// LetsEncryptURL is the directory of Let's Encrypt's production CA
+const LetsEncryptURL = autocert.DefaultACMEDirectory
+
+// retryInterval is how long to wait after a failed order
+const retryInterval = time.Hour
+
+// ACMEOptions configures an ACMEManager
+type ACMEOptions struct {
+	Domains      []string // Names to get certificates for; the first is served to clients without SNI
+	Email        string   // Account contact for expiry notices (optional)
+	CacheDir     string   // Where the account key and certificates are kept across restarts
+	DirectoryURL string   // ACME directory (default: LetsEncryptURL)
+}
+
+// ACMEManager obtains and renews certificates from an ACME CA with autocert,
+// answering TLS-ALPN-01 challenges on the listener it serves certificates
+// for. The listener must therefore be reachable on port 443 at each domain.
+type ACMEManager struct {
+	opts    ACMEOptions
+	manager *autocert.Manager
+}
+
+// NewACMEManager creates a manager that keeps the account key and
+// certificates in the cache directory, reusing those of a previous run
+func NewACMEManager(opts ACMEOptions) (*ACMEManager, error) {
+	if len(opts.Domains) == 0 {
+		return nil, fmt.Errorf("no ACME domains configured")
+	}
+	for i, domain := range opts.Domains {
+		domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+		if domain == "" || strings.ContainsAny(domain, "*/:") {
+			return nil, fmt.Errorf("invalid ACME domain %q: wildcards, ports and paths are not supported", opts.Domains[i])
+		}
+		opts.Domains[i] = domain
+	}
+	if opts.DirectoryURL == "" {
+		opts.DirectoryURL = LetsEncryptURL
+	}
+	if err := os.MkdirAll(opts.CacheDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create ACME cache directory: %w", err)
+	}
+
+	return &ACMEManager{
+		opts: opts,
+		manager: &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(opts.CacheDir),
+			HostPolicy: autocert.HostWhitelist(opts.Domains...),
+			Client:     &acme.Client{DirectoryURL: opts.DirectoryURL},
+			Email:      opts.Email,
+		},
+	}, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate, serving challenge
+// certificates to validation connections and the first domain's certificate
+// to clients without SNI
+func (m *ACMEManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if hello.ServerName == "" {
+		withName := *hello
+		withName.ServerName = m.opts.Domains[0]
+		hello = &withName
+	}
+	return m.manager.GetCertificate(hello)
+}
+
+// NextProtos returns the ALPN protocols to offer: HTTP plus the one
+// validation connections use
+func (m *ACMEManager) NextProtos() []string {
+	return []string{"h2", "http/1.1", acme.ALPNProto}
+}
+
+// Run obtains missing certificates now rather than on the first connection
+// for each domain, retrying failed ones until ctx is cancelled. Renewals are
+// scheduled by autocert once a certificate is loaded. Start it once the
+// listener is serving.
+func (m *ACMEManager) Run(ctx context.Context) {
+	pending := m.opts.Domains
+	for len(pending) > 0 {
+		var failed []string
+		for _, domain := range pending {
+			slog.Info("loading ACME certificate", "domain", domain, "directory", m.opts.DirectoryURL)
+			if _, err := m.manager.GetCertificate(prefetchHello(domain)); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Error("failed to obtain ACME certificate", "domain", domain, "error", err, "retry_in", retryInterval)
+				failed = append(failed, domain)
+				continue
+			}
+			slog.Info("ACME certificate ready", "domain", domain)
+		}
+		if pending = failed; len(pending) == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// prefetchHello is a handshake for domain from a client that supports ECDSA,
+// so the certificate most clients are served is the one obtained up front
+func prefetchHello(domain string) *tls.ClientHelloInfo {
+	return &tls.ClientHelloInfo{
+		ServerName:       domain,
+		SignatureSchemes: []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+		SupportedCurves:  []tls.CurveID{tls.CurveP256},
+		CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	}
+}
@@ -0,0 +1,101 @@
+// Package certs provides the certificates the gateway's listener serves:
+// from files, reloaded when they change, or obtained from an ACME
+// certificate authority such as Let's Encrypt
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// statInterval is how often the certificate files are checked for changes
+const statInterval = 10 * time.Second
+
+// FileSource serves a certificate and key from PEM files, reloading them when
+// either file changes so renewed certificates are picked up without a restart
+type FileSource struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time // Latest modification time of the two files
+	checkedAt time.Time
+}
+
+// NewFileSource loads a certificate and key from PEM files
+func NewFileSource(certFile, keyFile string) (*FileSource, error) {
+	s := &FileSource{certFile: certFile, keyFile: keyFile}
+	modTime, err := s.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.load(modTime); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (s *FileSource) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.checkedAt) >= statInterval {
+		s.checkedAt = time.Now()
+		if modTime, err := s.latestModTime(); err != nil {
+			slog.Warn("failed to check TLS certificate files", "error", err)
+		} else if modTime.After(s.modTime) {
+			// Keep serving the previous certificate if the new one doesn't load,
+			// e.g. while only one of the files has been replaced
+			if err := s.load(modTime); err != nil {
+				slog.Warn("failed to reload TLS certificate", "error", err)
+			} else {
+				slog.Info("reloaded TLS certificate", "cert_file", s.certFile)
+			}
+		}
+	}
+	return s.cert, nil
+}
+
+func (s *FileSource) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	s.cert = &cert
+	s.modTime = modTime
+	return nil
+}
+
+func (s *FileSource) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{s.certFile, s.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return latest, fmt.Errorf("failed to read TLS certificate: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// LoadClientCAs reads the PEM bundle of CAs client certificates must be
+// issued by. The system roots are deliberately not included.
+func LoadClientCAs(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", caFile)
+	}
+	return pool, nil
+}
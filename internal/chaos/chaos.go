@@ -0,0 +1,139 @@
+// Package chaos decides which proxied requests get injected faults: added
+// latency, error responses, connection resets and truncated streams, for
+// testing how clients handle a misbehaving provider.
+package chaos
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/match"
+)
+
+// RulesFile is the on-disk fault injection document
+type RulesFile struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Rule injects faults into matching requests. Rules are evaluated in order
+// and the first match applies; each fault is drawn independently.
+type Rule struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider,omitempty"` // Provider name glob (empty: any)
+	Path     string `json:"path,omitempty"`     // Request path glob or prefix, as in routing rules (empty: any)
+
+	LatencyMs int `json:"latency_ms,omitempty"` // Fixed delay before the request is forwarded
+	JitterMs  int `json:"jitter_ms,omitempty"`  // Random delay of up to this much on top
+
+	ErrorPercent float64 `json:"error_percent,omitempty"` // Share of requests answered with an error instead of forwarded
+	ErrorStatus  int     `json:"error_status,omitempty"`  // Status of injected errors (default: 500)
+
+	ResetPercent float64 `json:"reset_percent,omitempty"` // Share of requests whose connection is reset without a response
+
+	TruncatePercent    float64 `json:"truncate_percent,omitempty"`     // Share of streamed responses cut short
+	TruncateAfterBytes int64   `json:"truncate_after_bytes,omitempty"` // Bytes of a truncated stream the client gets (default: random, up to 4 KB)
+}
+
+// Fault is what is done to one request
+type Fault struct {
+	Rule          string
+	Latency       time.Duration
+	ErrorStatus   int   // Answer with this status instead of forwarding (0: no)
+	Reset         bool  // Reset the connection instead of forwarding
+	TruncateAfter int64 // Cut a streamed response after this many bytes (0: no)
+}
+
+// Active reports whether the fault changes anything
+func (f *Fault) Active() bool {
+	return f != nil && (f.Latency > 0 || f.ErrorStatus != 0 || f.Reset || f.TruncateAfter > 0)
+}
+
+// LoadRules reads and validates a fault injection rules file
+func LoadRules(filePath string) ([]*Rule, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chaos rules file %s: %w", filePath, err)
+	}
+
+	var file RulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse chaos rules file %s: %w", filePath, err)
+	}
+	if err := Validate(file.Rules); err != nil {
+		return nil, err
+	}
+	return file.Rules, nil
+}
+
+// Validate checks rules and names unnamed ones
+func Validate(rules []*Rule) error {
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("chaos-%d", i+1)
+		}
+		if rule.LatencyMs < 0 || rule.JitterMs < 0 {
+			return fmt.Errorf("chaos rule %q has a negative latency", rule.Name)
+		}
+		for _, percent := range []float64{rule.ErrorPercent, rule.ResetPercent, rule.TruncatePercent} {
+			if percent < 0 || percent > 100 {
+				return fmt.Errorf("chaos rule %q has a percent outside 0-100", rule.Name)
+			}
+		}
+		if rule.ErrorStatus != 0 && (rule.ErrorStatus < 400 || rule.ErrorStatus > 599) {
+			return fmt.Errorf("chaos rule %q has an error status outside 400-599", rule.Name)
+		}
+		if rule.TruncateAfterBytes < 0 {
+			return fmt.Errorf("chaos rule %q has a negative truncate_after_bytes", rule.Name)
+		}
+	}
+	return nil
+}
+
+// Decide draws the fault for a request to providerName at requestPath from
+// the first matching rule. It returns nil if no rule matches.
+func Decide(rules []*Rule, providerName, requestPath string) *Fault {
+	for _, rule := range rules {
+		if !rule.matches(providerName, requestPath) {
+			continue
+		}
+		fault := &Fault{Rule: rule.Name, Latency: time.Duration(rule.LatencyMs) * time.Millisecond}
+		if rule.JitterMs > 0 {
+			fault.Latency += time.Duration(rand.Intn(rule.JitterMs+1)) * time.Millisecond
+		}
+		switch {
+		case chance(rule.ResetPercent):
+			fault.Reset = true
+		case chance(rule.ErrorPercent):
+			fault.ErrorStatus = rule.ErrorStatus
+			if fault.ErrorStatus == 0 {
+				fault.ErrorStatus = 500
+			}
+		case chance(rule.TruncatePercent):
+			fault.TruncateAfter = rule.TruncateAfterBytes
+			if fault.TruncateAfter == 0 {
+				fault.TruncateAfter = 1 + rand.Int63n(4096)
+			}
+		}
+		return fault
+	}
+	return nil
+}
+
+// matches checks whether the rule applies to a request
+func (r *Rule) matches(providerName, requestPath string) bool {
+	if r.Provider != "" && !match.Glob(r.Provider, providerName) {
+		return false
+	}
+	if r.Path != "" && !match.Path(r.Path, requestPath) {
+		return false
+	}
+	return true
+}
+
+// chance reports true for percent out of 100 calls
+func chance(percent float64) bool {
+	return percent > 0 && rand.Float64()*100 < percent
+}
@@ -4,14 +4,160 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Port            int
-	DBPath          string
-	FileStoragePath string
+	Port                    int
+	TLSCertFile             string
+	TLSKeyFile              string
+	TLSClientCAFile         string
+	ACMEDomains             string
+	ACMEEmail               string
+	ACMECacheDir            string
+	ACMEDirectoryURL        string
+	LogLevel                string
+	LogFormat               string
+	DBPath                  string
+	FileStoragePath         string
+	FileStorageBucket       string
+	FileStoragePrefix       string
+	FileSignedURLSeconds    int
+	ThumbnailSize           int
+	RoutesFile              string
+	ToolsFile               string
+	ToolMaxRounds           int
+	FollowRedirects         bool
+	RetryMaxAttempts        int
+	RetryBackoffMs          int
+	RetryMaxBackoffMs       int
+	RetryOnStatus           string
+	UpstreamConnectTimeout  int
+	UpstreamReadTimeout     int
+	UpstreamTimeout         int
+	StreamReadTimeout       int
+	StreamTimeout           int
+	MaxIdleConns            int
+	MaxIdleConnsPerHost     int
+	IdleConnTimeout         int
+	UpstreamHTTP2           bool
+	UpstreamProxy           string
+	UpstreamCAFile          string
+	UpstreamTLSSkipVerify   bool
+	RequireVirtualKey       bool
+	OverrideHeaders         string
+	InjectStreamUsage       bool
+	StripInjectedUsage      bool
+	RecordChunks            bool
+	MaxResponseBufferMB     int
+	KeyRateLimitRPM         int
+	KeyRateLimitTPM         int
+	AdaptiveConcurrency     bool
+	ConcurrencyInitial      int
+	ConcurrencyMin          int
+	ConcurrencyMax          int
+	ConcurrencyMaxWait      int
+	ConcurrencyTolerance    float64
+	PricingFile             string
+	BudgetDailyUSD          float64
+	BudgetMonthlyUSD        float64
+	KeyBudgetDailyUSD       float64
+	KeyBudgetMonthlyUSD     float64
+	SecretScan              string
+	RedactCredentials       bool
+	RedactHeaders           string
+	RedactPatternsFile      string
+	RedactSecrets           bool
+	Moderation              string
+	ModerationURL           string
+	ModerationAPIKey        string
+	ModerationModel         string
+	ModerationTimeout       int
+	ModerationFailClosed    bool
+	Guardrails              string
+	GuardrailsThreshold     float64
+	GuardrailsRulesFile     string
+	GuardrailsClassifier    string
+	GuardrailsClassifierKey string
+	GuardrailsTimeout       int
+	APIKeyStrategy          string
+	APIKeyCooldown          int
+	PlaybackProviders       string
+	PlaybackMiss            string
+	CacheTTL                int
+	CacheStaleTTL           int
+	CacheProviders          string
+	InterceptResponses      string
+	InterceptTimeout        int
+	ChaosFile               string
+	PauseMode               string
+	PauseMaxWait            int
+	WriteQueueSize          int
+	WriteQueueFull          string
+	CompressBodiesOverKB    int
+	SamplingRules           string
+	RetentionDays           int
+	RetentionInterval       int
+	MaxDBSizeMB             int
+	MaxFilesGB              float64
+	FileQuotaGB             float64
+	ArchiveBucket           string
+	ArchivePrefix           string
+	ArchiveAfterDays        int
+	ArchiveInterval         int
+	S3Endpoint              string
+	S3Region                string
+	S3AccessKeyID           string
+	S3SecretAccessKey       string
+	S3SessionToken          string
+	MockResponse            string
+	MockTokensPerSecond     float64
+	FederationURL           string
+	FederationToken         string
+	FederationSource        string
+	FederationInterval      int
+	FederationIncludeFiles  bool
+	FederationIngestToken   string
+	SSEBroadcastBuffer      int
+	SSEClientBuffer         int
+	SSESlowConsumerPolicy   string
+	SSEHeartbeatInterval    int
+	EventSinks              string
+	ExportSinks             string
+	ExportChunks            bool
+	MetricsPort             int
+	WatchdogThreshold       int
+	WatchdogCancelAfter     int
+	WatchdogWebhookURL      string
+	FineTuneMonitor         bool
+	FineTunePollInterval    int
+	FineTuneWebhookURL      string
+	PredictionPollInterval  int
+	PredictionPollTimeout   int
+	ReplicateWebhookSecret  string
+	OutputContentTypes      string
+	OutputMaxMB             int
+	UpdateCheck             bool
+	UpdateCheckInterval     int
+	UpdateCheckRepository   string
+	UpgradeDrainTimeout     int
+	UpgradeReadyTimeout     int
+	AuthBaseURL             string
+	AuthSessionSecret       string
+	AuthSessionTTL          int
+	AuthAllowedUsers        string
+	AuthGoogleClientID      string
+	AuthGoogleClientSecret  string
+	AuthGitHubClientID      string
+	AuthGitHubClientSecret  string
+	AuthOIDCName            string
+	AuthOIDCIssuer          string
+	AuthOIDCClientID        string
+	AuthOIDCClientSecret    string
+	AuthTokens              string
+	AuthBasicUsers          string
 }
 
 var (
@@ -26,14 +172,223 @@ func Load() (*Config, error) {
 	_ = godotenv.Load()
 
 	cfg := &Config{
-		Port:            getEnvInt("PORT", defaultPort),
-		DBPath:          getEnv("DB_PATH", defaultDBPath),
-		FileStoragePath: getEnv("FILE_STORAGE_PATH", defaultFileStoragePath),
+		Port:                    getEnvInt("PORT", defaultPort),
+		TLSCertFile:             getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:              getEnv("TLS_KEY_FILE", ""),
+		TLSClientCAFile:         getEnv("TLS_CLIENT_CA_FILE", ""),
+		ACMEDomains:             getEnv("ACME_DOMAINS", ""),
+		ACMEEmail:               getEnv("ACME_EMAIL", ""),
+		ACMECacheDir:            getEnv("ACME_CACHE_DIR", "./data/certs"),
+		ACMEDirectoryURL:        getEnv("ACME_DIRECTORY_URL", ""),
+		LogLevel:                getEnv("LOG_LEVEL", "info"),
+		LogFormat:               getEnv("LOG_FORMAT", "text"),
+		DBPath:                  getEnv("DB_PATH", defaultDBPath),
+		FileStoragePath:         getEnv("FILE_STORAGE_PATH", defaultFileStoragePath),
+		FileStorageBucket:       getEnv("FILE_STORAGE_BUCKET", ""),
+		FileStoragePrefix:       getEnv("FILE_STORAGE_PREFIX", "aigw-files/"),
+		FileSignedURLSeconds:    getEnvInt("FILE_SIGNED_URL_SECONDS", 0),
+		ThumbnailSize:           getEnvInt("THUMBNAIL_SIZE", 256),
+		RoutesFile:              getEnv("ROUTES_FILE", ""),
+		ToolsFile:               getEnv("TOOLS_FILE", ""),
+		ToolMaxRounds:           getEnvInt("TOOL_MAX_ROUNDS", 5),
+		FollowRedirects:         getEnvBool("FOLLOW_REDIRECTS", false),
+		RetryMaxAttempts:        getEnvInt("RETRY_MAX_ATTEMPTS", 1),
+		RetryBackoffMs:          getEnvInt("RETRY_BACKOFF_MS", 500),
+		RetryMaxBackoffMs:       getEnvInt("RETRY_MAX_BACKOFF_MS", 10000),
+		RetryOnStatus:           getEnv("RETRY_ON_STATUS", "429,500,502,503,504"),
+		UpstreamConnectTimeout:  getEnvInt("UPSTREAM_CONNECT_TIMEOUT", 10),
+		UpstreamReadTimeout:     getEnvInt("UPSTREAM_READ_TIMEOUT", 300),
+		UpstreamTimeout:         getEnvInt("UPSTREAM_TIMEOUT", 600),
+		StreamReadTimeout:       getEnvInt("UPSTREAM_STREAM_READ_TIMEOUT", 300),
+		StreamTimeout:           getEnvInt("UPSTREAM_STREAM_TIMEOUT", 3600),
+		MaxIdleConns:            getEnvInt("UPSTREAM_MAX_IDLE_CONNS", 200),
+		MaxIdleConnsPerHost:     getEnvInt("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", 100),
+		IdleConnTimeout:         getEnvInt("UPSTREAM_IDLE_CONN_TIMEOUT", 90),
+		UpstreamHTTP2:           getEnvBool("UPSTREAM_HTTP2", true),
+		UpstreamProxy:           getEnv("UPSTREAM_PROXY", ""),
+		UpstreamCAFile:          getEnv("UPSTREAM_CA_FILE", ""),
+		UpstreamTLSSkipVerify:   getEnvBool("UPSTREAM_TLS_SKIP_VERIFY", false),
+		RequireVirtualKey:       getEnvBool("REQUIRE_VIRTUAL_KEY", false),
+		OverrideHeaders:         getEnv("OVERRIDE_HEADERS", "keys"),
+		InjectStreamUsage:       getEnvBool("INJECT_STREAM_USAGE", false),
+		StripInjectedUsage:      getEnvBool("STRIP_INJECTED_USAGE", true),
+		RecordChunks:            getEnvBool("RECORD_CHUNKS", false),
+		MaxResponseBufferMB:     getEnvInt("MAX_RESPONSE_BUFFER_MB", 10),
+		KeyRateLimitRPM:         getEnvInt("RATE_LIMIT_KEY_RPM", 0),
+		KeyRateLimitTPM:         getEnvInt("RATE_LIMIT_KEY_TPM", 0),
+		AdaptiveConcurrency:     getEnvBool("ADAPTIVE_CONCURRENCY", false),
+		ConcurrencyInitial:      getEnvInt("CONCURRENCY_INITIAL", 10),
+		ConcurrencyMin:          getEnvInt("CONCURRENCY_MIN", 1),
+		ConcurrencyMax:          getEnvInt("CONCURRENCY_MAX", 100),
+		ConcurrencyMaxWait:      getEnvInt("CONCURRENCY_MAX_WAIT", 30),
+		ConcurrencyTolerance:    getEnvFloat("CONCURRENCY_LATENCY_TOLERANCE", 2),
+		PricingFile:             getEnv("PRICING_FILE", ""),
+		BudgetDailyUSD:          getEnvFloat("BUDGET_DAILY_USD", 0),
+		BudgetMonthlyUSD:        getEnvFloat("BUDGET_MONTHLY_USD", 0),
+		KeyBudgetDailyUSD:       getEnvFloat("BUDGET_KEY_DAILY_USD", 0),
+		KeyBudgetMonthlyUSD:     getEnvFloat("BUDGET_KEY_MONTHLY_USD", 0),
+		SecretScan:              getEnv("SECRET_SCAN", "off"),
+		RedactCredentials:       getEnvBool("REDACT_CREDENTIALS", true),
+		RedactHeaders:           getEnv("REDACT_HEADERS", ""),
+		RedactPatternsFile:      getEnv("REDACT_PATTERNS_FILE", ""),
+		RedactSecrets:           getEnvBool("REDACT_SECRETS", false),
+		Moderation:              getEnv("MODERATION", "off"),
+		ModerationURL:           getEnv("MODERATION_URL", ""),
+		ModerationAPIKey:        getEnv("MODERATION_API_KEY", ""),
+		ModerationModel:         getEnv("MODERATION_MODEL", ""),
+		ModerationTimeout:       getEnvInt("MODERATION_TIMEOUT", 10),
+		ModerationFailClosed:    getEnvBool("MODERATION_FAIL_CLOSED", false),
+		Guardrails:              getEnv("GUARDRAILS", "off"),
+		GuardrailsThreshold:     getEnvFloat("GUARDRAILS_THRESHOLD", 0.7),
+		GuardrailsRulesFile:     getEnv("GUARDRAILS_RULES_FILE", ""),
+		GuardrailsClassifier:    getEnv("GUARDRAILS_CLASSIFIER_URL", ""),
+		GuardrailsClassifierKey: getEnv("GUARDRAILS_CLASSIFIER_API_KEY", ""),
+		GuardrailsTimeout:       getEnvInt("GUARDRAILS_TIMEOUT", 10),
+		APIKeyStrategy:          getEnv("API_KEY_STRATEGY", "round-robin"),
+		APIKeyCooldown:          getEnvInt("API_KEY_COOLDOWN", 60),
+		PlaybackProviders:       getEnv("PLAYBACK_PROVIDERS", ""),
+		PlaybackMiss:            getEnv("PLAYBACK_MISS", "error"),
+		CacheTTL:                getEnvInt("CACHE_TTL", 0),
+		CacheStaleTTL:           getEnvInt("CACHE_STALE_TTL", 0),
+		CacheProviders:          getEnv("CACHE_PROVIDERS", "*"),
+		InterceptResponses:      getEnv("INTERCEPT_RESPONSES", ""),
+		InterceptTimeout:        getEnvInt("INTERCEPT_TIMEOUT", 300),
+		ChaosFile:               getEnv("CHAOS_FILE", ""),
+		PauseMode:               getEnv("PAUSE_MODE", "queue"),
+		PauseMaxWait:            getEnvInt("PAUSE_MAX_WAIT", 0),
+		WriteQueueSize:          getEnvInt("WRITE_QUEUE_SIZE", 10000),
+		WriteQueueFull:          getEnv("WRITE_QUEUE_FULL", "block"),
+		CompressBodiesOverKB:    getEnvInt("COMPRESS_BODIES_OVER_KB", 32),
+		SamplingRules:           getEnv("SAMPLING_RULES", ""),
+		RetentionDays:           getEnvInt("RETENTION_DAYS", 0),
+		RetentionInterval:       getEnvInt("RETENTION_INTERVAL", 3600),
+		MaxDBSizeMB:             getEnvInt("MAX_DB_SIZE_MB", 0),
+		MaxFilesGB:              getEnvFloat("MAX_FILES_GB", 0),
+		FileQuotaGB:             getEnvFloat("FILE_QUOTA_GB", 0),
+		ArchiveBucket:           getEnv("ARCHIVE_BUCKET", ""),
+		ArchivePrefix:           getEnv("ARCHIVE_PREFIX", "aigw-archive/"),
+		ArchiveAfterDays:        getEnvInt("ARCHIVE_AFTER_DAYS", 0),
+		ArchiveInterval:         getEnvInt("ARCHIVE_INTERVAL", 3600),
+		S3Endpoint:              getEnv("S3_ENDPOINT", ""),
+		S3Region:                getEnv("S3_REGION", getEnv("AWS_REGION", "us-east-1")),
+		S3AccessKeyID:           getEnv("S3_ACCESS_KEY_ID", getEnv("AWS_ACCESS_KEY_ID", "")),
+		S3SecretAccessKey:       getEnv("S3_SECRET_ACCESS_KEY", getEnv("AWS_SECRET_ACCESS_KEY", "")),
+		S3SessionToken:          getEnv("S3_SESSION_TOKEN", getEnv("AWS_SESSION_TOKEN", "")),
+		MockResponse:            getEnv("MOCK_RESPONSE", ""),
+		MockTokensPerSecond:     getEnvFloat("MOCK_TOKENS_PER_SECOND", 0),
+		FederationURL:           getEnv("FEDERATION_URL", ""),
+		FederationToken:         getEnv("FEDERATION_TOKEN", ""),
+		FederationSource:        getEnv("FEDERATION_SOURCE", ""),
+		FederationInterval:      getEnvInt("FEDERATION_INTERVAL", 30),
+		FederationIncludeFiles:  getEnvBool("FEDERATION_INCLUDE_FILES", false),
+		FederationIngestToken:   getEnv("FEDERATION_INGEST_TOKEN", ""),
+		SSEBroadcastBuffer:      getEnvInt("SSE_BROADCAST_BUFFER", 100),
+		SSEClientBuffer:         getEnvInt("SSE_CLIENT_BUFFER", 10),
+		SSESlowConsumerPolicy:   getEnv("SSE_SLOW_CONSUMER_POLICY", "coalesce"),
+		SSEHeartbeatInterval:    getEnvInt("SSE_HEARTBEAT_INTERVAL", 15),
+		EventSinks:              getEnv("EVENT_SINKS", ""),
+		ExportSinks:             getEnv("EXPORT_SINKS", ""),
+		ExportChunks:            getEnvBool("EXPORT_CHUNKS", false),
+		MetricsPort:             getEnvInt("METRICS_PORT", 0),
+		WatchdogThreshold:       getEnvInt("WATCHDOG_THRESHOLD", 0),
+		WatchdogCancelAfter:     getEnvInt("WATCHDOG_CANCEL_AFTER", 0),
+		WatchdogWebhookURL:      getEnv("WATCHDOG_WEBHOOK_URL", ""),
+		FineTuneMonitor:         getEnvBool("FINE_TUNE_MONITOR", false),
+		FineTunePollInterval:    getEnvInt("FINE_TUNE_POLL_INTERVAL", 60),
+		FineTuneWebhookURL:      getEnv("FINE_TUNE_WEBHOOK_URL", ""),
+		PredictionPollInterval:  getEnvInt("PREDICTION_POLL_INTERVAL", 2),
+		PredictionPollTimeout:   getEnvInt("PREDICTION_POLL_TIMEOUT", 1800),
+		ReplicateWebhookSecret:  getEnv("REPLICATE_WEBHOOK_SECRET", ""),
+		OutputContentTypes:      getEnv("OUTPUT_CONTENT_TYPES", "image/*,audio/*,video/*,application/zip,application/octet-stream"),
+		OutputMaxMB:             getEnvInt("OUTPUT_MAX_MB", 500),
+		UpdateCheck:             getEnvBool("UPDATE_CHECK", false),
+		UpdateCheckInterval:     getEnvInt("UPDATE_CHECK_INTERVAL", 86400),
+		UpdateCheckRepository:   getEnv("UPDATE_CHECK_REPOSITORY", "ruqqq/simple-ai-gateway"),
+		UpgradeDrainTimeout:     getEnvInt("UPGRADE_DRAIN_TIMEOUT", 300),
+		UpgradeReadyTimeout:     getEnvInt("UPGRADE_READY_TIMEOUT", 30),
+		AuthBaseURL:             getEnv("AUTH_BASE_URL", ""),
+		AuthSessionSecret:       getEnv("AUTH_SESSION_SECRET", ""),
+		AuthSessionTTL:          getEnvInt("AUTH_SESSION_TTL", 24),
+		AuthAllowedUsers:        getEnv("AUTH_ALLOWED_USERS", ""),
+		AuthGoogleClientID:      getEnv("AUTH_GOOGLE_CLIENT_ID", ""),
+		AuthGoogleClientSecret:  getEnv("AUTH_GOOGLE_CLIENT_SECRET", ""),
+		AuthGitHubClientID:      getEnv("AUTH_GITHUB_CLIENT_ID", ""),
+		AuthGitHubClientSecret:  getEnv("AUTH_GITHUB_CLIENT_SECRET", ""),
+		AuthOIDCName:            getEnv("AUTH_OIDC_NAME", "oidc"),
+		AuthOIDCIssuer:          getEnv("AUTH_OIDC_ISSUER", ""),
+		AuthOIDCClientID:        getEnv("AUTH_OIDC_CLIENT_ID", ""),
+		AuthOIDCClientSecret:    getEnv("AUTH_OIDC_CLIENT_SECRET", ""),
+		AuthTokens:              getEnv("AUTH_TOKENS", ""),
+		AuthBasicUsers:          getEnv("AUTH_BASIC_USERS", ""),
 	}
 
 	return cfg, nil
 }
 
+// ProviderAPIKeys returns the gateway-side API keys for a provider from
+// {PROVIDER}_API_KEY (e.g. OPENAI_API_KEY, REPLICATE_API_KEY), which may list
+// several keys separated by commas
+func (c *Config) ProviderAPIKeys(providerName string) []string {
+	var keys []string
+	for _, key := range strings.Split(getEnv(strings.ToUpper(providerName)+"_API_KEY", ""), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// ProviderRateLimits returns the requests and tokens per minute allowed for a
+// provider from RATE_LIMIT_{PROVIDER}_RPM and RATE_LIMIT_{PROVIDER}_TPM (0 = unlimited)
+func (c *Config) ProviderRateLimits(providerName string) (int, int) {
+	prefix := "RATE_LIMIT_" + strings.ToUpper(providerName)
+	return getEnvInt(prefix+"_RPM", 0), getEnvInt(prefix+"_TPM", 0)
+}
+
+// UpstreamTimeouts limit calls to a provider, in seconds (0 = no limit).
+// Streaming requests have their own, usually longer, read and total limits.
+type UpstreamTimeouts struct {
+	Connect     int // Connecting, including the TLS handshake
+	Read        int // Waiting for the response headers, or between reads of the body
+	Total       int // The whole call, including retries and reading the body
+	StreamRead  int
+	StreamTotal int
+}
+
+// ProviderTimeouts returns the upstream timeouts of a provider: the
+// UPSTREAM_* settings, overridden by {PROVIDER}_CONNECT_TIMEOUT,
+// {PROVIDER}_READ_TIMEOUT, {PROVIDER}_TIMEOUT, {PROVIDER}_STREAM_READ_TIMEOUT
+// and {PROVIDER}_STREAM_TIMEOUT
+func (c *Config) ProviderTimeouts(providerName string) UpstreamTimeouts {
+	prefix := strings.ToUpper(providerName)
+	return UpstreamTimeouts{
+		Connect:     getEnvInt(prefix+"_CONNECT_TIMEOUT", c.UpstreamConnectTimeout),
+		Read:        getEnvInt(prefix+"_READ_TIMEOUT", c.UpstreamReadTimeout),
+		Total:       getEnvInt(prefix+"_TIMEOUT", c.UpstreamTimeout),
+		StreamRead:  getEnvInt(prefix+"_STREAM_READ_TIMEOUT", c.StreamReadTimeout),
+		StreamTotal: getEnvInt(prefix+"_STREAM_TIMEOUT", c.StreamTimeout),
+	}
+}
+
+// UpstreamNetwork is how the gateway reaches a provider
+type UpstreamNetwork struct {
+	Proxy         string // Proxy URL (http, https or socks5), "direct", or empty for HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+	CAFile        string // PEM certificates trusted in addition to the system's
+	TLSSkipVerify bool
+}
+
+// ProviderNetwork returns how to reach a provider: the UPSTREAM_PROXY,
+// UPSTREAM_CA_FILE and UPSTREAM_TLS_SKIP_VERIFY settings, overridden by
+// {PROVIDER}_PROXY, {PROVIDER}_CA_FILE and {PROVIDER}_TLS_SKIP_VERIFY
+func (c *Config) ProviderNetwork(providerName string) UpstreamNetwork {
+	prefix := strings.ToUpper(providerName)
+	return UpstreamNetwork{
+		Proxy:         getEnv(prefix+"_PROXY", c.UpstreamProxy),
+		CAFile:        getEnv(prefix+"_CA_FILE", c.UpstreamCAFile),
+		TLSSkipVerify: getEnvBool(prefix+"_TLS_SKIP_VERIFY", c.UpstreamTLSSkipVerify),
+	}
+}
+
 func getEnv(key, defaultVal string) string {
 	if val, exists := os.LookupEnv(key); exists {
 		return val
@@ -50,3 +405,23 @@ func getEnvInt(key string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+func getEnvFloat(key string, defaultVal float64) float64 {
+	if val, exists := os.LookupEnv(key); exists {
+		if floatVal, err := strconv.ParseFloat(val, 64); err == nil {
+			return floatVal
+		}
+		fmt.Fprintf(os.Stderr, "Warning: invalid number value for %s\n", key)
+	}
+	return defaultVal
+}
+
+func getEnvBool(key string, defaultVal bool) bool {
+	if val, exists := os.LookupEnv(key); exists {
+		if boolVal, err := strconv.ParseBool(val); err == nil {
+			return boolVal
+		}
+		fmt.Fprintf(os.Stderr, "Warning: invalid boolean value for %s\n", key)
+	}
+	return defaultVal
+}
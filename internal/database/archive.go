@@ -0,0 +1,138 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// ListArchivable returns up to limit requests created before the given time
+// that are still stored in full, oldest first. Requests restored from the
+// archive are left alone until restoredBefore.
+func (db *DB) ListArchivable(before, restoredBefore time.Time, limit int) ([]*Request, error) {
+	rows, err := db.conn.Query(
+		"SELECT "+requestColumns+" FROM requests WHERE archived_at IS NULL AND created_at < ? AND (restored_at IS NULL OR restored_at < ?) ORDER BY created_at, rowid LIMIT ?",
+		before.UTC().Format(sqliteTimeFormat), restoredBefore.UTC().Format(sqliteTimeFormat), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query archivable requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*Request
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan request: %w", err)
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
+
+// MarkArchived turns a request into a stub pointing at its archived record
+// under key: its headers, bodies, recorded stream chunks and binary file
+// records are dropped, while the rows stay so listings, stats and spend still count it.
+// It returns the paths of the files whose records were dropped, for the
+// caller to delete.
+func (db *DB) MarkArchived(requestID, key string) ([]string, error) {
+	db.waitStored(requestID)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result := &PurgeResult{}
+	if err := collectFiles(tx, result, "SELECT file_path, size FROM binary_files WHERE request_id = ?", requestID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM binary_files WHERE request_id = ?", requestID); err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to drop binary files: %w", err))
+	}
+	if _, err := tx.Exec("DELETE FROM response_chunks WHERE request_id = ?", requestID); err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to drop response chunks: %w", err))
+	}
+	if _, err := tx.Exec("UPDATE responses SET headers = '{}', body = '', body_gz = NULL, message = NULL WHERE request_id = ?", requestID); err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to drop response payloads: %w", err))
+	}
+	_, err = tx.Exec("UPDATE requests SET headers = '{}', body = '', body_gz = NULL, archive_key = ?, archived_at = ?, restored_at = NULL WHERE id = ?",
+		key, time.Now().UTC().Format(sqliteTimeFormat), requestID)
+	if err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to drop request payloads: %w", err))
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to commit archived request: %w", err))
+	}
+	return result.FilePaths, nil
+}
+
+// RestoreArchived puts the headers and bodies of an archived request and its
+// responses back from its archived record, along with their recorded stream
+// chunks (by response ID) and its binary file records, whose files must
+// already be saved at their FilePath
+func (db *DB) RestoreArchived(req *Request, responses []*Response, chunks map[string][]*ResponseChunk, files []*BinaryFile) error {
+	headerJSON, err := headersToJSON(req.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal headers: %w", err)
+	}
+
+	body, compressedBody := db.packBody(req.Body)
+	db.waitStored(req.ID)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE requests SET headers = ?, body = ?, body_gz = ?, archived_at = NULL, restored_at = ? WHERE id = ?",
+		headerJSON, body, compressedBody, time.Now().UTC().Format(sqliteTimeFormat), req.ID)
+	if err != nil {
+		return db.writeFailed(fmt.Errorf("failed to restore request: %w", err))
+	}
+	for _, resp := range responses {
+		respHeaderJSON, err := headersToJSON(resp.Headers)
+		if err != nil {
+			return fmt.Errorf("failed to marshal headers: %w", err)
+		}
+		respBody, respCompressedBody := db.packBody(resp.Body)
+		_, err = tx.Exec("UPDATE responses SET headers = ?, body = ?, body_gz = ?, message = ? WHERE id = ? AND request_id = ?",
+			respHeaderJSON, respBody, respCompressedBody, nullString(string(resp.Message)), resp.ID, req.ID)
+		if err != nil {
+			return db.writeFailed(fmt.Errorf("failed to restore response: %w", err))
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM response_chunks WHERE request_id = ?", req.ID); err != nil {
+		return db.writeFailed(fmt.Errorf("failed to clear response chunks: %w", err))
+	}
+	for _, resp := range responses {
+		for _, chunk := range chunks[resp.ID] {
+			_, err := tx.Exec("INSERT INTO response_chunks (response_id, request_id, sequence, offset_ms, data) VALUES (?, ?, ?, ?, ?)",
+				resp.ID, req.ID, chunk.Sequence, chunk.OffsetMs, chunk.Data)
+			if err != nil {
+				return db.writeFailed(fmt.Errorf("failed to restore response chunk: %w", err))
+			}
+		}
+	}
+	for _, file := range files {
+		_, err := tx.Exec(
+			"INSERT OR IGNORE INTO binary_files (id, request_id, response_id, file_path, content_type, size, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			file.ID, req.ID, file.ResponseID, file.FilePath, file.ContentType, file.Size, file.CreatedAt.UTC().Format(sqliteTimeFormat),
+		)
+		if err != nil {
+			return db.writeFailed(fmt.Errorf("failed to restore binary file: %w", err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return db.writeFailed(fmt.Errorf("failed to commit restored request: %w", err))
+	}
+	return nil
+}
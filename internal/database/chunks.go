@@ -0,0 +1,61 @@
+package database
+
+import "fmt"
+
+// StoreResponseChunks stores the chunks a streamed response arrived in
+func (db *DB) StoreResponseChunks(requestID, responseID string, chunks []*ResponseChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return db.writeFailed(fmt.Errorf("failed to begin transaction: %w", err))
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO response_chunks (response_id, request_id, sequence, offset_ms, data) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return db.writeFailed(fmt.Errorf("failed to prepare chunk insert: %w", err))
+	}
+	defer stmt.Close()
+
+	for _, chunk := range chunks {
+		if _, err := stmt.Exec(responseID, requestID, chunk.Sequence, chunk.OffsetMs, chunk.Data); err != nil {
+			return db.writeFailed(fmt.Errorf("failed to store response chunk: %w", err))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return db.writeFailed(fmt.Errorf("failed to commit response chunks: %w", err))
+	}
+	return nil
+}
+
+// GetResponseChunks retrieves the stored chunks of a streamed response in
+// arrival order
+func (db *DB) GetResponseChunks(responseID string) ([]*ResponseChunk, error) {
+	rows, err := db.conn.Query(
+		"SELECT sequence, offset_ms, data FROM response_chunks WHERE response_id = ? ORDER BY sequence",
+		responseID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query response chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []*ResponseChunk
+	for rows.Next() {
+		var chunk ResponseChunk
+		if err := rows.Scan(&chunk.Sequence, &chunk.OffsetMs, &chunk.Data); err != nil {
+			return nil, fmt.Errorf("failed to scan response chunk: %w", err)
+		}
+		chunks = append(chunks, &chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating response chunks: %w", err)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverName is the SQLite driver with the gateway's SQL functions
+const driverName = "sqlite3_aigw"
+
+func init() {
+	// gunzip(body_gz) lets search and other SQL read compressed bodies
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("gunzip", gunzipSQL, true)
+		},
+	})
+}
+
+// SetBodyCompression makes request and response bodies larger than size
+// bytes be stored gzipped; 0 stores every body as text
+func (db *DB) SetBodyCompression(size int) {
+	db.compressOver = size
+}
+
+// packBody returns the body and compressed body columns of a body: a body
+// under the threshold, or one that doesn't shrink, is stored as text
+func (db *DB) packBody(body string) (string, []byte) {
+	if db.compressOver <= 0 || len(body) <= db.compressOver {
+		return body, nil
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(gz, body); err != nil {
+		return body, nil
+	}
+	if err := gz.Close(); err != nil || buf.Len() >= len(body) {
+		return body, nil
+	}
+	return "", buf.Bytes()
+}
+
+// unpackBody returns the body stored in the body and compressed body columns
+func unpackBody(body string, compressed []byte) (string, error) {
+	if compressed == nil {
+		return body, nil
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress body: %w", err)
+	}
+	defer gz.Close()
+
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress body: %w", err)
+	}
+	return string(data), nil
+}
+
+// gunzipSQL implements the gunzip SQL function, returning NULL for NULL or
+// corrupt input
+func gunzipSQL(value interface{}) interface{} {
+	compressed, ok := value.([]byte)
+	if !ok || len(compressed) == 0 {
+		return nil
+	}
+	body, err := unpackBody("", compressed)
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+// bodyExpr is the SQL expression for the body of a row of requests or
+// responses, compressed or not
+func bodyExpr(prefix string) string {
+	return "COALESCE(gunzip(" + prefix + "body_gz), " + prefix + "body)"
+}
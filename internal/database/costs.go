@@ -0,0 +1,80 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// CostSummaryParams contains filter parameters for aggregating costs
+type CostSummaryParams struct {
+	Provider     string
+	VirtualKeyID string
+	DateFrom     time.Time
+	DateTo       time.Time
+}
+
+// CostSummaryRow is the aggregated usage and cost of one provider/model on one day (UTC)
+type CostSummaryRow struct {
+	Day             string  `json:"day"`
+	Provider        string  `json:"provider"`
+	Model           string  `json:"model"`
+	Responses       int     `json:"responses"`
+	InputTokens     int     `json:"input_tokens"`
+	OutputTokens    int     `json:"output_tokens"`
+	CachedTokens    int     `json:"cached_tokens"`
+	ReasoningTokens int     `json:"reasoning_tokens"`
+	CostUSD         float64 `json:"cost_usd"`
+	Unpriced        int     `json:"unpriced"` // Responses with usage but no known price
+}
+
+// CostSummary aggregates response usage and cost by day, provider and model
+func (db *DB) CostSummary(params *CostSummaryParams) ([]*CostSummaryRow, error) {
+	query := `SELECT date(r.created_at), q.provider, COALESCE(r.model, ''), COUNT(*),
+		COALESCE(SUM(r.input_tokens), 0), COALESCE(SUM(r.output_tokens), 0),
+		COALESCE(SUM(r.cached_tokens), 0), COALESCE(SUM(r.reasoning_tokens), 0), COALESCE(SUM(r.cost_usd), 0),
+		SUM(CASE WHEN r.model IS NOT NULL AND r.cost_usd IS NULL THEN 1 ELSE 0 END)
+		FROM responses r JOIN requests q ON q.id = r.request_id WHERE 1=1`
+	args := []interface{}{}
+
+	if params.Provider != "" {
+		query += " AND q.provider = ?"
+		args = append(args, params.Provider)
+	}
+	if params.VirtualKeyID != "" {
+		query += " AND q.virtual_key_id = ?"
+		args = append(args, params.VirtualKeyID)
+	}
+	if !params.DateFrom.IsZero() {
+		query += " AND r.created_at >= ?"
+		args = append(args, params.DateFrom.UTC().Format(sqliteTimeFormat))
+	}
+	if !params.DateTo.IsZero() {
+		query += " AND r.created_at <= ?"
+		args = append(args, params.DateTo.UTC().Format(sqliteTimeFormat))
+	}
+
+	query += " GROUP BY 1, 2, 3 ORDER BY 1 DESC, 9 DESC"
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query costs: %w", err)
+	}
+	defer rows.Close()
+
+	summary := []*CostSummaryRow{}
+	for rows.Next() {
+		var row CostSummaryRow
+		err := rows.Scan(&row.Day, &row.Provider, &row.Model, &row.Responses,
+			&row.InputTokens, &row.OutputTokens, &row.CachedTokens, &row.ReasoningTokens, &row.CostUSD, &row.Unpriced)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cost row: %w", err)
+		}
+		summary = append(summary, &row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating costs: %w", err)
+	}
+
+	return summary, nil
+}
@@ -3,14 +3,17 @@ package database
 import (
 	"database/sql"
 	"embed"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 //go:embed migrations/*.sql
@@ -18,7 +21,17 @@ var migrationFS embed.FS
 
 type DB struct {
 	conn *sql.DB
-	mu   sync.RWMutex
+	mu   sync.Mutex // Serializes writes; reads run concurrently under WAL
+
+	writeErrors atomic.Int64
+
+	sampling   []SamplingRule
+	sampledOut sync.Map // IDs of stored requests whose payloads are dropped if they succeed
+	redactor   Redactor
+	fts        bool // Bodies are indexed for full-text search
+	writer     *writeBehind
+
+	compressOver int // Bodies larger than this many bytes are stored gzipped; 0 = never
 }
 
 // New creates a new database connection and runs migrations
@@ -42,7 +55,11 @@ func New(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("database path %s exists but is not a directory", dirPath)
 	}
 
-	conn, err := sql.Open("sqlite3", absPath)
+	// WAL lets reads run alongside a write. Wait for locks rather than
+	// failing at once: during an in-place upgrade the old and the new process
+	// both write, and transactions take the write lock up front so they wait
+	// for it instead of failing when upgrading from a read lock.
+	conn, err := sql.Open(driverName, absPath+"?_busy_timeout=5000&_journal_mode=WAL&_synchronous=NORMAL&_txlock=immediate")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database at %s: %w", absPath, err)
 	}
@@ -65,6 +82,10 @@ func New(dbPath string) (*DB, error) {
 		conn.Close()
 		return nil, fmt.Errorf("migration failed: %w", err)
 	}
+	if db.fts, err = db.setupSearch(); err != nil {
+		conn.Close()
+		return nil, err
+	}
 
 	return db, nil
 }
@@ -73,6 +94,41 @@ func (db *DB) migrate() error {
 	migrations := []string{
 		"migrations/001_init.sql",
 		"migrations/002_add_error_fields.sql",
+		"migrations/003_add_route_rule.sql",
+		"migrations/004_add_virtual_keys.sql",
+		"migrations/005_add_rate_limits.sql",
+		"migrations/006_add_usage_and_budgets.sql",
+		"migrations/007_add_secret_findings.sql",
+		"migrations/008_add_federation.sql",
+		"migrations/009_add_usage_details.sql",
+		"migrations/010_add_playback.sql",
+		"migrations/011_add_response_cache.sql",
+		"migrations/012_add_soft_delete.sql",
+		"migrations/013_add_request_models.sql",
+		"migrations/014_add_fine_tune_jobs.sql",
+		"migrations/015_add_follow_ups.sql",
+		"migrations/016_add_mirrors.sql",
+		"migrations/017_add_overrides.sql",
+		"migrations/018_add_stale_while_revalidate.sql",
+		"migrations/019_add_sampled_out.sql",
+		"migrations/020_add_stream_reconstruction.sql",
+		"migrations/021_add_response_chunks.sql",
+		"migrations/022_add_response_warnings.sql",
+		"migrations/023_add_ttft.sql",
+		"migrations/024_add_cancelled.sql",
+		"migrations/025_add_timeout.sql",
+		"migrations/026_add_moderation.sql",
+		"migrations/027_add_risk_score.sql",
+		"migrations/028_add_edited_responses.sql",
+		"migrations/029_add_tags.sql",
+		"migrations/030_add_notes.sql",
+		"migrations/031_add_starred.sql",
+		"migrations/032_add_archive.sql",
+		"migrations/033_add_provider_index.sql",
+		"migrations/034_add_compressed_bodies.sql",
+		"migrations/035_add_client_info.sql",
+		"migrations/036_add_request_payloads.sql",
+		"migrations/037_add_file_access.sql",
 	}
 
 	for _, migrationFile := range migrations {
@@ -105,6 +161,17 @@ func (db *DB) migrate() error {
 	return nil
 }
 
+// WriteErrorCount returns how many times storing a request, response or binary file failed
+func (db *DB) WriteErrorCount() int64 {
+	return db.writeErrors.Load()
+}
+
+// writeFailed counts a failed write and returns err
+func (db *DB) writeFailed(err error) error {
+	db.writeErrors.Add(1)
+	return err
+}
+
 // hasMigrationBeenRun checks if a migration has already been executed
 func (db *DB) hasMigrationBeenRun(name string) (bool, error) {
 	// Create migrations_history table if it doesn't exist
@@ -133,28 +200,69 @@ func (db *DB) recordMigration(name string) error {
 	return err
 }
 
-// Close closes the database connection
+// Close stores any queued writes and closes the database connection
 func (db *DB) Close() error {
+	db.closeWriter()
 	return db.conn.Close()
 }
 
 // StoreRequest stores a request in the database
 func (db *DB) StoreRequest(input *StoreRequestInput) (string, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	id := uuid.New().String()
-	headerJSON, err := headersToJSON(input.Headers)
+	headers, body := db.redactRequest(input.Headers, input.Body)
+	headerJSON, err := headersToJSON(headers)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal headers: %w", err)
 	}
 
-	_, err = db.conn.Exec(
-		"INSERT INTO requests (id, provider, endpoint, method, headers, body) VALUES (?, ?, ?, ?, ?, ?)",
-		id, input.Provider, input.Endpoint, input.Method, headerJSON, input.Body,
-	)
+	var secretFindings sql.NullString
+	if len(input.SecretFindings) > 0 {
+		data, err := json.Marshal(input.SecretFindings)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal secret findings: %w", err)
+		}
+		secretFindings = nullString(string(data))
+	}
+	overrides, err := overridesToJSON(input.Overrides)
+	if err != nil {
+		return "", err
+	}
+	moderation, err := moderationToJSON(input.Moderation)
 	if err != nil {
-		return "", fmt.Errorf("failed to store request: %w", err)
+		return "", err
+	}
+	riskRules, err := riskRulesToJSON(input.RiskRules)
+	if err != nil {
+		return "", err
+	}
+	payload, err := payloadToJSON(input.Payload)
+	if err != nil {
+		return "", err
+	}
+
+	storedBody, compressedBody := db.packBody(body)
+	createdAt, createdAtText := storedNow()
+	err = db.write(&queuedWrite{
+		keys: []string{id},
+		insert: func(exec execer) error {
+			_, err := exec.Exec(
+				"INSERT INTO requests (id, provider, endpoint, query, method, headers, body, body_gz, payload, client_ip, forwarded_for, user_agent, route_rule, virtual_key_id, rejection_reason, secret_findings, fingerprint, replayed_from, requested_model, routed_model, follow_up_of, mirror_of, revalidation_of, overrides, moderation, risk_score, risk_rules, session_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+				id, input.Provider, input.Endpoint, nullString(input.Query), input.Method, headerJSON, storedBody, compressedBody, payload, nullString(input.ClientIP), nullString(input.ForwardedFor), nullString(input.UserAgent), input.RouteRule, nullString(input.VirtualKeyID), nullString(input.RejectionReason),
+				secretFindings, nullString(input.Fingerprint), nullString(input.ReplayedFrom), nullString(input.RequestedModel), nullString(input.RoutedModel),
+				nullString(input.FollowUpOf), nullString(input.MirrorOf), nullString(input.RevalidationOf), overrides, moderation, input.RiskScore, riskRules, nullString(input.SessionID), createdAtText,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to store request: %w", err)
+			}
+			return insertTags(exec, id, input.Tags)
+		},
+		request: queuedRequest(id, input, headers, body, createdAt),
+	})
+	if err != nil {
+		return "", err
+	}
+	if db.sampleOut(input) {
+		db.sampledOut.Store(id, struct{}{})
 	}
 
 	return id, nil
@@ -162,21 +270,33 @@ func (db *DB) StoreRequest(input *StoreRequestInput) (string, error) {
 
 // StoreResponse stores a response in the database
 func (db *DB) StoreResponse(input *StoreResponseInput) (string, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	id := uuid.New().String()
-	headerJSON, err := headersToJSON(input.Headers)
+	headers := db.redactHeaders(input.Headers)
+	headerJSON, err := headersToJSON(headers)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal headers: %w", err)
 	}
 
-	_, err = db.conn.Exec(
-		"INSERT INTO responses (id, request_id, status_code, headers, body, duration_ms, is_error, error_message) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		id, input.RequestID, input.StatusCode, headerJSON, input.Body, input.DurationMs, input.IsError, input.ErrorMessage,
-	)
+	storedBody, compressedBody := db.packBody(input.Body)
+	createdAt, createdAtText := storedNow()
+	err = db.write(&queuedWrite{
+		keys: []string{id, input.RequestID},
+		insert: func(exec execer) error {
+			_, err := exec.Exec(
+				"INSERT INTO responses (id, request_id, status_code, headers, body, body_gz, duration_ms, is_error, error_message, model, input_tokens, output_tokens, cached_tokens, reasoning_tokens, cost_usd, cached, stale, message, finish_reason, warnings, ttft_ms, cancelled, timeout, edited_from, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+				id, input.RequestID, input.StatusCode, headerJSON, storedBody, compressedBody, input.DurationMs, input.IsError, input.ErrorMessage,
+				nullString(input.Model), input.InputTokens, input.OutputTokens, input.CachedTokens, input.ReasoningTokens, input.CostUSD, input.Cached, input.Stale,
+				nullString(input.Message), nullString(input.FinishReason), nullString(input.Warnings), input.TTFTMs, input.Cancelled, nullString(input.Timeout), nullString(input.EditedFrom), createdAtText,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to store response: %w", err)
+			}
+			return nil
+		},
+		response: queuedResponse(id, input, headers, createdAt),
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to store response: %w", err)
+		return "", err
 	}
 
 	return id, nil
@@ -194,7 +314,7 @@ func (db *DB) StoreBinaryFile(requestID, responseID, filePath, contentType strin
 		id, requestID, responseID, filePath, contentType, size,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to store binary file: %w", err)
+		return "", db.writeFailed(fmt.Errorf("failed to store binary file: %w", err))
 	}
 
 	return id, nil
@@ -202,24 +322,204 @@ func (db *DB) StoreBinaryFile(requestID, responseID, filePath, contentType strin
 
 // GetRequest retrieves a request by ID
 func (db *DB) GetRequest(id string) (*Request, error) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	if w := db.pendingWrite(id); w != nil {
+		if w.request != nil {
+			req := *w.request
+			return &req, nil
+		}
+		<-w.stored
+	}
 
 	row := db.conn.QueryRow(
-		"SELECT id, provider, endpoint, method, headers, body, created_at FROM requests WHERE id = ?",
+		"SELECT "+requestColumns+" FROM requests WHERE id = ?",
 		id,
 	)
 
-	var req Request
-	var headerJSON string
-
-	err := row.Scan(&req.ID, &req.Provider, &req.Endpoint, &req.Method, &headerJSON, &req.Body, &req.CreatedAt)
+	req, err := scanRequest(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("request not found")
 		}
 		return nil, fmt.Errorf("failed to get request: %w", err)
 	}
+	if err := db.loadTags([]*Request{req}); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// ListFollowUps returns the IDs of the follow-up requests the gateway sent to
+// continue a request, oldest first
+func (db *DB) ListFollowUps(requestID string) ([]string, error) {
+	return db.listLinkedRequests("follow_up_of", requestID)
+}
+
+// ListMirrors returns the IDs of the copies of a request the gateway mirrored
+// to other providers
+func (db *DB) ListMirrors(requestID string) ([]string, error) {
+	return db.listLinkedRequests("mirror_of", requestID)
+}
+
+// ListRevalidations returns the IDs of the requests the gateway sent in the
+// background to refresh the stale cached response a request was served
+func (db *DB) ListRevalidations(requestID string) ([]string, error) {
+	return db.listLinkedRequests("revalidation_of", requestID)
+}
+
+// listLinkedRequests returns the IDs of requests whose link column points at
+// requestID, oldest first
+func (db *DB) listLinkedRequests(column, requestID string) ([]string, error) {
+	rows, err := db.conn.Query("SELECT id FROM requests WHERE "+column+" = ? ORDER BY created_at, rowid", requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list linked requests: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan linked request: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// requestColumns is the column list scanned by scanRequest
+const requestColumns = "id, provider, endpoint, query, method, headers, body, body_gz, payload, client_ip, forwarded_for, user_agent, route_rule, virtual_key_id, rejection_reason, secret_findings, source, replayed_from, requested_model, routed_model, follow_up_of, mirror_of, revalidation_of, overrides, moderation, risk_score, risk_rules, session_id, starred, sampled_out, archive_key, archived_at, deleted_at, created_at"
+
+// nullString converts an empty string to NULL for optional columns
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// overridesToJSON encodes applied override headers, or NULL if there are none
+func overridesToJSON(overrides map[string]string) (sql.NullString, error) {
+	if len(overrides) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to marshal overrides: %w", err)
+	}
+	return nullString(string(data)), nil
+}
+
+// payloadToJSON encodes the summary of a body stored as files, or NULL if
+// the body was stored as text
+func payloadToJSON(payload *RequestPayload) (sql.NullString, error) {
+	if payload == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	return nullString(string(data)), nil
+}
+
+// moderationToJSON encodes a moderation verdict, or NULL if there is none
+func moderationToJSON(moderation *ModerationResult) (sql.NullString, error) {
+	if moderation == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(moderation)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to marshal moderation result: %w", err)
+	}
+	return nullString(string(data)), nil
+}
+
+// riskRulesToJSON encodes the rules behind a risk score, or NULL if none matched
+func riskRulesToJSON(rules []string) (sql.NullString, error) {
+	if len(rules) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to marshal risk rules: %w", err)
+	}
+	return nullString(string(data)), nil
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRequest scans a row selected with requestColumns into a Request
+func scanRequest(row rowScanner) (*Request, error) {
+	var req Request
+	var headerJSON string
+	var compressedBody []byte
+	var query, payload, clientIP, forwardedFor, userAgent sql.NullString
+	var routeRule, virtualKeyID, rejectionReason, secretFindings, source, replayedFrom, requestedModel, routedModel, followUpOf, mirrorOf, revalidationOf, overrides, moderation, riskRules, sessionID, archiveKey sql.NullString
+	var riskScore sql.NullFloat64
+	var archivedAt, deletedAt sql.NullTime
+
+	err := row.Scan(&req.ID, &req.Provider, &req.Endpoint, &query, &req.Method, &headerJSON, &req.Body, &compressedBody, &payload, &clientIP, &forwardedFor, &userAgent, &routeRule, &virtualKeyID, &rejectionReason,
+		&secretFindings, &source, &replayedFrom, &requestedModel, &routedModel, &followUpOf, &mirrorOf, &revalidationOf, &overrides, &moderation, &riskScore, &riskRules, &sessionID, &req.Starred, &req.SampledOut, &archiveKey, &archivedAt, &deletedAt, &req.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body, err = unpackBody(req.Body, compressedBody); err != nil {
+		return nil, err
+	}
+
+	req.Query = query.String
+	if payload.Valid {
+		req.Payload = &RequestPayload{}
+		if err := json.Unmarshal([]byte(payload.String), req.Payload); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+	}
+	req.ClientIP = clientIP.String
+	req.ForwardedFor = forwardedFor.String
+	req.UserAgent = userAgent.String
+	req.RouteRule = routeRule.String
+	req.VirtualKeyID = virtualKeyID.String
+	req.RejectionReason = rejectionReason.String
+	req.Source = source.String
+	req.ReplayedFrom = replayedFrom.String
+	req.RequestedModel = requestedModel.String
+	req.RoutedModel = routedModel.String
+	req.FollowUpOf = followUpOf.String
+	req.MirrorOf = mirrorOf.String
+	req.RevalidationOf = revalidationOf.String
+	req.SessionID = sessionID.String
+	req.ArchiveKey = archiveKey.String
+	if archivedAt.Valid {
+		req.ArchivedAt = &archivedAt.Time
+	}
+	if deletedAt.Valid {
+		req.DeletedAt = &deletedAt.Time
+	}
+
+	if secretFindings.Valid {
+		if err := json.Unmarshal([]byte(secretFindings.String), &req.SecretFindings); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal secret findings: %w", err)
+		}
+	}
+	if overrides.Valid {
+		if err := json.Unmarshal([]byte(overrides.String), &req.Overrides); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal overrides: %w", err)
+		}
+	}
+	if moderation.Valid {
+		if err := json.Unmarshal([]byte(moderation.String), &req.Moderation); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal moderation result: %w", err)
+		}
+	}
+	if riskScore.Valid {
+		req.RiskScore = &riskScore.Float64
+	}
+	if riskRules.Valid {
+		if err := json.Unmarshal([]byte(riskRules.String), &req.RiskRules); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal risk rules: %w", err)
+		}
+	}
 
 	if headerJSON != "" {
 		headers, err := headersFromJSON(headerJSON)
@@ -234,19 +534,17 @@ func (db *DB) GetRequest(id string) (*Request, error) {
 
 // GetResponse retrieves a response by ID
 func (db *DB) GetResponse(id string) (*Response, error) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	if w := db.pendingWrite(id); w != nil && w.response != nil && w.response.ID == id {
+		resp := *w.response
+		return &resp, nil
+	}
 
 	row := db.conn.QueryRow(
-		"SELECT id, request_id, status_code, headers, body, duration_ms, is_error, error_message, created_at FROM responses WHERE id = ?",
+		"SELECT "+responseColumns+" FROM responses WHERE id = ?",
 		id,
 	)
 
-	var resp Response
-	var headerJSON string
-	var errorMessage sql.NullString
-
-	err := row.Scan(&resp.ID, &resp.RequestID, &resp.StatusCode, &headerJSON, &resp.Body, &resp.DurationMs, &resp.IsError, &errorMessage, &resp.CreatedAt)
+	resp, err := scanResponse(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("response not found")
@@ -254,10 +552,55 @@ func (db *DB) GetResponse(id string) (*Response, error) {
 		return nil, fmt.Errorf("failed to get response: %w", err)
 	}
 
+	return resp, nil
+}
+
+// responseColumns is the column list scanned by scanResponse
+const responseColumns = "id, request_id, status_code, headers, body, body_gz, duration_ms, is_error, error_message, model, input_tokens, output_tokens, cached_tokens, reasoning_tokens, cost_usd, cached, stale, message, finish_reason, warnings, ttft_ms, cancelled, timeout, edited_from, created_at"
+
+// scanResponse scans a row selected with responseColumns
+func scanResponse(row rowScanner) (*Response, error) {
+	var resp Response
+	var headerJSON string
+	var compressedBody []byte
+	var errorMessage, model, message, finishReason, warnings, timeout, editedFrom sql.NullString
+	var inputTokens, outputTokens, cachedTokens, reasoningTokens, ttftMs sql.NullInt64
+	var costUSD sql.NullFloat64
+
+	err := row.Scan(&resp.ID, &resp.RequestID, &resp.StatusCode, &headerJSON, &resp.Body, &compressedBody, &resp.DurationMs, &resp.IsError, &errorMessage,
+		&model, &inputTokens, &outputTokens, &cachedTokens, &reasoningTokens, &costUSD, &resp.Cached, &resp.Stale, &message, &finishReason, &warnings, &ttftMs, &resp.Cancelled, &timeout, &editedFrom, &resp.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Body, err = unpackBody(resp.Body, compressedBody); err != nil {
+		return nil, err
+	}
+
 	// Convert sql.NullString to *string
 	if errorMessage.Valid {
 		resp.ErrorMessage = &errorMessage.String
 	}
+	resp.Model = model.String
+	resp.InputTokens = int(inputTokens.Int64)
+	resp.OutputTokens = int(outputTokens.Int64)
+	resp.CachedTokens = int(cachedTokens.Int64)
+	resp.ReasoningTokens = int(reasoningTokens.Int64)
+	if costUSD.Valid {
+		resp.CostUSD = &costUSD.Float64
+	}
+	if message.Valid {
+		resp.Message = json.RawMessage(message.String)
+	}
+	resp.FinishReason = finishReason.String
+	if warnings.Valid {
+		resp.Warnings = json.RawMessage(warnings.String)
+	}
+	if ttftMs.Valid {
+		ttft := int(ttftMs.Int64)
+		resp.TTFTMs = &ttft
+	}
+	resp.Timeout = timeout.String
+	resp.EditedFrom = editedFrom.String
 
 	if headerJSON != "" {
 		headers, err := headersFromJSON(headerJSON)
@@ -270,21 +613,16 @@ func (db *DB) GetResponse(id string) (*Response, error) {
 	return &resp, nil
 }
 
-// GetResponseByRequestID retrieves the first response for a request
+// GetResponseByRequestID retrieves the final (most recently stored) response for a request
 func (db *DB) GetResponseByRequestID(requestID string) (*Response, error) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	db.waitStored(requestID)
 
 	row := db.conn.QueryRow(
-		"SELECT id, request_id, status_code, headers, body, duration_ms, is_error, error_message, created_at FROM responses WHERE request_id = ? LIMIT 1",
+		"SELECT "+responseColumns+" FROM responses WHERE request_id = ? ORDER BY rowid DESC LIMIT 1",
 		requestID,
 	)
 
-	var resp Response
-	var headerJSON string
-	var errorMessage sql.NullString
-
-	err := row.Scan(&resp.ID, &resp.RequestID, &resp.StatusCode, &headerJSON, &resp.Body, &resp.DurationMs, &resp.IsError, &errorMessage, &resp.CreatedAt)
+	resp, err := scanResponse(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("response not found")
@@ -292,114 +630,79 @@ func (db *DB) GetResponseByRequestID(requestID string) (*Response, error) {
 		return nil, fmt.Errorf("failed to get response: %w", err)
 	}
 
-	// Convert sql.NullString to *string
-	if errorMessage.Valid {
-		resp.ErrorMessage = &errorMessage.String
-	}
-
-	if headerJSON != "" {
-		headers, err := headersFromJSON(headerJSON)
-		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal headers: %w", err)
-		}
-		resp.Headers = headers
-	}
-
-	return &resp, nil
-}
-
-// ListRequestsParams contains filter parameters for listing requests
-type ListRequestsParams struct {
-	Provider    string
-	PathPattern string
-	DateFrom    time.Time
-	DateTo      time.Time
-	Limit       int
-	Offset      int
+	return resp, nil
 }
 
-// ListRequests returns a list of requests with optional filtering
-func (db *DB) ListRequests(params *ListRequestsParams) ([]*Request, error) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-
-	query := "SELECT id, provider, endpoint, method, headers, body, created_at FROM requests WHERE 1=1"
-	args := []interface{}{}
-
-	if params.Provider != "" {
-		query += " AND provider = ?"
-		args = append(args, params.Provider)
-	}
-
-	if params.PathPattern != "" {
-		query += " AND endpoint LIKE ?"
-		args = append(args, "%"+params.PathPattern+"%")
+// GetFinalResponses retrieves the final response of each of the given
+// requests, by request ID; requests without a response are left out
+func (db *DB) GetFinalResponses(requestIDs []string) (map[string]*Response, error) {
+	responses := make(map[string]*Response, len(requestIDs))
+	if len(requestIDs) == 0 {
+		return responses, nil
 	}
 
-	if !params.DateFrom.IsZero() {
-		query += " AND created_at >= ?"
-		args = append(args, params.DateFrom)
+	args := make([]interface{}, len(requestIDs))
+	for i, id := range requestIDs {
+		args[i] = id
 	}
-
-	if !params.DateTo.IsZero() {
-		query += " AND created_at <= ?"
-		args = append(args, params.DateTo)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(requestIDs)), ", ")
+	rows, err := db.conn.Query(
+		"SELECT "+responseColumns+" FROM responses WHERE rowid IN (SELECT MAX(rowid) FROM responses WHERE request_id IN ("+placeholders+") GROUP BY request_id)",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query responses: %w", err)
 	}
+	defer rows.Close()
 
-	query += " ORDER BY created_at DESC"
-
-	if params.Limit > 0 {
-		query += " LIMIT ?"
-		args = append(args, params.Limit)
+	for rows.Next() {
+		resp, err := scanResponse(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan response: %w", err)
+		}
+		responses[resp.RequestID] = resp
 	}
+	return responses, rows.Err()
+}
 
-	if params.Offset > 0 {
-		query += " OFFSET ?"
-		args = append(args, params.Offset)
-	}
+// GetResponsesByRequestID retrieves all responses for a request in the order they were stored.
+// A request has more than one response when intermediate redirect hops were captured.
+func (db *DB) GetResponsesByRequestID(requestID string) ([]*Response, error) {
+	db.waitStored(requestID)
 
-	rows, err := db.conn.Query(query, args...)
+	rows, err := db.conn.Query(
+		"SELECT "+responseColumns+" FROM responses WHERE request_id = ? ORDER BY rowid",
+		requestID,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query requests: %w", err)
+		return nil, fmt.Errorf("failed to query responses: %w", err)
 	}
 	defer rows.Close()
 
-	var requests []*Request
+	var responses []*Response
 
 	for rows.Next() {
-		var req Request
-		var headerJSON string
-
-		err := rows.Scan(&req.ID, &req.Provider, &req.Endpoint, &req.Method, &headerJSON, &req.Body, &req.CreatedAt)
+		resp, err := scanResponse(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan request: %w", err)
-		}
-
-		if headerJSON != "" {
-			headers, err := headersFromJSON(headerJSON)
-			if err != nil {
-				return nil, fmt.Errorf("failed to unmarshal headers: %w", err)
-			}
-			req.Headers = headers
+			return nil, fmt.Errorf("failed to scan response: %w", err)
 		}
 
-		requests = append(requests, &req)
+		responses = append(responses, resp)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating requests: %w", err)
+		return nil, fmt.Errorf("error iterating responses: %w", err)
 	}
 
-	return requests, nil
+	return responses, nil
 }
 
 // GetBinaryFilesByRequestID retrieves all binary files for a request
 func (db *DB) GetBinaryFilesByRequestID(requestID string) ([]*BinaryFile, error) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	db.waitStored(requestID)
 
 	rows, err := db.conn.Query(
-		"SELECT id, request_id, response_id, file_path, content_type, size, created_at FROM binary_files WHERE request_id = ? ORDER BY created_at",
+		"SELECT id, request_id, response_id, file_path, content_type, size, created_at FROM binary_files WHERE request_id = ? ORDER BY created_at, rowid",
 		requestID,
 	)
 	if err != nil {
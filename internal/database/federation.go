@@ -0,0 +1,177 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ListUnsyncedRequests returns requests not yet forwarded to the federation
+// aggregator whose records have settled: their latest response is older than
+// settle, or they are older than abandonAfter without a response.
+func (db *DB) ListUnsyncedRequests(limit int, settle, abandonAfter time.Duration) ([]*Request, error) {
+	now := time.Now().UTC()
+	rows, err := db.conn.Query(
+		"SELECT "+requestColumns+" FROM requests q WHERE synced_at IS NULL AND ("+
+			"(SELECT MAX(created_at) FROM responses WHERE request_id = q.id) <= ? OR created_at <= ?"+
+			") ORDER BY created_at LIMIT ?",
+		now.Add(-settle).Format(sqliteTimeFormat), now.Add(-abandonAfter).Format(sqliteTimeFormat), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unsynced requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := []*Request{}
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan request: %w", err)
+		}
+		requests = append(requests, req)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating requests: %w", err)
+	}
+	if err := db.loadTags(requests); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// MarkRequestsSynced records that requests were forwarded to the federation aggregator
+func (db *DB) MarkRequestsSynced(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	db.waitAllStored(ids)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	args := []interface{}{time.Now().UTC().Format(sqliteTimeFormat)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	_, err := db.conn.Exec("UPDATE requests SET synced_at = ? WHERE id IN ("+placeholders+")", args...)
+	if err != nil {
+		return fmt.Errorf("failed to mark requests synced: %w", err)
+	}
+	return nil
+}
+
+// IngestRecord stores a request and its responses recorded by another gateway
+// instance, keeping their original IDs and timestamps. It returns false if the
+// request was already ingested, in which case nothing is changed.
+func (db *DB) IngestRecord(source string, req *Request, responses []*Response) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	headers, body := db.redactRequest(req.Headers, req.Body)
+	headerJSON, err := headersToJSON(headers)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal headers: %w", err)
+	}
+	body, compressedBody := db.packBody(body)
+
+	var secretFindings *string
+	if len(req.SecretFindings) > 0 {
+		data, err := json.Marshal(req.SecretFindings)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal secret findings: %w", err)
+		}
+		findings := string(data)
+		secretFindings = &findings
+	}
+
+	overrides, err := overridesToJSON(req.Overrides)
+	if err != nil {
+		return false, err
+	}
+	moderation, err := moderationToJSON(req.Moderation)
+	if err != nil {
+		return false, err
+	}
+	riskRules, err := riskRulesToJSON(req.RiskRules)
+	if err != nil {
+		return false, err
+	}
+	payload, err := payloadToJSON(req.Payload)
+	if err != nil {
+		return false, err
+	}
+
+	// Keep the source of records relayed from an aggregator that is itself an edge
+	if req.Source != "" {
+		source = req.Source
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT OR IGNORE INTO requests (id, provider, endpoint, query, method, headers, body, body_gz, payload, client_ip, forwarded_for, user_agent, route_rule, virtual_key_id, rejection_reason, secret_findings, source, replayed_from, requested_model, routed_model, follow_up_of, mirror_of, revalidation_of, overrides, moderation, risk_score, risk_rules, session_id, sampled_out, created_at) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		req.ID, req.Provider, req.Endpoint, nullString(req.Query), req.Method, headerJSON, body, compressedBody, payload, nullString(req.ClientIP), nullString(req.ForwardedFor), nullString(req.UserAgent), req.RouteRule, nullString(req.VirtualKeyID),
+		nullString(req.RejectionReason), secretFindings, nullString(source), nullString(req.ReplayedFrom),
+		nullString(req.RequestedModel), nullString(req.RoutedModel), nullString(req.FollowUpOf), nullString(req.MirrorOf), nullString(req.RevalidationOf), overrides, moderation, req.RiskScore, riskRules, nullString(req.SessionID), req.SampledOut,
+		req.CreatedAt.UTC().Format(sqliteTimeFormat),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to ingest request: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if err := insertTags(tx, req.ID, req.Tags); err != nil {
+		return false, err
+	}
+
+	for _, resp := range responses {
+		respHeaderJSON, err := headersToJSON(db.redactHeaders(resp.Headers))
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal headers: %w", err)
+		}
+		respBody, respCompressedBody := db.packBody(resp.Body)
+
+		_, err = tx.Exec(
+			"INSERT OR IGNORE INTO responses (id, request_id, status_code, headers, body, body_gz, duration_ms, is_error, error_message, model, input_tokens, output_tokens, cached_tokens, reasoning_tokens, cost_usd, cached, stale, message, finish_reason, warnings, ttft_ms, cancelled, timeout, edited_from, created_at) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			resp.ID, req.ID, resp.StatusCode, respHeaderJSON, respBody, respCompressedBody, resp.DurationMs, resp.IsError, resp.ErrorMessage,
+			nullString(resp.Model), resp.InputTokens, resp.OutputTokens, resp.CachedTokens, resp.ReasoningTokens, resp.CostUSD, resp.Cached, resp.Stale,
+			nullString(string(resp.Message)), nullString(resp.FinishReason), nullString(string(resp.Warnings)), resp.TTFTMs, resp.Cancelled, nullString(resp.Timeout), nullString(resp.EditedFrom), resp.CreatedAt.UTC().Format(sqliteTimeFormat),
+		)
+		if err != nil {
+			return false, fmt.Errorf("failed to ingest response: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit ingested record: %w", err)
+	}
+	return true, nil
+}
+
+// IngestBinaryFile stores a binary file reference recorded by another gateway
+// instance, keeping its original ID. filePath is where the file was saved locally.
+func (db *DB) IngestBinaryFile(file *BinaryFile, filePath string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec(
+		"INSERT OR IGNORE INTO binary_files (id, request_id, response_id, file_path, content_type, size, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		file.ID, file.RequestID, file.ResponseID, filePath, file.ContentType, file.Size, file.CreatedAt.UTC().Format(sqliteTimeFormat),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to ingest binary file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// FileUsage counts stored binary files and their size
+type FileUsage struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// StorageStats summarizes the stored binary files on record
+type StorageStats struct {
+	FileUsage
+	ByProvider map[string]*FileUsage // Provider of the file's request
+	ByDay      map[string]*FileUsage // UTC day the file was stored, as YYYY-MM-DD
+}
+
+// GetStorageStats aggregates the binary files on record by provider and day
+func (db *DB) GetStorageStats() (*StorageStats, error) {
+	stats := &StorageStats{
+		ByProvider: make(map[string]*FileUsage),
+		ByDay:      make(map[string]*FileUsage),
+	}
+
+	rows, err := db.conn.Query(`SELECT COALESCE(q.provider, ''), COUNT(*), COALESCE(SUM(f.size), 0)
+		FROM binary_files f LEFT JOIN requests q ON q.id = f.request_id
+		GROUP BY 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate files by provider: %w", err)
+	}
+	for rows.Next() {
+		var provider string
+		usage := &FileUsage{}
+		if err := rows.Scan(&provider, &usage.Files, &usage.Bytes); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan file usage: %w", err)
+		}
+		stats.ByProvider[provider] = usage
+		stats.Files += usage.Files
+		stats.Bytes += usage.Bytes
+	}
+	rows.Close()
+
+	rows, err = db.conn.Query("SELECT date(created_at), COUNT(*), COALESCE(SUM(size), 0) FROM binary_files GROUP BY 1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate files by day: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day string
+		usage := &FileUsage{}
+		if err := rows.Scan(&day, &usage.Files, &usage.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan file usage: %w", err)
+		}
+		stats.ByDay[day] = usage
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating file usage: %w", err)
+	}
+	return stats, nil
+}
+
+// FindBinaryFile returns the record of a stored file and its position (from
+// 1) among the files of its request, or nil if no record has that path
+func (db *DB) FindBinaryFile(filePath string) (*BinaryFile, int, error) {
+	var file BinaryFile
+	var index int
+	err := db.conn.QueryRow(
+		"SELECT id, COALESCE(request_id, ''), COALESCE(response_id, ''), file_path, content_type, size, created_at, "+
+			"(SELECT COUNT(*) FROM binary_files o WHERE o.request_id = f.request_id AND (o.created_at < f.created_at OR o.created_at = f.created_at AND o.rowid <= f.rowid)) "+
+			"FROM binary_files f WHERE file_path = ? LIMIT 1",
+		filePath,
+	).Scan(&file.ID, &file.RequestID, &file.ResponseID, &file.FilePath, &file.ContentType, &file.Size, &file.CreatedAt, &index)
+	if err == sql.ErrNoRows {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find binary file: %w", err)
+	}
+	return &file, index, nil
+}
+
+// TouchBinaryFile records that a stored file was served, so the file quota
+// evicts it later
+func (db *DB) TouchBinaryFile(filePath string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, err := db.conn.Exec("UPDATE binary_files SET accessed_at = ? WHERE file_path = ?",
+		time.Now().UTC().Format(sqliteTimeFormat), filePath); err != nil {
+		return db.writeFailed(fmt.Errorf("failed to update binary file: %w", err))
+	}
+	return nil
+}
+
+// EvictBinaryFiles removes the records of up to n binary files, least
+// recently served (or stored, if never served) first. Their requests and
+// responses are kept; the stored files are left for the caller to delete.
+func (db *DB) EvictBinaryFiles(n int) (*PurgeResult, error) {
+	const selected = "id IN (SELECT id FROM binary_files ORDER BY COALESCE(accessed_at, created_at), rowid LIMIT ?)"
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result := &PurgeResult{}
+	if err := collectFiles(tx, result, "SELECT file_path, size FROM binary_files WHERE "+selected, n); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM binary_files WHERE "+selected, n); err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to evict binary files: %w", err))
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to commit eviction: %w", err))
+	}
+	return result, nil
+}
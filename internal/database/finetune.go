@@ -0,0 +1,212 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fineTuneJobColumns is the column list scanned by scanFineTuneJob
+const fineTuneJobColumns = "id, provider, request_id, url, model, training_file, training_request_id, status, fine_tuned_model, error, finished_at, created_at, updated_at"
+
+// StoreFineTuneJob starts tracking a fine-tuning job created through the
+// gateway and records its initial status as the first update. A job that is
+// already tracked is returned unchanged.
+func (db *DB) StoreFineTuneJob(input *StoreFineTuneJobInput) (*FineTuneJob, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to begin transaction: %w", err))
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT OR IGNORE INTO fine_tune_jobs (id, provider, request_id, url, model, training_file, training_request_id, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		input.ID, input.Provider, input.RequestID, input.URL, nullString(input.Model), nullString(input.TrainingFile),
+		nullString(input.TrainingRequestID), input.Status,
+	)
+	if err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to store fine-tune job: %w", err))
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		_, err = tx.Exec(
+			"INSERT INTO fine_tune_updates (id, job_id, status, body) VALUES (?, ?, ?, ?)",
+			uuid.New().String(), input.ID, input.Status, input.Body,
+		)
+		if err != nil {
+			return nil, db.writeFailed(fmt.Errorf("failed to store fine-tune update: %w", err))
+		}
+	}
+
+	job, err := scanFineTuneJob(tx.QueryRow("SELECT "+fineTuneJobColumns+" FROM fine_tune_jobs WHERE id = ?", input.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fine-tune job: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to commit fine-tune job: %w", err))
+	}
+	return job, nil
+}
+
+// UpdateFineTuneJob records the latest state of a tracked job. A status change
+// is stored as a new update; the returned flag reports whether there was one.
+func (db *DB) UpdateFineTuneJob(id string, input *UpdateFineTuneJobInput) (*FineTuneJob, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, false, db.writeFailed(fmt.Errorf("failed to begin transaction: %w", err))
+	}
+	defer tx.Rollback()
+
+	job, err := scanFineTuneJob(tx.QueryRow("SELECT "+fineTuneJobColumns+" FROM fine_tune_jobs WHERE id = ?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, fmt.Errorf("fine-tune job not found")
+		}
+		return nil, false, fmt.Errorf("failed to get fine-tune job: %w", err)
+	}
+	if job.Status == input.Status {
+		return job, false, nil
+	}
+
+	now := time.Now().UTC()
+	var finishedAt sql.NullString
+	if input.Finished {
+		finishedAt = sql.NullString{String: now.Format(sqliteTimeFormat), Valid: true}
+	}
+
+	_, err = tx.Exec(
+		"UPDATE fine_tune_jobs SET status = ?, fine_tuned_model = ?, error = ?, finished_at = ?, updated_at = ? WHERE id = ?",
+		input.Status, nullString(input.FineTunedModel), nullString(input.Error), finishedAt, now.Format(sqliteTimeFormat), id,
+	)
+	if err != nil {
+		return nil, false, db.writeFailed(fmt.Errorf("failed to update fine-tune job: %w", err))
+	}
+	_, err = tx.Exec(
+		"INSERT INTO fine_tune_updates (id, job_id, status, body) VALUES (?, ?, ?, ?)",
+		uuid.New().String(), id, input.Status, input.Body,
+	)
+	if err != nil {
+		return nil, false, db.writeFailed(fmt.Errorf("failed to store fine-tune update: %w", err))
+	}
+
+	job, err = scanFineTuneJob(tx.QueryRow("SELECT "+fineTuneJobColumns+" FROM fine_tune_jobs WHERE id = ?", id))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get fine-tune job: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, db.writeFailed(fmt.Errorf("failed to commit fine-tune update: %w", err))
+	}
+	return job, true, nil
+}
+
+// GetFineTuneJob retrieves a tracked fine-tuning job by its provider job ID
+func (db *DB) GetFineTuneJob(id string) (*FineTuneJob, error) {
+	job, err := scanFineTuneJob(db.conn.QueryRow("SELECT "+fineTuneJobColumns+" FROM fine_tune_jobs WHERE id = ?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("fine-tune job not found")
+		}
+		return nil, fmt.Errorf("failed to get fine-tune job: %w", err)
+	}
+	return job, nil
+}
+
+// ListFineTuneJobs lists tracked fine-tuning jobs, newest first. With
+// activeOnly, jobs that have finished are left out.
+func (db *DB) ListFineTuneJobs(activeOnly bool) ([]*FineTuneJob, error) {
+	query := "SELECT " + fineTuneJobColumns + " FROM fine_tune_jobs"
+	if activeOnly {
+		query += " WHERE finished_at IS NULL"
+	}
+	query += " ORDER BY created_at DESC"
+
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fine-tune jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []*FineTuneJob{}
+	for rows.Next() {
+		job, err := scanFineTuneJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan fine-tune job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// ListFineTuneUpdates lists the status changes of a job, oldest first
+func (db *DB) ListFineTuneUpdates(jobID string) ([]*FineTuneUpdate, error) {
+	rows, err := db.conn.Query(
+		"SELECT id, job_id, status, body, created_at FROM fine_tune_updates WHERE job_id = ? ORDER BY rowid",
+		jobID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fine-tune updates: %w", err)
+	}
+	defer rows.Close()
+
+	updates := []*FineTuneUpdate{}
+	for rows.Next() {
+		var update FineTuneUpdate
+		var body sql.NullString
+		if err := rows.Scan(&update.ID, &update.JobID, &update.Status, &body, &update.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan fine-tune update: %w", err)
+		}
+		update.Body = body.String
+		updates = append(updates, &update)
+	}
+	return updates, rows.Err()
+}
+
+// FindFileUploadRequest returns the ID of the most recent successful file
+// upload whose response names the given provider file ID, or empty if the
+// file wasn't uploaded through the gateway
+func (db *DB) FindFileUploadRequest(fileID string) (string, error) {
+	var id string
+	err := db.conn.QueryRow(
+		"SELECT r.id FROM requests r JOIN responses s ON s.request_id = r.id "+
+			"WHERE r.method = 'POST' AND r.endpoint LIKE '%/files' AND s.status_code < 300 AND "+bodyExpr("s.")+" LIKE ? "+
+			"ORDER BY r.created_at DESC LIMIT 1",
+		`%"`+fileID+`"%`,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find file upload: %w", err)
+	}
+	return id, nil
+}
+
+// scanFineTuneJob scans a row selected with fineTuneJobColumns
+func scanFineTuneJob(row rowScanner) (*FineTuneJob, error) {
+	var job FineTuneJob
+	var model, trainingFile, trainingRequestID, fineTunedModel, jobError sql.NullString
+	var finishedAt sql.NullTime
+
+	err := row.Scan(&job.ID, &job.Provider, &job.RequestID, &job.URL, &model, &trainingFile, &trainingRequestID,
+		&job.Status, &fineTunedModel, &jobError, &finishedAt, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Model = model.String
+	job.TrainingFile = trainingFile.String
+	job.TrainingRequestID = trainingRequestID.String
+	job.FineTunedModel = fineTunedModel.String
+	job.Error = jobError.String
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
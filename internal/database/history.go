@@ -0,0 +1,56 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MarkRequestsDeleted soft-deletes requests at the given time. They disappear
+// from listings but are kept, so listings as of an earlier time still include
+// them. It returns how many requests were newly marked.
+func (db *DB) MarkRequestsDeleted(ids []string, at time.Time) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	db.waitAllStored(ids)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := []interface{}{at.UTC().Format(sqliteTimeFormat)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	result, err := db.conn.Exec(
+		"UPDATE requests SET deleted_at = ? WHERE deleted_at IS NULL AND id IN ("+placeholders+")",
+		args...,
+	)
+	if err != nil {
+		return 0, db.writeFailed(fmt.Errorf("failed to mark requests deleted: %w", err))
+	}
+
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// GetResponseAsOf retrieves the response a request had at the given time: the
+// most recently stored one created no later than asOf, or nil if there was none
+func (db *DB) GetResponseAsOf(requestID string, asOf time.Time) (*Response, error) {
+	row := db.conn.QueryRow(
+		"SELECT "+responseColumns+" FROM responses WHERE request_id = ? AND created_at <= ? ORDER BY rowid DESC LIMIT 1",
+		requestID, asOf.UTC().Format(sqliteTimeFormat),
+	)
+
+	resp, err := scanResponse(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get response: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,248 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VirtualKeyPrefix is the prefix of every gateway-issued virtual key
+const VirtualKeyPrefix = "aigw-"
+
+// virtualKeyColumns is the column list scanned by scanVirtualKey
+const virtualKeyColumns = "id, name, key_prefix, disabled, rpm_limit, tpm_limit, daily_budget_usd, monthly_budget_usd, allow_overrides, last_used_at, revoked_at, created_at"
+
+// CreateVirtualKey generates a new virtual key. The plaintext key is returned
+// once and only its hash is stored.
+func (db *DB) CreateVirtualKey(name string) (*VirtualKey, string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	plaintext := VirtualKeyPrefix + hex.EncodeToString(secret)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	id := uuid.New().String()
+	prefix := plaintext[:len(VirtualKeyPrefix)+6]
+
+	_, err := db.conn.Exec(
+		"INSERT INTO virtual_keys (id, name, key_hash, key_prefix) VALUES (?, ?, ?, ?)",
+		id, name, hashVirtualKey(plaintext), prefix,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to store virtual key: %w", err)
+	}
+
+	row := db.conn.QueryRow("SELECT "+virtualKeyColumns+" FROM virtual_keys WHERE id = ?", id)
+	key, err := scanVirtualKey(row)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get virtual key: %w", err)
+	}
+
+	return key, plaintext, nil
+}
+
+// GetVirtualKey retrieves a virtual key by ID
+func (db *DB) GetVirtualKey(id string) (*VirtualKey, error) {
+	row := db.conn.QueryRow("SELECT "+virtualKeyColumns+" FROM virtual_keys WHERE id = ?", id)
+	key, err := scanVirtualKey(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("virtual key not found")
+		}
+		return nil, fmt.Errorf("failed to get virtual key: %w", err)
+	}
+
+	return key, nil
+}
+
+// LookupVirtualKey finds an active (not disabled or revoked) virtual key by its plaintext value
+func (db *DB) LookupVirtualKey(plaintext string) (*VirtualKey, error) {
+	row := db.conn.QueryRow(
+		"SELECT "+virtualKeyColumns+" FROM virtual_keys WHERE key_hash = ? AND disabled = 0 AND revoked_at IS NULL",
+		hashVirtualKey(plaintext),
+	)
+	key, err := scanVirtualKey(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("virtual key not found")
+		}
+		return nil, fmt.Errorf("failed to look up virtual key: %w", err)
+	}
+
+	return key, nil
+}
+
+// ListVirtualKeys returns all virtual keys, including revoked ones
+func (db *DB) ListVirtualKeys() ([]*VirtualKey, error) {
+	rows, err := db.conn.Query("SELECT " + virtualKeyColumns + " FROM virtual_keys ORDER BY created_at DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query virtual keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*VirtualKey{}
+	for rows.Next() {
+		key, err := scanVirtualKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan virtual key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating virtual keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// UpdateVirtualKey updates the name, disabled flag, rate limits, budgets and
+// override permission of a virtual key
+func (db *DB) UpdateVirtualKey(id string, input *UpdateVirtualKeyInput) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	query := "UPDATE virtual_keys SET id = id"
+	args := []interface{}{}
+
+	if input.Name != nil {
+		query += ", name = ?"
+		args = append(args, *input.Name)
+	}
+	if input.Disabled != nil {
+		query += ", disabled = ?"
+		args = append(args, *input.Disabled)
+	}
+	if input.RPMLimit != nil {
+		query += ", rpm_limit = ?"
+		args = append(args, limitValue(*input.RPMLimit))
+	}
+	if input.TPMLimit != nil {
+		query += ", tpm_limit = ?"
+		args = append(args, limitValue(*input.TPMLimit))
+	}
+	if input.DailyBudgetUSD != nil {
+		query += ", daily_budget_usd = ?"
+		args = append(args, budgetValue(*input.DailyBudgetUSD))
+	}
+	if input.MonthlyBudgetUSD != nil {
+		query += ", monthly_budget_usd = ?"
+		args = append(args, budgetValue(*input.MonthlyBudgetUSD))
+	}
+	if input.AllowOverrides != nil {
+		query += ", allow_overrides = ?"
+		args = append(args, *input.AllowOverrides)
+	}
+
+	query += " WHERE id = ?"
+	args = append(args, id)
+
+	result, err := db.conn.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update virtual key: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("virtual key not found")
+	}
+
+	return nil
+}
+
+// RevokeVirtualKey permanently revokes a virtual key. The row is kept so
+// requests remain attributed to it.
+func (db *DB) RevokeVirtualKey(id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	result, err := db.conn.Exec(
+		"UPDATE virtual_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke virtual key: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("virtual key not found")
+	}
+
+	return nil
+}
+
+// TouchVirtualKey records that a virtual key was just used
+func (db *DB) TouchVirtualKey(id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec("UPDATE virtual_keys SET last_used_at = ? WHERE id = ?", time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update virtual key usage: %w", err)
+	}
+	return nil
+}
+
+// IsVirtualKey reports whether a credential looks like a gateway-issued key
+func IsVirtualKey(credential string) bool {
+	return strings.HasPrefix(credential, VirtualKeyPrefix)
+}
+
+// scanVirtualKey scans a row selected with virtualKeyColumns into a VirtualKey
+func scanVirtualKey(row rowScanner) (*VirtualKey, error) {
+	var key VirtualKey
+	var lastUsedAt, revokedAt sql.NullTime
+	var rpmLimit, tpmLimit sql.NullInt64
+	var dailyBudget, monthlyBudget sql.NullFloat64
+
+	err := row.Scan(&key.ID, &key.Name, &key.KeyPrefix, &key.Disabled, &rpmLimit, &tpmLimit, &dailyBudget, &monthlyBudget,
+		&key.AllowOverrides, &lastUsedAt, &revokedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if rpmLimit.Valid {
+		rpm := int(rpmLimit.Int64)
+		key.RPMLimit = &rpm
+	}
+	if tpmLimit.Valid {
+		tpm := int(tpmLimit.Int64)
+		key.TPMLimit = &tpm
+	}
+	if dailyBudget.Valid {
+		key.DailyBudgetUSD = &dailyBudget.Float64
+	}
+	if monthlyBudget.Valid {
+		key.MonthlyBudgetUSD = &monthlyBudget.Float64
+	}
+
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return &key, nil
+}
+
+// limitValue stores negative limits as NULL so the key falls back to the default
+func limitValue(limit int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(limit), Valid: limit >= 0}
+}
+
+// budgetValue stores negative budgets as NULL so the key falls back to the default
+func budgetValue(budget float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: budget, Valid: budget >= 0}
+}
+
+func hashVirtualKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
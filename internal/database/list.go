@@ -0,0 +1,427 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned for a listing cursor that wasn't issued by
+// ListRequestSummaries for the same sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrInvalidSort is returned for a listing sorted by an unknown key
+var ErrInvalidSort = errors.New("invalid sort")
+
+// listSorts are the keys a listing can be sorted by, with their SQL on the
+// tables of listFrom. Missing values sort as 0, so keyset comparisons work.
+var listSorts = map[string]string{
+	"created_at":  "q.created_at",
+	"duration_ms": "COALESCE(r.duration_ms, 0)",
+	"status_code": "COALESCE(r.status_code, 0)",
+	"cost":        "COALESCE(r.cost_usd, 0)",
+	"tokens":      "COALESCE(r.input_tokens, 0) + COALESCE(r.output_tokens, 0)",
+	"provider":    "q.provider",
+}
+
+// ListRequestsParams contains filter parameters for listing requests
+type ListRequestsParams struct {
+	Provider     string
+	PathPattern  string
+	VirtualKeyID string
+	Source       string  // Gateway instance for federated records
+	HasSecrets   bool    // Only requests with secret scanner findings
+	Flagged      bool    // Only requests flagged by the moderation pre-check
+	MinRisk      float64 // Only requests with at least this prompt injection risk score
+	SessionID    string
+	Tags         []string // Only requests carrying all of these tags
+	Starred      bool     // Only starred requests
+	Search       string   // Only requests with this text in their body or a response body
+	StatusMin    int      // Only requests whose final response status is at least this
+	StatusMax    int      // Only requests whose final response status is at most this
+	IsError      *bool    // Only requests whose final response is (or isn't) an error
+	Model        string   // Only requests for this model: requested, routed or reported by a response
+	HasFiles     bool     // Only requests with stored binary files
+	DateFrom     time.Time
+	DateTo       time.Time
+	AsOf         time.Time // List as of this time: later requests are hidden, later deletions ignored
+	Sort         string    // Key from listSorts (default: created_at)
+	Ascending    bool      // Sort ascending instead of descending
+	Limit        int
+	Offset       int
+	Cursor       string // Continue after the last request of a previous page; replaces Offset
+}
+
+// sortName returns the key the listing is sorted by
+func (p *ListRequestsParams) sortName() string {
+	if p.Sort == "" {
+		return "created_at"
+	}
+	return p.Sort
+}
+
+// sortKey returns the listing's sort key with its direction, which cursors
+// are only valid for
+func (p *ListRequestsParams) sortKey() string {
+	if p.Ascending {
+		return p.sortName() + " asc"
+	}
+	return p.sortName() + " desc"
+}
+
+// RequestSummary is a listed request with the outcome of its final response
+type RequestSummary struct {
+	Request   *Request  // Without headers and body
+	Response  *Response // Final response (as of the listing's as_of time) without headers and body, nil if none
+	ImagePath string    // Path of the request's first stored image, if any
+}
+
+// listCursor is the position after the last request of a page: its sort
+// value and rowid, which breaks ties
+type listCursor struct {
+	Sort  string      `json:"s"`
+	Value interface{} `json:"v"`
+	RowID int64       `json:"r"`
+}
+
+// encode returns the cursor as an opaque string
+func (c *listCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor returned by encode
+func decodeCursor(s string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Value == nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// listFrom joins requests (q) with their final response (r), as it was at
+// asOf unless that is zero
+func listFrom(asOf time.Time) (string, []interface{}) {
+	if asOf.IsZero() {
+		return " FROM requests q LEFT JOIN responses r ON r.rowid = " +
+			"(SELECT rowid FROM responses WHERE request_id = q.id ORDER BY rowid DESC LIMIT 1)", nil
+	}
+	return " FROM requests q LEFT JOIN responses r ON r.rowid = " +
+			"(SELECT rowid FROM responses WHERE request_id = q.id AND created_at <= ? ORDER BY rowid DESC LIMIT 1)",
+		[]interface{}{asOf.UTC().Format(sqliteTimeFormat)}
+}
+
+// listFilter returns the conditions selecting the requests of a listing,
+// for a query built on listFrom
+func (db *DB) listFilter(params *ListRequestsParams) (string, []interface{}) {
+	query := " WHERE 1=1"
+	args := []interface{}{}
+
+	if params.Provider != "" {
+		query += " AND q.provider = ?"
+		args = append(args, params.Provider)
+	}
+
+	if params.VirtualKeyID != "" {
+		query += " AND q.virtual_key_id = ?"
+		args = append(args, params.VirtualKeyID)
+	}
+
+	if params.Source != "" {
+		query += " AND q.source = ?"
+		args = append(args, params.Source)
+	}
+
+	if params.HasSecrets {
+		query += " AND q.secret_findings IS NOT NULL"
+	}
+
+	if params.Flagged {
+		query += " AND q.moderation IS NOT NULL"
+	}
+
+	if params.MinRisk > 0 {
+		query += " AND q.risk_score >= ?"
+		args = append(args, params.MinRisk)
+	}
+
+	if params.SessionID != "" {
+		query += " AND q.session_id = ?"
+		args = append(args, params.SessionID)
+	}
+
+	if params.Starred {
+		query += " AND q.starred = 1"
+	}
+
+	for _, tag := range params.Tags {
+		query += " AND q.id IN (SELECT request_id FROM request_tags WHERE tag = ?)"
+		args = append(args, tag)
+	}
+
+	if params.Search != "" {
+		filter, filterArgs := db.searchFilter(params.Search)
+		query += filter
+		args = append(args, filterArgs...)
+	}
+
+	if params.StatusMin > 0 {
+		query += " AND r.status_code >= ?"
+		args = append(args, params.StatusMin)
+	}
+
+	if params.StatusMax > 0 {
+		query += " AND r.status_code <= ?"
+		args = append(args, params.StatusMax)
+	}
+
+	if params.IsError != nil {
+		query += " AND r.is_error = ?"
+		args = append(args, *params.IsError)
+	}
+
+	if params.Model != "" {
+		query += " AND (q.requested_model = ? OR q.routed_model = ? OR q.id IN (SELECT request_id FROM responses WHERE model = ?))"
+		args = append(args, params.Model, params.Model, params.Model)
+	}
+
+	if params.HasFiles {
+		query += " AND q.id IN (SELECT request_id FROM binary_files)"
+	}
+
+	if params.PathPattern != "" {
+		query += " AND q.endpoint LIKE ?"
+		args = append(args, "%"+params.PathPattern+"%")
+	}
+
+	if !params.DateFrom.IsZero() {
+		query += " AND q.created_at >= ?"
+		args = append(args, params.DateFrom)
+	}
+
+	if !params.DateTo.IsZero() {
+		query += " AND q.created_at <= ?"
+		args = append(args, params.DateTo)
+	}
+
+	if params.AsOf.IsZero() {
+		query += " AND q.deleted_at IS NULL"
+	} else {
+		asOf := params.AsOf.UTC().Format(sqliteTimeFormat)
+		query += " AND q.created_at <= ? AND (q.deleted_at IS NULL OR q.deleted_at > ?)"
+		args = append(args, asOf, asOf)
+	}
+
+	return query, args
+}
+
+// listPage returns the ordering and paging of a listing, newest first
+// unless sorted otherwise
+func listPage(params *ListRequestsParams) (string, []interface{}, error) {
+	column, ok := listSorts[params.sortName()]
+	if !ok {
+		return "", nil, ErrInvalidSort
+	}
+	direction, after := "DESC", "<"
+	if params.Ascending {
+		direction, after = "ASC", ">"
+	}
+
+	query, args := "", []interface{}{}
+	if params.Cursor != "" {
+		cursor, err := decodeCursor(params.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		if cursor.Sort != params.sortKey() {
+			return "", nil, ErrInvalidCursor
+		}
+		query += " AND (" + column + ", q.rowid) " + after + " (?, ?)"
+		args = append(args, cursor.Value, cursor.RowID)
+	}
+
+	query += " ORDER BY " + column + " " + direction + ", q.rowid " + direction
+
+	if params.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, params.Limit)
+	}
+
+	if params.Offset > 0 && params.Cursor == "" {
+		if params.Limit <= 0 {
+			query += " LIMIT -1"
+		}
+		query += " OFFSET ?"
+		args = append(args, params.Offset)
+	}
+
+	return query, args, nil
+}
+
+// ListRequests returns a list of requests with optional filtering
+func (db *DB) ListRequests(params *ListRequestsParams) ([]*Request, error) {
+	from, args := listFrom(params.AsOf)
+	filter, filterArgs := db.listFilter(params)
+	page, pageArgs, err := listPage(params)
+	if err != nil {
+		return nil, err
+	}
+	args = append(append(args, filterArgs...), pageArgs...)
+
+	rows, err := db.conn.Query("SELECT q."+strings.ReplaceAll(requestColumns, ", ", ", q.")+from+filter+page, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*Request
+
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan request: %w", err)
+		}
+
+		requests = append(requests, req)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating requests: %w", err)
+	}
+	if err := db.loadTags(requests); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// ListRequestSummaries returns a page of requests with the outcome of their
+// final responses, in one query. The cursor continues the listing after the
+// page; it is empty on the last page.
+func (db *DB) ListRequestSummaries(params *ListRequestsParams) ([]*RequestSummary, string, error) {
+	from, args := listFrom(params.AsOf)
+	filter, filterArgs := db.listFilter(params)
+	page, pageArgs, err := listPage(params)
+	if err != nil {
+		return nil, "", err
+	}
+	args = append(append(args, filterArgs...), pageArgs...)
+
+	rows, err := db.conn.Query(
+		"SELECT q.id, q.provider, q.endpoint, q.method, q.virtual_key_id, q.source, q.risk_score, q.session_id, q.starred, q.deleted_at, q.created_at, "+
+			"r.id, r.status_code, r.is_error, r.error_message, r.cancelled, r.timeout, r.duration_ms, r.input_tokens, r.output_tokens, r.cost_usd, "+
+			"(SELECT f.file_path FROM binary_files f WHERE f.request_id = q.id AND f.content_type LIKE 'image/%' ORDER BY f.rowid LIMIT 1), "+
+			listSorts[params.sortName()]+", q.rowid"+from+filter+page,
+		args...,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query requests: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []*RequestSummary
+	var requests []*Request
+	var last *listCursor
+	for rows.Next() {
+		summary, cursor, err := scanRequestSummary(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan request: %w", err)
+		}
+		summaries = append(summaries, summary)
+		requests = append(requests, summary.Request)
+		cursor.Sort = params.sortKey()
+		last = cursor
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating requests: %w", err)
+	}
+	if err := db.loadTags(requests); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if last != nil && params.Limit > 0 && len(summaries) == params.Limit {
+		next = last.encode()
+	}
+	return summaries, next, nil
+}
+
+// CountRequests returns how many requests match a listing's filters,
+// regardless of its paging
+func (db *DB) CountRequests(params *ListRequestsParams) (int, error) {
+	from, args := listFrom(params.AsOf)
+	filter, filterArgs := db.listFilter(params)
+	args = append(args, filterArgs...)
+
+	var count int
+	if err := db.conn.QueryRow("SELECT COUNT(*)"+from+filter, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count requests: %w", err)
+	}
+	return count, nil
+}
+
+// scanRequestSummary scans a row of ListRequestSummaries, returning the
+// request's position for the cursor
+func scanRequestSummary(row rowScanner) (*RequestSummary, *listCursor, error) {
+	var req Request
+	var virtualKeyID, source, sessionID sql.NullString
+	var riskScore, costUSD sql.NullFloat64
+	var deletedAt sql.NullTime
+	var responseID, errorMessage, timeout sql.NullString
+	var statusCode, durationMs, inputTokens, outputTokens sql.NullInt64
+	var isError, cancelled sql.NullBool
+	var imagePath sql.NullString
+	var cursor listCursor
+
+	err := row.Scan(&req.ID, &req.Provider, &req.Endpoint, &req.Method, &virtualKeyID, &source, &riskScore, &sessionID, &req.Starred, &deletedAt, &req.CreatedAt,
+		&responseID, &statusCode, &isError, &errorMessage, &cancelled, &timeout, &durationMs, &inputTokens, &outputTokens, &costUSD,
+		&imagePath, &cursor.Value, &cursor.RowID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Compare times as SQLite stores them
+	if t, ok := cursor.Value.(time.Time); ok {
+		cursor.Value = t.UTC().Format(sqliteTimeFormat)
+	}
+
+	req.VirtualKeyID = virtualKeyID.String
+	req.Source = source.String
+	req.SessionID = sessionID.String
+	if riskScore.Valid {
+		req.RiskScore = &riskScore.Float64
+	}
+	if deletedAt.Valid {
+		req.DeletedAt = &deletedAt.Time
+	}
+	summary := &RequestSummary{Request: &req, ImagePath: imagePath.String}
+
+	if responseID.Valid {
+		resp := &Response{
+			ID:           responseID.String,
+			RequestID:    req.ID,
+			StatusCode:   int(statusCode.Int64),
+			DurationMs:   int(durationMs.Int64),
+			IsError:      isError.Bool,
+			InputTokens:  int(inputTokens.Int64),
+			OutputTokens: int(outputTokens.Int64),
+			Cancelled:    cancelled.Bool,
+			Timeout:      timeout.String,
+		}
+		if errorMessage.Valid {
+			resp.ErrorMessage = &errorMessage.String
+		}
+		if costUSD.Valid {
+			resp.CostUSD = &costUSD.Float64
+		}
+		summary.Response = resp
+	}
+	return summary, &cursor, nil
+}
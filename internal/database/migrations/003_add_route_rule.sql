@@ -0,0 +1,2 @@
+-- Record which routing rule selected the provider for a request
+ALTER TABLE requests ADD COLUMN route_rule TEXT;
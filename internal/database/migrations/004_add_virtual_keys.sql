@@ -0,0 +1,16 @@
+-- Virtual keys: gateway-issued API keys used by clients to authenticate to the proxy
+CREATE TABLE IF NOT EXISTS virtual_keys (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,  -- SHA-256 of the key, the key itself is never stored
+    key_prefix TEXT NOT NULL,       -- First characters of the key for identification
+    disabled BOOLEAN DEFAULT 0,
+    last_used_at DATETIME,
+    revoked_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Attribute each request to the virtual key that made it
+ALTER TABLE requests ADD COLUMN virtual_key_id TEXT REFERENCES virtual_keys(id);
+
+CREATE INDEX IF NOT EXISTS idx_requests_virtual_key_id ON requests(virtual_key_id);
@@ -0,0 +1,6 @@
+-- Per-key rate limit overrides (NULL uses the gateway default, 0 is unlimited)
+ALTER TABLE virtual_keys ADD COLUMN rpm_limit INTEGER;
+ALTER TABLE virtual_keys ADD COLUMN tpm_limit INTEGER;
+
+-- Reason the gateway rejected a request without forwarding it (e.g. rate limited)
+ALTER TABLE requests ADD COLUMN rejection_reason TEXT;
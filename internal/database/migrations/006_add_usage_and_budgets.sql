@@ -0,0 +1,11 @@
+-- Token usage and estimated cost reported by each response
+ALTER TABLE responses ADD COLUMN model TEXT;
+ALTER TABLE responses ADD COLUMN input_tokens INTEGER;
+ALTER TABLE responses ADD COLUMN output_tokens INTEGER;
+ALTER TABLE responses ADD COLUMN cost_usd REAL;
+
+CREATE INDEX IF NOT EXISTS idx_responses_created_at ON responses(created_at);
+
+-- Per-key budget overrides in USD (NULL uses the gateway default, 0 is unlimited)
+ALTER TABLE virtual_keys ADD COLUMN daily_budget_usd REAL;
+ALTER TABLE virtual_keys ADD COLUMN monthly_budget_usd REAL;
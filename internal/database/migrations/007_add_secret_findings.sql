@@ -0,0 +1,2 @@
+-- Credentials detected in the request body by the secret scanner (JSON, matches masked)
+ALTER TABLE requests ADD COLUMN secret_findings TEXT;
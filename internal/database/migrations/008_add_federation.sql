@@ -0,0 +1,7 @@
+-- Gateway instance a request was recorded on (NULL for local requests)
+ALTER TABLE requests ADD COLUMN source TEXT;
+
+-- When the request was forwarded to the federation aggregator
+ALTER TABLE requests ADD COLUMN synced_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_requests_synced_at ON requests(synced_at);
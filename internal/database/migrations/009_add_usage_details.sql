@@ -0,0 +1,3 @@
+-- Normalized usage details: cached input tokens and reasoning output tokens
+ALTER TABLE responses ADD COLUMN cached_tokens INTEGER;
+ALTER TABLE responses ADD COLUMN reasoning_tokens INTEGER;
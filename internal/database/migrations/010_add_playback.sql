@@ -0,0 +1,4 @@
+-- Request fingerprints for playback matching, and the recording a replayed request was served from
+ALTER TABLE requests ADD COLUMN fingerprint TEXT;
+ALTER TABLE requests ADD COLUMN replayed_from TEXT;
+CREATE INDEX IF NOT EXISTS idx_requests_fingerprint ON requests(provider, fingerprint);
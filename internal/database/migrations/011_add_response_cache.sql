@@ -0,0 +1,2 @@
+-- Responses served from the response cache instead of the provider
+ALTER TABLE responses ADD COLUMN cached BOOLEAN NOT NULL DEFAULT 0;
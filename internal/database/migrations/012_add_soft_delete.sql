@@ -0,0 +1,4 @@
+-- Soft-delete marker: deleted requests are hidden from listings but kept, so
+-- listings as of an earlier time still show them
+ALTER TABLE requests ADD COLUMN deleted_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_requests_deleted_at ON requests(deleted_at);
@@ -0,0 +1,4 @@
+-- Model named in the request body, and the model a routing rule rewrote it to
+ALTER TABLE requests ADD COLUMN requested_model TEXT;
+ALTER TABLE requests ADD COLUMN routed_model TEXT;
+CREATE INDEX IF NOT EXISTS idx_requests_requested_model ON requests(requested_model);
@@ -0,0 +1,29 @@
+-- Fine-tuning jobs created through the gateway, polled until they finish
+CREATE TABLE IF NOT EXISTS fine_tune_jobs (
+    id TEXT PRIMARY KEY,                              -- Provider's job ID
+    provider TEXT NOT NULL,
+    request_id TEXT NOT NULL REFERENCES requests(id), -- Request that created the job
+    url TEXT NOT NULL,                                -- Upstream URL the job is polled at
+    model TEXT,
+    training_file TEXT,
+    training_request_id TEXT REFERENCES requests(id), -- Upload of the training file, if it went through the gateway
+    status TEXT NOT NULL,
+    fine_tuned_model TEXT,
+    error TEXT,
+    finished_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_fine_tune_jobs_finished_at ON fine_tune_jobs(finished_at);
+
+-- Status changes of a fine-tuning job, with the job object as returned by the provider
+CREATE TABLE IF NOT EXISTS fine_tune_updates (
+    id TEXT PRIMARY KEY,
+    job_id TEXT NOT NULL REFERENCES fine_tune_jobs(id),
+    status TEXT NOT NULL,
+    body TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_fine_tune_updates_job_id ON fine_tune_updates(job_id);
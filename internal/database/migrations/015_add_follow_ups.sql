@@ -0,0 +1,4 @@
+-- Follow-up requests the gateway sent on a client's behalf, e.g. answering
+-- tool calls it resolved itself, and the request they continue
+ALTER TABLE requests ADD COLUMN follow_up_of TEXT REFERENCES requests(id);
+CREATE INDEX IF NOT EXISTS idx_requests_follow_up_of ON requests(follow_up_of);
@@ -0,0 +1,3 @@
+-- Copies of requests the gateway mirrored to a second provider, and the original request
+ALTER TABLE requests ADD COLUMN mirror_of TEXT REFERENCES requests(id);
+CREATE INDEX IF NOT EXISTS idx_requests_mirror_of ON requests(mirror_of);
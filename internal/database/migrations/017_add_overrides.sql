@@ -0,0 +1,3 @@
+-- Policy override headers applied to a request (JSON), and the keys allowed to send them
+ALTER TABLE requests ADD COLUMN overrides TEXT;
+ALTER TABLE virtual_keys ADD COLUMN allow_overrides BOOLEAN NOT NULL DEFAULT 0;
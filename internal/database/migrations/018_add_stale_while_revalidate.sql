@@ -0,0 +1,4 @@
+-- Cached responses served past their TTL, and the background requests that refreshed them
+ALTER TABLE responses ADD COLUMN stale BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE requests ADD COLUMN revalidation_of TEXT REFERENCES requests(id);
+CREATE INDEX IF NOT EXISTS idx_requests_revalidation_of ON requests(revalidation_of);
@@ -0,0 +1,2 @@
+-- Successful requests dropped by storage sampling: the rows stay for stats and spend, the payloads don't
+ALTER TABLE requests ADD COLUMN sampled_out BOOLEAN NOT NULL DEFAULT 0;
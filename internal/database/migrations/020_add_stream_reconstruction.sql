@@ -0,0 +1,3 @@
+-- Streamed responses assembled into their final message
+ALTER TABLE responses ADD COLUMN message TEXT;       -- Assistant message as JSON, as a non-streamed response would carry it
+ALTER TABLE responses ADD COLUMN finish_reason TEXT;
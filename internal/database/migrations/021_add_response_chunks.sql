@@ -0,0 +1,11 @@
+-- Chunks of streamed responses as they arrived from the provider, for latency analysis
+CREATE TABLE IF NOT EXISTS response_chunks (
+    response_id TEXT NOT NULL REFERENCES responses(id),
+    request_id TEXT NOT NULL REFERENCES requests(id),
+    sequence INTEGER NOT NULL,  -- From 0, in arrival order
+    offset_ms REAL NOT NULL,    -- Arrival time since the request was forwarded
+    data TEXT NOT NULL,
+    PRIMARY KEY (response_id, sequence)
+);
+
+CREATE INDEX IF NOT EXISTS idx_response_chunks_request_id ON response_chunks(request_id);
@@ -0,0 +1,2 @@
+-- Deprecation notices and warnings providers attached to responses (JSON array)
+ALTER TABLE responses ADD COLUMN warnings TEXT;
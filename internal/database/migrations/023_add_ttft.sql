@@ -0,0 +1,2 @@
+-- Time from sending a streaming request upstream to the first byte of its response body
+ALTER TABLE responses ADD COLUMN ttft_ms INTEGER;
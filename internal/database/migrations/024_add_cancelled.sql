@@ -0,0 +1,2 @@
+-- Streams cut short because the client disconnected; the body is what arrived until then
+ALTER TABLE responses ADD COLUMN cancelled BOOLEAN NOT NULL DEFAULT 0;
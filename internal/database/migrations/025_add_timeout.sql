@@ -0,0 +1,3 @@
+-- Which upstream timeout ended a call (connect, read, total or watchdog), so
+-- timeouts can be told apart from other upstream errors
+ALTER TABLE responses ADD COLUMN timeout TEXT;
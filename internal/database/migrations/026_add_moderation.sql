@@ -0,0 +1,3 @@
+-- Verdict of the moderation pre-check on flagged requests (JSON: flagged
+-- categories); NULL if the request wasn't checked or passed
+ALTER TABLE requests ADD COLUMN moderation TEXT;
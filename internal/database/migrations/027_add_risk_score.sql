@@ -0,0 +1,4 @@
+-- Prompt injection risk score of the request body (0 to 1) and the rules
+-- that contributed to it (JSON); NULL if the request wasn't scored
+ALTER TABLE requests ADD COLUMN risk_score REAL;
+ALTER TABLE requests ADD COLUMN risk_rules TEXT;
@@ -0,0 +1,3 @@
+-- Responses edited or replaced while held by response interception, and the
+-- upstream response they replaced
+ALTER TABLE responses ADD COLUMN edited_from TEXT REFERENCES responses(id);
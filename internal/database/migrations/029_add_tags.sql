@@ -0,0 +1,12 @@
+-- Client-sent tags and session ID of a request; tags can also be changed later through the API
+ALTER TABLE requests ADD COLUMN session_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_requests_session_id ON requests(session_id);
+
+CREATE TABLE IF NOT EXISTS request_tags (
+    request_id TEXT NOT NULL REFERENCES requests(id),
+    tag TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (request_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_tags_tag ON request_tags(tag);
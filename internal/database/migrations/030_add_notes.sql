@@ -0,0 +1,10 @@
+-- Reviewer notes on requests
+CREATE TABLE IF NOT EXISTS request_notes (
+    id TEXT PRIMARY KEY,
+    request_id TEXT NOT NULL REFERENCES requests(id),
+    author TEXT,  -- Management user who wrote the note, if known
+    body TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_notes_request_id ON request_notes(request_id);
@@ -0,0 +1,3 @@
+-- Requests pinned in the UI for later comparison
+ALTER TABLE requests ADD COLUMN starred BOOLEAN NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_requests_starred ON requests(starred) WHERE starred = 1;
@@ -0,0 +1,5 @@
+-- Requests moved to object storage keep a stub row pointing at their archived record
+ALTER TABLE requests ADD COLUMN archive_key TEXT;
+ALTER TABLE requests ADD COLUMN archived_at DATETIME;
+ALTER TABLE requests ADD COLUMN restored_at DATETIME;
+CREATE INDEX IF NOT EXISTS idx_requests_archived_at ON requests(archived_at) WHERE archived_at IS NOT NULL;
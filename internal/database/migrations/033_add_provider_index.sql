@@ -0,0 +1,3 @@
+-- Provider filters on listings, costs and purges, newest first. requests(endpoint),
+-- responses(request_id) and binary_files(request_id) are indexed since 001_init.
+CREATE INDEX IF NOT EXISTS idx_requests_provider_created_at ON requests(provider, created_at);
@@ -0,0 +1,3 @@
+-- Gzipped bodies of large requests and responses; body is empty when set
+ALTER TABLE requests ADD COLUMN body_gz BLOB;
+ALTER TABLE responses ADD COLUMN body_gz BLOB;
@@ -0,0 +1,5 @@
+-- Query string of the request URI, and who sent the request
+ALTER TABLE requests ADD COLUMN query TEXT;
+ALTER TABLE requests ADD COLUMN client_ip TEXT;
+ALTER TABLE requests ADD COLUMN forwarded_for TEXT;
+ALTER TABLE requests ADD COLUMN user_agent TEXT;
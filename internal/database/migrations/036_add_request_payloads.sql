@@ -0,0 +1,2 @@
+-- Summary of multipart and binary request bodies, whose bytes are stored as binary files
+ALTER TABLE requests ADD COLUMN payload TEXT;
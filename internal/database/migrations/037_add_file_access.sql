@@ -0,0 +1,3 @@
+-- When a binary file was last served, so a file quota evicts the least recently used first
+ALTER TABLE binary_files ADD COLUMN accessed_at DATETIME;
+CREATE INDEX IF NOT EXISTS idx_binary_files_last_used ON binary_files(COALESCE(accessed_at, created_at));
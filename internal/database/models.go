@@ -7,26 +7,80 @@ import (
 
 // Request represents a stored API request
 type Request struct {
-	ID        string            `json:"id"`
-	Provider  string            `json:"provider"`
-	Endpoint  string            `json:"endpoint"`
-	Method    string            `json:"method"`
-	Headers   map[string]string `json:"headers"`
-	Body      string            `json:"body"`
-	CreatedAt time.Time         `json:"created_at"`
+	ID              string            `json:"id"`
+	Provider        string            `json:"provider"`
+	Endpoint        string            `json:"endpoint"`
+	Query           string            `json:"query,omitempty"` // Raw query string of the request URI, without the "?"
+	Method          string            `json:"method"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	Payload         *RequestPayload   `json:"payload,omitempty"`       // Summary of a multipart or binary body, stored as files instead
+	ClientIP        string            `json:"client_ip,omitempty"`     // Address the request came from
+	ForwardedFor    string            `json:"forwarded_for,omitempty"` // X-Forwarded-For sent with the request
+	UserAgent       string            `json:"user_agent,omitempty"`
+	RouteRule       string            `json:"route_rule,omitempty"`
+	VirtualKeyID    string            `json:"virtual_key_id,omitempty"`
+	RejectionReason string            `json:"rejection_reason,omitempty"`
+	SecretFindings  []SecretFinding   `json:"secret_findings,omitempty"`
+	Source          string            `json:"source,omitempty"`
+	ReplayedFrom    string            `json:"replayed_from,omitempty"`   // Request whose recorded response was played back
+	RequestedModel  string            `json:"requested_model,omitempty"` // Model named in the request body
+	RoutedModel     string            `json:"routed_model,omitempty"`    // Model a routing rule rewrote it to
+	FollowUpOf      string            `json:"follow_up_of,omitempty"`    // Request this gateway-sent follow-up continues
+	MirrorOf        string            `json:"mirror_of,omitempty"`       // Request this is a mirrored copy of
+	RevalidationOf  string            `json:"revalidation_of,omitempty"` // Request whose stale cached response this refreshed
+	Overrides       map[string]string `json:"overrides,omitempty"`       // Policy override headers the gateway applied
+	Moderation      *ModerationResult `json:"moderation,omitempty"`      // Verdict of the moderation pre-check, if it flagged the request
+	RiskScore       *float64          `json:"risk_score,omitempty"`      // Prompt injection risk, 0 to 1, if the request was scored
+	RiskRules       []string          `json:"risk_rules,omitempty"`      // Guardrail rules behind the risk score
+	SessionID       string            `json:"session_id,omitempty"`      // Client session, from X-AIGW-Session
+	Tags            []string          `json:"tags,omitempty"`            // From X-AIGW-Tag or added through the API
+	Starred         bool              `json:"starred,omitempty"`         // Pinned for later comparison
+	SampledOut      bool              `json:"sampled_out,omitempty"`     // Successful and dropped by storage sampling: headers and bodies weren't kept
+	ArchiveKey      string            `json:"archive_key,omitempty"`     // Object holding the request's archived record
+	ArchivedAt      *time.Time        `json:"archived_at,omitempty"`     // Moved to object storage: headers, bodies and files are in the archived record
+	DeletedAt       *time.Time        `json:"deleted_at,omitempty"`      // Soft-deleted: hidden from listings after this time
+	CreatedAt       time.Time         `json:"created_at"`
+}
+
+// SecretFinding is a credential detected in a request body, with the match masked
+type SecretFinding struct {
+	Rule  string `json:"rule"`
+	Match string `json:"match"`
+}
+
+// ModerationResult is the moderation pre-check's verdict on a flagged request
+type ModerationResult struct {
+	Flagged    bool     `json:"flagged"`
+	Categories []string `json:"categories,omitempty"`
 }
 
 // Response represents a stored API response
 type Response struct {
-	ID           string            `json:"id"`
-	RequestID    string            `json:"request_id"`
-	StatusCode   int               `json:"status_code"`
-	Headers      map[string]string `json:"headers"`
-	Body         string            `json:"body"`
-	DurationMs   int               `json:"duration_ms"`
-	IsError      bool              `json:"is_error"`
-	ErrorMessage *string           `json:"error_message,omitempty"`
-	CreatedAt    time.Time         `json:"created_at"`
+	ID              string            `json:"id"`
+	RequestID       string            `json:"request_id"`
+	StatusCode      int               `json:"status_code"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	DurationMs      int               `json:"duration_ms"`
+	IsError         bool              `json:"is_error"`
+	ErrorMessage    *string           `json:"error_message,omitempty"`
+	Model           string            `json:"model,omitempty"`
+	InputTokens     int               `json:"input_tokens,omitempty"`
+	OutputTokens    int               `json:"output_tokens,omitempty"`
+	CachedTokens    int               `json:"cached_tokens,omitempty"`
+	ReasoningTokens int               `json:"reasoning_tokens,omitempty"`
+	CostUSD         *float64          `json:"cost_usd,omitempty"`
+	Cached          bool              `json:"cached,omitempty"`        // Served from the response cache
+	Stale           bool              `json:"stale,omitempty"`         // Served from the cache past its TTL while being refreshed
+	Message         json.RawMessage   `json:"message,omitempty"`       // Streamed responses: the assembled assistant message
+	FinishReason    string            `json:"finish_reason,omitempty"` // Streamed responses: why the stream ended
+	Warnings        json.RawMessage   `json:"warnings,omitempty"`      // Deprecation notices and warnings from the provider
+	TTFTMs          *int              `json:"ttft_ms,omitempty"`       // Streamed responses: time to the first body byte
+	Cancelled       bool              `json:"cancelled,omitempty"`     // The client disconnected before the response finished
+	Timeout         string            `json:"timeout,omitempty"`       // Upstream timeout that ended the call: connect, read, total or watchdog
+	EditedFrom      string            `json:"edited_from,omitempty"`   // Upstream response this one replaced while held by response interception
+	CreatedAt       time.Time         `json:"created_at"`
 }
 
 // BinaryFile represents a stored binary file reference
@@ -40,24 +94,172 @@ type BinaryFile struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// RequestPayload summarizes a multipart or binary request body whose bytes
+// are stored as binary files of the request rather than in its body
+type RequestPayload struct {
+	ContentType string        `json:"content_type"`
+	Size        int64         `json:"size"`
+	Parts       []PayloadPart `json:"parts,omitempty"` // Fields of a multipart body, in order
+}
+
+// PayloadPart is a field of a multipart request body. File fields are each
+// stored as a binary file; other fields keep their value.
+type PayloadPart struct {
+	Name        string `json:"name"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size"`
+	Value       string `json:"value,omitempty"`
+	Truncated   bool   `json:"truncated,omitempty"` // Value was cut short
+}
+
+// Note is a reviewer's comment on a request
+type Note struct {
+	ID        string    `json:"id"`
+	RequestID string    `json:"request_id"`
+	Author    string    `json:"author,omitempty"` // Management user who wrote it, when login is on
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ResponseChunk is a piece of a streamed response as it arrived from the provider
+type ResponseChunk struct {
+	Sequence int     `json:"sequence"`
+	OffsetMs float64 `json:"offset_ms"` // Since the request was forwarded to the provider
+	Data     string  `json:"data"`
+}
+
+// VirtualKey represents a gateway-issued API key
+type VirtualKey struct {
+	ID               string     `json:"id"`
+	Name             string     `json:"name"`
+	KeyPrefix        string     `json:"key_prefix"`
+	Disabled         bool       `json:"disabled"`
+	RPMLimit         *int       `json:"rpm_limit,omitempty"`
+	TPMLimit         *int       `json:"tpm_limit,omitempty"`
+	DailyBudgetUSD   *float64   `json:"daily_budget_usd,omitempty"`
+	MonthlyBudgetUSD *float64   `json:"monthly_budget_usd,omitempty"`
+	AllowOverrides   bool       `json:"allow_overrides"` // May send per-request policy override headers
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// FineTuneJob is a fine-tuning job created through the gateway
+type FineTuneJob struct {
+	ID                string     `json:"id"` // Provider's job ID
+	Provider          string     `json:"provider"`
+	RequestID         string     `json:"request_id"` // Request that created the job
+	URL               string     `json:"url"`        // Upstream URL the job is polled at
+	Model             string     `json:"model,omitempty"`
+	TrainingFile      string     `json:"training_file,omitempty"`
+	TrainingRequestID string     `json:"training_request_id,omitempty"` // Upload of the training file through the gateway
+	Status            string     `json:"status"`
+	FineTunedModel    string     `json:"fine_tuned_model,omitempty"`
+	Error             string     `json:"error,omitempty"`
+	FinishedAt        *time.Time `json:"finished_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// FineTuneUpdate is a status change of a fine-tuning job
+type FineTuneUpdate struct {
+	ID        string    `json:"id"`
+	JobID     string    `json:"job_id"`
+	Status    string    `json:"status"`
+	Body      string    `json:"body"` // Job object as returned by the provider
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UpdateVirtualKeyInput is input for updating a virtual key; nil fields are left unchanged
+type UpdateVirtualKeyInput struct {
+	Name     *string
+	Disabled *bool
+	RPMLimit *int
+	TPMLimit *int
+	// Budgets in USD; a negative value clears the override
+	DailyBudgetUSD   *float64
+	MonthlyBudgetUSD *float64
+	AllowOverrides   *bool
+}
+
 // StoreRequestInput is input for storing a request
 type StoreRequestInput struct {
-	Provider string
-	Endpoint string
-	Method   string
-	Headers  map[string]string
-	Body     string
+	Provider        string
+	Endpoint        string
+	Query           string
+	Method          string
+	Headers         map[string]string
+	Body            string
+	Payload         *RequestPayload // Set instead of Body for multipart and binary bodies
+	ClientIP        string
+	ForwardedFor    string
+	UserAgent       string
+	RouteRule       string
+	VirtualKeyID    string
+	RejectionReason string
+	SecretFindings  []SecretFinding
+	Fingerprint     string // Playback match key, see proxy.requestFingerprint
+	ReplayedFrom    string
+	RequestedModel  string // Model named in the request body
+	RoutedModel     string // Model a routing rule rewrote it to, if any
+	FollowUpOf      string // Request a gateway-sent follow-up continues
+	MirrorOf        string // Request a mirrored copy was made of
+	RevalidationOf  string // Request served a stale cached response this refreshes
+	Overrides       map[string]string
+	Moderation      *ModerationResult // Set if the moderation pre-check flagged the request
+	RiskScore       *float64          // Set if the request was scored by the guardrails
+	RiskRules       []string
+	SessionID       string
+	Tags            []string // Normalized with NormalizeTags
+}
+
+// StoreFineTuneJobInput is input for tracking a fine-tuning job
+type StoreFineTuneJobInput struct {
+	ID                string
+	Provider          string
+	RequestID         string
+	URL               string
+	Model             string
+	TrainingFile      string
+	TrainingRequestID string
+	Status            string
+	Body              string
+}
+
+// UpdateFineTuneJobInput is the latest polled state of a fine-tuning job
+type UpdateFineTuneJobInput struct {
+	Status         string
+	FineTunedModel string
+	Error          string
+	Body           string
+	Finished       bool // The status is final; polling stops
 }
 
 // StoreResponseInput is input for storing a response
 type StoreResponseInput struct {
-	RequestID  string
-	StatusCode int
-	Headers    map[string]string
-	Body       string
-	DurationMs int
-	IsError    bool
-	ErrorMessage string
+	RequestID       string
+	StatusCode      int
+	Headers         map[string]string
+	Body            string
+	DurationMs      int
+	IsError         bool
+	ErrorMessage    string
+	Model           string
+	InputTokens     int // Including cached tokens
+	OutputTokens    int // Including reasoning tokens
+	CachedTokens    int
+	ReasoningTokens int
+	CostUSD         *float64 // nil when the model has no known price
+	Cached          bool
+	Stale           bool
+	Message         string // Assembled message of a streamed response (JSON)
+	FinishReason    string
+	Warnings        string // Provider warnings (JSON array)
+	TTFTMs          *int   // Streamed responses: time from sending upstream to the first body byte
+	Cancelled       bool   // The client disconnected; Body is what arrived until then
+	Timeout         string // Upstream timeout that ended the call (connect, read, total, watchdog)
+	EditedFrom      string // Upstream response an intercepted response was edited from
 }
 
 // Helper functions for JSON serialization
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// AddNote stores a reviewer note on a request
+func (db *DB) AddNote(requestID, author, body string) (*Note, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	id := uuid.New().String()
+	_, err := db.conn.Exec(
+		"INSERT INTO request_notes (id, request_id, author, body) VALUES (?, ?, ?, ?)",
+		id, requestID, nullString(author), body,
+	)
+	if err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to store note: %w", err))
+	}
+
+	note, err := scanNote(db.conn.QueryRow("SELECT id, request_id, author, body, created_at FROM request_notes WHERE id = ?", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note: %w", err)
+	}
+	return note, nil
+}
+
+// ListNotes returns the notes on a request, oldest first
+func (db *DB) ListNotes(requestID string) ([]*Note, error) {
+	rows, err := db.conn.Query(
+		"SELECT id, request_id, author, body, created_at FROM request_notes WHERE request_id = ? ORDER BY created_at, rowid",
+		requestID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notes: %w", err)
+	}
+	defer rows.Close()
+
+	var notes []*Note
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan note: %w", err)
+		}
+		notes = append(notes, note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notes: %w", err)
+	}
+	return notes, nil
+}
+
+// scanNote scans a note row
+func scanNote(row rowScanner) (*Note, error) {
+	var note Note
+	var author sql.NullString
+	if err := row.Scan(&note.ID, &note.RequestID, &author, &note.Body, &note.CreatedAt); err != nil {
+		return nil, err
+	}
+	note.Author = author.String
+	return &note, nil
+}
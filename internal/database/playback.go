@@ -0,0 +1,42 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// FindRecording returns the most recent successfully recorded response to a
+// request with the given fingerprint, or nil if there is none. Responses that
+// were themselves played back are not recordings.
+func (db *DB) FindRecording(provider, fingerprint string) (*Response, error) {
+	return db.findRecorded(provider, fingerprint, "is_error = 0")
+}
+
+// FindCachedResponse returns the most recent 2xx response recorded since the
+// given time for a request with the given fingerprint, or nil if there is none
+func (db *DB) FindCachedResponse(provider, fingerprint string, since time.Time) (*Response, error) {
+	return db.findRecorded(provider, fingerprint, "is_error = 0 AND status_code BETWEEN 200 AND 299 AND created_at >= ?",
+		since.UTC().Format(sqliteTimeFormat))
+}
+
+// findRecorded returns the most recent response matching condition to a
+// request with the given fingerprint that was neither played back itself,
+// archived nor deleted
+func (db *DB) findRecorded(provider, fingerprint, condition string, args ...interface{}) (*Response, error) {
+	row := db.conn.QueryRow(
+		"SELECT "+responseColumns+" FROM responses WHERE "+condition+" AND request_id IN ("+
+			"SELECT id FROM requests WHERE provider = ? AND fingerprint = ? AND replayed_from IS NULL AND archived_at IS NULL AND deleted_at IS NULL"+
+			") ORDER BY created_at DESC, rowid DESC LIMIT 1",
+		append(args, provider, fingerprint)...,
+	)
+
+	resp, err := scanResponse(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find recorded response: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// PredictionRequest is the logged request that created a prediction
+type PredictionRequest struct {
+	RequestID  string
+	ResponseID string
+	Files      int // Files already stored with the response
+}
+
+// FindPredictionRequest returns the most recent successful request to the
+// provider whose response created the prediction with the given ID, or nil
+// if it wasn't created through the gateway
+func (db *DB) FindPredictionRequest(provider, predictionID string) (*PredictionRequest, error) {
+	body := bodyExpr("s.")
+	var found PredictionRequest
+	err := db.conn.QueryRow(
+		"SELECT r.id, s.id, (SELECT COUNT(*) FROM binary_files f WHERE f.response_id = s.id) "+
+			"FROM requests r JOIN responses s ON s.request_id = r.id "+
+			"WHERE r.provider = ? AND r.method = 'POST' AND r.endpoint LIKE '%/predictions' AND s.status_code < 300 "+
+			"AND CASE WHEN json_valid("+body+") THEN json_extract("+body+", '$.id') END = ? "+
+			"ORDER BY r.created_at DESC LIMIT 1",
+		provider, predictionID,
+	).Scan(&found.RequestID, &found.ResponseID, &found.Files)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find prediction request: %w", err)
+	}
+	return &found, nil
+}
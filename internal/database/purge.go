@@ -0,0 +1,206 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PurgeFilter selects the requests removed by PurgeRequests. Empty fields
+// don't filter, so an empty filter selects every request.
+type PurgeFilter struct {
+	IDs      []string
+	Provider string
+	Before   time.Time // Only requests created before this time
+	HasFiles bool      // Only requests with stored binary files
+	Oldest   int       // Only the oldest matching requests, up to this many
+}
+
+// PurgeResult counts the records removed by PurgeRequests
+type PurgeResult struct {
+	Requests  int      `json:"requests"`
+	Responses int      `json:"responses"`
+	Files     int      `json:"files"`
+	FileBytes int64    `json:"file_bytes"`
+	FilePaths []string `json:"-"` // Stored files of the removed requests, for the caller to delete
+}
+
+// purgeTables are the tables holding rows of a request, removed along with it
+var purgeTables = []string{"binary_files", "response_chunks", "request_tags", "request_notes"}
+
+// PurgeRequests removes requests with their responses, stream chunks, tags,
+// notes and binary file records. Unlike MarkRequestsDeleted nothing is kept,
+// so listings as of an earlier time no longer include them either. The
+// stored files are left for the caller to delete.
+func (db *DB) PurgeRequests(filter *PurgeFilter) (*PurgeResult, error) {
+	where := " WHERE 1=1"
+	args := []interface{}{}
+	if len(filter.IDs) > 0 {
+		where += " AND id IN (" + strings.TrimSuffix(strings.Repeat("?,", len(filter.IDs)), ",") + ")"
+		for _, id := range filter.IDs {
+			args = append(args, id)
+		}
+	}
+	if filter.Provider != "" {
+		where += " AND provider = ?"
+		args = append(args, filter.Provider)
+	}
+	if !filter.Before.IsZero() {
+		where += " AND created_at < ?"
+		args = append(args, filter.Before.UTC().Format(sqliteTimeFormat))
+	}
+	if filter.HasFiles {
+		where += " AND id IN (SELECT request_id FROM binary_files)"
+	}
+	if filter.Oldest > 0 {
+		where = " WHERE id IN (SELECT id FROM requests" + where + " ORDER BY created_at, rowid LIMIT ?)"
+		args = append(args, filter.Oldest)
+	}
+	selected := "request_id IN (SELECT id FROM requests" + where + ")"
+
+	// Queued requests and responses must be stored to be removed
+	if len(filter.IDs) > 0 {
+		db.waitAllStored(filter.IDs)
+	} else {
+		db.flushWrites()
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result := &PurgeResult{}
+	if err := collectFiles(tx, result, "SELECT file_path, size FROM binary_files WHERE "+selected, args...); err != nil {
+		return nil, err
+	}
+
+	for _, table := range purgeTables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE "+selected, args...); err != nil {
+			return nil, db.writeFailed(fmt.Errorf("failed to purge %s: %w", table, err))
+		}
+	}
+	deleted, err := tx.Exec("DELETE FROM responses WHERE "+selected, args...)
+	if err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to purge responses: %w", err))
+	}
+	n, _ := deleted.RowsAffected()
+	result.Responses = int(n)
+
+	deleted, err = tx.Exec("DELETE FROM requests"+where, args...)
+	if err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to purge requests: %w", err))
+	}
+	n, _ = deleted.RowsAffected()
+	result.Requests = int(n)
+
+	if err := tx.Commit(); err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to commit purge: %w", err))
+	}
+	return result, nil
+}
+
+// PurgeOrphans removes responses, stream chunks, tags, notes and binary file
+// records left behind by requests that no longer exist, such as responses
+// stored for a request deleted while it was in flight. The stored files are
+// left for the caller to delete.
+func (db *DB) PurgeOrphans() (*PurgeResult, error) {
+	// Rows are only orphaned once their request is stored: files and chunks
+	// are stored directly, while their request may still be queued
+	db.flushWrites()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// Requests queued since the flush are still in flight
+	const orphaned = "request_id NOT IN (SELECT id FROM requests) AND request_id NOT IN (SELECT value FROM json_each(?))"
+	pending := db.pendingIDsJSON()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result := &PurgeResult{}
+	if err := collectFiles(tx, result, "SELECT file_path, size FROM binary_files WHERE "+orphaned, pending); err != nil {
+		return nil, err
+	}
+	for _, table := range purgeTables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE "+orphaned, pending); err != nil {
+			return nil, db.writeFailed(fmt.Errorf("failed to purge orphaned %s: %w", table, err))
+		}
+	}
+	deleted, err := tx.Exec("DELETE FROM responses WHERE "+orphaned, pending)
+	if err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to purge orphaned responses: %w", err))
+	}
+	n, _ := deleted.RowsAffected()
+	result.Responses = int(n)
+
+	if err := tx.Commit(); err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to commit purge: %w", err))
+	}
+	return result, nil
+}
+
+// collectFiles adds the binary files selected by query to result
+func collectFiles(tx *sql.Tx, result *PurgeResult, query string, args ...interface{}) error {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query binary files: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var filePath string
+		var size int64
+		if err := rows.Scan(&filePath, &size); err != nil {
+			return fmt.Errorf("failed to scan binary file: %w", err)
+		}
+		result.FilePaths = append(result.FilePaths, filePath)
+		result.FileBytes += size
+	}
+	result.Files = len(result.FilePaths)
+	return rows.Err()
+}
+
+// StoredFilePaths returns the paths of all files with a binary file record
+func (db *DB) StoredFilePaths() (map[string]bool, error) {
+	rows, err := db.conn.Query("SELECT file_path FROM binary_files")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query binary files: %w", err)
+	}
+	defer rows.Close()
+
+	paths := make(map[string]bool)
+	for rows.Next() {
+		var filePath string
+		if err := rows.Scan(&filePath); err != nil {
+			return nil, fmt.Errorf("failed to scan binary file: %w", err)
+		}
+		paths[filePath] = true
+	}
+	return paths, rows.Err()
+}
+
+// UsedBytes returns the size of the database's pages in use. Pages freed by
+// deletions are reused for new records, but the file doesn't shrink.
+func (db *DB) UsedBytes() (int64, error) {
+	var pageCount, freePages, pageSize int64
+	if err := db.conn.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, fmt.Errorf("failed to read page count: %w", err)
+	}
+	if err := db.conn.QueryRow("PRAGMA freelist_count").Scan(&freePages); err != nil {
+		return 0, fmt.Errorf("failed to read free page count: %w", err)
+	}
+	if err := db.conn.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("failed to read page size: %w", err)
+	}
+	return (pageCount - freePages) * pageSize, nil
+}
@@ -0,0 +1,31 @@
+package database
+
+// Redactor masks credentials in headers and bodies before they are stored
+// (implemented by guardrail.Redactor)
+type Redactor interface {
+	RedactHeaders(headers map[string]string) map[string]string
+	RedactBody(body string) string
+}
+
+// SetRedactor sets the redaction applied to the headers of stored requests
+// and responses, and to request bodies. Records ingested from other gateway
+// instances are redacted too.
+func (db *DB) SetRedactor(r Redactor) {
+	db.redactor = r
+}
+
+// redactRequest returns the headers and body of a request as they are stored
+func (db *DB) redactRequest(headers map[string]string, body string) (map[string]string, string) {
+	if db.redactor == nil {
+		return headers, body
+	}
+	return db.redactor.RedactHeaders(headers), db.redactor.RedactBody(body)
+}
+
+// redactHeaders returns response headers as they are stored
+func (db *DB) redactHeaders(headers map[string]string) map[string]string {
+	if db.redactor == nil {
+		return headers
+	}
+	return db.redactor.RedactHeaders(headers)
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/match"
+)
+
+// SamplingRule keeps only a percentage of the successful requests to matching
+// endpoints. Path is a path.Match pattern or a prefix ending at a "/", as in
+// routing rules.
+type SamplingRule struct {
+	Path    string
+	Percent float64
+}
+
+// ParseSamplingRules parses a comma-separated list of path=percent entries,
+// e.g. "/openai/v1/embeddings=5,/openai/=50"
+func ParseSamplingRules(s string) ([]SamplingRule, error) {
+	var rules []SamplingRule
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		pattern, value, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(pattern) == "" {
+			return nil, fmt.Errorf("invalid sampling rule %q (expected path=percent)", entry)
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid sampling percent in %q (expected 0 to 100)", entry)
+		}
+		rules = append(rules, SamplingRule{Path: strings.TrimSpace(pattern), Percent: percent})
+	}
+	return rules, nil
+}
+
+// SetSampling sets the storage sampling rules; the first rule matching a
+// request's endpoint applies and unmatched requests are always kept
+func (db *DB) SetSampling(rules []SamplingRule) {
+	db.sampling = rules
+}
+
+// sampleOut decides when a request is stored whether its payloads are dropped
+// once it succeeds. Requests the gateway or the client singled out (rejected,
+// flagged by the secret scanner, moderation or a guardrail rule, sent with
+// override headers, or linked to another request) are always kept.
+func (db *DB) sampleOut(input *StoreRequestInput) bool {
+	if len(db.sampling) == 0 || input.RejectionReason != "" || len(input.SecretFindings) > 0 || input.Moderation != nil || len(input.RiskRules) > 0 || len(input.Overrides) > 0 ||
+		input.FollowUpOf != "" || input.MirrorOf != "" || input.RevalidationOf != "" || input.ReplayedFrom != "" {
+		return false
+	}
+	for _, rule := range db.sampling {
+		if match.Path(rule.Path, input.Endpoint) {
+			return rand.Float64()*100 >= rule.Percent
+		}
+	}
+	return false
+}
+
+// SettleRequest applies storage sampling once a request's final response is
+// stored. If the request was sampled out and the response succeeded, its
+// headers, bodies, stream chunks and binary file records are dropped; the
+// rows stay so stats, spend and budgets still count it, but it no longer
+// feeds the response cache or playback. Errors are always kept in full. It returns the
+// paths of the files whose records were dropped, for the caller to delete.
+func (db *DB) SettleRequest(resp *Response) ([]string, error) {
+	if _, ok := db.sampledOut.LoadAndDelete(resp.RequestID); !ok || resp.IsError || resp.StatusCode >= 400 {
+		return nil, nil
+	}
+	db.waitStored(resp.RequestID)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT file_path FROM binary_files WHERE request_id = ?", resp.RequestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query binary files: %w", err)
+	}
+	var files []string
+	for rows.Next() {
+		var filePath string
+		if err := rows.Scan(&filePath); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan binary file: %w", err)
+		}
+		files = append(files, filePath)
+	}
+	rows.Close()
+
+	if _, err := tx.Exec("DELETE FROM binary_files WHERE request_id = ?", resp.RequestID); err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to drop binary files: %w", err))
+	}
+	if _, err := tx.Exec("DELETE FROM response_chunks WHERE request_id = ?", resp.RequestID); err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to drop response chunks: %w", err))
+	}
+	if _, err := tx.Exec("UPDATE responses SET headers = '{}', body = '', body_gz = NULL WHERE request_id = ?", resp.RequestID); err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to drop response payloads: %w", err))
+	}
+	_, err = tx.Exec("UPDATE requests SET headers = '{}', body = '', body_gz = NULL, fingerprint = NULL, sampled_out = 1 WHERE id = ?", resp.RequestID)
+	if err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to drop request payloads: %w", err))
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, db.writeFailed(fmt.Errorf("failed to commit sampled out request: %w", err))
+	}
+	return files, nil
+}
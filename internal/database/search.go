@@ -0,0 +1,134 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// searchSchema indexes request and response bodies for full-text search.
+// The indexes are external-content FTS5 tables over the bodies, keyed by
+// rowid and kept in sync by triggers, so bodies aren't stored twice.
+// Compressed bodies are indexed decompressed. It is applied outside the
+// migrations because FTS5 is only compiled into builds with the sqlite_fts5 tag.
+const searchSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS request_search USING fts5(body, content='requests', content_rowid='rowid');
+CREATE VIRTUAL TABLE IF NOT EXISTS response_search USING fts5(body, content='responses', content_rowid='rowid');
+
+CREATE TRIGGER IF NOT EXISTS request_search_insert AFTER INSERT ON requests BEGIN
+    INSERT INTO request_search(rowid, body) VALUES (new.rowid, COALESCE(gunzip(new.body_gz), new.body));
+END;
+CREATE TRIGGER IF NOT EXISTS request_search_delete AFTER DELETE ON requests BEGIN
+    INSERT INTO request_search(request_search, rowid, body) VALUES ('delete', old.rowid, COALESCE(gunzip(old.body_gz), old.body));
+END;
+CREATE TRIGGER IF NOT EXISTS request_search_update AFTER UPDATE OF body, body_gz ON requests BEGIN
+    INSERT INTO request_search(request_search, rowid, body) VALUES ('delete', old.rowid, COALESCE(gunzip(old.body_gz), old.body));
+    INSERT INTO request_search(rowid, body) VALUES (new.rowid, COALESCE(gunzip(new.body_gz), new.body));
+END;
+
+CREATE TRIGGER IF NOT EXISTS response_search_insert AFTER INSERT ON responses BEGIN
+    INSERT INTO response_search(rowid, body) VALUES (new.rowid, COALESCE(gunzip(new.body_gz), new.body));
+END;
+CREATE TRIGGER IF NOT EXISTS response_search_delete AFTER DELETE ON responses BEGIN
+    INSERT INTO response_search(response_search, rowid, body) VALUES ('delete', old.rowid, COALESCE(gunzip(old.body_gz), old.body));
+END;
+CREATE TRIGGER IF NOT EXISTS response_search_update AFTER UPDATE OF body, body_gz ON responses BEGIN
+    INSERT INTO response_search(response_search, rowid, body) VALUES ('delete', old.rowid, COALESCE(gunzip(old.body_gz), old.body));
+    INSERT INTO response_search(rowid, body) VALUES (new.rowid, COALESCE(gunzip(new.body_gz), new.body));
+END;
+`
+
+// searchTriggers are the triggers created by searchSchema
+var searchTriggers = []string{
+	"request_search_insert", "request_search_delete", "request_search_update",
+	"response_search_insert", "response_search_delete", "response_search_update",
+}
+
+// setupSearch creates the full-text indexes, indexing existing bodies when
+// they weren't kept in sync before. It returns false if this build of SQLite
+// has no FTS5.
+func (db *DB) setupSearch() (bool, error) {
+	var available bool
+	if err := db.conn.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&available); err != nil {
+		return false, fmt.Errorf("failed to check for FTS5: %w", err)
+	}
+	if !available {
+		// Triggers left by a build with FTS5 would fail every write; the
+		// index is rebuilt when such a build opens the database again
+		for _, trigger := range searchTriggers {
+			if _, err := db.conn.Exec("DROP TRIGGER IF EXISTS " + trigger); err != nil {
+				return false, fmt.Errorf("failed to drop search trigger: %w", err)
+			}
+		}
+		return false, nil
+	}
+
+	var synced int
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'request_search_insert'").Scan(&synced); err != nil {
+		return false, fmt.Errorf("failed to check search index: %w", err)
+	}
+	// Recreate the triggers, so ones from before bodies were compressed are replaced
+	for _, trigger := range searchTriggers {
+		if _, err := db.conn.Exec("DROP TRIGGER IF EXISTS " + trigger); err != nil {
+			return false, fmt.Errorf("failed to drop search trigger: %w", err)
+		}
+	}
+	if _, err := db.conn.Exec(searchSchema); err != nil {
+		return false, fmt.Errorf("failed to create search index: %w", err)
+	}
+	if synced == 0 {
+		// 'rebuild' would read the body column, missing compressed bodies
+		for table, content := range map[string]string{"request_search": "requests", "response_search": "responses"} {
+			if _, err := db.conn.Exec("INSERT INTO " + table + "(" + table + ") VALUES ('delete-all')"); err != nil {
+				return false, fmt.Errorf("failed to build search index: %w", err)
+			}
+			if _, err := db.conn.Exec("INSERT INTO " + table + "(rowid, body) SELECT rowid, " + bodyExpr("") + " FROM " + content); err != nil {
+				return false, fmt.Errorf("failed to build search index: %w", err)
+			}
+		}
+	}
+	return true, nil
+}
+
+// FullTextSearch reports whether searches use the FTS5 index. Without it,
+// they scan the bodies.
+func (db *DB) FullTextSearch() bool {
+	return db.fts
+}
+
+// OptimizeSearch merges the full-text indexes, so the space of deleted
+// bodies is freed. Without FTS5 there is nothing to do.
+func (db *DB) OptimizeSearch() error {
+	if !db.fts {
+		return nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, table := range []string{"request_search", "response_search"} {
+		if _, err := db.conn.Exec("INSERT INTO " + table + "(" + table + ") VALUES ('optimize')"); err != nil {
+			return fmt.Errorf("failed to optimize search index: %w", err)
+		}
+	}
+	return nil
+}
+
+// searchFilter returns the condition on listed requests (q) matching a search for
+// text in the request body or the body of any of its responses
+func (db *DB) searchFilter(text string) (string, []interface{}) {
+	if !db.fts {
+		pattern := "%" + escapeLike(text) + "%"
+		return " AND (" + bodyExpr("q.") + " LIKE ? ESCAPE '\\' OR q.id IN (SELECT request_id FROM responses WHERE " + bodyExpr("") + " LIKE ? ESCAPE '\\'))", []interface{}{pattern, pattern}
+	}
+
+	// Match the text as a phrase, so FTS5 query syntax in it is taken literally
+	phrase := `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+	return " AND (q.rowid IN (SELECT rowid FROM request_search WHERE request_search MATCH ?)" +
+			" OR q.id IN (SELECT request_id FROM responses WHERE rowid IN (SELECT rowid FROM response_search WHERE response_search MATCH ?)))",
+		[]interface{}{phrase, phrase}
+}
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
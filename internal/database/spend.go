@@ -0,0 +1,31 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// sqliteTimeFormat matches how SQLite's CURRENT_TIMESTAMP stores created_at
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// SpendSince returns the total estimated cost in USD of responses stored since
+// the given time. If virtualKeyID is set, only requests made with that key count.
+func (db *DB) SpendSince(since time.Time, virtualKeyID string) (float64, error) {
+	query := "SELECT COALESCE(SUM(r.cost_usd), 0) FROM responses r"
+	args := []interface{}{}
+	if virtualKeyID != "" {
+		query += " JOIN requests q ON q.id = r.request_id WHERE q.virtual_key_id = ? AND"
+		args = append(args, virtualKeyID)
+	} else {
+		query += " WHERE"
+	}
+	query += " r.created_at >= ?"
+	args = append(args, since.UTC().Format(sqliteTimeFormat))
+
+	var spend float64
+	if err := db.conn.QueryRow(query, args...).Scan(&spend); err != nil {
+		return 0, fmt.Errorf("failed to sum spend: %w", err)
+	}
+
+	return spend, nil
+}
@@ -0,0 +1,91 @@
+package database
+
+import "fmt"
+
+// Stats are aggregate counts over the stored (not deleted) requests
+type Stats struct {
+	TotalRequests      int
+	RequestsByProvider map[string]int
+	RequestsByStatus   map[int]int // By final response status; requests without a response are left out
+	TTFTByProvider     map[string]*TTFTStats
+}
+
+// TTFTStats summarizes the time to first token of streamed responses
+type TTFTStats struct {
+	Streams int     `json:"streams"`
+	AvgMs   float64 `json:"avg_ms"`
+	P50Ms   int     `json:"p50_ms"`
+	P95Ms   int     `json:"p95_ms"`
+	MaxMs   int     `json:"max_ms"`
+}
+
+// GetStats aggregates request counts and streaming latency
+func (db *DB) GetStats() (*Stats, error) {
+	stats := &Stats{
+		RequestsByProvider: make(map[string]int),
+		RequestsByStatus:   make(map[int]int),
+		TTFTByProvider:     make(map[string]*TTFTStats),
+	}
+
+	rows, err := db.conn.Query("SELECT provider, COUNT(*) FROM requests WHERE deleted_at IS NULL GROUP BY provider")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count requests: %w", err)
+	}
+	for rows.Next() {
+		var provider string
+		var count int
+		if err := rows.Scan(&provider, &count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan request count: %w", err)
+		}
+		stats.RequestsByProvider[provider] = count
+		stats.TotalRequests += count
+	}
+	rows.Close()
+
+	// The final response of a request is its last stored one
+	rows, err = db.conn.Query(`SELECT r.status_code, COUNT(*) FROM responses r
+		JOIN requests q ON q.id = r.request_id AND q.deleted_at IS NULL
+		WHERE r.rowid = (SELECT MAX(rowid) FROM responses WHERE request_id = r.request_id)
+		GROUP BY r.status_code`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count statuses: %w", err)
+	}
+	for rows.Next() {
+		var status, count int
+		if err := rows.Scan(&status, &count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan status count: %w", err)
+		}
+		stats.RequestsByStatus[status] = count
+	}
+	rows.Close()
+
+	// Nearest-rank percentiles: the smallest value at or above the rank
+	rows, err = db.conn.Query(`WITH t AS (
+			SELECT q.provider AS provider, r.ttft_ms AS ttft,
+				ROW_NUMBER() OVER (PARTITION BY q.provider ORDER BY r.ttft_ms) AS rank,
+				COUNT(*) OVER (PARTITION BY q.provider) AS n
+			FROM responses r JOIN requests q ON q.id = r.request_id AND q.deleted_at IS NULL
+			WHERE r.ttft_ms IS NOT NULL
+		)
+		SELECT provider, COUNT(*), AVG(ttft),
+			MIN(CASE WHEN rank >= 0.5 * n THEN ttft END), MIN(CASE WHEN rank >= 0.95 * n THEN ttft END), MAX(ttft)
+		FROM t GROUP BY provider`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate time to first token: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var provider string
+		var ttft TTFTStats
+		if err := rows.Scan(&provider, &ttft.Streams, &ttft.AvgMs, &ttft.P50Ms, &ttft.P95Ms, &ttft.MaxMs); err != nil {
+			return nil, fmt.Errorf("failed to scan time to first token: %w", err)
+		}
+		stats.TTFTByProvider[provider] = &ttft
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating time to first token: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// maxTagLength is the longest tag accepted, in bytes
+const maxTagLength = 100
+
+// NormalizeTags trims tags and drops empty and repeated ones. It returns an
+// error if a tag is too long.
+func NormalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d bytes", tag[:20]+"...", maxTagLength)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized, nil
+}
+
+// UpdateRequestTags adds and removes tags of a request and returns its tags
+func (db *DB) UpdateRequestTags(requestID string, add, remove []string) ([]string, error) {
+	db.waitStored(requestID)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertTags(tx, requestID, add); err != nil {
+		return nil, err
+	}
+	for _, tag := range remove {
+		if _, err := tx.Exec("DELETE FROM request_tags WHERE request_id = ? AND tag = ?", requestID, tag); err != nil {
+			return nil, fmt.Errorf("failed to remove tag: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tags: %w", err)
+	}
+
+	tags := map[string][]string{}
+	if err := db.queryTags(tags, []string{requestID}); err != nil {
+		return nil, err
+	}
+	return tags[requestID], nil
+}
+
+// SetRequestStarred stars or unstars a request
+func (db *DB) SetRequestStarred(requestID string, starred bool) error {
+	db.waitStored(requestID)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	result, err := db.conn.Exec("UPDATE requests SET starred = ? WHERE id = ?", starred, requestID)
+	if err != nil {
+		return db.writeFailed(fmt.Errorf("failed to update request: %w", err))
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("request not found")
+	}
+	return nil
+}
+
+// execer is implemented by *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertTags adds tags to a request, ignoring ones it already has
+func insertTags(exec execer, requestID string, tags []string) error {
+	for _, tag := range tags {
+		if _, err := exec.Exec("INSERT OR IGNORE INTO request_tags (request_id, tag) VALUES (?, ?)", requestID, tag); err != nil {
+			return fmt.Errorf("failed to store tag: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadTags fills in the tags of requests
+func (db *DB) loadTags(requests []*Request) error {
+	if len(requests) == 0 {
+		return nil
+	}
+	ids := make([]string, len(requests))
+	for i, req := range requests {
+		ids[i] = req.ID
+	}
+	tags := make(map[string][]string, len(requests))
+	if err := db.queryTags(tags, ids); err != nil {
+		return err
+	}
+	for _, req := range requests {
+		req.Tags = tags[req.ID]
+	}
+	return nil
+}
+
+// queryTags collects the tags of the requests with the given IDs into tags,
+// by request ID and in the order they were added
+func (db *DB) queryTags(tags map[string][]string, ids []string) error {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	rows, err := db.conn.Query("SELECT request_id, tag FROM request_tags WHERE request_id IN ("+placeholders+") ORDER BY rowid", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var requestID, tag string
+		if err := rows.Scan(&requestID, &tag); err != nil {
+			return fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags[requestID] = append(tags[requestID], tag)
+	}
+	return rows.Err()
+}
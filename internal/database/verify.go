@@ -0,0 +1,127 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// TrafficParams selects the recorded traffic an assertion is checked against.
+// Deleted requests are never included.
+type TrafficParams struct {
+	Provider string // Optional
+	Since    time.Time
+}
+
+// where returns the conditions on requests (aliased q) selected by params
+func (p *TrafficParams) where() (string, []interface{}) {
+	clause := "q.deleted_at IS NULL AND q.created_at >= ?"
+	args := []interface{}{p.Since.UTC().Format(sqliteTimeFormat)}
+	if p.Provider != "" {
+		clause += " AND q.provider = ?"
+		args = append(args, p.Provider)
+	}
+	return clause, args
+}
+
+// ModelUsageRow is one model used by requests: as requested by the client,
+// as routed to, or as reported by the provider
+type ModelUsageRow struct {
+	Model         string
+	Requests      int
+	LastRequestID string
+}
+
+// ModelUsage returns every model the selected requests used, most used first
+func (db *DB) ModelUsage(params *TrafficParams) ([]*ModelUsageRow, error) {
+	where, args := params.where()
+	query := `SELECT model, COUNT(DISTINCT id), MAX(created_at), id FROM (
+			SELECT q.id, q.created_at, q.requested_model AS model FROM requests q WHERE ` + where + `
+			UNION ALL SELECT q.id, q.created_at, q.routed_model FROM requests q WHERE ` + where + `
+			UNION ALL SELECT q.id, q.created_at, r.model FROM requests q JOIN responses r ON r.request_id = q.id WHERE ` + where + `
+		) WHERE model IS NOT NULL AND model != ''
+		GROUP BY model ORDER BY 2 DESC, model`
+	args = append(append(append([]interface{}{}, args...), args...), args...)
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query model usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []*ModelUsageRow
+	for rows.Next() {
+		var row ModelUsageRow
+		var lastSeen string
+		if err := rows.Scan(&row.Model, &row.Requests, &lastSeen, &row.LastRequestID); err != nil {
+			return nil, fmt.Errorf("failed to scan model usage row: %w", err)
+		}
+		usage = append(usage, &row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating model usage: %w", err)
+	}
+	return usage, nil
+}
+
+// RequestOutcomes counts the selected requests that got a final response, and
+// how many of those failed (an error or a status of 400 or more). Requests
+// the client cancelled are left out of both.
+func (db *DB) RequestOutcomes(params *TrafficParams) (requests, errors int, failedIDs []string, err error) {
+	where, args := params.where()
+	query := `SELECT q.id, r.is_error OR r.status_code >= 400
+		FROM requests q JOIN responses r ON r.request_id = q.id
+		WHERE ` + where + ` AND r.cancelled = 0
+		AND r.rowid = (SELECT MAX(rowid) FROM responses WHERE request_id = q.id)
+		ORDER BY q.created_at DESC`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to query request outcomes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var failed bool
+		if err := rows.Scan(&id, &failed); err != nil {
+			return 0, 0, nil, fmt.Errorf("failed to scan request outcome: %w", err)
+		}
+		requests++
+		if failed {
+			errors++
+			failedIDs = append(failedIDs, id)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, 0, nil, fmt.Errorf("error iterating request outcomes: %w", err)
+	}
+	return requests, errors, failedIDs, nil
+}
+
+// EachRequestBody calls fn with the ID and body of each selected request,
+// newest first, until fn returns false
+func (db *DB) EachRequestBody(params *TrafficParams, fn func(id, body string) bool) error {
+	where, args := params.where()
+	rows, err := db.conn.Query("SELECT q.id, COALESCE("+bodyExpr("q.")+", '') FROM requests q WHERE "+where+" ORDER BY q.created_at DESC", args...)
+	if err != nil {
+		return fmt.Errorf("failed to query request bodies: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, body string
+		if err := rows.Scan(&id, &body); err != nil {
+			return fmt.Errorf("failed to scan request body: %w", err)
+		}
+		if !fn(id, body) {
+			break
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating request bodies: %w", err)
+	}
+	return nil
+}
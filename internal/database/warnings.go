@@ -0,0 +1,91 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// WarningSummaryParams contains filter parameters for aggregating warnings
+type WarningSummaryParams struct {
+	Provider string
+	Model    string
+	DateFrom time.Time
+	DateTo   time.Time
+}
+
+// WarningSummaryRow is one distinct provider warning for one provider/model
+type WarningSummaryRow struct {
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	Kind      string    `json:"kind"`
+	Source    string    `json:"source"`
+	Message   string    `json:"message"`
+	Responses int       `json:"responses"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// WarningSummary aggregates the warnings stored on responses by provider,
+// model and warning, most recently seen first
+func (db *DB) WarningSummary(params *WarningSummaryParams) ([]*WarningSummaryRow, error) {
+	query := `SELECT q.provider, COALESCE(r.model, q.routed_model, q.requested_model, ''),
+		COALESCE(json_extract(w.value, '$.kind'), ''), COALESCE(json_extract(w.value, '$.source'), ''),
+		COALESCE(json_extract(w.value, '$.message'), ''), COUNT(*), MIN(r.created_at), MAX(r.created_at)
+		FROM responses r JOIN requests q ON q.id = r.request_id, json_each(r.warnings) w
+		WHERE r.warnings IS NOT NULL`
+	args := []interface{}{}
+
+	if params.Provider != "" {
+		query += " AND q.provider = ?"
+		args = append(args, params.Provider)
+	}
+	if params.Model != "" {
+		query += " AND COALESCE(r.model, q.routed_model, q.requested_model) = ?"
+		args = append(args, params.Model)
+	}
+	if !params.DateFrom.IsZero() {
+		query += " AND r.created_at >= ?"
+		args = append(args, params.DateFrom.UTC().Format(sqliteTimeFormat))
+	}
+	if !params.DateTo.IsZero() {
+		query += " AND r.created_at <= ?"
+		args = append(args, params.DateTo.UTC().Format(sqliteTimeFormat))
+	}
+
+	query += " GROUP BY 1, 2, 3, 4, 5 ORDER BY 8 DESC, 6 DESC"
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query warnings: %w", err)
+	}
+	defer rows.Close()
+
+	summary := []*WarningSummaryRow{}
+	for rows.Next() {
+		var row WarningSummaryRow
+		var firstSeen, lastSeen string
+		err := rows.Scan(&row.Provider, &row.Model, &row.Kind, &row.Source, &row.Message, &row.Responses, &firstSeen, &lastSeen)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan warning row: %w", err)
+		}
+		row.FirstSeen = parseSQLiteTime(firstSeen)
+		row.LastSeen = parseSQLiteTime(lastSeen)
+		summary = append(summary, &row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating warnings: %w", err)
+	}
+	return summary, nil
+}
+
+// parseSQLiteTime parses a timestamp returned by an aggregate, which SQLite
+// hands back as text; zero if it can't be read
+func parseSQLiteTime(s string) time.Time {
+	for _, layout := range []string{sqliteTimeFormat, time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
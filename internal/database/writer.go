@@ -0,0 +1,322 @@
+package database
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// writeBatchSize is the most queued writes stored in one transaction
+const writeBatchSize = 100
+
+// WriteBehindOptions configures write-behind of requests and responses
+type WriteBehindOptions struct {
+	QueueSize int  // Writes waiting to be stored
+	DropFull  bool // Discard writes while the queue is full instead of waiting for room
+}
+
+// writeBehind queues request and response inserts for a writer goroutine,
+// so the proxy doesn't wait for SQLite. Queued records are served from
+// memory by GetRequest and GetResponse until they are stored.
+type writeBehind struct {
+	queue    chan *queuedWrite
+	dropFull bool
+	closeMu  sync.RWMutex // Held for writing while the queue is closed
+	closed   bool
+	done     chan struct{} // Closed when the writer has stored every queued write
+	pending  sync.Map      // Request and response IDs to their latest queued write
+	dropped  atomic.Int64
+}
+
+// queuedWrite is an insert waiting for the writer, with the record it stores
+type queuedWrite struct {
+	keys     []string // IDs in writeBehind.pending
+	insert   func(exec execer) error
+	request  *Request
+	response *Response
+	stored   chan struct{} // Closed once the insert ran, whether or not it succeeded
+}
+
+// StartWriteBehind makes StoreRequest and StoreResponse queue their inserts
+// for a background writer that stores them in batches. Close stores what is
+// still queued.
+func (db *DB) StartWriteBehind(opts WriteBehindOptions) {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 10000
+	}
+	db.writer = &writeBehind{
+		queue:    make(chan *queuedWrite, opts.QueueSize),
+		dropFull: opts.DropFull,
+		done:     make(chan struct{}),
+	}
+	go db.runWriter()
+}
+
+// WriteQueueDepth returns how many writes are waiting to be stored
+func (db *DB) WriteQueueDepth() int {
+	if db.writer == nil {
+		return 0
+	}
+	return len(db.writer.queue)
+}
+
+// DroppedWrites returns how many request and response writes were discarded
+// for a full queue
+func (db *DB) DroppedWrites() int64 {
+	if db.writer == nil {
+		return 0
+	}
+	return db.writer.dropped.Load()
+}
+
+// write stores a record now, or queues it when write-behind is on
+func (db *DB) write(w *queuedWrite) error {
+	if wb := db.writer; wb != nil {
+		wb.closeMu.RLock()
+		defer wb.closeMu.RUnlock()
+		if !wb.closed {
+			return db.enqueue(wb, w)
+		}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if err := w.insert(db.conn); err != nil {
+		return db.writeFailed(err)
+	}
+	return nil
+}
+
+// enqueue queues a write, waiting for room or dropping it if the queue is full
+func (db *DB) enqueue(wb *writeBehind, w *queuedWrite) error {
+	w.stored = make(chan struct{})
+	for _, key := range w.keys {
+		wb.pending.Store(key, w)
+	}
+
+	if !wb.dropFull {
+		wb.queue <- w
+		return nil
+	}
+	select {
+	case wb.queue <- w:
+	default:
+		wb.dropped.Add(1)
+		db.finishWrite(wb, w)
+		slog.Warn("write queue full, dropping record", "keys", w.keys)
+	}
+	return nil
+}
+
+// runWriter stores queued writes until the queue is closed, taking as many
+// as are waiting (up to writeBatchSize) into each transaction
+func (db *DB) runWriter() {
+	wb := db.writer
+	defer close(wb.done)
+
+	for w := range wb.queue {
+		batch := []*queuedWrite{w}
+	fill:
+		for len(batch) < writeBatchSize {
+			select {
+			case next, ok := <-wb.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+
+		db.storeBatch(batch)
+		for _, w := range batch {
+			db.finishWrite(wb, w)
+		}
+	}
+}
+
+// storeBatch stores writes in one transaction. If that fails they are
+// stored one by one, so a bad record only loses itself.
+func (db *DB) storeBatch(batch []*queuedWrite) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.conn.Begin()
+	if err == nil {
+		for _, w := range batch {
+			if err = w.insert(tx); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err == nil {
+			return
+		}
+		tx.Rollback()
+	}
+
+	for _, w := range batch {
+		if err := w.insert(db.conn); err != nil {
+			db.writeFailed(err)
+			slog.Error("failed to store queued record", "keys", w.keys, "error", err)
+		}
+	}
+}
+
+// finishWrite marks a queued write as no longer pending
+func (db *DB) finishWrite(wb *writeBehind, w *queuedWrite) {
+	for _, key := range w.keys {
+		wb.pending.CompareAndDelete(key, w)
+	}
+	close(w.stored)
+}
+
+// pendingWrite returns the latest queued write of a request or response ID,
+// or nil if it has none
+func (db *DB) pendingWrite(id string) *queuedWrite {
+	if db.writer == nil {
+		return nil
+	}
+	if w, ok := db.writer.pending.Load(id); ok {
+		return w.(*queuedWrite)
+	}
+	return nil
+}
+
+// waitStored waits until the queued writes of a request or response ID are stored
+func (db *DB) waitStored(id string) {
+	if w := db.pendingWrite(id); w != nil {
+		<-w.stored
+	}
+}
+
+// waitAllStored waits until the queued writes of each ID are stored
+func (db *DB) waitAllStored(ids []string) {
+	for _, id := range ids {
+		db.waitStored(id)
+	}
+}
+
+// flushWrites waits until every write queued so far is stored, for changes
+// that select rows by a filter rather than by ID
+func (db *DB) flushWrites() {
+	if db.writer == nil {
+		return
+	}
+	db.writer.pending.Range(func(_, w interface{}) bool {
+		<-w.(*queuedWrite).stored
+		return true
+	})
+}
+
+// pendingIDsJSON returns the request and response IDs with writes still
+// queued as a JSON array, for SQL to leave their rows alone with json_each
+func (db *DB) pendingIDsJSON() string {
+	ids := []string{}
+	if db.writer != nil {
+		db.writer.pending.Range(func(key, _ interface{}) bool {
+			ids = append(ids, key.(string))
+			return true
+		})
+	}
+	data, _ := json.Marshal(ids)
+	return string(data)
+}
+
+// closeWriter stops queueing writes and waits for the queued ones to be stored
+func (db *DB) closeWriter() {
+	wb := db.writer
+	if wb == nil {
+		return
+	}
+	wb.closeMu.Lock()
+	if !wb.closed {
+		wb.closed = true
+		close(wb.queue)
+	}
+	wb.closeMu.Unlock()
+	<-wb.done
+}
+
+// queuedRequest returns the request a StoreRequest call stores, as GetRequest
+// would read it back
+func queuedRequest(id string, input *StoreRequestInput, headers map[string]string, body string, createdAt time.Time) *Request {
+	return &Request{
+		ID:              id,
+		Provider:        input.Provider,
+		Endpoint:        input.Endpoint,
+		Query:           input.Query,
+		Method:          input.Method,
+		Headers:         headers,
+		Body:            body,
+		Payload:         input.Payload,
+		ClientIP:        input.ClientIP,
+		ForwardedFor:    input.ForwardedFor,
+		UserAgent:       input.UserAgent,
+		RouteRule:       input.RouteRule,
+		VirtualKeyID:    input.VirtualKeyID,
+		RejectionReason: input.RejectionReason,
+		SecretFindings:  input.SecretFindings,
+		ReplayedFrom:    input.ReplayedFrom,
+		RequestedModel:  input.RequestedModel,
+		RoutedModel:     input.RoutedModel,
+		FollowUpOf:      input.FollowUpOf,
+		MirrorOf:        input.MirrorOf,
+		RevalidationOf:  input.RevalidationOf,
+		Overrides:       input.Overrides,
+		Moderation:      input.Moderation,
+		RiskScore:       input.RiskScore,
+		RiskRules:       input.RiskRules,
+		SessionID:       input.SessionID,
+		Tags:            input.Tags,
+		CreatedAt:       createdAt,
+	}
+}
+
+// queuedResponse returns the response a StoreResponse call stores, as
+// GetResponse would read it back
+func queuedResponse(id string, input *StoreResponseInput, headers map[string]string, createdAt time.Time) *Response {
+	resp := &Response{
+		ID:              id,
+		RequestID:       input.RequestID,
+		StatusCode:      input.StatusCode,
+		Headers:         headers,
+		Body:            input.Body,
+		DurationMs:      input.DurationMs,
+		IsError:         input.IsError,
+		Model:           input.Model,
+		InputTokens:     input.InputTokens,
+		OutputTokens:    input.OutputTokens,
+		CachedTokens:    input.CachedTokens,
+		ReasoningTokens: input.ReasoningTokens,
+		CostUSD:         input.CostUSD,
+		Cached:          input.Cached,
+		Stale:           input.Stale,
+		FinishReason:    input.FinishReason,
+		TTFTMs:          input.TTFTMs,
+		Cancelled:       input.Cancelled,
+		Timeout:         input.Timeout,
+		EditedFrom:      input.EditedFrom,
+		CreatedAt:       createdAt,
+	}
+	errorMessage := input.ErrorMessage
+	resp.ErrorMessage = &errorMessage
+	if input.Message != "" {
+		resp.Message = json.RawMessage(input.Message)
+	}
+	if input.Warnings != "" {
+		resp.Warnings = json.RawMessage(input.Warnings)
+	}
+	return resp
+}
+
+// storedNow returns the current time as it is stored and read back
+func storedNow() (time.Time, string) {
+	now := time.Now().UTC().Truncate(time.Second)
+	return now, now.Format(sqliteTimeFormat)
+}
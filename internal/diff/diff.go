@@ -0,0 +1,187 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// maxLines bounds the line diff, which is quadratic in the number of lines
+const maxLines = 5000
+
+// Result is the difference between two bodies
+type Result struct {
+	Format    string    `json:"format"` // "json" or "text"
+	Equal     bool      `json:"equal"`
+	Changes   []*Change `json:"changes,omitempty"`   // JSON bodies
+	Lines     []*Line   `json:"lines,omitempty"`     // Text bodies
+	Truncated bool      `json:"truncated,omitempty"` // Too large for a line diff
+}
+
+// Change is a difference at one path of two JSON documents
+type Change struct {
+	Path string      `json:"path"`
+	Op   string      `json:"op"` // "added", "removed" or "changed"
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Line is an added or removed line of a text diff. OldLine and NewLine are
+// 1-based positions in the respective body (0 when the line isn't in it).
+type Line struct {
+	Op      string `json:"op"` // "added" or "removed"
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+	Text    string `json:"text"`
+}
+
+// Bodies compares two bodies, structurally when both are JSON and line by
+// line otherwise
+func Bodies(a, b string) *Result {
+	var av, bv interface{}
+	if isJSON(a, &av) && isJSON(b, &bv) {
+		changes := JSON(av, bv)
+		return &Result{Format: "json", Equal: len(changes) == 0, Changes: changes}
+	}
+
+	if a == b {
+		return &Result{Format: "text", Equal: true}
+	}
+	lines, ok := Lines(a, b)
+	return &Result{Format: "text", Lines: lines, Truncated: !ok}
+}
+
+// JSON returns the changes between two decoded JSON values, ordered by path
+func JSON(a, b interface{}) []*Change {
+	var changes []*Change
+	compare("", a, b, &changes)
+	return changes
+}
+
+func compare(path string, a, b interface{}, changes *[]*Change) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, key := range unionKeys(av, bv) {
+			child := joinKey(path, key)
+			aChild, inA := av[key]
+			bChild, inB := bv[key]
+			switch {
+			case !inB:
+				*changes = append(*changes, &Change{Path: child, Op: "removed", Old: aChild})
+			case !inA:
+				*changes = append(*changes, &Change{Path: child, Op: "added", New: bChild})
+			default:
+				compare(child, aChild, bChild, changes)
+			}
+		}
+		return
+
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(bv):
+				*changes = append(*changes, &Change{Path: child, Op: "removed", Old: av[i]})
+			case i >= len(av):
+				*changes = append(*changes, &Change{Path: child, Op: "added", New: bv[i]})
+			default:
+				compare(child, av[i], bv[i], changes)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, &Change{Path: rootPath(path), Op: "changed", Old: a, New: b})
+	}
+}
+
+// Lines returns the added and removed lines between two texts using a
+// longest-common-subsequence diff. It returns false if either text is too
+// large to diff.
+func Lines(a, b string) ([]*Line, bool) {
+	al := strings.Split(a, "\n")
+	bl := strings.Split(b, "\n")
+	if len(al) > maxLines || len(bl) > maxLines {
+		return nil, false
+	}
+
+	// lcs[i][j] is the LCS length of al[i:] and bl[j:]
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []*Line
+	i, j := 0, 0
+	for i < len(al) || j < len(bl) {
+		switch {
+		case i < len(al) && j < len(bl) && al[i] == bl[j]:
+			i++
+			j++
+		case i < len(al) && (j == len(bl) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, &Line{Op: "removed", OldLine: i + 1, Text: al[i]})
+			i++
+		default:
+			lines = append(lines, &Line{Op: "added", NewLine: j + 1, Text: bl[j]})
+			j++
+		}
+	}
+	return lines, true
+}
+
+func isJSON(s string, v *interface{}) bool {
+	trimmed := bytes.TrimSpace([]byte(s))
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return false
+	}
+	return json.Unmarshal(trimmed, v) == nil
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func rootPath(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return path
+}
@@ -0,0 +1,131 @@
+// Package export publishes completed traffic records, and optionally the
+// chunks of streamed responses as they arrive, to event sinks for downstream
+// processing pipelines.
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/sink"
+)
+
+// SchemaVersion is incremented on incompatible changes to Record or Chunk
+const SchemaVersion = 1
+
+// Topics that exported payloads are published under
+const (
+	TopicRecord = "record"
+	TopicChunk  = "chunk"
+)
+
+// queueSize is how many payloads are queued per sink before new ones are dropped
+const queueSize = 10000
+
+// Record is a completed request with its response
+type Record struct {
+	SchemaVersion int                `json:"schema_version"`
+	Type          string             `json:"type"` // Always "record"
+	Request       *database.Request  `json:"request"`
+	Response      *database.Response `json:"response"`
+}
+
+// Chunk is a piece of a streamed response body as read from the provider.
+// Sequence starts at 0 for each request; the final Record follows the last chunk.
+type Chunk struct {
+	SchemaVersion int       `json:"schema_version"`
+	Type          string    `json:"type"` // Always "chunk"
+	RequestID     string    `json:"request_id"`
+	Provider      string    `json:"provider"`
+	Sequence      int       `json:"sequence"`
+	Data          string    `json:"data"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// Exporter publishes records and chunks to its sinks in the background
+type Exporter struct {
+	queues []*sink.Queue
+	chunks bool
+}
+
+// New creates an exporter publishing to the given sinks, keyed by name for
+// logging. chunks enables Chunk payloads for streamed responses.
+func New(sinks map[string]sink.Sink, chunks bool) *Exporter {
+	e := &Exporter{chunks: chunks}
+	for name, s := range sinks {
+		e.queues = append(e.queues, sink.NewQueue(name, s, queueSize))
+	}
+	return e
+}
+
+// ChunksEnabled reports whether streamed response chunks are exported
+func (e *Exporter) ChunksEnabled() bool {
+	return e.chunks
+}
+
+// Record publishes a completed request and its response
+func (e *Exporter) Record(req *database.Request, resp *database.Response) {
+	e.publish(TopicRecord, &Record{
+		SchemaVersion: SchemaVersion,
+		Type:          TopicRecord,
+		Request:       req,
+		Response:      resp,
+	})
+}
+
+// Chunk publishes a piece of a streamed response
+func (e *Exporter) Chunk(requestID, provider string, sequence int, data []byte) {
+	if !e.chunks {
+		return
+	}
+	e.publish(TopicChunk, &Chunk{
+		SchemaVersion: SchemaVersion,
+		Type:          TopicChunk,
+		RequestID:     requestID,
+		Provider:      provider,
+		Sequence:      sequence,
+		Data:          string(data),
+		Timestamp:     time.Now(),
+	})
+}
+
+func (e *Exporter) publish(topic string, v interface{}) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("failed to encode export payload", "topic", topic, "error", err)
+		return
+	}
+	for _, q := range e.queues {
+		q.Enqueue(topic, payload)
+	}
+}
+
+// Dropped returns how many payloads were dropped because a sink's queue was full
+func (e *Exporter) Dropped() int64 {
+	var n int64
+	for _, q := range e.queues {
+		n += q.Dropped()
+	}
+	return n
+}
+
+// Failed returns how many payloads a sink failed to publish
+func (e *Exporter) Failed() int64 {
+	var n int64
+	for _, q := range e.queues {
+		n += q.Failed()
+	}
+	return n
+}
+
+// Close publishes what is still queued, until ctx is done, and closes the sinks
+func (e *Exporter) Close(ctx context.Context) {
+	for _, q := range e.queues {
+		if err := q.Close(ctx); err != nil {
+			slog.Warn("failed to close export sink", "error", err)
+		}
+	}
+}
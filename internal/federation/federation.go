@@ -0,0 +1,83 @@
+package federation
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/storage"
+)
+
+// Batch is the payload an edge gateway sends to POST /api/ingest on the aggregator
+type Batch struct {
+	Source  string    `json:"source"`
+	Records []*Record `json:"records"`
+}
+
+// Record is a request with everything recorded for it
+type Record struct {
+	Request   *database.Request    `json:"request"`
+	Responses []*database.Response `json:"responses"`
+	Files     []*File              `json:"files,omitempty"`
+	// Recorded stream chunks by response ID; only archived records carry them
+	Chunks map[string][]*database.ResponseChunk `json:"chunks,omitempty"`
+}
+
+// File is a binary file reference with its content, sent only when file
+// forwarding is enabled
+type File struct {
+	*database.BinaryFile
+	Content []byte `json:"content"`
+}
+
+// IngestResult summarizes an ingested batch
+type IngestResult struct {
+	Ingested  int `json:"ingested"`
+	Duplicate int `json:"duplicate"`
+}
+
+// Ingest stores a batch received from an edge gateway. Records that were
+// already ingested are skipped, so edges can safely resend a batch.
+func Ingest(db *database.DB, fs storage.FileStorage, batch *Batch) (*IngestResult, error) {
+	if batch.Source == "" {
+		return nil, fmt.Errorf("batch source is required")
+	}
+
+	result := &IngestResult{}
+	for _, record := range batch.Records {
+		if record.Request == nil || record.Request.ID == "" {
+			return result, fmt.Errorf("record without a request")
+		}
+		// The provider names the directory the record's files are saved in
+		if err := storage.CheckProvider(record.Request.Provider); err != nil {
+			return result, fmt.Errorf("record %s: %w", record.Request.ID, err)
+		}
+
+		inserted, err := db.IngestRecord(batch.Source, record.Request, record.Responses)
+		if err != nil {
+			return result, err
+		}
+		if !inserted {
+			result.Duplicate++
+			continue
+		}
+		result.Ingested++
+
+		for _, file := range record.Files {
+			if file.BinaryFile == nil {
+				continue
+			}
+			filePath, _, err := fs.SaveFile(record.Request.Provider, file.ContentType, bytes.NewReader(file.Content))
+			if err != nil {
+				slog.Warn("failed to save federated file", "file_id", file.ID, "error", err)
+				continue
+			}
+			if err := db.IngestBinaryFile(file.BinaryFile, filePath); err != nil {
+				slog.Warn("failed to store federated file reference", "file_id", file.ID, "error", err)
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,186 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/storage"
+)
+
+const (
+	// batchSize is the maximum number of records sent per ingest call
+	batchSize = 100
+
+	// settleDelay gives asynchronous post-processing (e.g. file downloads)
+	// time to finish after the final response is stored
+	settleDelay = 10 * time.Second
+
+	// abandonAfter is when a request that never got a response is sent anyway
+	abandonAfter = time.Hour
+)
+
+// Options configures a Syncer
+type Options struct {
+	URL          string        // Base URL of the aggregator gateway
+	Token        string        // Bearer token expected by the aggregator (optional)
+	Source       string        // Name of this gateway instance
+	Interval     time.Duration // Time between sync rounds
+	IncludeFiles bool          // Also send binary file contents
+}
+
+// Syncer forwards locally recorded requests and responses to an aggregator gateway
+type Syncer struct {
+	db     *database.DB
+	fs     storage.FileStorage
+	opts   Options
+	client *http.Client
+}
+
+// NewSyncer creates a new syncer
+func NewSyncer(db *database.DB, fs storage.FileStorage, opts Options) *Syncer {
+	if opts.Source == "" {
+		opts.Source, _ = os.Hostname()
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+
+	return &Syncer{
+		db:     db,
+		fs:     fs,
+		opts:   opts,
+		client: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Source returns the name this gateway reports to the aggregator
+func (s *Syncer) Source() string {
+	return s.opts.Source
+}
+
+// Run syncs every interval until the context is cancelled
+func (s *Syncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the backlog a batch at a time
+		for {
+			n, err := s.SyncOnce(ctx)
+			if err != nil {
+				slog.Warn("federation sync failed", "error", err)
+				break
+			}
+			if n < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncOnce sends one batch of settled, unsynced records and returns how many were sent
+func (s *Syncer) SyncOnce(ctx context.Context) (int, error) {
+	requests, err := s.db.ListUnsyncedRequests(batchSize, settleDelay, abandonAfter)
+	if err != nil {
+		return 0, err
+	}
+	if len(requests) == 0 {
+		return 0, nil
+	}
+
+	batch := &Batch{Source: s.opts.Source}
+	ids := make([]string, 0, len(requests))
+	for _, req := range requests {
+		record, err := s.buildRecord(req)
+		if err != nil {
+			return 0, err
+		}
+		batch.Records = append(batch.Records, record)
+		ids = append(ids, req.ID)
+	}
+
+	if err := s.send(ctx, batch); err != nil {
+		return 0, err
+	}
+
+	if err := s.db.MarkRequestsSynced(ids); err != nil {
+		return 0, err
+	}
+
+	slog.Info("federation sync", "records", len(ids), "url", s.opts.URL)
+	return len(ids), nil
+}
+
+// buildRecord collects the responses and, optionally, files of a request
+func (s *Syncer) buildRecord(req *database.Request) (*Record, error) {
+	responses, err := s.db.GetResponsesByRequestID(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	record := &Record{Request: req, Responses: responses}
+
+	if !s.opts.IncludeFiles {
+		return record, nil
+	}
+
+	files, err := s.db.GetBinaryFilesByRequestID(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		content, err := storage.ReadFile(s.fs, file.FilePath)
+		if err != nil {
+			slog.Warn("failed to read file for federation", "path", file.FilePath, "error", err)
+			continue
+		}
+		record.Files = append(record.Files, &File{BinaryFile: file, Content: content})
+	}
+
+	return record, nil
+}
+
+// send posts a batch to the aggregator's ingest endpoint
+func (s *Syncer) send(ctx context.Context, batch *Batch) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+
+	url := strings.TrimSuffix(s.opts.URL, "/") + "/api/ingest"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create ingest request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.opts.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach aggregator: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("aggregator returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
@@ -0,0 +1,277 @@
+package finetune
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// Options configures a Monitor
+type Options struct {
+	Interval   time.Duration                   // Time between polls of unfinished jobs
+	WebhookURL string                          // Receives a JSON notification when a job finishes (optional)
+	OnUpdate   func(job *database.FineTuneJob) // Called when a job is created or changes status (optional)
+}
+
+// Monitor polls fine-tuning jobs created through the gateway until they
+// finish, recording every status change
+type Monitor struct {
+	db        *database.DB
+	providers map[string]provider.Provider
+	opts      Options
+	client    *http.Client
+
+	mu sync.Mutex
+	// Authorization header each job was created with. Only kept in memory;
+	// after a restart jobs are polled with the gateway's own key.
+	credentials map[string]string
+}
+
+// job is the part of a provider's fine-tuning job object the monitor reads
+type job struct {
+	ID             string `json:"id"`
+	Object         string `json:"object"`
+	Model          string `json:"model"`
+	TrainingFile   string `json:"training_file"`
+	Status         string `json:"status"`
+	FineTunedModel string `json:"fine_tuned_model"`
+	Error          *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// finished reports whether the job reached a final status
+func (j *job) finished() bool {
+	switch j.Status {
+	case "succeeded", "failed", "cancelled":
+		return true
+	}
+	return false
+}
+
+// errorMessage returns the job's failure reason, if any
+func (j *job) errorMessage() string {
+	if j.Error == nil {
+		return ""
+	}
+	if j.Error.Message != "" {
+		return j.Error.Message
+	}
+	return j.Error.Code
+}
+
+// New creates a monitor for jobs on the given providers
+func New(db *database.DB, providers []provider.Provider, opts Options) *Monitor {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+
+	m := &Monitor{
+		db:          db,
+		providers:   make(map[string]provider.Provider, len(providers)),
+		opts:        opts,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		credentials: make(map[string]string),
+	}
+	for _, p := range providers {
+		m.providers[p.Name()] = p
+	}
+	return m
+}
+
+// IsCreateRequest reports whether an upstream request creates a fine-tuning job
+func IsCreateRequest(method, path string) bool {
+	return method == http.MethodPost && strings.HasSuffix(strings.TrimSuffix(path, "/"), "/fine_tuning/jobs")
+}
+
+// Track starts monitoring the job returned by a successful create request.
+// req is the upstream request that created it.
+func (m *Monitor) Track(prov provider.Provider, requestID string, req *http.Request, body []byte) error {
+	var created job
+	if err := json.Unmarshal(body, &created); err != nil {
+		return fmt.Errorf("failed to parse fine-tune job: %w", err)
+	}
+	if created.ID == "" || created.Object != "fine_tuning.job" {
+		return fmt.Errorf("response is not a fine-tune job")
+	}
+
+	jobURL := *req.URL
+	jobURL.Path = strings.TrimSuffix(jobURL.Path, "/") + "/" + created.ID
+	jobURL.RawPath = ""
+	jobURL.RawQuery = ""
+
+	var trainingRequestID string
+	if created.TrainingFile != "" {
+		var err error
+		if trainingRequestID, err = m.db.FindFileUploadRequest(created.TrainingFile); err != nil {
+			slog.Warn("failed to find training file upload", "job_id", created.ID, "error", err)
+		}
+	}
+
+	stored, err := m.db.StoreFineTuneJob(&database.StoreFineTuneJobInput{
+		ID:                created.ID,
+		Provider:          prov.Name(),
+		RequestID:         requestID,
+		URL:               jobURL.String(),
+		Model:             created.Model,
+		TrainingFile:      created.TrainingFile,
+		TrainingRequestID: trainingRequestID,
+		Status:            created.Status,
+		Body:              string(body),
+	})
+	if err != nil {
+		return err
+	}
+
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		m.mu.Lock()
+		m.credentials[created.ID] = auth
+		m.mu.Unlock()
+	}
+
+	slog.Info("tracking fine-tune job", "job_id", stored.ID, "provider", stored.Provider, "status", stored.Status)
+	m.notify(stored)
+	return nil
+}
+
+// Run polls unfinished jobs every interval until the context is cancelled
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.PollOnce(ctx); err != nil {
+				slog.Warn("fine-tune poll failed", "error", err)
+			}
+		}
+	}
+}
+
+// PollOnce fetches the current state of every unfinished job
+func (m *Monitor) PollOnce(ctx context.Context) error {
+	jobs, err := m.db.ListFineTuneJobs(true)
+	if err != nil {
+		return err
+	}
+
+	for _, j := range jobs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := m.poll(ctx, j); err != nil {
+			slog.Warn("failed to poll fine-tune job", "job_id", j.ID, "provider", j.Provider, "error", err)
+		}
+	}
+	return nil
+}
+
+// poll fetches one job and records a status change
+func (m *Monitor) poll(ctx context.Context, tracked *database.FineTuneJob) error {
+	prov := m.providers[tracked.Provider]
+	if prov == nil {
+		return fmt.Errorf("provider %q is not registered", tracked.Provider)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tracked.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create poll request: %w", err)
+	}
+	m.mu.Lock()
+	auth := m.credentials[tracked.ID]
+	m.mu.Unlock()
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	if err := prov.PrepareRequest(req); err != nil {
+		return err
+	}
+
+	client := m.client
+	if transporter, ok := prov.(provider.Transporter); ok {
+		client = &http.Client{Transport: transporter.Transport(), Timeout: m.client.Timeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read job: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("provider returned status %d", resp.StatusCode)
+	}
+
+	var current job
+	if err := json.Unmarshal(body, &current); err != nil {
+		return fmt.Errorf("failed to parse fine-tune job: %w", err)
+	}
+	if current.Status == "" {
+		return fmt.Errorf("job has no status")
+	}
+
+	updated, changed, err := m.db.UpdateFineTuneJob(tracked.ID, &database.UpdateFineTuneJobInput{
+		Status:         current.Status,
+		FineTunedModel: current.FineTunedModel,
+		Error:          current.errorMessage(),
+		Body:           string(body),
+		Finished:       current.finished(),
+	})
+	if err != nil || !changed {
+		return err
+	}
+
+	slog.Info("fine-tune job updated", "job_id", updated.ID, "status", updated.Status)
+	if updated.FinishedAt != nil {
+		m.mu.Lock()
+		delete(m.credentials, updated.ID)
+		m.mu.Unlock()
+	}
+	m.notify(updated)
+	return nil
+}
+
+// notify reports a job change to OnUpdate, and finished jobs to the webhook
+func (m *Monitor) notify(j *database.FineTuneJob) {
+	if m.opts.OnUpdate != nil {
+		m.opts.OnUpdate(j)
+	}
+	if m.opts.WebhookURL != "" && j.FinishedAt != nil {
+		go m.sendWebhook(j)
+	}
+}
+
+// sendWebhook posts a finished job to the configured webhook
+func (m *Monitor) sendWebhook(j *database.FineTuneJob) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"event": "fine_tune_finished",
+		"job":   j,
+	})
+
+	resp, err := m.client.Post(m.opts.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("failed to send fine-tune webhook", "job_id", j.ID, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Warn("fine-tune webhook rejected notification", "job_id", j.ID, "status", resp.StatusCode)
+	}
+}
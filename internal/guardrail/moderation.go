@@ -0,0 +1,188 @@
+package guardrail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultModerationURL is OpenAI's moderation endpoint
+const DefaultModerationURL = "https://api.openai.com/v1/moderations"
+
+// ModerationOptions configures a Moderator
+type ModerationOptions struct {
+	URL     string        // Endpoint speaking OpenAI's moderation API (default: DefaultModerationURL)
+	APIKey  string        // Sent as a bearer token (optional for self-hosted endpoints)
+	Model   string        // Moderation model (optional)
+	Timeout time.Duration // Per check (default: 10 seconds)
+}
+
+// Moderator checks prompts against a moderation endpoint
+type Moderator struct {
+	opts   ModerationOptions
+	client *http.Client
+}
+
+// ModerationResult is the verdict on a flagged prompt
+type ModerationResult struct {
+	Flagged    bool     `json:"flagged"`
+	Categories []string `json:"categories,omitempty"` // Flagged categories, e.g. harassment, violence
+}
+
+// NewModerator creates a moderator
+func NewModerator(opts ModerationOptions) *Moderator {
+	if opts.URL == "" {
+		opts.URL = DefaultModerationURL
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	return &Moderator{opts: opts, client: &http.Client{Timeout: opts.Timeout}}
+}
+
+// URL returns the moderation endpoint
+func (m *Moderator) URL() string {
+	return m.opts.URL
+}
+
+// Check sends the prompt text of a request body to the moderation endpoint.
+// It returns nil if the body has no prompt text to check.
+func (m *Moderator) Check(ctx context.Context, body []byte) (*ModerationResult, error) {
+	inputs := PromptText(body)
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+
+	payload := map[string]any{"input": inputs}
+	if m.opts.Model != "" {
+		payload["model"] = m.opts.Model
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.opts.URL, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.opts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.opts.APIKey)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("moderation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("moderation request failed: status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var verdict struct {
+		Results []struct {
+			Flagged    bool            `json:"flagged"`
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return nil, fmt.Errorf("failed to parse moderation response: %w", err)
+	}
+	if len(verdict.Results) == 0 {
+		return nil, fmt.Errorf("moderation response has no results")
+	}
+
+	// One result per input; the request is flagged if any input is
+	result := &ModerationResult{}
+	seen := make(map[string]bool)
+	for _, r := range verdict.Results {
+		result.Flagged = result.Flagged || r.Flagged
+		for category, flagged := range r.Categories {
+			if flagged && !seen[category] {
+				seen[category] = true
+				result.Categories = append(result.Categories, category)
+			}
+		}
+	}
+	sort.Strings(result.Categories)
+	return result, nil
+}
+
+// promptBody covers the request bodies prompt text is read from: OpenAI chat
+// and legacy completions, embeddings, the Responses API, image generation,
+// and Anthropic messages
+type promptBody struct {
+	Messages []struct {
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+	System json.RawMessage `json:"system"`
+	Prompt json.RawMessage `json:"prompt"`
+	Input  json.RawMessage `json:"input"`
+}
+
+// PromptText returns the text a client sent in a JSON request body, one
+// entry per message or prompt
+func PromptText(body []byte) []string {
+	var parsed promptBody
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil
+	}
+	var texts []string
+	add := func(raw json.RawMessage) {
+		for _, text := range contentText(raw) {
+			if strings.TrimSpace(text) != "" {
+				texts = append(texts, text)
+			}
+		}
+	}
+	add(parsed.System)
+	for _, message := range parsed.Messages {
+		add(message.Content)
+	}
+	add(parsed.Prompt)
+	add(parsed.Input)
+	return texts
+}
+
+// contentText reads text from a string, a list of strings, or a list of
+// content parts or Responses API input items
+func contentText(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return []string{text}
+	}
+	var items []json.RawMessage
+	if json.Unmarshal(raw, &items) != nil {
+		return nil
+	}
+	var texts []string
+	for _, item := range items {
+		if json.Unmarshal(item, &text) == nil {
+			texts = append(texts, text)
+			continue
+		}
+		var part struct {
+			Type    string          `json:"type"`
+			Text    string          `json:"text"`
+			Content json.RawMessage `json:"content"`
+		}
+		if json.Unmarshal(item, &part) != nil {
+			continue
+		}
+		if part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+		texts = append(texts, contentText(part.Content)...)
+	}
+	return texts
+}
@@ -0,0 +1,85 @@
+package guardrail
+
+import (
+	"regexp"
+)
+
+// piiRule is a named pattern for one kind of personal data. valid, if set,
+// rejects matches that only look like it.
+type piiRule struct {
+	name    string
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}
+
+// piiRules are the kinds of personal data ScanPII looks for
+var piiRules = []piiRule{
+	{"email", regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`), nil},
+	{"phone", regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?\(?\b\d{3}\)?[ .-]\d{3}[ .-]\d{4}\b|\+\d{10,15}\b`), nil},
+	{"credit_card", regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), luhn},
+	{"us_ssn", regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), nil},
+}
+
+// PIIKinds lists the kinds of personal data ScanPII can detect
+func PIIKinds() []string {
+	kinds := make([]string, len(piiRules))
+	for i, rule := range piiRules {
+		kinds[i] = rule.name
+	}
+	return kinds
+}
+
+// ScanPII returns the personal data (email addresses, phone numbers, card
+// numbers, US social security numbers) found in a body, at most one finding
+// per distinct match. kinds limits the scan to some of PIIKinds; nil scans
+// for all.
+func ScanPII(body []byte, kinds ...string) []Finding {
+	var findings []Finding
+	seen := make(map[string]bool)
+
+	for _, rule := range piiRules {
+		if len(kinds) > 0 && !contains(kinds, rule.name) {
+			continue
+		}
+		for _, match := range rule.pattern.FindAll(body, -1) {
+			if seen[string(match)] || (rule.valid != nil && !rule.valid(string(match))) {
+				continue
+			}
+			seen[string(match)] = true
+			findings = append(findings, Finding{Rule: rule.name, Match: mask(string(match))})
+		}
+	}
+
+	return findings
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum of card numbers
+func luhn(s string) bool {
+	sum, digits, double := 0, 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+		double = !double
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
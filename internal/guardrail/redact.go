@@ -0,0 +1,142 @@
+package guardrail
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// CredentialHeaders carry credentials and are masked wherever headers leave
+// the proxy path: in storage and in export bundles
+var CredentialHeaders = []string{
+	"Authorization", "Proxy-Authorization", "X-Api-Key", "Api-Key", "X-Goog-Api-Key",
+	"X-AIGW-Key", "Cookie", "Set-Cookie",
+}
+
+// authScheme matches the scheme of an Authorization header value
+var authScheme = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]* `)
+
+// Redactor masks credentials in headers and bodies before they are stored.
+// The request sent upstream is not affected.
+type Redactor struct {
+	headers  map[string]bool // Canonical header names
+	patterns []*regexp.Regexp
+	secrets  bool // Also scrub what the secret scanner finds
+}
+
+// RedactorOptions configures a Redactor
+type RedactorOptions struct {
+	Credentials bool     // Mask CredentialHeaders
+	Headers     []string // Further headers to mask
+	Patterns    []string // Regular expressions for body content to mask; only the first group if they have one
+	Secrets     bool     // Scrub credentials found by the secret scanner from bodies
+}
+
+// NewRedactor compiles a redactor. It returns nil if there is nothing to redact.
+func NewRedactor(opts RedactorOptions) (*Redactor, error) {
+	r := &Redactor{headers: make(map[string]bool), secrets: opts.Secrets}
+	if opts.Credentials {
+		for _, name := range CredentialHeaders {
+			r.headers[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	for _, name := range opts.Headers {
+		if name = strings.TrimSpace(name); name != "" {
+			r.headers[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	for _, expr := range opts.Patterns {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", expr, err)
+		}
+		r.patterns = append(r.patterns, pattern)
+	}
+	if len(r.headers) == 0 && len(r.patterns) == 0 && !r.secrets {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// LoadPatterns reads redaction patterns from a file, one regular expression
+// per line. Blank lines and lines starting with # are skipped.
+func LoadPatterns(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction patterns: %w", err)
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// RedactHeaders returns a copy of headers with the redacted ones masked
+func (r *Redactor) RedactHeaders(headers map[string]string) map[string]string {
+	if len(r.headers) == 0 {
+		return headers
+	}
+	redacted := make(map[string]string, len(headers))
+	for key, value := range headers {
+		if r.headers[http.CanonicalHeaderKey(key)] && value != "" {
+			value = MaskCredential(value)
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// RedactBody masks the matches of the redaction patterns, and the credentials
+// found by the secret scanner if enabled
+func (r *Redactor) RedactBody(body string) string {
+	if r.secrets {
+		body = string(ScrubSecrets([]byte(body)))
+	}
+	for _, pattern := range r.patterns {
+		if pattern.NumSubexp() == 0 {
+			body = pattern.ReplaceAllLiteralString(body, "[REDACTED]")
+			continue
+		}
+		// Mask only the first group, keeping the context around it
+		var out strings.Builder
+		last := 0
+		for _, loc := range pattern.FindAllStringSubmatchIndex(body, -1) {
+			if loc[2] < 0 {
+				continue
+			}
+			out.WriteString(body[last:loc[2]])
+			out.WriteString("[REDACTED]")
+			last = loc[3]
+		}
+		out.WriteString(body[last:])
+		body = out.String()
+	}
+	return body
+}
+
+// MaskCredential masks a credential header value, keeping the authorization
+// scheme and, for long tokens, the last four characters so the key that was
+// used can still be told apart: "Bearer sk-...wxyz" becomes "Bearer ****wxyz"
+func MaskCredential(value string) string {
+	scheme := authScheme.FindString(value)
+	credential := strings.TrimSpace(value[len(scheme):])
+	if strings.Contains(credential, " ") {
+		// Not scheme and token, e.g. a cookie list
+		scheme, credential = "", value
+	}
+	const visible = 4
+	if len(credential) < 24 || strings.EqualFold(strings.TrimSpace(scheme), "Basic") {
+		return scheme + "****"
+	}
+	return scheme + "****" + credential[len(credential)-visible:]
+}
@@ -0,0 +1,201 @@
+package guardrail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RiskRule scores prompts that look like prompt injection or jailbreak
+// attempts. A rule matches if any of its keywords (case-insensitive) or its
+// pattern occurs in the prompt text.
+type RiskRule struct {
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords,omitempty"`
+	Pattern  string   `json:"pattern,omitempty"` // Regular expression
+	Weight   float64  `json:"weight"`            // Risk a match adds, 0 to 1
+}
+
+// DefaultRiskRules are the heuristics used when no rules file is configured
+var DefaultRiskRules = []RiskRule{
+	{Name: "ignore_instructions", Pattern: `(?i)\b(?:ignore|disregard|forget)\b.{0,30}\b(?:previous|prior|above|earlier|all|your)\b.{0,20}\b(?:instructions|rules|prompts?|guidelines)\b`, Weight: 0.7},
+	{Name: "system_prompt_leak", Pattern: `(?i)\b(?:reveal|print|repeat|show|output)\b.{0,30}\b(?:system prompt|initial instructions|hidden instructions)\b`, Weight: 0.6},
+	{Name: "role_override", Pattern: `(?i)\byou are (?:now|no longer)\b|\bfrom now on,? you (?:will|are|must)\b`, Weight: 0.4},
+	{Name: "jailbreak_persona", Keywords: []string{"do anything now", "developer mode enabled", "jailbreak", "dan mode", "no restrictions"}, Weight: 0.6},
+	{Name: "fake_delimiters", Pattern: `(?i)(?:<\|?/?(?:system|im_start|im_end)\|?>|\[/?INST\]|###\s*(?:system|instruction))`, Weight: 0.5},
+}
+
+// LoadRiskRules reads risk rules from a JSON file holding a list of rules
+func LoadRiskRules(path string) ([]RiskRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read risk rules: %w", err)
+	}
+	var rules []RiskRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse risk rules: %w", err)
+	}
+	return rules, nil
+}
+
+// compiledRiskRule is a RiskRule ready for matching
+type compiledRiskRule struct {
+	RiskRule
+	keywords []string // Lower-cased
+	pattern  *regexp.Regexp
+}
+
+// RiskScorerOptions configures a RiskScorer
+type RiskScorerOptions struct {
+	Rules         []RiskRule
+	ClassifierURL string        // Endpoint scoring prompt text (optional)
+	APIKey        string        // Sent to the classifier as a bearer token (optional)
+	Timeout       time.Duration // Per classifier call (default: 10 seconds)
+}
+
+// RiskScorer scores the prompt text of requests for prompt injection and
+// jailbreak attempts
+type RiskScorer struct {
+	rules  []compiledRiskRule
+	opts   RiskScorerOptions
+	client *http.Client
+}
+
+// RiskScore is the outcome of scoring a prompt
+type RiskScore struct {
+	Score float64  `json:"score"`           // 0 (benign) to 1
+	Rules []string `json:"rules,omitempty"` // Rules that matched, plus "classifier" if it scored higher
+}
+
+// NewRiskScorer compiles the rules of a scorer
+func NewRiskScorer(opts RiskScorerOptions) (*RiskScorer, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	s := &RiskScorer{opts: opts, client: &http.Client{Timeout: opts.Timeout}}
+	for _, rule := range opts.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("risk rule without a name")
+		}
+		if rule.Weight < 0 || rule.Weight > 1 {
+			return nil, fmt.Errorf("risk rule %s: weight must be between 0 and 1", rule.Name)
+		}
+		compiled := compiledRiskRule{RiskRule: rule}
+		for _, keyword := range rule.Keywords {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				compiled.keywords = append(compiled.keywords, strings.ToLower(keyword))
+			}
+		}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("risk rule %s: invalid pattern: %w", rule.Name, err)
+			}
+			compiled.pattern = pattern
+		}
+		if len(compiled.keywords) == 0 && compiled.pattern == nil {
+			return nil, fmt.Errorf("risk rule %s has neither keywords nor a pattern", rule.Name)
+		}
+		s.rules = append(s.rules, compiled)
+	}
+	return s, nil
+}
+
+// Score scores the prompt text of a request body. Matching rules combine as
+// independent probabilities, so two rules of weight 0.5 score 0.75; the
+// classifier's score is used when it is higher. It returns nil if the body
+// has no prompt text. A failed classifier call is returned as an error along
+// with the score of the rules.
+func (s *RiskScorer) Score(ctx context.Context, body []byte) (*RiskScore, error) {
+	texts := PromptText(body)
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	result := &RiskScore{}
+	benign := 1.0
+	for _, rule := range s.rules {
+		if rule.matches(texts) {
+			benign *= 1 - rule.Weight
+			result.Rules = append(result.Rules, rule.Name)
+		}
+	}
+	result.Score = 1 - benign
+
+	if s.opts.ClassifierURL == "" {
+		return result, nil
+	}
+	score, err := s.classify(ctx, texts)
+	if err != nil {
+		return result, err
+	}
+	if score > result.Score {
+		result.Score = score
+		result.Rules = append(result.Rules, "classifier")
+	}
+	return result, nil
+}
+
+// matches reports whether the rule matches any of the texts
+func (r *compiledRiskRule) matches(texts []string) bool {
+	for _, text := range texts {
+		if r.pattern != nil && r.pattern.MatchString(text) {
+			return true
+		}
+		if len(r.keywords) == 0 {
+			continue
+		}
+		lower := strings.ToLower(text)
+		for _, keyword := range r.keywords {
+			if strings.Contains(lower, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// classify asks the classifier endpoint for a score. It is sent
+// {"input": [texts]} and answers {"score": 0.93}.
+func (s *RiskScorer) classify(ctx context.Context, texts []string) (float64, error) {
+	data, err := json.Marshal(map[string]any{"input": texts})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.opts.ClassifierURL, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.opts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.opts.APIKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("risk classifier request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("risk classifier request failed: status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var verdict struct {
+		Score *float64 `json:"score"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return 0, fmt.Errorf("failed to parse risk classifier response: %w", err)
+	}
+	if verdict.Score == nil || *verdict.Score < 0 || *verdict.Score > 1 {
+		return 0, fmt.Errorf("risk classifier response has no score between 0 and 1")
+	}
+	return *verdict.Score, nil
+}
@@ -0,0 +1,70 @@
+package guardrail
+
+import (
+	"regexp"
+)
+
+// Finding is a credential or personal data detected in a prompt. Match is
+// masked so the sensitive value itself is never stored.
+type Finding struct {
+	Rule  string `json:"rule"`
+	Match string `json:"match"`
+}
+
+// secretRule is a named pattern for one kind of credential
+type secretRule struct {
+	name    string
+	pattern *regexp.Regexp
+	span    *regexp.Regexp // Whole credential to scrub, when pattern only detects its start
+}
+
+// secretRules are the credentials the scanner looks for
+var secretRules = []secretRule{
+	{"aws_access_key_id", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), nil},
+	{"aws_secret_access_key", regexp.MustCompile(`(?i)aws_?secret_?access_?key\\?["']?\s*[:=]\s*\\?["']?[A-Za-z0-9/+=]{40}`), nil},
+	{"github_token", regexp.MustCompile(`\b(?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}\b`), nil},
+	{"github_pat", regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{82}\b`), nil},
+	{"private_key", regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )?PRIVATE KEY(?: BLOCK)?-----`),
+		regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )?PRIVATE KEY(?: BLOCK)?-----[\s\S]*?-----END (?:[A-Z]+ )?PRIVATE KEY(?: BLOCK)?-----`)},
+}
+
+// ScanSecrets returns the credentials found in a request body, at most one
+// finding per distinct match
+func ScanSecrets(body []byte) []Finding {
+	var findings []Finding
+	seen := make(map[string]bool)
+
+	for _, rule := range secretRules {
+		for _, match := range rule.pattern.FindAll(body, -1) {
+			if seen[string(match)] {
+				continue
+			}
+			seen[string(match)] = true
+			findings = append(findings, Finding{Rule: rule.name, Match: mask(string(match))})
+		}
+	}
+
+	return findings
+}
+
+// ScrubSecrets replaces the credentials in a body with a marker naming the
+// rule that found them, e.g. [REDACTED:github_token]
+func ScrubSecrets(body []byte) []byte {
+	for _, rule := range secretRules {
+		marker := []byte("[REDACTED:" + rule.name + "]")
+		if rule.span != nil {
+			body = rule.span.ReplaceAll(body, marker)
+		}
+		body = rule.pattern.ReplaceAll(body, marker)
+	}
+	return body
+}
+
+// mask keeps just enough of a match to recognise it
+func mask(s string) string {
+	const visible = 8
+	if len(s) <= visible {
+		return "****"
+	}
+	return s[:visible] + "****"
+}
@@ -0,0 +1,155 @@
+package importer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/federation"
+	"github.com/ruqqq/simple-ai-gateway/internal/storage"
+)
+
+// harLog is the part of a HAR 1.2 document that is imported
+type harLog struct {
+	Entries []*harEntry `json:"entries"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"` // Total time in milliseconds
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+}
+
+type harRequest struct {
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Headers  []harHeader `json:"headers"`
+	PostData *struct {
+		Text string `json:"text"`
+	} `json:"postData"`
+}
+
+type harResponse struct {
+	Status  int         `json:"status"` // 0 if no response was received
+	Headers []harHeader `json:"headers"`
+	Content struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Encoding string `json:"encoding"` // "base64" for binary bodies
+	} `json:"content"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// importHAR stores the entries of a HAR capture. Request IDs are derived
+// from the entry, so importing the same capture again finds duplicates.
+func importHAR(db *database.DB, fs storage.FileStorage, log *harLog, opts *Options, result *Result) error {
+	for i, entry := range log.Entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil || entry.Request.Method == "" {
+			result.Skipped++
+			continue
+		}
+
+		var body string
+		if entry.Request.PostData != nil {
+			body = entry.Request.PostData.Text
+		}
+		id := uuid.NewSHA1(uuid.NameSpaceURL, []byte(entry.StartedDateTime.Format(time.RFC3339Nano)+" "+entry.Request.Method+" "+entry.Request.URL+"\n"+body))
+		headers := harHeaders(entry.Request.Headers)
+		req := &database.Request{
+			ID:           id.String(),
+			Provider:     harProvider(u, opts),
+			Endpoint:     u.Path,
+			Query:        u.RawQuery,
+			Method:       entry.Request.Method,
+			Headers:      headers,
+			Body:         body,
+			ForwardedFor: headers["X-Forwarded-For"],
+			UserAgent:    headers["User-Agent"],
+			CreatedAt:    entry.StartedDateTime,
+		}
+
+		var responses []*database.Response
+		var files []*federation.File
+		if entry.Response.Status > 0 {
+			content := entry.Response.Content
+			respBody := []byte(content.Text)
+			if content.Encoding == "base64" {
+				if respBody, err = base64.StdEncoding.DecodeString(content.Text); err != nil {
+					return fmt.Errorf("entry %d: invalid base64 response content: %w", i+1, err)
+				}
+			}
+			resp := &database.Response{
+				ID:         uuid.NewSHA1(id, []byte("response")).String(),
+				StatusCode: entry.Response.Status,
+				Headers:    harHeaders(entry.Response.Headers),
+				Body:       string(respBody),
+				DurationMs: int(entry.Time),
+				IsError:    entry.Response.Status >= 400,
+				CreatedAt:  entry.StartedDateTime.Add(time.Duration(entry.Time * float64(time.Millisecond))),
+			}
+			responses = append(responses, resp)
+
+			// Binary responses are stored as files, like proxied ones
+			mimeType, _, _ := strings.Cut(content.MimeType, ";")
+			if isBinary(mimeType) && len(respBody) > 0 {
+				files = append(files, &federation.File{
+					BinaryFile: &database.BinaryFile{ID: uuid.New().String(), ResponseID: resp.ID, ContentType: mimeType},
+					Content:    respBody,
+				})
+			}
+		}
+
+		if err := store(db, fs, opts, result, req, responses, files); err != nil {
+			return fmt.Errorf("entry %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// harProvider returns the provider of a HAR entry: the configured one, the
+// provider prefix of a URL captured at the gateway, or else the URL's host
+func harProvider(u *url.URL, opts *Options) string {
+	if opts.Provider != "" {
+		return opts.Provider
+	}
+	first, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if opts.Router != nil && opts.Router.Provider(first) != nil {
+		return first
+	}
+	return u.Hostname()
+}
+
+// harHeaders converts HAR headers to stored headers, keeping the first value
+// of each like the proxy does. HTTP/2 pseudo-headers are dropped.
+func harHeaders(headers []harHeader) map[string]string {
+	result := make(map[string]string, len(headers))
+	for _, h := range headers {
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		name := http.CanonicalHeaderKey(h.Name)
+		if _, ok := result[name]; !ok {
+			result[name] = h.Value
+		}
+	}
+	return result
+}
+
+// isBinary reports whether responses of this content type are stored as files
+func isBinary(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/") ||
+		strings.HasPrefix(contentType, "audio/") ||
+		strings.HasPrefix(contentType, "video/")
+}
@@ -0,0 +1,141 @@
+// Package importer recreates recorded traffic from the gateway's JSONL
+// export or from a HAR capture, so captures can be moved between machines.
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/federation"
+	"github.com/ruqqq/simple-ai-gateway/internal/router"
+	"github.com/ruqqq/simple-ai-gateway/internal/storage"
+)
+
+// DefaultSource is recorded as the source of imported requests that have none
+const DefaultSource = "import"
+
+// Options configures an import
+type Options struct {
+	Source   string         // Source of imported requests that have none (default: DefaultSource)
+	Provider string         // Provider of HAR entries (default: taken from the URL)
+	Router   *router.Router // Recognizes provider prefixes in HAR URLs (optional)
+}
+
+// Result summarizes an import
+type Result struct {
+	Format    string `json:"format"` // "jsonl" or "har"
+	Imported  int    `json:"imported"`
+	Duplicate int    `json:"duplicate"` // Requests that were already stored
+	Skipped   int    `json:"skipped"`   // Stream chunks and lines without a request
+	Files     int    `json:"files"`     // Binary file references recreated
+}
+
+// line is one line of a JSONL import: an export Record (request and
+// response), a federation record or export bundle record (request,
+// responses and files), or a stream chunk. A HAR document has a log.
+type line struct {
+	Type string `json:"type"`
+	federation.Record
+	Response *database.Response `json:"response"`
+	Log      *harLog            `json:"log"`
+}
+
+// Import stores the records read from r. Requests keep their IDs and
+// timestamps, and requests that are already stored are skipped, so an
+// import can safely be repeated. An import that fails part way keeps what
+// was stored before the error.
+func Import(db *database.DB, fs storage.FileStorage, r io.Reader, opts Options) (*Result, error) {
+	if opts.Source == "" {
+		opts.Source = DefaultSource
+	}
+
+	result := &Result{Format: "jsonl"}
+	decoder := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		var record line
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, fmt.Errorf("record %d: invalid JSON: %w", n, err)
+		}
+
+		if record.Log != nil && n == 1 {
+			result.Format = "har"
+			return result, importHAR(db, fs, record.Log, &opts, result)
+		}
+		if record.Type == "chunk" || record.Request == nil || record.Request.ID == "" {
+			result.Skipped++
+			continue
+		}
+		responses := record.Responses
+		if record.Response != nil {
+			responses = append(responses, record.Response)
+		}
+		if err := store(db, fs, &opts, result, record.Request, responses, record.Files); err != nil {
+			return result, fmt.Errorf("record %d: %w", n, err)
+		}
+	}
+}
+
+// store stores a request with its responses and files, counting it in result
+func store(db *database.DB, fs storage.FileStorage, opts *Options, result *Result, req *database.Request, responses []*database.Response, files []*federation.File) error {
+	if req.CreatedAt.IsZero() {
+		req.CreatedAt = time.Now()
+	}
+	for _, resp := range responses {
+		resp.RequestID = req.ID
+		if resp.CreatedAt.IsZero() {
+			resp.CreatedAt = req.CreatedAt
+		}
+	}
+
+	inserted, err := db.IngestRecord(opts.Source, req, responses)
+	if err != nil {
+		return err
+	}
+	if !inserted {
+		result.Duplicate++
+		return nil
+	}
+	result.Imported++
+
+	for _, file := range files {
+		if file.BinaryFile == nil || file.ID == "" {
+			continue
+		}
+		file.RequestID = req.ID
+		if file.CreatedAt.IsZero() {
+			file.CreatedAt = req.CreatedAt
+		}
+
+		// Without content the reference is kept as it is, for files copied
+		// to the file storage separately
+		filePath := file.FilePath
+		if len(file.Content) > 0 {
+			saved, size, err := fs.SaveFile(req.Provider, file.ContentType, bytes.NewReader(file.Content))
+			if err != nil {
+				slog.Warn("failed to save imported file", "file_id", file.ID, "error", err)
+				continue
+			}
+			filePath, file.Size = saved, size
+		} else if !filepath.IsLocal(filePath) {
+			slog.Warn("skipping imported file reference outside the file storage", "file_id", file.ID, "file", filePath)
+			continue
+		}
+		if err := db.IngestBinaryFile(file.BinaryFile, filePath); err != nil {
+			slog.Warn("failed to store imported file reference", "file_id", file.ID, "error", err)
+			continue
+		}
+		result.Files++
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Package keypool spreads upstream requests across several gateway-side API
+// keys of a provider and rests keys that get rate limited.
+package keypool
+
+import (
+	"sync"
+	"time"
+)
+
+// Strategies for picking the next key
+const (
+	// RoundRobin cycles through the keys that aren't cooling down
+	RoundRobin = "round-robin"
+	// LeastLimited picks the key whose last 429 is longest ago (or never happened)
+	LeastLimited = "least-limited"
+)
+
+// Options configures a Pool
+type Options struct {
+	Strategy string        // RoundRobin (default) or LeastLimited
+	Cooldown time.Duration // How long a key rests after a 429 without Retry-After
+}
+
+// Pool hands out a provider's API keys
+type Pool struct {
+	mu       sync.Mutex
+	keys     []*key
+	next     int
+	strategy string
+	cooldown time.Duration
+}
+
+type key struct {
+	value        string
+	requests     int64
+	throttled    int64
+	lastLimited  time.Time
+	coolingUntil time.Time
+}
+
+// KeyStats reports a key's usage. The key itself is masked.
+type KeyStats struct {
+	Key             string     `json:"key"`
+	Requests        int64      `json:"requests"`
+	Throttled       int64      `json:"throttled"`
+	CoolingUntil    *time.Time `json:"cooling_until,omitempty"`
+	LastThrottledAt *time.Time `json:"last_throttled_at,omitempty"`
+}
+
+// New creates a pool of the given keys
+func New(keys []string, opts Options) *Pool {
+	if opts.Strategy == "" {
+		opts.Strategy = RoundRobin
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = time.Minute
+	}
+
+	p := &Pool{strategy: opts.Strategy, cooldown: opts.Cooldown}
+	for _, k := range keys {
+		p.keys = append(p.keys, &key{value: k})
+	}
+	return p
+}
+
+// Pick returns the key for the next request. Keys cooling down are skipped;
+// if all are, the one that becomes available first is used.
+func (p *Pool) Pick() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var picked *key
+	for i := range p.keys {
+		k := p.keys[(p.next+i)%len(p.keys)]
+		if k.coolingUntil.After(now) {
+			continue
+		}
+		if picked == nil || (p.strategy == LeastLimited && k.lastLimited.Before(picked.lastLimited)) {
+			picked = k
+		}
+		if p.strategy != LeastLimited {
+			break
+		}
+	}
+	if picked == nil {
+		for _, k := range p.keys {
+			if picked == nil || k.coolingUntil.Before(picked.coolingUntil) {
+				picked = k
+			}
+		}
+	}
+
+	for i, k := range p.keys {
+		if k == picked {
+			p.next = i + 1
+		}
+	}
+	picked.requests++
+	return picked.value
+}
+
+// Observe records a response sent with the key. A 429 starts a cooldown of
+// retryAfter, or the pool's default cooldown if the provider gave none.
+func (p *Pool) Observe(value string, statusCode int, retryAfter time.Duration) {
+	if statusCode != 429 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, k := range p.keys {
+		if k.value != value {
+			continue
+		}
+		if retryAfter <= 0 {
+			retryAfter = p.cooldown
+		}
+		now := time.Now()
+		k.throttled++
+		k.lastLimited = now
+		k.coolingUntil = now.Add(retryAfter)
+		return
+	}
+}
+
+// Stats returns the usage of every key, in configuration order
+func (p *Pool) Stats() []KeyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]KeyStats, 0, len(p.keys))
+	for _, k := range p.keys {
+		s := KeyStats{Key: Mask(k.value), Requests: k.requests, Throttled: k.throttled}
+		if k.coolingUntil.After(now) {
+			until := k.coolingUntil
+			s.CoolingUntil = &until
+		}
+		if !k.lastLimited.IsZero() {
+			last := k.lastLimited
+			s.LastThrottledAt = &last
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// Mask shortens a key to its first and last characters, for display
+func Mask(value string) string {
+	if len(value) < 12 {
+		return "****"
+	}
+	return value[:3] + "..." + value[len(value)-4:]
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type contextKey struct{}
+
+// Setup installs the default slog logger. level is debug, info, warn or
+// error; format is text or json.
+func Setup(w io.Writer, level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q (expected text or json)", format)
+	}
+
+	slog.SetDefault(slog.New(&contextHandler{Handler: handler}))
+	return nil
+}
+
+// With returns a context whose log records carry the given attributes, e.g.
+// the correlation ID of the HTTP request being served. Use the slog *Context
+// functions (slog.InfoContext, ...) for them to be added.
+func With(ctx context.Context, args ...any) context.Context {
+	existing, _ := ctx.Value(contextKey{}).([]any)
+	merged := make([]any, 0, len(existing)+len(args))
+	merged = append(append(merged, existing...), args...)
+	return context.WithValue(ctx, contextKey{}, merged)
+}
+
+// contextHandler adds the attributes stored by With to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if args, ok := ctx.Value(contextKey{}).([]any); ok {
+		r.Add(args...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// CopyAttrs returns ctx carrying the log attributes stored in from, so a
+// context with a different lifetime (e.g. the shutdown context used for
+// upstream calls) keeps the request's correlation attributes
+func CopyAttrs(ctx, from context.Context) context.Context {
+	args, ok := from.Value(contextKey{}).([]any)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, args)
+}
@@ -0,0 +1,28 @@
+// Package match holds the pattern matching shared by routing, chaos and
+// storage sampling rules
+package match
+
+import (
+	"path"
+	"strings"
+)
+
+// Path reports whether a request path matches a rule's path: as a path.Match
+// glob, or as a prefix ending at a "/", so "/openai" matches
+// "/openai/v1/models" but not "/openai-proxy"
+func Path(pattern, p string) bool {
+	if Glob(pattern, p) || p == pattern {
+		return true
+	}
+	return strings.HasPrefix(p, strings.TrimSuffix(pattern, "/")+"/")
+}
+
+// Glob matches value against a path.Match pattern, falling back to equality
+// when the pattern is malformed
+func Glob(pattern, value string) bool {
+	ok, err := path.Match(pattern, value)
+	if err != nil {
+		return pattern == value
+	}
+	return ok
+}
@@ -0,0 +1,136 @@
+// Package pgp writes passphrase-encrypted OpenPGP messages (RFC 4880), so
+// data leaving the gateway can be opened with a stock `gpg --decrypt`.
+package pgp
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// OpenPGP packet tags
+const (
+	tagSymmetricKeyEncryptedSessionKey = 3
+	tagLiteralData                     = 11
+	tagSymIntegrityProtectedData       = 18
+	tagModificationDetectionCode       = 19
+)
+
+// Algorithm IDs and parameters
+const (
+	cipherAES256     = 9
+	hashSHA256       = 8
+	s2kIteratedSalty = 3
+	// s2kCount is the coded S2K iteration count: 16 MiB of hashed input
+	s2kCount = 0xE0
+)
+
+// EncryptSymmetric writes plaintext to w as an OpenPGP message encrypted with
+// AES-256 under a key derived from passphrase (iterated and salted S2K with
+// SHA-256), integrity protected with a modification detection code. filename
+// is recorded in the literal data packet for the decrypting side.
+func EncryptSymmetric(w io.Writer, plaintext []byte, passphrase, filename string) error {
+	if passphrase == "" {
+		return fmt.Errorf("empty passphrase")
+	}
+
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := deriveKey([]byte(passphrase), salt, s2kDecodeCount(s2kCount))
+
+	// The session key is the S2K output itself, so the packet carries no encrypted key
+	skesk := []byte{4, cipherAES256, s2kIteratedSalty, hashSHA256}
+	skesk = append(skesk, salt...)
+	skesk = append(skesk, s2kCount)
+	if err := writePacket(w, tagSymmetricKeyEncryptedSessionKey, skesk); err != nil {
+		return err
+	}
+
+	if len(filename) > 255 {
+		filename = filename[:255]
+	}
+	var literal bytes.Buffer
+	literal.WriteByte('b')
+	literal.WriteByte(byte(len(filename)))
+	literal.WriteString(filename)
+	binary.Write(&literal, binary.BigEndian, uint32(time.Now().Unix()))
+	literal.Write(plaintext)
+
+	// Encrypted content: random prefix with its last two bytes repeated, the
+	// literal data packet, then the MDC packet hashing everything before it
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	prefix := make([]byte, block.BlockSize()+2)
+	if _, err := rand.Read(prefix[:block.BlockSize()]); err != nil {
+		return fmt.Errorf("failed to generate prefix: %w", err)
+	}
+	copy(prefix[block.BlockSize():], prefix[block.BlockSize()-2:block.BlockSize()])
+
+	var content bytes.Buffer
+	content.Write(prefix)
+	if err := writePacket(&content, tagLiteralData, literal.Bytes()); err != nil {
+		return err
+	}
+	content.Write([]byte{0xC0 | tagModificationDetectionCode, sha1.Size})
+	mdc := sha1.Sum(content.Bytes())
+	content.Write(mdc[:])
+
+	encrypted := make([]byte, 1+content.Len())
+	encrypted[0] = 1 // SEIPD version
+	cipher.NewCFBEncrypter(block, make([]byte, block.BlockSize())).XORKeyStream(encrypted[1:], content.Bytes())
+	return writePacket(w, tagSymIntegrityProtectedData, encrypted)
+}
+
+// deriveKey implements the iterated and salted S2K with SHA-256: the salt and
+// passphrase are hashed repeatedly until count bytes went into the hash
+func deriveKey(passphrase, salt []byte, count int) []byte {
+	input := append(append([]byte{}, salt...), passphrase...)
+	if count < len(input) {
+		count = len(input)
+	}
+
+	h := sha256.New()
+	for count > len(input) {
+		h.Write(input)
+		count -= len(input)
+	}
+	h.Write(input[:count])
+	return h.Sum(nil)
+}
+
+// s2kDecodeCount expands the one-byte coded S2K iteration count
+func s2kDecodeCount(c byte) int {
+	return (16 + int(c&15)) << (uint(c>>4) + 6)
+}
+
+// writePacket writes a packet with a new-format header and a definite length
+func writePacket(w io.Writer, tag byte, body []byte) error {
+	header := []byte{0xC0 | tag}
+	switch n := len(body); {
+	case n < 192:
+		header = append(header, byte(n))
+	case n < 8384:
+		n -= 192
+		header = append(header, byte(n>>8)+192, byte(n))
+	default:
+		header = append(header, 0xFF)
+		header = binary.BigEndian.AppendUint32(header, uint32(n))
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
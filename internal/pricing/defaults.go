@@ -0,0 +1,43 @@
+package pricing
+
+// defaultPrices are list prices in USD per million tokens for common models.
+// Entries in PRICING_FILE are merged over these.
+var defaultPrices = Table{
+	"gpt-4o":                 {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":            {Input: 0.15, Output: 0.60},
+	"gpt-4.1":                {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini":           {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":           {Input: 0.10, Output: 0.40},
+	"gpt-4-turbo":            {Input: 10.00, Output: 30.00},
+	"gpt-4":                  {Input: 30.00, Output: 60.00},
+	"gpt-3.5-turbo":          {Input: 0.50, Output: 1.50},
+	"o1":                     {Input: 15.00, Output: 60.00},
+	"o1-mini":                {Input: 1.10, Output: 4.40},
+	"o3":                     {Input: 2.00, Output: 8.00},
+	"o3-mini":                {Input: 1.10, Output: 4.40},
+	"o4-mini":                {Input: 1.10, Output: 4.40},
+	"text-embedding-3-small": {Input: 0.02},
+	"text-embedding-3-large": {Input: 0.13},
+	"text-embedding-ada-002": {Input: 0.10},
+}
+
+// Default returns a copy of the built-in price table
+func Default() Table {
+	table := make(Table, len(defaultPrices))
+	for model, price := range defaultPrices {
+		table[model] = price
+	}
+	return table
+}
+
+// Merge returns a new table with overrides applied over t
+func (t Table) Merge(overrides Table) Table {
+	merged := make(Table, len(t)+len(overrides))
+	for model, price := range t {
+		merged[model] = price
+	}
+	for model, price := range overrides {
+		merged[model] = price
+	}
+	return merged
+}
@@ -0,0 +1,60 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Price is the cost of a model in USD per million tokens
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Table maps model names to prices. Keys match a model exactly, or as a
+// prefix (e.g. "gpt-4o" prices "gpt-4o-2024-08-06"); the longest key wins.
+type Table map[string]Price
+
+// LoadTable reads a JSON price table: {"gpt-4o": {"input": 2.5, "output": 10}}
+func LoadTable(filePath string) (Table, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pricing file %s: %w", filePath, err)
+	}
+
+	var table Table
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("failed to parse pricing file %s: %w", filePath, err)
+	}
+
+	return table, nil
+}
+
+// Lookup returns the price for a model
+func (t Table) Lookup(model string) (Price, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+
+	bestKey := ""
+	for key := range t {
+		if strings.HasPrefix(model, key) && len(key) > len(bestKey) {
+			bestKey = key
+		}
+	}
+	if bestKey == "" {
+		return Price{}, false
+	}
+	return t[bestKey], true
+}
+
+// Cost returns the USD cost of the given usage, and false if the model has no price
+func (t Table) Cost(model string, inputTokens, outputTokens int) (float64, bool) {
+	price, ok := t.Lookup(model)
+	if !ok {
+		return 0, false
+	}
+	return (float64(inputTokens)*price.Input + float64(outputTokens)*price.Output) / 1_000_000, true
+}
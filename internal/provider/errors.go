@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Canned error types the gateway can answer with instead of calling the provider,
+// or when the gateway itself fails the request
+const (
+	ErrorTypeRateLimit        = "rate_limit"
+	ErrorTypeServerError      = "server_error"
+	ErrorTypeContentSensitive = "content_sensitive"
+	ErrorTypeQuotaExceeded    = "quota_exceeded"
+	ErrorTypeAuthentication   = "authentication"
+	ErrorTypePermission       = "permission"
+	ErrorTypeInvalidRequest   = "invalid_request"
+	ErrorTypeUpstream         = "upstream_error"
+	ErrorTypeTimeout          = "timeout"
+	ErrorTypeNotFound         = "not_found"
+	ErrorTypeUnavailable      = "unavailable"
+)
+
+// CannedErrorProvider is implemented by providers that can shape gateway-generated
+// errors like their own API errors, so client SDKs parse them cleanly
+type CannedErrorProvider interface {
+	// GetCannedError returns the status code and JSON body for an error type
+	GetCannedError(errorType, message string) (int, []byte)
+}
+
+// CannedError returns a provider-shaped error response, falling back to the
+// OpenAI error schema for providers that don't implement CannedErrorProvider
+// (or when no provider is known, e.g. the request matched none)
+func CannedError(p Provider, errorType, message string) (int, []byte) {
+	if cep, ok := p.(CannedErrorProvider); ok {
+		return cep.GetCannedError(errorType, message)
+	}
+	return openAIError(errorType, message)
+}
+
+// openAIError builds an error in OpenAI's {"error": {...}} schema
+func openAIError(errorType, message string) (int, []byte) {
+	status := cannedErrorStatus(errorType)
+
+	errType, code := "server_error", ""
+	switch errorType {
+	case ErrorTypeRateLimit:
+		errType, code = "requests", "rate_limit_exceeded"
+	case ErrorTypeContentSensitive:
+		errType, code = "invalid_request_error", "content_policy_violation"
+	case ErrorTypeQuotaExceeded:
+		errType, code = "insufficient_quota", "insufficient_quota"
+	case ErrorTypeAuthentication:
+		errType, code = "invalid_request_error", "invalid_api_key"
+	case ErrorTypePermission:
+		errType, code = "invalid_request_error", "permission_denied"
+	case ErrorTypeInvalidRequest:
+		errType = "invalid_request_error"
+	case ErrorTypeTimeout:
+		code = "timeout"
+	case ErrorTypeNotFound:
+		errType, code = "invalid_request_error", "not_found"
+	case ErrorTypeUnavailable:
+		code = "service_unavailable"
+	}
+
+	body := map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    errType,
+			"param":   nil,
+			"code":    nullIfEmpty(code),
+		},
+	}
+	data, _ := json.Marshal(body)
+	return status, data
+}
+
+// cannedErrorStatus maps a canned error type to its HTTP status code
+func cannedErrorStatus(errorType string) int {
+	switch errorType {
+	case ErrorTypeRateLimit, ErrorTypeQuotaExceeded:
+		return http.StatusTooManyRequests
+	case ErrorTypeContentSensitive, ErrorTypeInvalidRequest:
+		return http.StatusBadRequest
+	case ErrorTypeAuthentication:
+		return http.StatusUnauthorized
+	case ErrorTypePermission:
+		return http.StatusForbidden
+	case ErrorTypeUpstream:
+		return http.StatusBadGateway
+	case ErrorTypeTimeout:
+		return http.StatusGatewayTimeout
+	case ErrorTypeNotFound:
+		return http.StatusNotFound
+	case ErrorTypeUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
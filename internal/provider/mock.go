@@ -0,0 +1,337 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/storage"
+)
+
+const (
+	MockBaseURL = "mock://gateway"
+
+	// defaultMockResponse is the completion returned when none is configured
+	defaultMockResponse = "This is a mock response from the AI gateway."
+	// defaultMockModel is reported when the request names no model
+	defaultMockModel = "mock-model"
+	// defaultMockDimensions is the embedding size when the request sets none
+	defaultMockDimensions = 8
+)
+
+// Headers a client can send to the mock provider to shape a single response
+const (
+	MockResponseHeader = "X-Mock-Response" // Completion text
+	MockStatusHeader   = "X-Mock-Status"   // Answer with an error of this status code
+)
+
+// MockOptions configures the mock provider
+type MockOptions struct {
+	Response        string  // Completion text (default: defaultMockResponse)
+	TokensPerSecond float64 // Pace of streamed tokens (0 = as fast as possible)
+}
+
+// MockProvider answers OpenAI-shaped requests to /mock/v1/* in-process, so
+// test suites can run against the gateway without any upstream. Responses are
+// deterministic: the same request body always gets the same content and IDs.
+type MockProvider struct {
+	opts MockOptions
+}
+
+// NewMockProvider creates a new mock provider
+func NewMockProvider() *MockProvider {
+	return &MockProvider{opts: MockOptions{Response: defaultMockResponse}}
+}
+
+// SetOptions configures the canned responses
+func (p *MockProvider) SetOptions(opts MockOptions) {
+	if opts.Response == "" {
+		opts.Response = defaultMockResponse
+	}
+	p.opts = opts
+}
+
+// Name returns "mock"
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+// GetBaseURL returns the mock provider's pseudo base URL
+func (p *MockProvider) GetBaseURL() string {
+	return MockBaseURL
+}
+
+// ShouldProxy handles requests with the /mock/v1/ prefix
+func (p *MockProvider) ShouldProxy(path string) bool {
+	return strings.HasPrefix(path, "/mock/v1/")
+}
+
+// GetProxyURL strips the /mock prefix
+func (p *MockProvider) GetProxyURL(path string) string {
+	return MockBaseURL + strings.TrimPrefix(path, "/mock")
+}
+
+// PrepareRequest accepts any request; the mock provider needs no credentials
+func (p *MockProvider) PrepareRequest(req *http.Request) error {
+	return nil
+}
+
+// IsStreamingEndpoint checks if this endpoint returns server-sent events
+func (p *MockProvider) IsStreamingEndpoint(path string) bool {
+	return strings.HasSuffix(path, "/v1/chat/completions") || strings.HasSuffix(path, "/v1/completions")
+}
+
+// ProcessResponse does nothing for mock responses
+func (p *MockProvider) ProcessResponse(responseBody string, requestID, responseID string, fs storage.FileStorage, db *database.DB) error {
+	return nil
+}
+
+// RequestStreamUsage asks for a usage chunk like OpenAI's stream_options
+func (p *MockProvider) RequestStreamUsage(body []byte) ([]byte, bool) {
+	return (&OpenAIProvider{}).RequestStreamUsage(body)
+}
+
+// IsStreamUsageChunk reports whether an SSE data payload is the usage-only chunk
+func (p *MockProvider) IsStreamUsageChunk(data []byte) bool {
+	return (&OpenAIProvider{}).IsStreamUsageChunk(data)
+}
+
+// Transport answers requests in-process instead of calling an upstream
+func (p *MockProvider) Transport() http.RoundTripper {
+	return mockTransport{opts: p.opts}
+}
+
+// mockRequest covers the request fields the mock provider responds to
+type mockRequest struct {
+	Model         string `json:"model"`
+	Stream        bool   `json:"stream"`
+	MaxTokens     int    `json:"max_tokens"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	Dimensions int             `json:"dimensions"`
+	Input      json.RawMessage `json:"input"`
+}
+
+type mockTransport struct {
+	opts MockOptions
+}
+
+func (t mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+	}
+
+	if status, err := strconv.Atoi(req.Header.Get(MockStatusHeader)); err == nil && status >= 400 {
+		return mockError(req, status, fmt.Sprintf("Mock error with status %d", status)), nil
+	}
+
+	var mr mockRequest
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &mr); err != nil {
+			_, data := openAIError(ErrorTypeInvalidRequest, "Request body is not valid JSON")
+			return mockResponse(req, http.StatusBadRequest, "application/json", io.NopCloser(bytes.NewReader(data))), nil
+		}
+	}
+	if mr.Model == "" {
+		mr.Model = defaultMockModel
+	}
+
+	text := t.opts.Response
+	if custom := req.Header.Get(MockResponseHeader); custom != "" {
+		text = custom
+	}
+
+	seed := sha256.Sum256(body)
+	id := hex.EncodeToString(seed[:6])
+
+	switch {
+	case req.Method == http.MethodGet && req.URL.Path == "/v1/models":
+		return mockJSON(req, map[string]interface{}{
+			"object": "list",
+			"data": []map[string]interface{}{
+				{"id": defaultMockModel, "object": "model", "created": 0, "owned_by": "aigw"},
+			},
+		}), nil
+	case req.Method == http.MethodPost && req.URL.Path == "/v1/chat/completions":
+		return t.completion(req, &mr, "chatcmpl-mock-"+id, "chat.completion", text, len(body)), nil
+	case req.Method == http.MethodPost && req.URL.Path == "/v1/completions":
+		return t.completion(req, &mr, "cmpl-mock-"+id, "text_completion", text, len(body)), nil
+	case req.Method == http.MethodPost && req.URL.Path == "/v1/embeddings":
+		return mockEmbeddings(req, &mr, seed), nil
+	}
+
+	return mockError(req, http.StatusNotFound, fmt.Sprintf("The mock provider does not implement %s %s", req.Method, req.URL.Path)), nil
+}
+
+// completion answers a chat or text completion, streamed if requested.
+// The text is split into word tokens and truncated at max_tokens.
+func (t mockTransport) completion(req *http.Request, mr *mockRequest, id, object, text string, bodySize int) *http.Response {
+	tokens := strings.SplitAfter(text, " ")
+	finishReason := "stop"
+	if mr.MaxTokens > 0 && len(tokens) > mr.MaxTokens {
+		tokens, finishReason = tokens[:mr.MaxTokens], "length"
+	}
+	usage := map[string]int{
+		"prompt_tokens":     (bodySize + 3) / 4,
+		"completion_tokens": len(tokens),
+		"total_tokens":      (bodySize+3)/4 + len(tokens),
+	}
+	created := time.Now().Unix()
+	chat := object == "chat.completion"
+
+	// choice builds the choice of a full response (delta == false) or stream chunk
+	choice := func(content string, delta bool, finish interface{}) map[string]interface{} {
+		c := map[string]interface{}{"index": 0, "finish_reason": finish}
+		switch {
+		case !chat:
+			c["text"] = content
+		case delta:
+			c["delta"] = map[string]interface{}{"content": content}
+		default:
+			c["message"] = map[string]interface{}{"role": "assistant", "content": content}
+		}
+		return c
+	}
+
+	if !mr.Stream {
+		return mockJSON(req, map[string]interface{}{
+			"id":      id,
+			"object":  object,
+			"created": created,
+			"model":   mr.Model,
+			"choices": []interface{}{choice(strings.Join(tokens, ""), false, finishReason)},
+			"usage":   usage,
+		})
+	}
+
+	chunkObject := object
+	if chat {
+		chunkObject = "chat.completion.chunk"
+	}
+	chunk := func(choices []interface{}) map[string]interface{} {
+		return map[string]interface{}{"id": id, "object": chunkObject, "created": created, "model": mr.Model, "choices": choices}
+	}
+
+	var events []map[string]interface{}
+	for _, token := range tokens {
+		events = append(events, chunk([]interface{}{choice(token, true, nil)}))
+	}
+	events = append(events, chunk([]interface{}{choice("", true, finishReason)}))
+	if mr.StreamOptions != nil && mr.StreamOptions.IncludeUsage {
+		final := chunk([]interface{}{})
+		final["usage"] = usage
+		events = append(events, final)
+	}
+
+	var delay time.Duration
+	if t.opts.TokensPerSecond > 0 {
+		delay = time.Duration(float64(time.Second) / t.opts.TokensPerSecond)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		for i, event := range events {
+			if i > 0 && delay > 0 {
+				select {
+				case <-req.Context().Done():
+					pw.CloseWithError(req.Context().Err())
+					return
+				case <-time.After(delay):
+				}
+			}
+			data, _ := json.Marshal(event)
+			if _, err := fmt.Fprintf(pw, "data: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		io.WriteString(pw, "data: [DONE]\n\n")
+		pw.Close()
+	}()
+
+	return mockResponse(req, http.StatusOK, "text/event-stream", pr)
+}
+
+// mockEmbeddings returns a unit vector per input derived from the request body
+func mockEmbeddings(req *http.Request, mr *mockRequest, seed [32]byte) *http.Response {
+	inputs := 1
+	var list []json.RawMessage
+	if json.Unmarshal(mr.Input, &list) == nil && len(list) > 0 {
+		inputs = len(list)
+	}
+	dimensions := mr.Dimensions
+	if dimensions <= 0 {
+		dimensions = defaultMockDimensions
+	}
+
+	rng := rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(seed[:8]))))
+	data := make([]map[string]interface{}, inputs)
+	for i := range data {
+		vector := make([]float64, dimensions)
+		var norm float64
+		for j := range vector {
+			vector[j] = rng.Float64()*2 - 1
+			norm += vector[j] * vector[j]
+		}
+		for j := range vector {
+			vector[j] /= math.Sqrt(norm)
+		}
+		data[i] = map[string]interface{}{"object": "embedding", "index": i, "embedding": vector}
+	}
+
+	promptTokens := (len(mr.Input) + 3) / 4
+	return mockJSON(req, map[string]interface{}{
+		"object": "list",
+		"data":   data,
+		"model":  mr.Model,
+		"usage":  map[string]int{"prompt_tokens": promptTokens, "total_tokens": promptTokens},
+	})
+}
+
+// mockError returns an OpenAI-shaped error with the given status
+func mockError(req *http.Request, status int, message string) *http.Response {
+	errorType := ErrorTypeServerError
+	switch status {
+	case http.StatusTooManyRequests:
+		errorType = ErrorTypeRateLimit
+	case http.StatusUnauthorized:
+		errorType = ErrorTypeAuthentication
+	case http.StatusNotFound:
+		errorType = ErrorTypeNotFound
+	case http.StatusBadRequest:
+		errorType = ErrorTypeInvalidRequest
+	}
+	_, data := openAIError(errorType, message)
+	return mockResponse(req, status, "application/json", io.NopCloser(bytes.NewReader(data)))
+}
+
+func mockJSON(req *http.Request, v interface{}) *http.Response {
+	data, _ := json.Marshal(v)
+	return mockResponse(req, http.StatusOK, "application/json", io.NopCloser(bytes.NewReader(data)))
+}
+
+func mockResponse(req *http.Request, status int, contentType string, body io.ReadCloser) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       body,
+		Request:    req,
+	}
+}
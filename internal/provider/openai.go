@@ -1,7 +1,11 @@
 package provider
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 
@@ -16,6 +20,7 @@ const (
 // OpenAIProvider implements the Provider interface for OpenAI
 type OpenAIProvider struct {
 	baseURL string
+	apiKey  string
 }
 
 // NewOpenAIProvider creates a new OpenAI provider
@@ -49,11 +54,20 @@ func (p *OpenAIProvider) GetProxyURL(path string) string {
 	return p.baseURL + strippedPath
 }
 
+// SetAPIKey sets the gateway-side API key used when the client sends none
+func (p *OpenAIProvider) SetAPIKey(key string) {
+	p.apiKey = key
+}
+
 // PrepareRequest adds OpenAI-specific headers
 func (p *OpenAIProvider) PrepareRequest(req *http.Request) error {
 	// OpenAI API key should already be in the Authorization header
-	// passed by the client. We validate it exists.
+	// passed by the client, unless the gateway has its own key configured.
 	authHeader := req.Header.Get("Authorization")
+	if apiKey := InjectedAPIKey(req, p.apiKey); authHeader == "" && apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		authHeader = req.Header.Get("Authorization")
+	}
 	if authHeader == "" {
 		return fmt.Errorf("missing Authorization header")
 	}
@@ -88,9 +102,120 @@ func (p *OpenAIProvider) IsStreamingEndpoint(path string) bool {
 	return false
 }
 
-// ProcessResponse is a no-op for OpenAI
-// OpenAI responses don't need post-processing
-func (p *OpenAIProvider) ProcessResponse(responseBody string, requestID, responseID string, fs *storage.FileStorage, db *database.DB) error {
-	// No-op: OpenAI responses don't require post-processing
+// RequestStreamUsage sets stream_options.include_usage so the final stream
+// chunk reports token usage
+func (p *OpenAIProvider) RequestStreamUsage(body []byte) ([]byte, bool) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return body, false
+	}
+
+	streamOptions, _ := payload["stream_options"].(map[string]interface{})
+	if streamOptions == nil {
+		streamOptions = make(map[string]interface{})
+	}
+	if includeUsage, ok := streamOptions["include_usage"].(bool); ok && includeUsage {
+		return body, false
+	}
+
+	streamOptions["include_usage"] = true
+	payload["stream_options"] = streamOptions
+
+	rewritten, err := json.Marshal(payload)
+	if err != nil {
+		return body, false
+	}
+	return rewritten, true
+}
+
+// IsStreamUsageChunk reports whether a chunk is the usage-only chunk OpenAI
+// sends last when include_usage is set (empty choices, non-null usage)
+func (p *OpenAIProvider) IsStreamUsageChunk(data []byte) bool {
+	var chunk struct {
+		Choices []json.RawMessage `json:"choices"`
+		Usage   json.RawMessage   `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return false
+	}
+	return len(chunk.Choices) == 0 && len(chunk.Usage) > 0 && string(chunk.Usage) != "null"
+}
+
+// GetCannedError returns an error in OpenAI's error schema
+func (p *OpenAIProvider) GetCannedError(errorType, message string) (int, []byte) {
+	return openAIError(errorType, message)
+}
+
+// ProcessResponse stores the images of image generation responses requested
+// with response_format=b64_json, and of image_generation_call outputs of the
+// Responses API, which carry them inline as base64
+func (p *OpenAIProvider) ProcessResponse(responseBody string, requestID, responseID string, fs storage.FileStorage, db *database.DB) error {
+	// Most responses have no images; skip parsing them
+	if !strings.Contains(responseBody, `"b64_json"`) && !strings.Contains(responseBody, `"image_generation_call"`) {
+		return nil
+	}
+
+	var response struct {
+		Data []struct {
+			B64JSON string `json:"b64_json"`
+		} `json:"data"`
+		OutputFormat string `json:"output_format"` // png, jpeg or webp; gpt-image models only
+		Output       []struct {
+			Type         string `json:"type"`
+			Result       string `json:"result"`
+			OutputFormat string `json:"output_format"`
+		} `json:"output"`
+	}
+	if err := json.Unmarshal([]byte(responseBody), &response); err != nil {
+		return fmt.Errorf("failed to parse response JSON: %w", err)
+	}
+
+	type inlineImage struct{ data, format string }
+	var images []inlineImage
+	for _, item := range response.Data {
+		if item.B64JSON != "" {
+			images = append(images, inlineImage{item.B64JSON, response.OutputFormat})
+		}
+	}
+	for _, item := range response.Output {
+		if item.Type == "image_generation_call" && item.Result != "" {
+			images = append(images, inlineImage{item.Result, item.OutputFormat})
+		}
+	}
+
+	for _, image := range images {
+		if err := storeInlineImage(image.data, image.format, requestID, responseID, fs, db); err != nil {
+			slog.Warn("failed to store OpenAI output image", "request_id", requestID, "error", err)
+			// Continue with other images if one fails
+		}
+	}
+	return nil
+}
+
+// storeInlineImage decodes a base64 image and stores it as a binary file of
+// the response. Its content type comes from the output format, if given, or
+// else from its content.
+func storeInlineImage(data, format, requestID, responseID string, fs storage.FileStorage, db *database.DB) error {
+	content, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	contentType := http.DetectContentType(content)
+	if format != "" {
+		contentType = "image/" + format
+	}
+
+	filePath, size, err := fs.SaveFile("openai", contentType, bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+
+	// Store binary file reference
+	if _, err := db.StoreBinaryFile(requestID, responseID, filePath, contentType, size); err != nil {
+		return fmt.Errorf("failed to store binary file reference: %w", err)
+	}
+
+	slog.Info("stored OpenAI output image", "request_id", requestID, "path", filePath, "bytes", size)
 	return nil
 }
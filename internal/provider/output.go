@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultOutputContentTypes are the output files downloaded when no
+// allowlist is configured: media, archives, and untyped binaries like model
+// weights
+var DefaultOutputContentTypes = []string{"image/*", "audio/*", "video/*", "application/zip", "application/octet-stream"}
+
+// OutputOptions controls which output files referenced by URL in responses
+// are downloaded and stored
+type OutputOptions struct {
+	ContentTypes []string // Allowed content types; "type/*" matches a whole type
+	MaxBytes     int64    // Largest file stored (0 = no limit)
+}
+
+// Allows reports whether files of the given content type are stored
+func (o OutputOptions) Allows(contentType string) bool {
+	contentType = baseContentType(contentType)
+	for _, allowed := range o.ContentTypes {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == contentType || allowed == "*/*" ||
+			(strings.HasSuffix(allowed, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// outputContentType returns the content type of a downloaded output: the
+// one the server sent, or else one guessed from the URL's extension, since
+// file hosts often serve everything as application/octet-stream
+func outputContentType(header, rawURL string) string {
+	contentType := baseContentType(header)
+	if contentType != "" && contentType != "application/octet-stream" && contentType != "binary/octet-stream" {
+		return contentType
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		if guessed := baseContentType(mime.TypeByExtension(path.Ext(u.Path))); guessed != "" {
+			return guessed
+		}
+	}
+	return "application/octet-stream"
+}
+
+// baseContentType strips the parameters from a content type
+func baseContentType(contentType string) string {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// limitedReader fails reads once more than max bytes were read, so a
+// download that outgrows the limit is abandoned instead of cut short
+type limitedReader struct {
+	r   io.Reader
+	max int64
+	n   int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		return n, fmt.Errorf("file is larger than %d bytes", l.max)
+	}
+	return n, err
+}
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/ruqqq/simple-ai-gateway/internal/database"
@@ -31,5 +32,59 @@ type Provider interface {
 
 	// ProcessResponse handles post-response processing (e.g., downloading images)
 	// This is optional - providers can implement a no-op version if not needed
-	ProcessResponse(responseBody string, requestID, responseID string, fs *storage.FileStorage, db *database.DB) error
+	ProcessResponse(responseBody string, requestID, responseID string, fs storage.FileStorage, db *database.DB) error
+}
+
+// APIKeyInjector is implemented by providers that can inject a gateway-configured
+// API key into requests that arrive without credentials
+type APIKeyInjector interface {
+	// SetAPIKey sets the key PrepareRequest injects when the client sent none
+	SetAPIKey(key string)
+}
+
+type apiKeyContextKey struct{}
+
+// WithAPIKey returns a context whose requests get key injected instead of the
+// key set with SetAPIKey, e.g. one the gateway picked from a key pool
+func WithAPIKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+// InjectedAPIKey returns the gateway-side key to inject into a request: the
+// one carried by its context, or else fallback. APIKeyInjector
+// implementations should use it in PrepareRequest.
+func InjectedAPIKey(req *http.Request, fallback string) string {
+	if key, ok := req.Context().Value(apiKeyContextKey{}).(string); ok && key != "" {
+		return key
+	}
+	return fallback
+}
+
+// Transporter is implemented by providers that send requests through their
+// own round tripper instead of the gateway's HTTP client, e.g. to answer them
+// in-process like the mock provider
+type Transporter interface {
+	// Transport returns the round tripper used for the provider's requests
+	Transport() http.RoundTripper
+}
+
+// PredictionPoller is implemented by providers whose responses can describe
+// a prediction still running upstream, like Replicate's asynchronous
+// predictions, whose outputs only exist once it completes
+type PredictionPoller interface {
+	// PendingPrediction returns the ID of the unfinished prediction a
+	// response body describes and the URL that reports its progress, or
+	// false if the body is final
+	PendingPrediction(responseBody string) (id, pollURL string, ok bool)
+}
+
+// StreamUsageRequester is implemented by providers whose streaming responses
+// only report token usage when the request explicitly asks for it
+type StreamUsageRequester interface {
+	// RequestStreamUsage returns the request body with usage reporting enabled
+	// and whether it had to be added (false if the client already asked for it)
+	RequestStreamUsage(body []byte) ([]byte, bool)
+
+	// IsStreamUsageChunk reports whether an SSE data payload is the usage-only chunk
+	IsStreamUsageChunk(data []byte) bool
 }
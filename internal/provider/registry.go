@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   []Provider
+)
+
+func init() {
+	// Built-in providers are registered first so they keep routing precedence
+	Register(NewOpenAIProvider())
+	Register(NewReplicateProvider())
+	Register(NewMockProvider())
+}
+
+// Register makes a provider available to the gateway. It is intended to be
+// called from init functions of provider packages compiled into the binary.
+// Register panics if a provider with the same name is already registered.
+func Register(p Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if p == nil {
+		panic("provider: Register provider is nil")
+	}
+	for _, existing := range registry {
+		if existing.Name() == p.Name() {
+			panic(fmt.Sprintf("provider: Register called twice for provider %q", p.Name()))
+		}
+	}
+
+	registry = append(registry, p)
+}
+
+// Registered returns all registered providers in registration order
+func Registered() []Provider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	providers := make([]Provider, len(registry))
+	copy(providers, registry)
+	return providers
+}
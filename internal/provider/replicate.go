@@ -3,6 +3,8 @@ package provider
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
@@ -18,12 +20,15 @@ const (
 // ReplicateProvider implements the Provider interface for Replicate
 type ReplicateProvider struct {
 	baseURL string
+	apiKey  string
+	output  OutputOptions
 }
 
 // NewReplicateProvider creates a new Replicate provider
 func NewReplicateProvider() *ReplicateProvider {
 	return &ReplicateProvider{
 		baseURL: ReplicateBaseURL,
+		output:  OutputOptions{ContentTypes: DefaultOutputContentTypes},
 	}
 }
 
@@ -51,11 +56,25 @@ func (p *ReplicateProvider) GetProxyURL(path string) string {
 	return p.baseURL + strippedPath
 }
 
+// SetAPIKey sets the gateway-side API token used when the client sends none
+func (p *ReplicateProvider) SetAPIKey(key string) {
+	p.apiKey = key
+}
+
+// SetOutputOptions sets which output files are downloaded and stored
+func (p *ReplicateProvider) SetOutputOptions(opts OutputOptions) {
+	p.output = opts
+}
+
 // PrepareRequest validates and prepares the request for Replicate
 func (p *ReplicateProvider) PrepareRequest(req *http.Request) error {
 	// Replicate API key should be in Authorization header with "Token" format
 	// Format: "Authorization: Token <token>" (not Bearer)
 	authHeader := req.Header.Get("Authorization")
+	if apiKey := InjectedAPIKey(req, p.apiKey); authHeader == "" && apiKey != "" {
+		req.Header.Set("Authorization", "Token "+apiKey)
+		authHeader = req.Header.Get("Authorization")
+	}
 	if authHeader == "" {
 		return fmt.Errorf("missing Authorization header")
 	}
@@ -94,9 +113,100 @@ func (p *ReplicateProvider) IsStreamingEndpoint(path string) bool {
 	return false
 }
 
+// GetCannedError returns an error in Replicate's problem-details schema
+func (p *ReplicateProvider) GetCannedError(errorType, message string) (int, []byte) {
+	status := cannedErrorStatus(errorType)
+
+	title := "Internal server error"
+	switch errorType {
+	case ErrorTypeRateLimit:
+		title = "Request was throttled"
+	case ErrorTypeContentSensitive:
+		title = "Input flagged as sensitive"
+	case ErrorTypeQuotaExceeded:
+		// Replicate reports exhausted spend limits as 402 Payment Required
+		status, title = http.StatusPaymentRequired, "Monthly spend limit reached"
+	case ErrorTypeAuthentication:
+		title = "Unauthenticated"
+	case ErrorTypePermission:
+		title = "Forbidden"
+	case ErrorTypeInvalidRequest:
+		title = "Invalid request"
+	case ErrorTypeUpstream:
+		title = "Bad gateway"
+	case ErrorTypeTimeout:
+		title = "Gateway timeout"
+	case ErrorTypeNotFound:
+		title = "Not found"
+	case ErrorTypeUnavailable:
+		title = "Service unavailable"
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"title":  title,
+		"detail": message,
+		"status": status,
+	})
+	return status, data
+}
+
+// ExtractUsage reads the token counts language models report in a
+// prediction's metrics
+func (p *ReplicateProvider) ExtractUsage(body string) (*Usage, bool) {
+	return ScanUsage(body, func(data []byte) (*Usage, bool) {
+		var prediction struct {
+			Model   string `json:"model"`
+			Metrics *struct {
+				InputTokenCount  int `json:"input_token_count"`
+				OutputTokenCount int `json:"output_token_count"`
+			} `json:"metrics"`
+		}
+		if err := json.Unmarshal(data, &prediction); err != nil || prediction.Metrics == nil {
+			return nil, false
+		}
+		return &Usage{
+			Model:        prediction.Model,
+			InputTokens:  prediction.Metrics.InputTokenCount,
+			OutputTokens: prediction.Metrics.OutputTokenCount,
+		}, true
+	})
+}
+
+// ReconstructStream assembles a prediction's output stream: the data of
+// "output" events is concatenated, and the stream ends with a "done" event
+// (whose reason is empty unless the prediction was canceled or failed) or an
+// "error" event
+func (p *ReplicateProvider) ReconstructStream(body string) (*StreamResult, bool) {
+	result := &StreamResult{Message: &StreamMessage{Role: "assistant"}}
+	var content strings.Builder
+	scanSSE(body, func(event, data string) {
+		switch event {
+		case "output":
+			content.WriteString(data)
+			result.Chunks++
+		case "done":
+			var done struct {
+				Reason string `json:"reason"`
+			}
+			json.Unmarshal([]byte(data), &done)
+			result.FinishReason = done.Reason
+			if result.FinishReason == "" {
+				result.FinishReason = "done"
+			}
+		case "error":
+			result.FinishReason = "error"
+		}
+	})
+	if result.Chunks == 0 && result.FinishReason == "" {
+		return nil, false
+	}
+	result.Message.Content = content.String()
+	return result, true
+}
+
 // ProcessResponse handles post-response processing for Replicate
-// Downloads and stores images from the output field locally
-func (p *ReplicateProvider) ProcessResponse(responseBody string, requestID, responseID string, fs *storage.FileStorage, db *database.DB) error {
+// Downloads and stores the files in the output field locally
+func (p *ReplicateProvider) ProcessResponse(responseBody string, requestID, responseID string, fs storage.FileStorage, db *database.DB) error {
 	// Parse the response JSON
 	var response map[string]interface{}
 	if err := json.Unmarshal([]byte(responseBody), &response); err != nil {
@@ -109,56 +219,79 @@ func (p *ReplicateProvider) ProcessResponse(responseBody string, requestID, resp
 		return nil // No output field, nothing to do
 	}
 
-	// Handle different output formats
-	var urls []string
-	switch v := output.(type) {
-	case string:
-		// Single URL
-		if isImageURL(v) {
-			urls = []string{v}
-		}
-	case []interface{}:
-		// Array of URLs
-		for _, item := range v {
-			if str, ok := item.(string); ok && isImageURL(str) {
-				urls = append(urls, str)
-			}
-		}
-	}
-
-	// Download and store each image
+	// Download and store each output file
 	httpClient := &http.Client{
 		Timeout: 30 * time.Second,
 	}
 
-	for _, url := range urls {
-		if err := downloadAndStoreImage(url, requestID, responseID, fs, db, httpClient); err != nil {
-			fmt.Printf("Warning: failed to download/store image from %s: %v\n", url, err)
-			// Continue with other images if one fails
+	for _, url := range outputURLs(output, nil) {
+		if err := p.downloadOutput(url, requestID, responseID, fs, db, httpClient); err != nil {
+			slog.Warn("failed to download/store Replicate output", "request_id", requestID, "url", url, "error", err)
+			// Continue with other files if one fails
 		}
 	}
 
 	return nil
 }
 
-// Helper function to check if a string is an image URL
-func isImageURL(url string) bool {
-	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+// PendingPrediction returns the prediction a response describes if it is
+// still starting or processing. Only progress URLs on the Replicate API are
+// followed, so the client's token isn't sent anywhere else.
+func (p *ReplicateProvider) PendingPrediction(responseBody string) (string, string, bool) {
+	var prediction struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+		URLs   struct {
+			Get string `json:"get"`
+		} `json:"urls"`
+	}
+	if err := json.Unmarshal([]byte(responseBody), &prediction); err != nil || prediction.ID == "" {
+		return "", "", false
+	}
+	if prediction.Status != "starting" && prediction.Status != "processing" {
+		return "", "", false
+	}
+	if !strings.HasPrefix(prediction.URLs.Get, p.baseURL+"/") {
+		return "", "", false
+	}
+	return prediction.ID, prediction.URLs.Get, true
+}
+
+// outputURLs collects the file URLs in a prediction's output, which can be
+// a URL, a list of them, or an object of named outputs
+func outputURLs(output interface{}, urls []string) []string {
+	switch v := output.(type) {
+	case string:
+		if isFileURL(v) {
+			urls = append(urls, v)
+		}
+	case []interface{}:
+		for _, item := range v {
+			urls = outputURLs(item, urls)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			urls = outputURLs(item, urls)
+		}
+	}
+	return urls
+}
+
+// isFileURL reports whether an output string is a URL rather than text, e.g.
+// a token streamed by a language model
+func isFileURL(s string) bool {
+	if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
 		return false
 	}
-	return strings.HasSuffix(strings.ToLower(url), ".png") ||
-		strings.HasSuffix(strings.ToLower(url), ".jpg") ||
-		strings.HasSuffix(strings.ToLower(url), ".jpeg") ||
-		strings.HasSuffix(strings.ToLower(url), ".gif") ||
-		strings.HasSuffix(strings.ToLower(url), ".webp")
+	return !strings.ContainsAny(s, " \t\n")
 }
 
-// Helper function to download and store an image
-func downloadAndStoreImage(url, requestID, responseID string, fs *storage.FileStorage, db *database.DB, client *http.Client) error {
-	// Download the image
+// downloadOutput downloads an output file and stores it with the response,
+// unless its content type isn't allowed or it is too large
+func (p *ReplicateProvider) downloadOutput(url, requestID, responseID string, fs storage.FileStorage, db *database.DB, client *http.Client) error {
 	resp, err := client.Get(url)
 	if err != nil {
-		return fmt.Errorf("failed to download image: %w", err)
+		return fmt.Errorf("failed to download file: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -166,13 +299,20 @@ func downloadAndStoreImage(url, requestID, responseID string, fs *storage.FileSt
 		return fmt.Errorf("download returned status %d", resp.StatusCode)
 	}
 
-	// Save to storage
-	contentType := resp.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = "image/png" // Default to PNG
+	contentType := outputContentType(resp.Header.Get("Content-Type"), url)
+	if !p.output.Allows(contentType) {
+		slog.Debug("skipping Replicate output of a type not allowed", "request_id", requestID, "url", url, "content_type", contentType)
+		return nil
+	}
+	body := io.Reader(resp.Body)
+	if max := p.output.MaxBytes; max > 0 {
+		if resp.ContentLength > max {
+			return fmt.Errorf("file is larger than %d bytes", max)
+		}
+		body = &limitedReader{r: resp.Body, max: max}
 	}
 
-	filePath, size, err := fs.SaveFile("replicate", contentType, resp.Body)
+	filePath, size, err := fs.SaveFile("replicate", contentType, body)
 	if err != nil {
 		return fmt.Errorf("failed to save file: %w", err)
 	}
@@ -183,6 +323,6 @@ func downloadAndStoreImage(url, requestID, responseID string, fs *storage.FileSt
 		return fmt.Errorf("failed to store binary file reference: %w", err)
 	}
 
-	fmt.Printf("Stored Replicate output image: %s (%d bytes)\n", filePath, size)
+	slog.Info("stored Replicate output", "request_id", requestID, "path", filePath, "content_type", contentType, "bytes", size)
 	return nil
 }
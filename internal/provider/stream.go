@@ -0,0 +1,169 @@
+package provider
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+)
+
+// StreamMessage is a streamed response assembled into the message a
+// non-streamed response would have carried
+type StreamMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Refusal   string           `json:"refusal,omitempty"`
+	ToolCalls []StreamToolCall `json:"tool_calls,omitempty"`
+}
+
+// StreamToolCall is a tool call assembled from its streamed fragments
+type StreamToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// StreamResult is the outcome of a streamed response
+type StreamResult struct {
+	Message      *StreamMessage
+	FinishReason string
+	Chunks       int // Data chunks that went into the message
+}
+
+// StreamReconstructor is implemented by providers whose streams aren't in
+// OpenAI's chat completion chunk format. Providers that don't implement it
+// are parsed with ParseChatStream.
+type StreamReconstructor interface {
+	// ReconstructStream assembles a stored stream into its final message
+	ReconstructStream(body string) (*StreamResult, bool)
+}
+
+// ReconstructStream assembles a streamed response body from prov into its
+// final message. It returns false if the body holds no chunks it understands.
+func ReconstructStream(prov Provider, body string) (*StreamResult, bool) {
+	if reconstructor, ok := prov.(StreamReconstructor); ok {
+		return reconstructor.ReconstructStream(body)
+	}
+	return ParseChatStream(body)
+}
+
+// chatChunk is an OpenAI chat (delta) or legacy (text) completion chunk
+type chatChunk struct {
+	Choices []struct {
+		Index int `json:"index"`
+		Delta *struct {
+			Role      string `json:"role"`
+			Content   string `json:"content"`
+			Refusal   string `json:"refusal"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Type     string `json:"type"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		Text         string `json:"text"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// ParseChatStream assembles an OpenAI chat or legacy completion stream:
+// content, refusal and tool call fragments are concatenated in order. Only
+// the first choice of multi-choice (n > 1) streams is assembled.
+func ParseChatStream(body string) (*StreamResult, bool) {
+	result := &StreamResult{Message: &StreamMessage{Role: "assistant"}}
+	var content, refusal strings.Builder
+	toolCalls := make(map[int]*StreamToolCall)
+	var order []int
+
+	scanSSE(body, func(_, data string) {
+		var chunk chatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			result.Chunks++
+			if choice.FinishReason != "" {
+				result.FinishReason = choice.FinishReason
+			}
+			content.WriteString(choice.Text)
+			delta := choice.Delta
+			if delta == nil {
+				continue
+			}
+			if delta.Role != "" {
+				result.Message.Role = delta.Role
+			}
+			content.WriteString(delta.Content)
+			refusal.WriteString(delta.Refusal)
+
+			for _, fragment := range delta.ToolCalls {
+				call, ok := toolCalls[fragment.Index]
+				if !ok {
+					call = &StreamToolCall{Type: "function"}
+					toolCalls[fragment.Index] = call
+					order = append(order, fragment.Index)
+				}
+				if fragment.ID != "" {
+					call.ID = fragment.ID
+				}
+				if fragment.Type != "" {
+					call.Type = fragment.Type
+				}
+				call.Function.Name += fragment.Function.Name
+				call.Function.Arguments += fragment.Function.Arguments
+			}
+		}
+	})
+	if result.Chunks == 0 {
+		return nil, false
+	}
+
+	result.Message.Content = content.String()
+	result.Message.Refusal = refusal.String()
+	for _, index := range order {
+		result.Message.ToolCalls = append(result.Message.ToolCalls, *toolCalls[index])
+	}
+	return result, true
+}
+
+// scanSSE calls fn with the event name and data of each event of a
+// server-sent event stream. Multi-line data is joined with newlines; the
+// [DONE] sentinel is skipped.
+func scanSSE(body string, fn func(event, data string)) {
+	var event string
+	var data []string
+	dispatch := func() {
+		if len(data) > 0 && !(len(data) == 1 && data[0] == "[DONE]") {
+			fn(event, strings.Join(data, "\n"))
+		}
+		event, data = "", nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			dispatch()
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	dispatch()
+}
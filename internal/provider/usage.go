@@ -0,0 +1,223 @@
+package provider
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+)
+
+// Usage is the provider-agnostic token usage of a response. InputTokens
+// includes CachedTokens and OutputTokens includes ReasoningTokens, whatever
+// convention the provider reports them in.
+type Usage struct {
+	Model           string
+	InputTokens     int
+	OutputTokens    int
+	CachedTokens    int // Input tokens served from the provider's prompt cache
+	ReasoningTokens int // Output tokens spent on hidden reasoning
+}
+
+// UsageExtractor is implemented by providers that report usage in their own
+// format. Providers that don't implement it are parsed with ParseUsage.
+type UsageExtractor interface {
+	// ExtractUsage reads the model and token usage from a stored response body
+	ExtractUsage(body string) (*Usage, bool)
+}
+
+// ExtractUsage returns the normalized usage of a response body from prov
+func ExtractUsage(prov Provider, body string) (*Usage, bool) {
+	if extractor, ok := prov.(UsageExtractor); ok {
+		return extractor.ExtractUsage(body)
+	}
+	return ParseUsage(body)
+}
+
+// usageFields covers OpenAI chat completions (prompt/completion), OpenAI
+// responses (input/output with details) and Anthropic (input/output with
+// separate cache counts) usage objects
+type usageFields struct {
+	PromptTokens             int `json:"prompt_tokens"`
+	CompletionTokens         int `json:"completion_tokens"`
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	InputTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+	CompletionTokensDetails *struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+	OutputTokensDetails *struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"output_tokens_details"`
+}
+
+// geminiUsage is Gemini's usageMetadata object
+type geminiUsage struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+}
+
+// usageEnvelope is a response body or stream chunk that may carry usage
+type usageEnvelope struct {
+	Model         string       `json:"model"`
+	ModelVersion  string       `json:"modelVersion"`
+	Usage         *usageFields `json:"usage"`
+	UsageMetadata *geminiUsage `json:"usageMetadata"`
+
+	// Anthropic message_start and OpenAI response.completed stream events
+	// nest the message or response
+	Message  *usageEnvelope `json:"message"`
+	Response *usageEnvelope `json:"response"`
+}
+
+// ParseUsage reads usage in the OpenAI, Anthropic or Gemini format from a JSON
+// response body or the data chunks of a server-sent event stream. It returns
+// false if the body reports no usage.
+func ParseUsage(body string) (*Usage, bool) {
+	return ScanUsage(body, func(data []byte) (*Usage, bool) {
+		var env usageEnvelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			return nil, false
+		}
+		return env.toUsage()
+	})
+}
+
+// ScanUsage applies parse to a JSON response body or to each data chunk of a
+// server-sent event stream. Streams may spread usage over several chunks
+// (e.g. input tokens first, output tokens last), so non-zero counts of later
+// chunks are merged over earlier ones. Bodies without any token counts report
+// no usage.
+func ScanUsage(body string, parse func(data []byte) (*Usage, bool)) (*Usage, bool) {
+	trimmed := strings.TrimSpace(body)
+	if strings.HasPrefix(trimmed, "{") {
+		usage, ok := parse([]byte(trimmed))
+		if !ok || !usage.hasTokens() {
+			return nil, false
+		}
+		return usage, true
+	}
+
+	var found *Usage
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+
+		usage, ok := parse([]byte(data))
+		if !ok {
+			continue
+		}
+		if found == nil {
+			found = usage
+		} else {
+			found.merge(usage)
+		}
+	}
+
+	// Chunks that only name the model don't make a stream report usage
+	if found == nil || !found.hasTokens() {
+		return nil, false
+	}
+	return found, true
+}
+
+func (u *Usage) hasTokens() bool {
+	return u.InputTokens != 0 || u.OutputTokens != 0
+}
+
+// merge overwrites u with the non-zero values of other
+func (u *Usage) merge(other *Usage) {
+	if other.Model != "" {
+		u.Model = other.Model
+	}
+	if other.InputTokens != 0 {
+		u.InputTokens = other.InputTokens
+	}
+	if other.OutputTokens != 0 {
+		u.OutputTokens = other.OutputTokens
+	}
+	if other.CachedTokens != 0 {
+		u.CachedTokens = other.CachedTokens
+	}
+	if other.ReasoningTokens != 0 {
+		u.ReasoningTokens = other.ReasoningTokens
+	}
+}
+
+func (env *usageEnvelope) toUsage() (*Usage, bool) {
+	for _, nested := range []*usageEnvelope{env.Message, env.Response} {
+		if nested != nil {
+			if usage, ok := nested.toUsage(); ok {
+				return usage, true
+			}
+		}
+	}
+
+	model := env.Model
+	if model == "" {
+		model = env.ModelVersion
+	}
+
+	if g := env.UsageMetadata; g != nil {
+		return &Usage{
+			Model:           model,
+			InputTokens:     g.PromptTokenCount,
+			OutputTokens:    g.CandidatesTokenCount + g.ThoughtsTokenCount,
+			CachedTokens:    g.CachedContentTokenCount,
+			ReasoningTokens: g.ThoughtsTokenCount,
+		}, true
+	}
+
+	f := env.Usage
+	if f == nil {
+		// A chunk naming the model without usage still identifies the stream's model
+		if model != "" {
+			return &Usage{Model: model}, true
+		}
+		return nil, false
+	}
+
+	usage := &Usage{
+		Model:        model,
+		InputTokens:  f.PromptTokens,
+		OutputTokens: f.CompletionTokens,
+	}
+	if usage.InputTokens == 0 && usage.OutputTokens == 0 {
+		// Anthropic reports cache reads and writes outside input_tokens
+		usage.InputTokens = f.InputTokens + f.CacheReadInputTokens + f.CacheCreationInputTokens
+		usage.OutputTokens = f.OutputTokens
+	}
+
+	switch {
+	case f.PromptTokensDetails != nil:
+		usage.CachedTokens = f.PromptTokensDetails.CachedTokens
+	case f.InputTokensDetails != nil:
+		usage.CachedTokens = f.InputTokensDetails.CachedTokens
+	default:
+		usage.CachedTokens = f.CacheReadInputTokens
+	}
+	switch {
+	case f.CompletionTokensDetails != nil:
+		usage.ReasoningTokens = f.CompletionTokensDetails.ReasoningTokens
+	case f.OutputTokensDetails != nil:
+		usage.ReasoningTokens = f.OutputTokensDetails.ReasoningTokens
+	}
+
+	return usage, true
+}
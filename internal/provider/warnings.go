@@ -0,0 +1,162 @@
+package provider
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Warning kinds
+const (
+	WarningDeprecation = "deprecation" // The model or endpoint is deprecated
+	WarningSunset      = "sunset"      // The model or endpoint stops working at a date
+	WarningNotice      = "warning"     // Any other provider warning
+)
+
+// Warning is a deprecation notice or warning a provider attached to a response
+type Warning struct {
+	Kind    string `json:"kind"`
+	Source  string `json:"source"` // Header name, or body field ("body.warnings")
+	Message string `json:"message"`
+}
+
+// WarningExtractor is implemented by providers that report warnings in their
+// own format. Providers that don't implement it are parsed with ParseWarnings.
+type WarningExtractor interface {
+	// ExtractWarnings reads the warnings from stored response headers and body
+	ExtractWarnings(headers map[string]string, body string) []Warning
+}
+
+// ExtractWarnings returns the warnings of a response from prov
+func ExtractWarnings(prov Provider, headers map[string]string, body string) []Warning {
+	if extractor, ok := prov.(WarningExtractor); ok {
+		return extractor.ExtractWarnings(headers, body)
+	}
+	return ParseWarnings(headers, body)
+}
+
+// ParseWarnings reads warnings from the standard Deprecation, Sunset and
+// Warning headers, provider headers naming a deprecation or warning (such as
+// openai-model-deprecation), and top-level warning/warnings fields of JSON
+// bodies and stream events
+func ParseWarnings(headers map[string]string, body string) []Warning {
+	var warnings []Warning
+	seen := make(map[Warning]bool)
+	add := func(w Warning) {
+		w.Message = strings.TrimSpace(w.Message)
+		if w.Message != "" && !seen[w] {
+			seen[w] = true
+			warnings = append(warnings, w)
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if kind, ok := warningHeaderKind(name); ok {
+			add(Warning{Kind: kind, Source: strings.ToLower(name), Message: warningHeaderMessage(name, headers[name])})
+		}
+	}
+
+	trimmed := strings.TrimSpace(body)
+	if strings.HasPrefix(trimmed, "{") {
+		for _, w := range bodyWarnings(trimmed) {
+			add(w)
+		}
+	} else if trimmed != "" {
+		scanSSE(body, func(_, data string) {
+			for _, w := range bodyWarnings(data) {
+				add(w)
+			}
+		})
+	}
+	return warnings
+}
+
+// warningHeaderKind classifies a response header as a warning
+func warningHeaderKind(name string) (string, bool) {
+	name = strings.ToLower(name)
+	switch {
+	case name == "sunset":
+		return WarningSunset, true
+	case strings.Contains(name, "deprecat"):
+		return WarningDeprecation, true
+	case name == "warning" || strings.HasSuffix(name, "-warning") || strings.HasSuffix(name, "-warnings"):
+		return WarningNotice, true
+	}
+	return "", false
+}
+
+// warningHeaderMessage returns the text of a warning header. The standard
+// Warning header (`299 - "text"`) is reduced to its quoted text; a bare
+// Deprecation header (`true`, or `@<unix time>`) gets a readable message.
+func warningHeaderMessage(name, value string) string {
+	switch strings.ToLower(name) {
+	case "warning":
+		if start := strings.Index(value, `"`); start >= 0 {
+			if end := strings.Index(value[start+1:], `"`); end >= 0 {
+				return value[start+1 : start+1+end]
+			}
+		}
+	case "deprecation":
+		if value == "true" || strings.HasPrefix(value, "@") {
+			return "deprecated (Deprecation: " + value + ")"
+		}
+		return "deprecated since " + value
+	case "sunset":
+		return "sunset at " + value
+	}
+	return value
+}
+
+// bodyWarnings reads the warning (a string) or warnings (strings, or objects
+// with a message) fields of a JSON object
+func bodyWarnings(data string) []Warning {
+	var env struct {
+		Warning  json.RawMessage   `json:"warning"`
+		Warnings []json.RawMessage `json:"warnings"`
+	}
+	if json.Unmarshal([]byte(data), &env) != nil {
+		return nil
+	}
+
+	var warnings []Warning
+	if text, ok := warningText(env.Warning); ok {
+		warnings = append(warnings, Warning{Kind: WarningNotice, Source: "body.warning", Message: text})
+	}
+	for _, raw := range env.Warnings {
+		if text, ok := warningText(raw); ok {
+			warnings = append(warnings, Warning{Kind: WarningNotice, Source: "body.warnings", Message: text})
+		}
+	}
+	return warnings
+}
+
+// warningText returns the text of a body warning: a string, or an object's
+// message, msg or detail
+func warningText(raw json.RawMessage) (string, bool) {
+	if len(raw) == 0 {
+		return "", false
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text, text != ""
+	}
+	var obj struct {
+		Message string `json:"message"`
+		Msg     string `json:"msg"`
+		Detail  string `json:"detail"`
+	}
+	if json.Unmarshal(raw, &obj) != nil {
+		return "", false
+	}
+	for _, text := range []string{obj.Message, obj.Msg, obj.Detail} {
+		if text != "" {
+			return text, true
+		}
+	}
+	return "", false
+}
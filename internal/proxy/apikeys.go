@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/keypool"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// SetAPIKeyPool spreads requests without client credentials across several
+// gateway-side keys of a provider, instead of the single key set on the provider
+func (ph *ProxyHandler) SetAPIKeyPool(providerName string, pool *keypool.Pool) {
+	if ph.keyPools == nil {
+		ph.keyPools = make(map[string]*keypool.Pool)
+	}
+	ph.keyPools[providerName] = pool
+}
+
+// APIKeyStats returns the usage of pooled API keys by provider
+func (ph *ProxyHandler) APIKeyStats() map[string][]keypool.KeyStats {
+	if len(ph.keyPools) == 0 {
+		return nil
+	}
+	stats := make(map[string][]keypool.KeyStats, len(ph.keyPools))
+	for name, pool := range ph.keyPools {
+		stats[name] = pool.Stats()
+	}
+	return stats
+}
+
+// pickAPIKey attaches a pooled key to the request for the provider to inject.
+// Requests carrying their own credentials are left alone.
+func (ph *ProxyHandler) pickAPIKey(prov provider.Provider, r *http.Request) *http.Request {
+	pool := ph.keyPools[prov.Name()]
+	if pool == nil || r.Header.Get("Authorization") != "" {
+		return r
+	}
+	return r.WithContext(provider.WithAPIKey(r.Context(), pool.Pick()))
+}
+
+// observeAPIKey records the outcome of an upstream attempt for the pooled key it used
+func (ph *ProxyHandler) observeAPIKey(prov provider.Provider, req *http.Request, resp *http.Response) {
+	pool := ph.keyPools[prov.Name()]
+	key := provider.InjectedAPIKey(req, "")
+	if pool == nil || key == "" || resp == nil {
+		return
+	}
+	retryAfter, _ := parseRetryAfter(resp.Header.Get("Retry-After"))
+	pool.Observe(key, resp.StatusCode, retryAfter)
+}
+
+// rotateAPIKey switches a request that was rate limited on a pooled key to the
+// pool's next key before it is retried. It reports whether the key changed.
+func (ph *ProxyHandler) rotateAPIKey(prov provider.Provider, req *http.Request) (*http.Request, bool, error) {
+	pool := ph.keyPools[prov.Name()]
+	previous := provider.InjectedAPIKey(req, "")
+	if pool == nil || previous == "" {
+		return req, false, nil
+	}
+
+	key := pool.Pick()
+	if key == previous {
+		return req, false, nil
+	}
+	next := req.Clone(provider.WithAPIKey(req.Context(), key))
+	next.Header.Del("Authorization")
+	if err := prov.PrepareRequest(next); err != nil {
+		return nil, false, err
+	}
+	return next, true, nil
+}
+
+// withAPIKeyOf carries the pooled key picked for req over to ctx, which
+// replaces the request's context for the upstream call
+func withAPIKeyOf(ctx context.Context, req *http.Request) context.Context {
+	if key := provider.InjectedAPIKey(req, ""); key != "" {
+		return provider.WithAPIKey(ctx, key)
+	}
+	return ctx
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/logging"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+	"github.com/ruqqq/simple-ai-gateway/internal/router"
+)
+
+// Kinds of requests the gateway sends in the background on behalf of a client request
+const (
+	backgroundMirror       = "mirror"
+	backgroundRevalidation = "revalidation"
+)
+
+// backgroundCopy copies the client request to send to prov in the background.
+// The copy outlives the client request, so it is only cancelled on shutdown.
+// The client's credentials are dropped if prov isn't the provider they were
+// sent for, in favor of the gateway-side key.
+func (ph *ProxyHandler) backgroundCopy(r *http.Request, decision *router.Decision, prov provider.Provider, linkAttr, requestID string) *http.Request {
+	ctx := logging.With(logging.CopyAttrs(ph.GetShutdownContext(), r.Context()), linkAttr, requestID)
+	bodyBytes, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	copied := r.Clone(ctx)
+	copied.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	if prov.Name() != decision.Provider.Name() {
+		copied.Header.Del("Authorization")
+		copied.Header.Del("X-Api-Key")
+	}
+	return ph.pickAPIKey(prov, copied)
+}
+
+// sendBackground logs, sends and records a request the gateway makes in the
+// background. input carries the stored request's metadata and its link to
+// the client request.
+func (ph *ProxyHandler) sendBackground(kind string, decision *router.Decision, r *http.Request, input *database.StoreRequestInput) {
+	ctx := r.Context()
+	prov := decision.Provider
+	start := time.Now()
+
+	requestID, reqData, err := ph.logRequest(prov, r, input)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to log background request", "kind", kind, "error", err)
+		return
+	}
+	if reqData != nil {
+		go ph.apiHandler.BroadcastRequestCreated(reqData)
+	}
+
+	proxyReq, err := ph.prepareProxyRequest(prov, decision, r)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to prepare background request", "kind", kind, "error", err)
+		ph.logErrorResponse(ctx, requestID, err, start)
+		return
+	}
+
+	upstreamCtx, deadline, stopTimeouts := ph.withTimeouts(proxyReq.Context(), prov, false)
+	defer stopTimeouts()
+	proxyReq = proxyReq.WithContext(upstreamCtx)
+
+	resp, err := ph.sendFollowingRedirects(ph.client(prov), proxyReq, requestID, start)
+	ph.observeAPIKey(prov, proxyReq, resp)
+	if err != nil {
+		slog.WarnContext(ctx, "background request failed", "kind", kind, "target_provider", prov.Name(), "error", err)
+		if timeout := ph.timedOut(upstreamCtx, prov, err); timeout != nil {
+			ph.logUpstreamTimeout(ctx, prov, requestID, timeout, start)
+		} else {
+			ph.logErrorResponse(ctx, requestID, err, start)
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(deadline.wrap(resp.Body))
+	if timeout := ph.timedOut(upstreamCtx, prov, nil); timeout != nil {
+		slog.WarnContext(ctx, "background request failed", "kind", kind, "target_provider", prov.Name(), "error", timeout)
+		ph.logUpstreamTimeout(ctx, prov, requestID, timeout, start)
+		return
+	}
+	if contentEncoding := resp.Header.Get("Content-Encoding"); contentEncoding != "" && len(body) > 0 {
+		if decompressed, err := decompressBody(body, contentEncoding); err == nil {
+			body = decompressed
+		}
+	}
+
+	headers := make(map[string]string)
+	for key, values := range resp.Header {
+		if len(values) > 0 {
+			headers[key] = values[0]
+		}
+	}
+	respInput := &database.StoreResponseInput{
+		RequestID:  requestID,
+		StatusCode: resp.StatusCode,
+		Headers:    headers,
+		Body:       string(body),
+		DurationMs: int(time.Since(start).Milliseconds()),
+	}
+	ph.recordUsage(prov, respInput)
+	ph.recordWarnings(ctx, prov, respInput)
+
+	responseID, err := ph.db.StoreResponse(respInput)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to log background response", "kind", kind, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "background request completed", "kind", kind, "target_provider", prov.Name(), "status", resp.StatusCode, "duration_ms", respInput.DurationMs)
+	if storedResp, err := ph.db.GetResponse(responseID); err == nil && storedResp != nil {
+		ph.responseCreated(storedResp)
+	}
+}
@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/pricing"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// Budget holds daily and monthly spend limits in USD. Zero means unlimited.
+type Budget struct {
+	DailyUSD   float64
+	MonthlyUSD float64
+}
+
+// budgetExceeded describes the budget a request was rejected against
+type budgetExceeded struct {
+	scope    string // "global" or "key"
+	period   string // "daily" or "monthly"
+	spent    float64
+	budget   float64
+	keyID    string
+	keyName  string
+	resetsAt time.Time
+}
+
+// reason is the rejection message returned to the client
+func (b *budgetExceeded) reason() string {
+	subject := "Gateway"
+	if b.scope == "key" {
+		subject = fmt.Sprintf("Key %s", b.keyName)
+	}
+	return fmt.Sprintf("%s %s budget exceeded: spent $%.4f of $%.2f, resets at %s",
+		subject, b.period, b.spent, b.budget, b.resetsAt.Format(time.RFC3339))
+}
+
+// SetPricing sets the price table used to estimate the cost of each response
+func (ph *ProxyHandler) SetPricing(table pricing.Table) {
+	ph.pricing = table
+}
+
+// SetBudgets configures the global budget and the default per-virtual-key
+// budget. Keys can override the default with their own daily/monthly budgets.
+func (ph *ProxyHandler) SetBudgets(global, defaultKey Budget) {
+	ph.globalBudget = global
+	ph.defaultKeyBudget = defaultKey
+}
+
+// checkBudgets compares spend in the current UTC day and month against the
+// global and virtual key budgets. It returns nil if the request may proceed.
+func (ph *ProxyHandler) checkBudgets(virtualKey *database.VirtualKey) *budgetExceeded {
+	now := time.Now().UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	check := func(scope, keyID string, budget Budget) *budgetExceeded {
+		periods := []struct {
+			name  string
+			limit float64
+			start time.Time
+			reset time.Time
+		}{
+			{"daily", budget.DailyUSD, dayStart, dayStart.AddDate(0, 0, 1)},
+			{"monthly", budget.MonthlyUSD, monthStart, monthStart.AddDate(0, 1, 0)},
+		}
+
+		for _, p := range periods {
+			if p.limit <= 0 {
+				continue
+			}
+			spent, err := ph.db.SpendSince(p.start, keyID)
+			if err != nil {
+				slog.Warn("failed to check budget", "period", p.name, "error", err)
+				continue
+			}
+			if spent >= p.limit {
+				return &budgetExceeded{scope: scope, period: p.name, spent: spent, budget: p.limit, keyID: keyID, resetsAt: p.reset}
+			}
+		}
+		return nil
+	}
+
+	if exceeded := check("global", "", ph.globalBudget); exceeded != nil {
+		return exceeded
+	}
+
+	if virtualKey != nil {
+		if exceeded := check("key", virtualKey.ID, ph.keyBudget(virtualKey)); exceeded != nil {
+			exceeded.keyName = virtualKey.Name
+			return exceeded
+		}
+	}
+
+	return nil
+}
+
+// keyBudget returns the effective budget of a virtual key
+func (ph *ProxyHandler) keyBudget(key *database.VirtualKey) Budget {
+	budget := ph.defaultKeyBudget
+	if key.DailyBudgetUSD != nil {
+		budget.DailyUSD = *key.DailyBudgetUSD
+	}
+	if key.MonthlyBudgetUSD != nil {
+		budget.MonthlyUSD = *key.MonthlyBudgetUSD
+	}
+	return budget
+}
+
+// recordUsage fills in the model, normalized token usage and estimated cost
+// reported by a response body
+func (ph *ProxyHandler) recordUsage(prov provider.Provider, input *database.StoreResponseInput) {
+	usage, ok := provider.ExtractUsage(prov, input.Body)
+	if !ok {
+		return
+	}
+	ph.applyUsage(input, usage)
+}
+
+// applyUsage fills in the model, token usage and estimated cost of a response
+func (ph *ProxyHandler) applyUsage(input *database.StoreResponseInput, usage *provider.Usage) {
+	input.Model = usage.Model
+	input.InputTokens = usage.InputTokens
+	input.OutputTokens = usage.OutputTokens
+	input.CachedTokens = usage.CachedTokens
+	input.ReasoningTokens = usage.ReasoningTokens
+	if cost, ok := ph.pricing.Cost(usage.Model, usage.InputTokens, usage.OutputTokens); ok {
+		input.CostUSD = &cost
+	}
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+	"github.com/ruqqq/simple-ai-gateway/internal/router"
+)
+
+// CacheHeader tells the client whether the response came from the response
+// cache: HIT, STALE when it is past the TTL and being refreshed, MISS, or
+// BYPASS when the client asked not to be served from it.
+// Sent by a client with "bypass", it is a cache override (see CacheOverrideHeader).
+const CacheHeader = "X-AIGW-Cache"
+
+// Values of CacheHeader for responses served from the cache
+const (
+	cacheHit   = "HIT"
+	cacheStale = "STALE"
+)
+
+// SetResponseCache answers repeated identical requests to the given providers
+// ("*" for all) with the successful response recorded within ttl
+func (ph *ProxyHandler) SetResponseCache(providers []string, ttl time.Duration) {
+	ph.cacheProviders = newProviderSet(providers)
+	ph.cacheTTL = ttl
+}
+
+// SetStaleWhileRevalidate keeps serving a cached response for up to staleTTL
+// after it expires, while it is refreshed in the background
+func (ph *ProxyHandler) SetStaleWhileRevalidate(staleTTL time.Duration) {
+	ph.cacheStaleTTL = staleTTL
+}
+
+// findCached returns the cached response for a request, if its provider is
+// cached and the client didn't send Cache-Control: no-cache or no-store, or
+// an X-AIGW-Cache: bypass override, nor does its routing target's policy
+// bypass the cache. The status is HIT, or STALE for a
+// response past the TTL that should be revalidated.
+func (ph *ProxyHandler) findCached(ctx context.Context, w http.ResponseWriter, r *http.Request, prov provider.Provider, fingerprint string) (*database.Response, string) {
+	if ph.cacheTTL <= 0 || !ph.cacheProviders.contains(prov) {
+		return nil, ""
+	}
+
+	cacheControl := strings.ToLower(r.Header.Get("Cache-Control"))
+	o, _ := ctx.Value(overridesKey{}).(*requestOverrides)
+	p := policyOf(ctx)
+	if strings.Contains(cacheControl, "no-cache") || strings.Contains(cacheControl, "no-store") || (o != nil && o.cacheBypass) || (p != nil && p.Cache == "bypass") {
+		w.Header().Set(CacheHeader, "BYPASS")
+		return nil, ""
+	}
+
+	cached, err := ph.db.FindCachedResponse(prov.Name(), fingerprint, time.Now().Add(-ph.cacheTTL-ph.cacheStaleTTL))
+	if err != nil {
+		slog.WarnContext(ctx, "failed to look up cached response", "error", err)
+	}
+	if cached == nil {
+		w.Header().Set(CacheHeader, "MISS")
+		return nil, ""
+	}
+	if time.Since(cached.CreatedAt) > ph.cacheTTL {
+		return cached, cacheStale
+	}
+	return cached, cacheHit
+}
+
+// revalidate refreshes a stale cached response by sending the request
+// upstream again in the background. The refresh is stored as a request of
+// its own, linked with revalidation_of, and becomes the cached response if
+// it succeeds; until then the stale one keeps being served. Only one refresh
+// per cached request runs at a time.
+func (ph *ProxyHandler) revalidate(decision *router.Decision, r *http.Request, original *database.StoreRequestInput, requestID string) {
+	key := decision.Provider.Name() + "/" + original.Fingerprint
+	if _, running := ph.revalidating.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	rr := ph.backgroundCopy(r, decision, decision.Provider, "revalidation_of", requestID)
+	input := &database.StoreRequestInput{
+		RouteRule:      original.RouteRule,
+		VirtualKeyID:   original.VirtualKeyID,
+		Fingerprint:    original.Fingerprint,
+		RequestedModel: original.RequestedModel,
+		RoutedModel:    original.RoutedModel,
+		RevalidationOf: requestID,
+	}
+
+	ph.inflightWg.Add(1)
+	go func() {
+		defer ph.inflightWg.Done()
+		defer ph.revalidating.Delete(key)
+		ph.sendBackground(backgroundRevalidation, decision, rr, input)
+	}()
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// statusClientClosedRequest is stored for requests the client abandoned
+// before the response started (nginx's 499)
+const statusClientClosedRequest = 499
+
+// errClientCancelled is the cancellation cause of upstream calls whose client
+// went away
+var errClientCancelled = errors.New("client_cancelled")
+
+// cancelOnDisconnect returns a context derived from upstreamCtx that is
+// cancelled when clientCtx ends, or when the returned cancel is called (for
+// a failed write to the client). Upstream calls run on the gateway's shutdown
+// context, so without this they would run to completion for nobody. stop
+// releases the context.
+func cancelOnDisconnect(upstreamCtx, clientCtx context.Context) (ctx context.Context, cancel, stop func()) {
+	ctx, cancelCause := context.WithCancelCause(upstreamCtx)
+	stopAfter := context.AfterFunc(clientCtx, func() { cancelCause(errClientCancelled) })
+	return ctx, func() { cancelCause(errClientCancelled) }, func() {
+		stopAfter()
+		cancelCause(nil)
+	}
+}
+
+// cancelledByClient reports whether ctx was cancelled because the client disconnected
+func cancelledByClient(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errClientCancelled)
+}
+
+// clientCancelledMessage describes a response the client didn't wait for
+func clientCancelledMessage(received int) string {
+	return fmt.Sprintf("client_cancelled: the client disconnected after %d bytes of the response", received)
+}
+
+// clientWriter calls onError once when writing to the client fails
+type clientWriter struct {
+	w       io.Writer
+	onError func()
+	failed  bool
+}
+
+func (c *clientWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if err != nil && !c.failed {
+		c.failed = true
+		c.onError()
+	}
+	return n, err
+}
+
+// logCancelledResponse stores the outcome of a request whose client
+// disconnected before the upstream response arrived
+func (ph *ProxyHandler) logCancelledResponse(ctx context.Context, requestID string, start time.Time) {
+	responseID, err := ph.db.StoreResponse(&database.StoreResponseInput{
+		RequestID:    requestID,
+		StatusCode:   statusClientClosedRequest,
+		Headers:      make(map[string]string),
+		DurationMs:   int(time.Since(start).Milliseconds()),
+		IsError:      true,
+		ErrorMessage: clientCancelledMessage(0),
+		Cancelled:    true,
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to log cancelled response", "error", err)
+		return
+	}
+
+	go func() {
+		storedResp, err := ph.db.GetResponse(responseID)
+		if err == nil && storedResp != nil {
+			ph.responseCreated(storedResp)
+		}
+	}()
+}
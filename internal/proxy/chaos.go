@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/chaos"
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// SetChaosRules replaces the fault injection rules; nil turns it off
+func (ph *ProxyHandler) SetChaosRules(rules []*chaos.Rule) error {
+	if err := chaos.Validate(rules); err != nil {
+		return err
+	}
+	ph.chaosMu.Lock()
+	defer ph.chaosMu.Unlock()
+	ph.chaosRules = rules
+	return nil
+}
+
+// ChaosRules returns the fault injection rules
+func (ph *ProxyHandler) ChaosRules() []*chaos.Rule {
+	ph.chaosMu.RLock()
+	defer ph.chaosMu.RUnlock()
+	return ph.chaosRules
+}
+
+// decideFault draws the fault to inject into a request, or nil
+func (ph *ProxyHandler) decideFault(prov provider.Provider, r *http.Request) *chaos.Fault {
+	fault := chaos.Decide(ph.ChaosRules(), prov.Name(), r.URL.Path)
+	if !fault.Active() {
+		return nil
+	}
+	return fault
+}
+
+// injectFault delays the request and injects an error or a connection reset
+// in place of the upstream call. It returns false if the request must not be
+// forwarded.
+func (ph *ProxyHandler) injectFault(ctx context.Context, w http.ResponseWriter, prov provider.Provider, requestID string, fault *chaos.Fault, start time.Time) bool {
+	if fault.Latency > 0 {
+		slog.InfoContext(ctx, "chaos: delaying request", "rule", fault.Rule, "latency", fault.Latency.String())
+		ph.metrics.observeChaos(prov.Name(), "latency")
+		timer := time.NewTimer(fault.Latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			ph.logCancelledResponse(ctx, requestID, start)
+			return false
+		}
+	}
+
+	switch {
+	case fault.Reset:
+		slog.WarnContext(ctx, "chaos: resetting connection", "rule", fault.Rule)
+		ph.metrics.observeChaos(prov.Name(), "reset")
+		ph.storeFault(ctx, requestID, 0, nil, "chaos: connection reset by rule "+fault.Rule, start)
+		resetConnection(w)
+		return false
+	case fault.ErrorStatus != 0:
+		message := fmt.Sprintf("chaos: injected %d by rule %s", fault.ErrorStatus, fault.Rule)
+		slog.WarnContext(ctx, "chaos: injecting error", "rule", fault.Rule, "status", fault.ErrorStatus)
+		ph.metrics.observeChaos(prov.Name(), "error")
+		errorType := provider.ErrorTypeServerError
+		switch fault.ErrorStatus {
+		case http.StatusTooManyRequests:
+			errorType = provider.ErrorTypeRateLimit
+		case http.StatusServiceUnavailable:
+			errorType = provider.ErrorTypeUnavailable
+		}
+		_, body := provider.CannedError(prov, errorType, message)
+		ph.storeFault(ctx, requestID, fault.ErrorStatus, body, message, start)
+		writeCannedError(w, fault.ErrorStatus, body)
+		return false
+	}
+	return true
+}
+
+// storeFault records an injected fault as the request's response
+func (ph *ProxyHandler) storeFault(ctx context.Context, requestID string, statusCode int, body []byte, message string, start time.Time) {
+	if requestID == "" {
+		return
+	}
+	input := &database.StoreResponseInput{
+		RequestID:    requestID,
+		StatusCode:   statusCode,
+		Body:         string(body),
+		DurationMs:   int(time.Since(start).Milliseconds()),
+		IsError:      true,
+		ErrorMessage: message,
+	}
+	if body != nil {
+		input.Headers = map[string]string{"Content-Type": "application/json"}
+	}
+	responseID, err := ph.db.StoreResponse(input)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to log injected fault", "error", err)
+		return
+	}
+	go func() {
+		if stored, err := ph.db.GetResponse(responseID); err == nil && stored != nil {
+			ph.responseCreated(stored)
+		}
+	}()
+}
+
+// resetConnection drops the client connection without a response: a TCP
+// reset on HTTP/1.x, a stream reset on HTTP/2
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// truncatedReader ends a streamed response after a number of bytes
+type truncatedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (t *truncatedReader) Read(p []byte) (int, error) {
+	if t.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > t.remaining {
+		p = p[:t.remaining]
+	}
+	n, err := t.r.Read(p)
+	t.remaining -= int64(n)
+	return n, err
+}
+
+// truncated reports whether the stream was cut short
+func (t *truncatedReader) truncated() bool {
+	return t.remaining <= 0
+}
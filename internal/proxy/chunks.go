@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// SetChunkRecording enables storing the chunks of streamed responses with
+// their arrival times
+func (ph *ProxyHandler) SetChunkRecording(enabled bool) {
+	ph.recordChunks = enabled
+}
+
+// chunkRecorder timestamps a streamed response body as it is read from the
+// provider. Server-sent event streams are split into events; other streams
+// are recorded as read.
+type chunkRecorder struct {
+	start  time.Time
+	events bool
+	buf    bytes.Buffer
+	chunks []*database.ResponseChunk
+}
+
+// newChunkRecorder returns nil unless chunk recording is enabled. start is
+// when the request was forwarded to the provider.
+func (ph *ProxyHandler) newChunkRecorder(contentType string, start time.Time) *chunkRecorder {
+	if !ph.recordChunks {
+		return nil
+	}
+	return &chunkRecorder{start: start, events: strings.HasPrefix(contentType, "text/event-stream")}
+}
+
+func (c *chunkRecorder) Write(p []byte) (int, error) {
+	if !c.events {
+		c.add(p)
+		return len(p), nil
+	}
+
+	c.buf.Write(p)
+	for {
+		end := sseEventEnd(c.buf.Bytes())
+		if end < 0 {
+			break
+		}
+		c.add(c.buf.Next(end))
+	}
+	return len(p), nil
+}
+
+func (c *chunkRecorder) add(data []byte) {
+	c.chunks = append(c.chunks, &database.ResponseChunk{
+		Sequence: len(c.chunks),
+		OffsetMs: float64(time.Since(c.start).Microseconds()) / 1000,
+		Data:     string(data),
+	})
+}
+
+// store saves the recorded chunks, including a trailing partial event, for
+// the stored response
+func (c *chunkRecorder) store(ctx context.Context, db *database.DB, requestID, responseID string) {
+	if c.buf.Len() > 0 {
+		c.add(c.buf.Bytes())
+		c.buf.Reset()
+	}
+	if err := db.StoreResponseChunks(requestID, responseID, c.chunks); err != nil {
+		slog.WarnContext(ctx, "failed to store response chunks", "error", err)
+	}
+}
@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+)
+
+var errMissingClientCert = errors.New("a client certificate issued by the gateway's CA is required")
+
+// SetRequireClientCert rejects proxy requests whose TLS connection didn't
+// present a client certificate verified against the listener's client CAs
+func (ph *ProxyHandler) SetRequireClientCert(require bool) {
+	ph.requireClientCert = require
+}
+
+// authenticateClientCert returns the subject of the client certificate the
+// connection was verified with, or "" if there is none and none is required.
+// The listener verifies certificates when they are presented but doesn't
+// require them, so the management API stays reachable from browsers; the
+// requirement is enforced here, for proxy traffic only.
+func (ph *ProxyHandler) authenticateClientCert(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		if ph.requireClientCert {
+			return "", errMissingClientCert
+		}
+		return "", nil
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	if leaf.Subject.CommonName != "" {
+		return leaf.Subject.CommonName, nil
+	}
+	return leaf.Subject.String(), nil
+}
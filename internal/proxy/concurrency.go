@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+	"github.com/ruqqq/simple-ai-gateway/internal/ratelimit"
+)
+
+// SetAdaptiveConcurrency limits concurrent upstream calls per provider, with
+// limits that adapt to upstream latency and throttling. Requests wait up to
+// maxWait for a free slot before they are rejected.
+func (ph *ProxyHandler) SetAdaptiveConcurrency(opts ratelimit.AdaptiveOptions, maxWait time.Duration) {
+	ph.concurrency = ratelimit.NewAdaptive(opts)
+	ph.concurrencyWait = maxWait
+}
+
+// ConcurrencyLimits returns the current adaptive concurrency limit per provider
+func (ph *ProxyHandler) ConcurrencyLimits() map[string]int {
+	if ph.concurrency == nil {
+		return nil
+	}
+	return ph.concurrency.Limits()
+}
+
+// acquireConcurrency waits for a concurrency slot for the provider. It returns
+// the function releasing the slot, or a rejection reason if none freed up in time.
+func (ph *ProxyHandler) acquireConcurrency(ctx context.Context, prov provider.Provider) (func(), string) {
+	if ph.concurrency == nil {
+		return func() {}, ""
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ph.concurrencyWait)
+	defer cancel()
+
+	release, err := ph.concurrency.Acquire(ctx, prov.Name())
+	if err != nil {
+		return nil, fmt.Sprintf("Concurrency limit reached for provider %s (%d in flight)", prov.Name(), ph.concurrency.Limit(prov.Name()))
+	}
+	return release, ""
+}
+
+// observeConcurrency feeds an upstream response into the provider's adaptive limit
+func (ph *ProxyHandler) observeConcurrency(prov provider.Provider, statusCode int, latency time.Duration) {
+	if ph.concurrency == nil {
+		return
+	}
+	ph.concurrency.Observe(prov.Name(), latency, statusCode == http.StatusTooManyRequests)
+}
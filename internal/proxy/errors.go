@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// writeError answers the client with a provider-shaped error for a failure
+// that originates in the gateway, so client SDKs can parse it. prov may be nil
+// when no provider is known, in which case the OpenAI schema is used.
+func writeError(w http.ResponseWriter, prov provider.Provider, errorType, message string) {
+	statusCode, body := provider.CannedError(prov, errorType, message)
+	writeCannedError(w, statusCode, body)
+}
+
+// errorProvider returns the provider whose error schema a failed request should
+// get, or nil if the request doesn't route to any provider
+func (ph *ProxyHandler) errorProvider(r *http.Request) provider.Provider {
+	if decision, err := ph.router.Route(r); err == nil {
+		return decision.Provider
+	}
+	return nil
+}
+
+// rejectRequest answers a request the gateway refuses to forward with a
+// provider-shaped canned error and records the rejection as its response
+func (ph *ProxyHandler) rejectRequest(ctx context.Context, w http.ResponseWriter, prov provider.Provider, requestID, errorType, message string, start time.Time) {
+	slog.WarnContext(ctx, "request rejected", "reason", message)
+	ph.metrics.observeRejection(prov.Name(), errorType)
+
+	statusCode, body := provider.CannedError(prov, errorType, message)
+
+	if requestID != "" {
+		responseID, err := ph.db.StoreResponse(&database.StoreResponseInput{
+			RequestID:    requestID,
+			StatusCode:   statusCode,
+			Headers:      map[string]string{"Content-Type": "application/json"},
+			Body:         string(body),
+			DurationMs:   int(time.Since(start).Milliseconds()),
+			IsError:      true,
+			ErrorMessage: message,
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "failed to log rejected response", "error", err)
+		} else {
+			go func() {
+				storedResp, err := ph.db.GetResponse(responseID)
+				if err == nil && storedResp != nil {
+					ph.responseCreated(storedResp)
+				}
+			}()
+		}
+	}
+
+	writeCannedError(w, statusCode, body)
+}
+
+func writeCannedError(w http.ResponseWriter, statusCode int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"log/slog"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/export"
+)
+
+// SetExporter publishes every completed request/response record, and streamed
+// chunks if the exporter has them enabled, through exp
+func (ph *ProxyHandler) SetExporter(exp *export.Exporter) {
+	ph.exporter = exp
+}
+
+// responseCreated announces a stored response to live event clients and the
+// exporter, then settles storage sampling for the request. Export sinks get
+// sampled out records in full.
+func (ph *ProxyHandler) responseCreated(resp *database.Response) {
+	defer ph.settleSampling(resp)
+	ph.announceResponse(resp)
+}
+
+// announceResponse announces a stored response to live event clients and the
+// exporter without settling storage sampling, for responses whose request
+// still has outputs to store
+func (ph *ProxyHandler) announceResponse(resp *database.Response) {
+	ph.apiHandler.BroadcastResponseCreated(resp)
+
+	if ph.exporter == nil {
+		return
+	}
+	req, err := ph.db.GetRequest(resp.RequestID)
+	if err != nil || req == nil {
+		slog.Warn("failed to load request for export", "request_id", resp.RequestID, "error", err)
+		return
+	}
+	ph.exporter.Record(req, resp)
+}
+
+// settleSampling drops the payloads and stored files of a sampled out request
+// that succeeded
+func (ph *ProxyHandler) settleSampling(resp *database.Response) {
+	files, err := ph.db.SettleRequest(resp)
+	if err != nil {
+		slog.Warn("failed to drop sampled out request", "request_id", resp.RequestID, "error", err)
+	}
+	for _, file := range files {
+		if err := ph.storage.DeleteFile(file); err != nil {
+			slog.Warn("failed to delete sampled out file", "file", file, "error", err)
+		}
+	}
+}
+
+// chunkExporter exports each write of a streamed response body as a chunk
+type chunkExporter struct {
+	exporter  *export.Exporter
+	requestID string
+	provider  string
+	sequence  int
+}
+
+// newChunkExporter returns nil unless chunk export is enabled
+func (ph *ProxyHandler) newChunkExporter(requestID, provider string) *chunkExporter {
+	if ph.exporter == nil || !ph.exporter.ChunksEnabled() {
+		return nil
+	}
+	return &chunkExporter{exporter: ph.exporter, requestID: requestID, provider: provider}
+}
+
+func (c *chunkExporter) Write(p []byte) (int, error) {
+	c.exporter.Chunk(c.requestID, c.provider, c.sequence, p)
+	c.sequence++
+	return len(p), nil
+}
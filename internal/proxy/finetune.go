@@ -0,0 +1,25 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/finetune"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// SetFineTuneMonitor tracks fine-tuning jobs created through the gateway
+func (ph *ProxyHandler) SetFineTuneMonitor(m *finetune.Monitor) {
+	ph.fineTunes = m
+}
+
+// trackFineTune hands a job created by a successful fine-tuning request to the monitor
+func (ph *ProxyHandler) trackFineTune(ctx context.Context, prov provider.Provider, proxyReq *http.Request, requestID string, statusCode int, body []byte) {
+	if ph.fineTunes == nil || statusCode < 200 || statusCode >= 300 || !finetune.IsCreateRequest(proxyReq.Method, proxyReq.URL.Path) {
+		return
+	}
+	if err := ph.fineTunes.Track(prov, requestID, proxyReq, body); err != nil {
+		slog.WarnContext(ctx, "failed to track fine-tune job", "error", err)
+	}
+}
@@ -0,0 +1,49 @@
+package proxy
+
+import "sync"
+
+// inflightGauges tracks requests currently being proxied, per provider
+type inflightGauges struct {
+	mu         sync.Mutex
+	total      int
+	byProvider map[string]int
+}
+
+func newInflightGauges() *inflightGauges {
+	return &inflightGauges{byProvider: make(map[string]int)}
+}
+
+func (g *inflightGauges) inc(providerName string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.total++
+	g.byProvider[providerName]++
+}
+
+func (g *inflightGauges) dec(providerName string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.total--
+	g.byProvider[providerName]--
+	if g.byProvider[providerName] <= 0 {
+		delete(g.byProvider, providerName)
+	}
+}
+
+// InflightCount returns the number of requests currently being proxied
+func (ph *ProxyHandler) InflightCount() int {
+	ph.gauges.mu.Lock()
+	defer ph.gauges.mu.Unlock()
+	return ph.gauges.total
+}
+
+// InflightByProvider returns the number of requests currently being proxied per provider
+func (ph *ProxyHandler) InflightByProvider() map[string]int {
+	ph.gauges.mu.Lock()
+	defer ph.gauges.mu.Unlock()
+	counts := make(map[string]int, len(ph.gauges.byProvider))
+	for name, n := range ph.gauges.byProvider {
+		counts[name] = n
+	}
+	return counts
+}
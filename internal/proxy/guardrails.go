@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/guardrail"
+)
+
+// Guardrail modes
+const (
+	GuardrailsOff   = "off"
+	GuardrailsScore = "score" // record the risk score of every request and forward it
+	GuardrailsBlock = "block" // also reject requests scoring at or above the threshold
+)
+
+// riskAssessment is the risk score of a request and whether it reaches the
+// threshold
+type riskAssessment struct {
+	*guardrail.RiskScore
+	high bool
+}
+
+// SetGuardrails scores prompts for prompt injection and jailbreak attempts
+// with scorer. Requests scoring threshold or more are high risk.
+func (ph *ProxyHandler) SetGuardrails(scorer *guardrail.RiskScorer, mode string, threshold float64) {
+	ph.riskScorer = scorer
+	ph.guardrailsMode = mode
+	ph.riskThreshold = threshold
+}
+
+// scoreRisk scores the prompt text of a request body. It returns nil if
+// guardrails are off or the body has no prompt text. A failed classifier
+// call is logged and the score of the rules is used.
+func (ph *ProxyHandler) scoreRisk(r *http.Request) *riskAssessment {
+	if ph.riskScorer == nil || (ph.guardrailsMode != GuardrailsScore && ph.guardrailsMode != GuardrailsBlock) {
+		return nil
+	}
+
+	bodyBytes, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	score, err := ph.riskScorer.Score(r.Context(), bodyBytes)
+	if err != nil {
+		slog.WarnContext(r.Context(), "risk classifier failed, using rule score", "error", err)
+		ph.metrics.observeGuardrail("error")
+	}
+	if score == nil {
+		return nil
+	}
+	assessment := &riskAssessment{RiskScore: score, high: score.Score >= ph.riskThreshold}
+	switch {
+	case err != nil:
+	case assessment.high:
+		ph.metrics.observeGuardrail("high")
+	default:
+		ph.metrics.observeGuardrail("low")
+	}
+	return assessment
+}
+
+// riskReason is the rejection message for a request blocked by the guardrails
+func riskReason(risk *riskAssessment) string {
+	return fmt.Sprintf("Request blocked: prompt looks like a prompt injection attempt (risk %.2f: %s)", risk.Score, strings.Join(risk.Rules, ", "))
+}
@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/api"
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// interception holds upstream responses of selected providers until they are
+// released through the API
+type interception struct {
+	mu        sync.Mutex
+	providers providerSet
+	timeout   time.Duration
+	held      map[string]*heldResponse // By request ID
+}
+
+// heldResponse is a response waiting for release. release receives the
+// edit to apply, which may be empty.
+type heldResponse struct {
+	info    *api.HeldResponse
+	release chan *api.ResponseEdit
+}
+
+// SetResponseInterception holds the responses of providers (names or "*")
+// before they are sent to the client, for up to timeout (default: 5 minutes)
+func (ph *ProxyHandler) SetResponseInterception(providers []string, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	ph.interception.mu.Lock()
+	defer ph.interception.mu.Unlock()
+	ph.interception.providers = newProviderSet(providers)
+	ph.interception.timeout = timeout
+}
+
+// InterceptedProviders returns the providers whose responses are held
+func (ph *ProxyHandler) InterceptedProviders() []string {
+	ph.interception.mu.Lock()
+	defer ph.interception.mu.Unlock()
+	names := make([]string, 0, len(ph.interception.providers))
+	for name := range ph.interception.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetInterceptedProviders changes the providers whose responses are held
+func (ph *ProxyHandler) SetInterceptedProviders(providers []string) {
+	ph.interception.mu.Lock()
+	defer ph.interception.mu.Unlock()
+	ph.interception.providers = newProviderSet(providers)
+	slog.Info("response interception changed", "providers", providers)
+}
+
+// HeldResponses returns the responses waiting for release, oldest first
+func (ph *ProxyHandler) HeldResponses() []*api.HeldResponse {
+	ph.interception.mu.Lock()
+	defer ph.interception.mu.Unlock()
+	held := make([]*api.HeldResponse, 0, len(ph.interception.held))
+	for _, h := range ph.interception.held {
+		held = append(held, h.info)
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i].HeldAt.Before(held[j].HeldAt) })
+	return held
+}
+
+// ReleaseResponse sends a held response on to the client, with edit applied
+func (ph *ProxyHandler) ReleaseResponse(requestID string, edit *api.ResponseEdit) error {
+	ph.interception.mu.Lock()
+	held, ok := ph.interception.held[requestID]
+	if ok {
+		delete(ph.interception.held, requestID)
+	}
+	ph.interception.mu.Unlock()
+	if !ok {
+		return api.ErrNotHeld
+	}
+	held.release <- edit
+	return nil
+}
+
+// intercepts reports whether responses from prov are held
+func (ph *ProxyHandler) intercepts(prov provider.Provider) bool {
+	ph.interception.mu.Lock()
+	defer ph.interception.mu.Unlock()
+	return ph.interception.providers.contains(prov)
+}
+
+// holdResponse holds an upstream response until it is released through the
+// API, the hold times out, the client goes away or the gateway shuts down.
+// It returns the edit to apply, nil to send the response unchanged, and
+// false if the client is gone.
+func (ph *ProxyHandler) holdResponse(ctx context.Context, prov provider.Provider, requestID, endpoint string, statusCode int, headers http.Header, body []byte) (*api.ResponseEdit, bool) {
+	ph.interception.mu.Lock()
+	timeout := ph.interception.timeout
+	now := time.Now().UTC()
+	held := &heldResponse{
+		info: &api.HeldResponse{
+			RequestID:  requestID,
+			Provider:   prov.Name(),
+			Endpoint:   endpoint,
+			StatusCode: statusCode,
+			Headers:    firstValues(headers),
+			Body:       string(body),
+			HeldAt:     now,
+			ReleaseAt:  now.Add(timeout),
+		},
+		// Buffered so a release racing with the timeout never blocks the API
+		release: make(chan *api.ResponseEdit, 1),
+	}
+	if ph.interception.held == nil {
+		ph.interception.held = make(map[string]*heldResponse)
+	}
+	ph.interception.held[requestID] = held
+	ph.interception.mu.Unlock()
+
+	slog.InfoContext(ctx, "holding response for release", "status", statusCode, "timeout", timeout)
+	go ph.apiHandler.BroadcastResponseHeld(held.info)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	how := "released"
+	var edit *api.ResponseEdit
+	select {
+	case edit = <-held.release:
+		if edit != nil && (edit.StatusCode != 0 || len(edit.Headers) > 0 || edit.Body != nil) {
+			how = "edited"
+		} else {
+			edit = nil
+		}
+	case <-timer.C:
+		how = "timeout"
+	case <-ph.GetShutdownContext().Done():
+		how = "shutdown"
+	case <-ctx.Done():
+		how = "client_gone"
+	}
+
+	ph.interception.mu.Lock()
+	if ph.interception.held[requestID] == held {
+		delete(ph.interception.held, requestID)
+	}
+	ph.interception.mu.Unlock()
+
+	slog.InfoContext(ctx, "held response released", "how", how)
+	go ph.apiHandler.BroadcastResponseReleased(requestID, how)
+	return edit, how != "client_gone"
+}
+
+// applyEdit stores an edited response as the request's final response and
+// returns what is sent to the client instead of the upstream response.
+// body is the decompressed upstream body.
+func (ph *ProxyHandler) applyEdit(ctx context.Context, requestID, responseID string, edit *api.ResponseEdit, statusCode int, headers http.Header, body []byte, start time.Time) (int, http.Header, []byte) {
+	// Sent uncompressed, with its own length
+	headers = headers.Clone()
+	headers.Del("Content-Encoding")
+	headers.Del("Content-Length")
+	if edit.StatusCode != 0 {
+		statusCode = edit.StatusCode
+	}
+	if edit.Body != nil {
+		body = []byte(*edit.Body)
+	}
+	for key, value := range edit.Headers {
+		if value == "" {
+			headers.Del(key)
+		} else {
+			headers.Set(key, value)
+		}
+	}
+
+	respInput := &database.StoreResponseInput{
+		RequestID:  requestID,
+		StatusCode: statusCode,
+		Headers:    firstValues(headers),
+		Body:       string(body),
+		DurationMs: int(time.Since(start).Milliseconds()),
+		IsError:    statusCode >= 400,
+		EditedFrom: responseID,
+	}
+	if editedID, err := ph.db.StoreResponse(respInput); err != nil {
+		slog.WarnContext(ctx, "failed to log edited response", "error", err)
+	} else if stored, err := ph.db.GetResponse(editedID); err == nil {
+		go ph.responseCreated(stored)
+	}
+	return statusCode, headers, body
+}
+
+// firstValues flattens headers to their first values, as they are stored
+func firstValues(headers http.Header) map[string]string {
+	flat := make(map[string]string, len(headers))
+	for key, values := range headers {
+		if len(values) > 0 {
+			flat[key] = values[0]
+		}
+	}
+	return flat
+}
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+var (
+	errMissingVirtualKey = errors.New("a gateway API key is required")
+	errInvalidVirtualKey = errors.New("invalid gateway API key")
+)
+
+// SetRequireVirtualKey rejects proxy requests that don't present a valid virtual key
+func (ph *ProxyHandler) SetRequireVirtualKey(require bool) {
+	ph.requireVirtualKey = require
+}
+
+// authenticateVirtualKey extracts and validates a gateway-issued virtual key.
+// The key may be sent in X-AIGW-Key or in place of the provider key in
+// Authorization / x-api-key. It is removed from the request so it is neither
+// stored nor forwarded upstream. Returns nil if no key was presented and none is required.
+func (ph *ProxyHandler) authenticateVirtualKey(r *http.Request) (*database.VirtualKey, error) {
+	plaintext := r.Header.Get("X-AIGW-Key")
+	r.Header.Del("X-AIGW-Key")
+
+	if plaintext == "" {
+		if _, credential, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && database.IsVirtualKey(credential) {
+			plaintext = credential
+			r.Header.Del("Authorization")
+		} else if apiKey := r.Header.Get("X-Api-Key"); database.IsVirtualKey(apiKey) {
+			plaintext = apiKey
+			r.Header.Del("X-Api-Key")
+		}
+	}
+
+	if plaintext == "" {
+		if ph.requireVirtualKey {
+			return nil, errMissingVirtualKey
+		}
+		return nil, nil
+	}
+
+	key, err := ph.db.LookupVirtualKey(plaintext)
+	if err != nil {
+		return nil, errInvalidVirtualKey
+	}
+
+	go func() {
+		if err := ph.db.TouchVirtualKey(key.ID); err != nil {
+			slog.WarnContext(r.Context(), "failed to update virtual key usage", "key_id", key.ID, "error", err)
+		}
+	}()
+
+	return key, nil
+}
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/metrics"
+)
+
+// proxyMetrics holds the proxy's Prometheus metrics. A nil *proxyMetrics
+// records nothing, so metrics stay optional.
+type proxyMetrics struct {
+	requests   *metrics.CounterVec
+	upstream   *metrics.HistogramVec
+	ttft       *metrics.HistogramVec
+	rejections *metrics.CounterVec
+	retries    *metrics.CounterVec
+	timeouts   *metrics.CounterVec
+	conns      *metrics.CounterVec
+	toolCalls  *metrics.CounterVec
+	moderation *metrics.CounterVec
+	guardrails *metrics.CounterVec
+	chaos      *metrics.CounterVec
+}
+
+// SetMetrics registers the proxy's metrics with a registry
+func (ph *ProxyHandler) SetMetrics(reg *metrics.Registry) {
+	ph.metrics = &proxyMetrics{
+		requests: reg.NewCounterVec("aigw_requests_total",
+			"Proxied requests by provider and response status code.", "provider", "status"),
+		upstream: reg.NewHistogramVec("aigw_upstream_latency_seconds",
+			"Time from sending a request upstream to receiving the response headers.", metrics.DefaultBuckets, "provider"),
+		ttft: reg.NewHistogramVec("aigw_time_to_first_token_seconds",
+			"Time from sending a streaming request upstream to receiving the first byte of the response body.", metrics.DefaultBuckets, "provider"),
+		rejections: reg.NewCounterVec("aigw_rejected_requests_total",
+			"Requests the gateway refused to forward, by provider and reason.", "provider", "reason"),
+		retries: reg.NewCounterVec("aigw_upstream_retries_total",
+			"Upstream attempts retried, by provider and reason (status code or error).", "provider", "reason"),
+		timeouts: reg.NewCounterVec("aigw_upstream_timeouts_total",
+			"Upstream calls ended by a timeout, by provider and kind (connect, read, total or watchdog).", "provider", "kind"),
+		conns: reg.NewCounterVec("aigw_upstream_connections_total",
+			"Connections upstream calls were sent on, by provider and whether they were reused from the pool.", "provider", "reused"),
+		toolCalls: reg.NewCounterVec("aigw_tool_calls_total",
+			"Tool calls resolved at the gateway, by tool and outcome (ok or error).", "tool", "outcome"),
+		moderation: reg.NewCounterVec("aigw_moderation_checks_total",
+			"Moderation pre-checks of prompts, by result (passed, flagged or error).", "result"),
+		guardrails: reg.NewCounterVec("aigw_risk_checks_total",
+			"Prompt injection risk checks, by result (low, high, or error when the classifier failed).", "result"),
+		chaos: reg.NewCounterVec("aigw_chaos_faults_total",
+			"Faults injected by chaos rules, by provider and fault (latency, error, reset or truncate).", "provider", "fault"),
+	}
+
+	reg.NewGaugeFunc("aigw_inflight_requests", "Requests currently being proxied.", func() float64 {
+		return float64(ph.InflightCount())
+	})
+	reg.NewGaugeVecFunc("aigw_inflight_requests_by_provider", "Requests currently being proxied, by provider.", "provider",
+		func() map[string]float64 {
+			values := make(map[string]float64)
+			for name, count := range ph.InflightByProvider() {
+				values[name] = float64(count)
+			}
+			return values
+		})
+	reg.NewGaugeFunc("aigw_paused_requests", "Requests queued while the proxy is paused.", func() float64 {
+		return float64(ph.QueuedCount())
+	})
+	reg.NewGaugeVecFunc("aigw_concurrency_limit", "Adaptive concurrency limit, by provider.", "provider",
+		func() map[string]float64 {
+			values := make(map[string]float64)
+			for name, limit := range ph.ConcurrencyLimits() {
+				values[name] = float64(limit)
+			}
+			return values
+		})
+}
+
+func (m *proxyMetrics) observeRequest(providerName string, statusCode int) {
+	if m == nil {
+		return
+	}
+	if statusCode == 0 {
+		// Nothing written: net/http sends an implicit 200
+		statusCode = http.StatusOK
+	}
+	m.requests.Inc(providerName, strconv.Itoa(statusCode))
+}
+
+func (m *proxyMetrics) observeUpstream(providerName string, latency time.Duration) {
+	if m == nil {
+		return
+	}
+	m.upstream.Observe(latency.Seconds(), providerName)
+}
+
+func (m *proxyMetrics) observeTTFT(providerName string, ttft time.Duration) {
+	if m == nil {
+		return
+	}
+	m.ttft.Observe(ttft.Seconds(), providerName)
+}
+
+func (m *proxyMetrics) observeRejection(providerName, reason string) {
+	if m == nil {
+		return
+	}
+	m.rejections.Inc(providerName, reason)
+}
+
+func (m *proxyMetrics) observeRetry(providerName, reason string) {
+	if m == nil {
+		return
+	}
+	m.retries.Inc(providerName, reason)
+}
+
+func (m *proxyMetrics) observeTimeout(providerName, kind string) {
+	if m == nil {
+		return
+	}
+	m.timeouts.Inc(providerName, kind)
+}
+
+func (m *proxyMetrics) observeConnection(providerName string, reused bool) {
+	if m == nil {
+		return
+	}
+	m.conns.Inc(providerName, strconv.FormatBool(reused))
+}
+
+func (m *proxyMetrics) observeToolCall(tool string, err error) {
+	if m == nil {
+		return
+	}
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.toolCalls.Inc(tool, outcome)
+}
+
+// statusRecorder captures the final status code written to the client
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	// Informational responses (e.g. 103 Early Hints) precede the final status
+	if code >= 200 && r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming responses working through the recorder
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (m *proxyMetrics) observeModeration(result string) {
+	if m == nil {
+		return
+	}
+	m.moderation.Inc(result)
+}
+
+func (m *proxyMetrics) observeGuardrail(result string) {
+	if m == nil {
+		return
+	}
+	m.guardrails.Inc(result)
+}
+
+func (m *proxyMetrics) observeChaos(providerName, fault string) {
+	if m == nil {
+		return
+	}
+	m.chaos.Inc(providerName, fault)
+}
@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/router"
+)
+
+// mirrorRequest sends a copy of the client request to the decision's mirror
+// provider in the background. The copy and its response are stored as a
+// request of their own, linked to the original with mirror_of.
+func (ph *ProxyHandler) mirrorRequest(decision *router.Decision, r *http.Request, original *database.StoreRequestInput, requestID string) {
+	mirror := decision.Mirror
+	if mirror == nil {
+		return
+	}
+
+	mr := ph.backgroundCopy(r, decision, mirror.Provider, "mirror_of", requestID)
+	input := &database.StoreRequestInput{
+		RouteRule:      original.RouteRule,
+		VirtualKeyID:   original.VirtualKeyID,
+		RequestedModel: mirror.Model,
+		RoutedModel:    mirror.RoutedModel(),
+		MirrorOf:       requestID,
+	}
+
+	ph.inflightWg.Add(1)
+	go func() {
+		defer ph.inflightWg.Done()
+		ph.sendBackground(backgroundMirror, mirror, mr, input)
+	}()
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/guardrail"
+)
+
+// Moderation modes
+const (
+	ModerationOff   = "off"
+	ModerationFlag  = "flag"  // record flagged requests and forward them
+	ModerationBlock = "block" // record flagged requests and reject them
+)
+
+// SetModeration checks prompts with moderator before they are forwarded.
+// With failClosed, requests are rejected when the check itself fails;
+// otherwise they are forwarded unchecked.
+func (ph *ProxyHandler) SetModeration(moderator *guardrail.Moderator, mode string, failClosed bool) {
+	ph.moderator = moderator
+	ph.moderationMode = mode
+	ph.moderationFailClosed = failClosed
+}
+
+// moderate checks the prompt text of a request body. It returns the verdict
+// if the request was flagged, nil if it passed or has no prompt text, and an
+// error if the check failed.
+func (ph *ProxyHandler) moderate(r *http.Request) (*database.ModerationResult, error) {
+	if ph.moderator == nil || (ph.moderationMode != ModerationFlag && ph.moderationMode != ModerationBlock) {
+		return nil, nil
+	}
+
+	bodyBytes, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	result, err := ph.moderator.Check(r.Context(), bodyBytes)
+	switch {
+	case err != nil:
+		slog.WarnContext(r.Context(), "moderation check failed", "error", err, "fail_closed", ph.moderationFailClosed)
+		ph.metrics.observeModeration("error")
+		return nil, err
+	case result == nil:
+		return nil, nil
+	case !result.Flagged:
+		ph.metrics.observeModeration("passed")
+		return nil, nil
+	}
+	ph.metrics.observeModeration("flagged")
+	return &database.ModerationResult{Flagged: true, Categories: result.Categories}, nil
+}
+
+// moderationReason is the rejection message for a request blocked by the
+// moderation pre-check
+func moderationReason(moderation *database.ModerationResult) string {
+	if len(moderation.Categories) == 0 {
+		return "Request blocked: prompt was flagged by content moderation"
+	}
+	return fmt.Sprintf("Request blocked: prompt was flagged by content moderation (%s)", strings.Join(moderation.Categories, ", "))
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/router"
+)
+
+// Request headers that override gateway policy for a single request
+const (
+	RouteOverrideHeader = "X-AIGW-Route" // Provider to send the request to, bypassing routing rules
+	CacheOverrideHeader = CacheHeader    // "bypass" skips the response cache lookup
+	RetryOverrideHeader = "X-AIGW-Retry" // Upstream attempts, replacing the retry policy's
+)
+
+// Who may send override headers
+const (
+	OverridesOff  = "off"  // Override headers are stripped and ignored
+	OverridesKeys = "keys" // Only virtual keys with allow_overrides
+	OverridesAll  = "all"  // Any client, for local development
+)
+
+// maxRetryOverride caps the attempts a client can ask for
+const maxRetryOverride = router.MaxRetries
+
+// defaultRetryPolicy backs retry overrides when the gateway doesn't retry by default
+var defaultRetryPolicy = RetryPolicy{
+	Backoff:    500 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+	Statuses:   DefaultRetryStatuses,
+}
+
+var errOverridesNotAllowed = errors.New("this key is not allowed to send X-AIGW-* override headers")
+
+// requestOverrides are the policy overrides a client asked for
+type requestOverrides struct {
+	headers     map[string]string // As sent, recorded on the request
+	route       string
+	cacheBypass bool
+	retries     int // Attempts, 0 to keep the retry policy
+}
+
+// overridesKey carries a request's overrides to the upstream call
+type overridesKey struct{}
+
+// SetOverrideHeaders sets who may send override headers: off, keys or all
+func (ph *ProxyHandler) SetOverrideHeaders(mode string) {
+	ph.overrideMode = mode
+}
+
+// parseOverrides extracts the override headers from the request. They are
+// removed so they are neither stored with the headers nor forwarded upstream.
+// Returns nil if none were sent or overrides are off, and an error if the
+// client may not override or a value is invalid.
+func (ph *ProxyHandler) parseOverrides(r *http.Request, key *database.VirtualKey) (*requestOverrides, error) {
+	headers := make(map[string]string)
+	for _, name := range []string{RouteOverrideHeader, CacheOverrideHeader, RetryOverrideHeader} {
+		if value := strings.TrimSpace(r.Header.Get(name)); value != "" {
+			headers[name] = value
+		}
+		r.Header.Del(name)
+	}
+	if len(headers) == 0 || ph.overrideMode == "" || ph.overrideMode == OverridesOff {
+		return nil, nil
+	}
+	if ph.overrideMode == OverridesKeys && (key == nil || !key.AllowOverrides) {
+		return nil, errOverridesNotAllowed
+	}
+
+	o := &requestOverrides{headers: headers, route: headers[RouteOverrideHeader]}
+	if value, ok := headers[CacheOverrideHeader]; ok {
+		if !strings.EqualFold(value, "bypass") {
+			return nil, fmt.Errorf("invalid %s override %q (expected bypass)", CacheOverrideHeader, value)
+		}
+		o.cacheBypass = true
+	}
+	if value, ok := headers[RetryOverrideHeader]; ok {
+		attempts, err := strconv.Atoi(value)
+		if err != nil || attempts < 1 || attempts > maxRetryOverride {
+			return nil, fmt.Errorf("invalid %s override %q (expected 1 to %d attempts)", RetryOverrideHeader, value, maxRetryOverride)
+		}
+		o.retries = attempts
+	}
+	return o, nil
+}
+
+// withOverrides attaches the request's overrides to ctx
+func withOverrides(ctx context.Context, o *requestOverrides) context.Context {
+	if o == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, overridesKey{}, o)
+}
+
+// withOverridesOf carries the overrides and target policy of req over to
+// ctx, which replaces the request's context for the upstream call
+func withOverridesOf(ctx context.Context, req *http.Request) context.Context {
+	o, _ := req.Context().Value(overridesKey{}).(*requestOverrides)
+	return withPolicyOf(withOverrides(ctx, o), req)
+}
+
+// retryPolicy returns the retry policy for an upstream call, with the
+// attempts a client override or else the routing target's policy asked for.
+// Nil means no retries.
+func (ph *ProxyHandler) retryPolicy(ctx context.Context) *RetryPolicy {
+	attempts := 0
+	if p := policyOf(ctx); p != nil {
+		attempts = p.Retries
+	}
+	if o, _ := ctx.Value(overridesKey{}).(*requestOverrides); o != nil && o.retries > 0 {
+		attempts = o.retries
+	}
+	if attempts == 0 {
+		return ph.retry
+	}
+	policy := defaultRetryPolicy
+	if ph.retry != nil {
+		policy = *ph.retry
+	}
+	policy.MaxAttempts = attempts
+	return &policy
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/api"
+)
+
+// Pause modes
+const (
+	PauseQueue  = "queue"  // hold new requests until the proxy is resumed
+	PauseReject = "reject" // reject new requests with the unavailable canned error
+)
+
+// pauseSwitch stops new requests from being forwarded while paused
+type pauseSwitch struct {
+	mu          sync.Mutex
+	defaultMode string
+	maxWait     time.Duration
+	paused      bool
+	mode        string
+	since       time.Time
+	resumed     chan struct{} // Closed on resume
+	queued      int
+}
+
+// SetPauseOptions sets the mode used when a pause doesn't name one and how
+// long queued requests wait for resume before they are rejected (0: until
+// resumed)
+func (ph *ProxyHandler) SetPauseOptions(defaultMode string, maxWait time.Duration) {
+	ph.pause.mu.Lock()
+	defer ph.pause.mu.Unlock()
+	ph.pause.defaultMode = defaultMode
+	ph.pause.maxWait = maxWait
+}
+
+// Pause stops forwarding new requests: they wait for resume in queue mode,
+// or are rejected in reject mode. Pausing a paused proxy changes its mode;
+// requests already queued stay queued.
+func (ph *ProxyHandler) Pause(mode string) (*api.PauseState, error) {
+	ph.pause.mu.Lock()
+	if mode == "" {
+		mode = ph.pause.defaultMode
+	}
+	if mode == "" {
+		mode = PauseQueue
+	}
+	if mode != PauseQueue && mode != PauseReject {
+		ph.pause.mu.Unlock()
+		return nil, fmt.Errorf("invalid pause mode %q (expected queue or reject)", mode)
+	}
+	if !ph.pause.paused {
+		ph.pause.paused = true
+		ph.pause.since = time.Now().UTC()
+		ph.pause.resumed = make(chan struct{})
+	}
+	ph.pause.mode = mode
+	state := ph.pauseState()
+	ph.pause.mu.Unlock()
+
+	slog.Warn("proxy paused", "mode", mode)
+	go ph.apiHandler.BroadcastProxyPaused(mode)
+	return state, nil
+}
+
+// Resume forwards new requests again and releases the queued ones
+func (ph *ProxyHandler) Resume() *api.PauseState {
+	ph.pause.mu.Lock()
+	if !ph.pause.paused {
+		state := ph.pauseState()
+		ph.pause.mu.Unlock()
+		return state
+	}
+	released, pausedFor := ph.pause.queued, time.Since(ph.pause.since)
+	close(ph.pause.resumed)
+	ph.pause.paused = false
+	ph.pause.mode = ""
+	ph.pause.resumed = nil
+	ph.pause.queued = 0
+	state := ph.pauseState()
+	ph.pause.mu.Unlock()
+
+	slog.Info("proxy resumed", "released", released, "paused_for", pausedFor.Round(time.Second).String())
+	go ph.apiHandler.BroadcastProxyResumed(released, pausedFor)
+	return state
+}
+
+// PauseStatus reports whether the proxy is paused
+func (ph *ProxyHandler) PauseStatus() *api.PauseState {
+	ph.pause.mu.Lock()
+	defer ph.pause.mu.Unlock()
+	return ph.pauseState()
+}
+
+// QueuedCount returns the number of requests waiting for resume
+func (ph *ProxyHandler) QueuedCount() int {
+	ph.pause.mu.Lock()
+	defer ph.pause.mu.Unlock()
+	return ph.pause.queued
+}
+
+// pauseState builds the pause status; the caller holds ph.pause.mu
+func (ph *ProxyHandler) pauseState() *api.PauseState {
+	state := &api.PauseState{Paused: ph.pause.paused, Queued: ph.pause.queued}
+	if ph.pause.paused {
+		since := ph.pause.since
+		state.Mode, state.Since = ph.pause.mode, &since
+	}
+	return state
+}
+
+// waitIfPaused holds a request while the proxy is paused in queue mode. It
+// returns a rejection reason if the proxy is paused in reject mode, or the
+// request could not wait for resume.
+func (ph *ProxyHandler) waitIfPaused(ctx context.Context) string {
+	ph.pause.mu.Lock()
+	if !ph.pause.paused {
+		ph.pause.mu.Unlock()
+		return ""
+	}
+	if ph.pause.mode == PauseReject {
+		ph.pause.mu.Unlock()
+		return "Gateway is paused, try again later"
+	}
+	resumed, maxWait := ph.pause.resumed, ph.pause.maxWait
+	ph.pause.queued++
+	ph.pause.mu.Unlock()
+
+	defer func() {
+		ph.pause.mu.Lock()
+		// Resume already reset the count
+		if ph.pause.resumed == resumed {
+			ph.pause.queued--
+		}
+		ph.pause.mu.Unlock()
+	}()
+
+	slog.InfoContext(ctx, "proxy paused, queueing request")
+	var timeout <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-resumed:
+		return ""
+	case <-timeout:
+		return fmt.Sprintf("Gateway is paused: request was not resumed within %s", maxWait)
+	case <-ph.GetShutdownContext().Done():
+		return "Gateway is shutting down"
+	case <-ctx.Done():
+		return "Client closed the request while the gateway was paused"
+	}
+}
@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// maxPartValue is the longest multipart field value kept in a payload summary
+const maxPartValue = 4096
+
+// savedFile is a request body file saved to storage, waiting for its request
+// to be stored
+type savedFile struct {
+	path        string
+	contentType string
+	size        int64
+}
+
+// isBinaryBody reports whether a request body is stored as files rather than
+// text: multipart forms, and bodies that aren't text, JSON, XML or a URL-encoded form
+func isBinaryBody(contentType string, body []byte) bool {
+	if len(body) == 0 {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return !utf8.Valid(body)
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		return true
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json", strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/x-ndjson", mediaType == "application/jsonl",
+		mediaType == "application/xml", strings.HasSuffix(mediaType, "+xml"),
+		mediaType == "application/x-www-form-urlencoded":
+		return false
+	}
+	return true
+}
+
+// savePayload saves a multipart or binary request body to file storage and
+// returns its summary. Each file of a multipart form is saved on its own; a
+// form that can't be parsed, like any other binary body, is saved whole.
+func (ph *ProxyHandler) savePayload(ctx context.Context, providerName, contentType string, body []byte) (*database.RequestPayload, []savedFile) {
+	payload := &database.RequestPayload{ContentType: contentType, Size: int64(len(body))}
+
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		parts, files, err := ph.saveParts(providerName, params["boundary"], body)
+		if err == nil {
+			payload.Parts = parts
+			return payload, files
+		}
+		ph.deleteSaved(files)
+		slog.WarnContext(ctx, "failed to parse multipart request body, storing it whole", "error", err)
+	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	filePath, size, err := ph.storage.SaveFile(providerName, contentType, bytes.NewReader(body))
+	if err != nil {
+		slog.WarnContext(ctx, "failed to save request body", "error", err)
+		return payload, nil
+	}
+	return payload, []savedFile{{path: filePath, contentType: contentType, size: size}}
+}
+
+// saveParts saves the files of a multipart form and summarizes its fields
+func (ph *ProxyHandler) saveParts(providerName, boundary string, body []byte) ([]database.PayloadPart, []savedFile, error) {
+	var parts []database.PayloadPart
+	var files []savedFile
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return parts, files, nil
+		}
+		if err != nil {
+			return nil, files, err
+		}
+
+		summary := database.PayloadPart{
+			Name:        part.FormName(),
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+		}
+		if summary.Filename == "" {
+			value, err := io.ReadAll(part)
+			if err != nil {
+				return nil, files, err
+			}
+			summary.Size = int64(len(value))
+			if len(value) > maxPartValue {
+				value, summary.Truncated = value[:maxPartValue], true
+			}
+			summary.Value = string(value)
+		} else {
+			// Clients often send files as octet-stream; the extension says more
+			contentType := summary.ContentType
+			if contentType == "" || contentType == "application/octet-stream" {
+				contentType = mime.TypeByExtension(path.Ext(summary.Filename))
+			}
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			filePath, size, err := ph.storage.SaveFile(providerName, contentType, part)
+			if err != nil {
+				return nil, files, err
+			}
+			summary.Size = size
+			files = append(files, savedFile{path: filePath, contentType: contentType, size: size})
+		}
+		parts = append(parts, summary)
+	}
+}
+
+// deleteSaved removes saved request body files whose request wasn't stored
+func (ph *ProxyHandler) deleteSaved(files []savedFile) {
+	for _, file := range files {
+		if err := ph.storage.DeleteFile(file.path); err != nil {
+			slog.Warn("failed to delete request body file", "file", file.path, "error", err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// What happens to requests to a played back provider that have no recording
+const (
+	PlaybackMissError   = "error"   // reject with a not found error
+	PlaybackMissForward = "forward" // forward upstream, recording the response for next time
+)
+
+// ReplayedFromHeader is set on played back responses to the ID of the recorded request
+const ReplayedFromHeader = "X-AIGW-Replayed-From"
+
+// SetPlayback serves recorded responses instead of calling the given
+// providers ("*" for all). onMiss is one of the PlaybackMiss* modes.
+func (ph *ProxyHandler) SetPlayback(providers []string, onMiss string) {
+	ph.playbackProviders = newProviderSet(providers)
+	ph.playbackMiss = onMiss
+}
+
+// playsBack reports whether requests to prov are answered from recordings
+func (ph *ProxyHandler) playsBack(prov provider.Provider) bool {
+	return ph.playbackProviders.contains(prov)
+}
+
+// providerSet is a set of provider names, where "*" matches every provider
+type providerSet map[string]bool
+
+func newProviderSet(names []string) providerSet {
+	set := make(providerSet)
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			set[name] = true
+		}
+	}
+	return set
+}
+
+func (s providerSet) contains(prov provider.Provider) bool {
+	return s[prov.Name()] || s["*"]
+}
+
+// findRecording returns the recorded response for a request to a played back
+// provider. missed reports a request without a recording that must not be forwarded.
+func (ph *ProxyHandler) findRecording(ctx context.Context, prov provider.Provider, fingerprint string) (*database.Response, bool) {
+	if !ph.playsBack(prov) {
+		return nil, false
+	}
+
+	recording, err := ph.db.FindRecording(prov.Name(), fingerprint)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to look up recording", "error", err)
+	}
+	if recording == nil {
+		return nil, ph.playbackMiss != PlaybackMissForward
+	}
+	return recording, false
+}
+
+// replay answers a request with a recorded response, either played back or
+// from the response cache (cacheStatus HIT or STALE), and stores it as the
+// request's response
+func (ph *ProxyHandler) replay(ctx context.Context, w http.ResponseWriter, recording *database.Response, cacheStatus, requestID, method string, start time.Time) {
+	cached := cacheStatus != ""
+	if cached {
+		slog.InfoContext(ctx, "serving cached response", "recording", recording.RequestID, "status", recording.StatusCode, "cache", cacheStatus)
+	} else {
+		slog.InfoContext(ctx, "replaying recorded response", "recording", recording.RequestID, "status", recording.StatusCode)
+	}
+
+	if requestID != "" {
+		responseID, err := ph.db.StoreResponse(&database.StoreResponseInput{
+			RequestID:  requestID,
+			StatusCode: recording.StatusCode,
+			Headers:    recording.Headers,
+			Body:       recording.Body,
+			DurationMs: int(time.Since(start).Milliseconds()),
+			Cached:     cached,
+			Stale:      cacheStatus == cacheStale,
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "failed to log replayed response", "error", err)
+		} else {
+			go func() {
+				storedResp, err := ph.db.GetResponse(responseID)
+				if err == nil && storedResp != nil {
+					ph.responseCreated(storedResp)
+				}
+			}()
+		}
+	}
+
+	for key, value := range recording.Headers {
+		// Bodies are stored decoded and are written in one piece
+		switch http.CanonicalHeaderKey(key) {
+		case "Content-Encoding", "Content-Length", "Transfer-Encoding":
+			continue
+		}
+		w.Header().Set(key, value)
+	}
+	w.Header().Set(ReplayedFromHeader, recording.RequestID)
+	if cached {
+		w.Header().Set(CacheHeader, cacheStatus)
+		w.Header().Set("Age", strconv.Itoa(int(time.Since(recording.CreatedAt).Seconds())))
+	}
+	w.WriteHeader(recording.StatusCode)
+
+	if bodyAllowed(method, recording.StatusCode) {
+		io.WriteString(w, recording.Body)
+	}
+}
+
+// requestFingerprint identifies a request for playback matching by its
+// method, path, query and body. JSON bodies are normalized first, so key
+// order and whitespace don't matter.
+func requestFingerprint(r *http.Request) string {
+	bodyBytes, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", r.Method, r.URL.Path, r.URL.Query().Encode())
+	h.Write(normalizeJSON(bodyBytes))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeJSON re-encodes a JSON body compactly with sorted object keys,
+// returning other bodies unchanged
+func normalizeJSON(body []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return body
+	}
+
+	normalized, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return normalized
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/router"
+)
+
+// policyKey carries the policy of a request's routing target to the upstream call
+type policyKey struct{}
+
+// withPolicy attaches a routing target's policy to ctx
+func withPolicy(ctx context.Context, p *router.Policy) context.Context {
+	if p == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, policyKey{}, p)
+}
+
+// policyOf returns the routing target policy attached to ctx, or nil
+func policyOf(ctx context.Context) *router.Policy {
+	p, _ := ctx.Value(policyKey{}).(*router.Policy)
+	return p
+}
+
+// withPolicyOf carries the target policy of req over to ctx, which replaces
+// the request's context for the upstream call
+func withPolicyOf(ctx context.Context, req *http.Request) context.Context {
+	return withPolicy(ctx, policyOf(req.Context()))
+}
+
+// policyTimeouts returns t with the timeouts a target policy sets replaced
+func policyTimeouts(t Timeouts, p *router.Policy) Timeouts {
+	if p == nil {
+		return t
+	}
+	for _, v := range []struct {
+		seconds int
+		timeout *time.Duration
+	}{
+		{p.Timeout, &t.Total},
+		{p.ReadTimeout, &t.Read},
+		{p.StreamTimeout, &t.StreamTotal},
+		{p.StreamReadTimeout, &t.StreamRead},
+	} {
+		if v.seconds > 0 {
+			*v.timeout = time.Duration(v.seconds) * time.Second
+		}
+	}
+	return t
+}
@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// maxPredictionBody is the largest prediction read while polling
+const maxPredictionBody = 10 << 20
+
+// SetPredictionPolling makes the gateway follow predictions created through
+// it until they complete, polling every interval for at most timeout, and
+// store their outputs with the request that created them. An interval of 0
+// turns it off; a timeout of 0 polls until the gateway shuts down.
+func (ph *ProxyHandler) SetPredictionPolling(interval, timeout time.Duration) {
+	ph.predictionInterval = interval
+	ph.predictionTimeout = timeout
+}
+
+// pendingPrediction is a prediction followed until it completes
+type pendingPrediction struct {
+	prov       provider.Provider
+	requestID  string
+	responseID string
+	pollURL    string
+	auth       string // Authorization header of the request that created it
+}
+
+// followPrediction starts following the prediction a successful create
+// request returned, if it is still running. It reports whether it did; the
+// outputs are then stored once it completes.
+func (ph *ProxyHandler) followPrediction(ctx context.Context, prov provider.Provider, proxyReq *http.Request, requestID, responseID string, statusCode int, body []byte) bool {
+	poller, ok := prov.(provider.PredictionPoller)
+	if !ok || ph.predictionInterval <= 0 || proxyReq.Method != http.MethodPost || statusCode < 200 || statusCode >= 300 {
+		return false
+	}
+	id, pollURL, ok := poller.PendingPrediction(string(body))
+	if !ok {
+		return false
+	}
+
+	pending := &pendingPrediction{
+		prov:       prov,
+		requestID:  requestID,
+		responseID: responseID,
+		pollURL:    pollURL,
+		auth:       proxyReq.Header.Get("Authorization"),
+	}
+	if _, loaded := ph.predictions.LoadOrStore(id, pending); loaded {
+		return true
+	}
+	slog.InfoContext(ctx, "following prediction", "prediction_id", id)
+	go ph.pollPrediction(id, pending)
+	return true
+}
+
+// pollPrediction polls a prediction until it completes, the timeout passes
+// or the gateway shuts down
+func (ph *ProxyHandler) pollPrediction(id string, pending *pendingPrediction) {
+	ctx := ph.GetShutdownContext()
+	if ph.predictionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ph.predictionTimeout)
+		defer cancel()
+	}
+	poller := pending.prov.(provider.PredictionPoller)
+
+	ticker := time.NewTicker(ph.predictionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if _, ok := ph.predictions.LoadAndDelete(id); ok {
+				slog.Warn("stopped following prediction before it completed", "prediction_id", id, "request_id", pending.requestID, "error", ctx.Err())
+				ph.settlePrediction(pending.responseID)
+			}
+			return
+		case <-ticker.C:
+		}
+
+		// Completed elsewhere, e.g. by a webhook
+		if _, ok := ph.predictions.Load(id); !ok {
+			return
+		}
+		body, err := ph.fetchPrediction(ctx, pending)
+		if err != nil {
+			slog.Warn("failed to poll prediction", "prediction_id", id, "error", err)
+			continue
+		}
+		if _, _, running := poller.PendingPrediction(string(body)); !running {
+			ph.completePrediction(id, body)
+			return
+		}
+	}
+}
+
+// fetchPrediction gets the current state of a prediction
+func (ph *ProxyHandler) fetchPrediction(ctx context.Context, pending *pendingPrediction) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pending.pollURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create poll request: %w", err)
+	}
+	if pending.auth != "" {
+		req.Header.Set("Authorization", pending.auth)
+	}
+	if err := pending.prov.PrepareRequest(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := ph.client(pending.prov).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPredictionBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prediction: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("provider returned status %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// completePrediction stores the outputs of a completed prediction with the
+// request that created it. It reports false if the prediction wasn't being
+// followed, or was already completed.
+func (ph *ProxyHandler) completePrediction(id string, body []byte) bool {
+	value, ok := ph.predictions.LoadAndDelete(id)
+	if !ok {
+		return false
+	}
+	pending := value.(*pendingPrediction)
+	ph.storePrediction(pending.prov, id, pending.requestID, pending.responseID, body)
+	return true
+}
+
+// storePrediction stores the outputs of a completed prediction with the
+// request that created it, settles its storage sampling and announces it to
+// live event clients
+func (ph *ProxyHandler) storePrediction(prov provider.Provider, id, requestID, responseID string, body []byte) {
+	var prediction struct {
+		Status string `json:"status"`
+	}
+	_ = json.Unmarshal(body, &prediction)
+
+	slog.Info("prediction completed", "prediction_id", id, "request_id", requestID, "status", prediction.Status)
+	if err := prov.ProcessResponse(string(body), requestID, responseID, ph.storage, ph.db); err != nil {
+		slog.Warn("provider post-response processing failed", "prediction_id", id, "error", err)
+	}
+	ph.settlePrediction(responseID)
+	ph.apiHandler.BroadcastPredictionCompleted(requestID, prov.Name(), id, prediction.Status)
+}
+
+// settlePrediction settles storage sampling for the request that created a
+// prediction, once its outputs are stored or no longer expected
+func (ph *ProxyHandler) settlePrediction(responseID string) {
+	resp, err := ph.db.GetResponse(responseID)
+	if err != nil || resp == nil {
+		slog.Warn("failed to load prediction response", "response_id", responseID, "error", err)
+		return
+	}
+	ph.settleSampling(resp)
+}
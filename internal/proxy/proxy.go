@@ -5,44 +5,114 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/andybalholm/brotli"
 	"github.com/ruqqq/simple-ai-gateway/internal/api"
+	"github.com/ruqqq/simple-ai-gateway/internal/chaos"
 	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/export"
+	"github.com/ruqqq/simple-ai-gateway/internal/finetune"
+	"github.com/ruqqq/simple-ai-gateway/internal/guardrail"
+	"github.com/ruqqq/simple-ai-gateway/internal/keypool"
+	"github.com/ruqqq/simple-ai-gateway/internal/logging"
+	"github.com/ruqqq/simple-ai-gateway/internal/pricing"
 	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+	"github.com/ruqqq/simple-ai-gateway/internal/ratelimit"
+	"github.com/ruqqq/simple-ai-gateway/internal/router"
 	"github.com/ruqqq/simple-ai-gateway/internal/storage"
+	"github.com/ruqqq/simple-ai-gateway/internal/tools"
 )
 
 type ProxyHandler struct {
-	db              *database.DB
-	storage         *storage.FileStorage
-	providers       map[string]provider.Provider
-	broadcaster     *api.SSEBroadcaster
-	apiHandler      *api.Handler
-	inflightWg      sync.WaitGroup
-	shutdownCtx     context.Context
-	shutdownMutex   sync.RWMutex
+	db            *database.DB
+	storage       storage.FileStorage
+	router        *router.Router
+	broadcaster   *api.SSEBroadcaster
+	apiHandler    *api.Handler
+	inflightWg    sync.WaitGroup
+	shutdownCtx   context.Context
+	shutdownMutex sync.RWMutex
+	gauges        *inflightGauges
+
+	followRedirects    bool
+	retry              *RetryPolicy
+	timeouts           map[string]Timeouts
+	transportOpts      *TransportOptions
+	networks           map[string]Network
+	clients            sync.Map // Provider name to its shared *http.Client
+	overrideMode       string
+	requireVirtualKey  bool
+	requireClientCert  bool
+	injectStreamUsage  bool
+	stripInjectedUsage bool
+	recordChunks       bool
+	maxBufferedBody    int64
+	predictionInterval time.Duration
+	predictionTimeout  time.Duration
+	predictions        sync.Map // Prediction ID to the *pendingPrediction followed until it completes
+	webhookKey         []byte   // Replicate webhook signing key, nil when webhooks are off
+
+	rateLimiter      *ratelimit.Limiter
+	providerLimits   map[string]ratelimit.Limits
+	defaultKeyLimits ratelimit.Limits
+	concurrency      *ratelimit.AdaptiveLimiter
+	concurrencyWait  time.Duration
+	keyPools         map[string]*keypool.Pool
+
+	pricing          pricing.Table
+	globalBudget     Budget
+	defaultKeyBudget Budget
+
+	secretScanMode       string
+	moderator            *guardrail.Moderator
+	moderationMode       string
+	moderationFailClosed bool
+	riskScorer           *guardrail.RiskScorer
+	guardrailsMode       string
+	riskThreshold        float64
+
+	playbackProviders providerSet
+	playbackMiss      string
+	cacheProviders    providerSet
+	cacheTTL          time.Duration
+	cacheStaleTTL     time.Duration
+	revalidating      sync.Map // Provider and fingerprint of stale cached responses being refreshed
+	seenWarnings      sync.Map // Distinct provider warnings already logged
+	interception      interception
+	chaosMu           sync.RWMutex
+	chaosRules        []*chaos.Rule
+	pause             pauseSwitch
+
+	metrics   *proxyMetrics
+	watchdog  *watchdog
+	exporter  *export.Exporter
+	fineTunes *finetune.Monitor
+
+	tools         tools.Set
+	toolMaxRounds int
 }
 
 // New creates a new proxy handler
-func New(db *database.DB, fs *storage.FileStorage, providers []provider.Provider, broadcaster *api.SSEBroadcaster, apiHandler *api.Handler) *ProxyHandler {
-	providerMap := make(map[string]provider.Provider)
-	for _, p := range providers {
-		providerMap[p.Name()] = p
-	}
-
+func New(db *database.DB, fs storage.FileStorage, rt *router.Router, broadcaster *api.SSEBroadcaster, apiHandler *api.Handler) *ProxyHandler {
 	return &ProxyHandler{
 		db:          db,
 		storage:     fs,
-		providers:   providerMap,
+		router:      rt,
 		broadcaster: broadcaster,
 		apiHandler:  apiHandler,
+		gauges:      newInflightGauges(),
 		shutdownCtx: context.Background(), // Default context, will be replaced by SetShutdownContext
 	}
 }
@@ -71,9 +141,9 @@ func (ph *ProxyHandler) WaitForInflightRequests(ctx context.Context) {
 
 	select {
 	case <-done:
-		fmt.Println("All in-flight requests completed")
+		slog.Info("all in-flight requests completed")
 	case <-ctx.Done():
-		fmt.Println("Timeout waiting for in-flight requests to complete")
+		slog.Warn("timeout waiting for in-flight requests to complete")
 	}
 }
 
@@ -85,50 +155,233 @@ func (ph *ProxyHandler) Handle(w http.ResponseWriter, r *http.Request) {
 
 	start := time.Now()
 
-	// Find the appropriate provider
-	var selectedProvider provider.Provider
-	for _, p := range ph.providers {
-		if p.ShouldProxy(r.URL.Path) {
-			selectedProvider = p
-			break
-		}
+	// Record the final status code for metrics
+	recorder := &statusRecorder{ResponseWriter: w}
+	w = recorder
+	providerName := "none"
+	defer func() { ph.metrics.observeRequest(providerName, recorder.status) }()
+
+	// Authenticate the client's certificate, if the listener asks for one
+	clientCert, err := ph.authenticateClientCert(r)
+	if err != nil {
+		writeError(w, ph.errorProvider(r), provider.ErrorTypeAuthentication, err.Error())
+		return
+	}
+	if clientCert != "" {
+		r = r.WithContext(logging.With(r.Context(), "client_cert", clientCert))
+	}
+
+	// Authenticate the client's virtual key, if any
+	virtualKey, err := ph.authenticateVirtualKey(r)
+	if err != nil {
+		writeError(w, ph.errorProvider(r), provider.ErrorTypeAuthentication, err.Error())
+		return
+	}
+
+	// Apply the client's policy override headers, if it may send them
+	overrides, err := ph.parseOverrides(r, virtualKey)
+	if errors.Is(err, errOverridesNotAllowed) {
+		writeError(w, ph.errorProvider(r), provider.ErrorTypePermission, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, ph.errorProvider(r), provider.ErrorTypeInvalidRequest, err.Error())
+		return
+	}
+	if overrides != nil {
+		r = r.WithContext(withOverrides(r.Context(), overrides))
+		slog.InfoContext(r.Context(), "applying request overrides", "overrides", overrides.headers)
+	}
+
+	// Take the client's tags and session off the request
+	tags, session, err := parseTags(r)
+	if err != nil {
+		writeError(w, ph.errorProvider(r), provider.ErrorTypeInvalidRequest, err.Error())
+		return
 	}
 
-	if selectedProvider == nil {
-		http.Error(w, "No provider found for this request", http.StatusBadRequest)
+	// Find the appropriate provider, or the one the client asked for
+	var decision *router.Decision
+	if overrides != nil && overrides.route != "" {
+		decision, err = ph.router.RouteTo(r, overrides.route)
+		if err != nil {
+			writeError(w, nil, provider.ErrorTypeInvalidRequest, fmt.Sprintf("Invalid %s override: %v", RouteOverrideHeader, err))
+			return
+		}
+	} else {
+		decision, err = ph.router.Route(r)
+	}
+	if errors.Is(err, router.ErrNoProvider) {
+		writeError(w, nil, provider.ErrorTypeInvalidRequest, "No provider found for this request")
+		return
+	} else if err != nil {
+		writeError(w, nil, provider.ErrorTypeServerError, fmt.Sprintf("Failed to route request: %v", err))
 		return
 	}
+	selectedProvider := decision.Provider
+	r = r.WithContext(withPolicy(logging.With(r.Context(), "provider", selectedProvider.Name()), decision.Policy()))
+	providerName = selectedProvider.Name()
+
+	ph.gauges.inc(selectedProvider.Name())
+	defer ph.gauges.dec(selectedProvider.Name())
 
 	// Log the incoming request
-	requestID, reqData, err := ph.logRequest(selectedProvider, r)
+	logInput := &database.StoreRequestInput{
+		RouteRule:      decision.RuleName(),
+		RequestedModel: decision.Model,
+		RoutedModel:    decision.RoutedModel(),
+	}
+	if virtualKey != nil {
+		logInput.VirtualKeyID = virtualKey.ID
+	}
+	if overrides != nil {
+		logInput.Overrides = overrides.headers
+	}
+	logInput.Tags, logInput.SessionID = tags, session
+
+	// Look up the recorded response if the provider is played back, or else a
+	// cached one. WebSocket sessions are never recorded for either.
+	upgrade := isWebSocketUpgrade(r)
+	if !upgrade {
+		logInput.Fingerprint = requestFingerprint(r)
+	}
+	recording, missed := ph.findRecording(r.Context(), selectedProvider, logInput.Fingerprint)
+	cacheStatus := ""
+	if recording == nil && !missed {
+		recording, cacheStatus = ph.findCached(r.Context(), w, r, selectedProvider, logInput.Fingerprint)
+	}
+	if recording != nil {
+		logInput.ReplayedFrom = recording.RequestID
+	}
+
+	// Scan for credentials, then wait out a pause and enforce budgets and rate limits before anything is sent upstream
+	rejectionType, rejection := "", ""
+	var retryAfter time.Duration
+	var budget *budgetExceeded
+	logInput.SecretFindings = ph.scanSecrets(r)
+	moderation, moderationErr := ph.moderate(r)
+	logInput.Moderation = moderation
+	risk := ph.scoreRisk(r)
+	if risk != nil {
+		logInput.RiskScore, logInput.RiskRules = &risk.Score, risk.Rules
+	}
+	if len(logInput.SecretFindings) > 0 && ph.secretScanMode == SecretScanBlock {
+		rejectionType, rejection = provider.ErrorTypeContentSensitive, secretsReason(logInput.SecretFindings)
+	} else if moderation != nil && ph.moderationMode == ModerationBlock {
+		rejectionType, rejection = provider.ErrorTypeContentSensitive, moderationReason(moderation)
+	} else if moderationErr != nil && ph.moderationFailClosed {
+		rejectionType, rejection = provider.ErrorTypeServerError, "Request blocked: content moderation is unavailable"
+	} else if risk != nil && risk.high && ph.guardrailsMode == GuardrailsBlock {
+		rejectionType, rejection = provider.ErrorTypeContentSensitive, riskReason(risk)
+	} else if missed {
+		rejectionType, rejection = provider.ErrorTypeNotFound, "No recorded response matches this request"
+	} else if recording != nil {
+		// Played back and cached requests don't reach the provider, so pauses, budgets and rate limits don't apply
+	} else if reason := ph.waitIfPaused(r.Context()); reason != "" {
+		rejectionType, rejection = provider.ErrorTypeUnavailable, reason
+	} else if budget = ph.checkBudgets(virtualKey); budget != nil {
+		rejectionType, rejection = provider.ErrorTypeQuotaExceeded, budget.reason()
+	} else if reason, wait, refund := ph.checkRateLimits(selectedProvider, virtualKey, r); reason != "" {
+		rejectionType, rejection, retryAfter = provider.ErrorTypeRateLimit, reason, wait
+	} else if release, reason := ph.acquireConcurrency(r.Context(), selectedProvider); reason != "" {
+		refund()
+		rejectionType, rejection = provider.ErrorTypeRateLimit, reason
+	} else {
+		defer release()
+	}
+	logInput.RejectionReason = rejection
+
+	requestID, reqData, err := ph.logRequest(selectedProvider, r, logInput)
 	if err != nil {
-		fmt.Printf("Warning: failed to log request: %v\n", err)
+		slog.WarnContext(r.Context(), "failed to log request", "error", err)
 		// Continue anyway, logging failure shouldn't block proxying
 	} else if reqData != nil {
+		r = r.WithContext(logging.With(r.Context(), "request_id", requestID))
+		w.Header().Set(api.RequestIDHeader, requestID)
 		// Emit request created event asynchronously
 		go ph.apiHandler.BroadcastRequestCreated(reqData)
 	}
 
+	if len(logInput.SecretFindings) > 0 {
+		action := "flagged"
+		if ph.secretScanMode == SecretScanBlock {
+			action = "blocked"
+		}
+		slog.WarnContext(r.Context(), "credentials detected in request", "action", action, "findings", len(logInput.SecretFindings))
+		go ph.apiHandler.BroadcastSecretDetected(requestID, providerName, logInput.VirtualKeyID, action, logInput.SecretFindings)
+	}
+	if moderation != nil {
+		action := "flagged"
+		if ph.moderationMode == ModerationBlock {
+			action = "blocked"
+		}
+		slog.WarnContext(r.Context(), "request flagged by content moderation", "action", action, "categories", moderation.Categories)
+		go ph.apiHandler.BroadcastModerationFlagged(requestID, providerName, logInput.VirtualKeyID, action, moderation.Categories)
+	}
+	if risk != nil && risk.high {
+		action := "flagged"
+		if ph.guardrailsMode == GuardrailsBlock {
+			action = "blocked"
+		}
+		slog.WarnContext(r.Context(), "high risk prompt", "action", action, "risk_score", risk.Score, "rules", risk.Rules)
+		go ph.apiHandler.BroadcastHighRiskPrompt(requestID, providerName, logInput.VirtualKeyID, action, risk.Score, risk.Rules)
+	}
+	if budget != nil {
+		go ph.apiHandler.BroadcastBudgetExceeded(requestID, providerName, budget.scope, budget.keyID, budget.period, budget.spent, budget.budget)
+	}
+	if rejection != "" {
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		}
+		ph.rejectRequest(r.Context(), w, selectedProvider, requestID, rejectionType, rejection, start)
+		return
+	}
+
+	if recording != nil {
+		if cacheStatus == cacheStale {
+			ph.revalidate(decision, r, logInput, requestID)
+		}
+		ph.replay(r.Context(), w, recording, cacheStatus, requestID, r.Method, start)
+		return
+	}
+
+	// Send a copy to the mirror provider in the background, if the matched rule mirrors
+	if !upgrade {
+		ph.mirrorRequest(decision, r, logInput, requestID)
+	}
+
+	// Inject faults from the chaos rules before the upstream call
+	var truncateAfter int64
+	if fault := ph.decideFault(selectedProvider, r); fault != nil {
+		if !ph.injectFault(r.Context(), w, selectedProvider, requestID, fault, start) {
+			return
+		}
+		truncateAfter = fault.TruncateAfter
+	}
+
 	// Check if this is a streaming request
-	isStreaming := ph.isStreamingRequest(selectedProvider, r)
+	isStreaming := ph.isStreamingRequest(selectedProvider, decision, r)
 
-	// Prepare the proxy request
-	proxyReq, err := ph.prepareProxyRequest(selectedProvider, r)
+	// Prepare the proxy request, with a pooled gateway-side key if the client sent no credentials
+	r = ph.pickAPIKey(selectedProvider, r)
+	proxyReq, err := ph.prepareProxyRequest(selectedProvider, decision, r)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to prepare request: %v", err), http.StatusBadRequest)
+		writeError(w, selectedProvider, provider.ErrorTypeInvalidRequest, fmt.Sprintf("Failed to prepare request: %v", err))
 		return
 	}
 
 	// Execute the proxy request
-	if isStreaming {
-		ph.handleStreamingResponse(w, selectedProvider, proxyReq, requestID)
+	if upgrade {
+		ph.handleWebSocket(w, selectedProvider, proxyReq, requestID, start)
+	} else if isStreaming {
+		dropEvent := ph.applyStreamUsage(selectedProvider, proxyReq)
+		ph.handleStreamingResponse(w, selectedProvider, proxyReq, requestID, dropEvent, truncateAfter)
 	} else {
 		ph.handleRegularResponse(w, selectedProvider, proxyReq, requestID, start)
 	}
 }
 
 // logErrorResponse logs an error response to the database
-func (ph *ProxyHandler) logErrorResponse(requestID string, err error, start time.Time) (string, error) {
+func (ph *ProxyHandler) logErrorResponse(ctx context.Context, requestID string, err error, start time.Time) (string, error) {
 	duration := int(time.Since(start).Milliseconds())
 
 	respInput := &database.StoreResponseInput{
@@ -143,14 +396,16 @@ func (ph *ProxyHandler) logErrorResponse(requestID string, err error, start time
 
 	responseID, dbErr := ph.db.StoreResponse(respInput)
 	if dbErr != nil {
-		fmt.Printf("Warning: failed to log error response: %v\n", dbErr)
+		slog.WarnContext(ctx, "failed to log error response", "error", dbErr)
+	} else if storedResp, err := ph.db.GetResponse(responseID); err == nil && storedResp != nil {
+		go ph.responseCreated(storedResp)
 	}
 
 	return responseID, nil
 }
 
 // logAbortedResponse logs a response for a request that was aborted due to server shutdown
-func (ph *ProxyHandler) logAbortedResponse(requestID string, start time.Time) (string, error) {
+func (ph *ProxyHandler) logAbortedResponse(ctx context.Context, requestID string, start time.Time) (string, error) {
 	duration := int(time.Since(start).Milliseconds())
 
 	respInput := &database.StoreResponseInput{
@@ -165,13 +420,13 @@ func (ph *ProxyHandler) logAbortedResponse(requestID string, start time.Time) (s
 
 	responseID, dbErr := ph.db.StoreResponse(respInput)
 	if dbErr != nil {
-		fmt.Printf("Warning: failed to log aborted response: %v\n", dbErr)
+		slog.WarnContext(ctx, "failed to log aborted response", "error", dbErr)
 	}
 
 	// Emit response created event
 	storedResp, err := ph.db.GetResponse(responseID)
 	if err == nil && storedResp != nil {
-		go ph.apiHandler.BroadcastResponseCreated(storedResp)
+		go ph.responseCreated(storedResp)
 	}
 
 	return responseID, nil
@@ -205,7 +460,7 @@ func decompressBody(body []byte, contentEncoding string) ([]byte, error) {
 
 	case "deflate", "compress":
 		// These encodings are not supported yet, return original
-		fmt.Printf("Warning: unsupported Content-Encoding: %s, storing compressed\n", contentEncoding)
+		slog.Warn("unsupported Content-Encoding, storing compressed", "encoding", contentEncoding)
 		return body, nil
 
 	case "", "identity":
@@ -218,8 +473,9 @@ func decompressBody(body []byte, contentEncoding string) ([]byte, error) {
 	}
 }
 
-// logRequest logs the incoming request to the database
-func (ph *ProxyHandler) logRequest(prov provider.Provider, r *http.Request) (string, *database.Request, error) {
+// logRequest logs the incoming request to the database. Gateway metadata
+// (route rule, virtual key, ...) is taken from input; the request itself fills the rest.
+func (ph *ProxyHandler) logRequest(prov provider.Provider, r *http.Request, input *database.StoreRequestInput) (string, *database.Request, error) {
 	// Read body
 	bodyBytes, _ := io.ReadAll(r.Body)
 	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
@@ -232,18 +488,33 @@ func (ph *ProxyHandler) logRequest(prov provider.Provider, r *http.Request) (str
 		}
 	}
 
-	input := &database.StoreRequestInput{
-		Provider: prov.Name(),
-		Endpoint: r.URL.Path,
-		Method:   r.Method,
-		Headers:  headers,
-		Body:     string(bodyBytes),
+	input.Provider = prov.Name()
+	input.Endpoint = r.URL.Path
+	input.Query = r.URL.RawQuery
+	input.Method = r.Method
+	input.Headers = headers
+	input.Body = string(bodyBytes)
+	input.ClientIP = clientIP(r)
+	input.ForwardedFor = r.Header.Get("X-Forwarded-For")
+	input.UserAgent = r.UserAgent()
+
+	// Keep multipart and binary bodies as files, with a summary in the request
+	var files []savedFile
+	if contentType := r.Header.Get("Content-Type"); isBinaryBody(contentType, bodyBytes) {
+		input.Payload, files = ph.savePayload(r.Context(), prov.Name(), contentType, bodyBytes)
+		input.Body = ""
 	}
 
 	id, err := ph.db.StoreRequest(input)
 	if err != nil {
+		ph.deleteSaved(files)
 		return "", nil, err
 	}
+	for _, file := range files {
+		if _, err := ph.db.StoreBinaryFile(id, "", file.path, file.contentType, file.size); err != nil {
+			slog.WarnContext(r.Context(), "failed to store request body file reference", "error", err)
+		}
+	}
 
 	// Retrieve the stored request to get its creation time
 	storedReq, err := ph.db.GetRequest(id)
@@ -254,15 +525,31 @@ func (ph *ProxyHandler) logRequest(prov provider.Provider, r *http.Request) (str
 	return id, storedReq, nil
 }
 
+// clientIP returns the address a request came from, without its port.
+// Requests the gateway sends itself have none.
+func clientIP(r *http.Request) string {
+	if r.RemoteAddr == "" {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // prepareProxyRequest prepares the request to be sent to the provider
-func (ph *ProxyHandler) prepareProxyRequest(prov provider.Provider, r *http.Request) (*http.Request, error) {
+func (ph *ProxyHandler) prepareProxyRequest(prov provider.Provider, decision *router.Decision, r *http.Request) (*http.Request, error) {
 	// Read the body
 	bodyBytes, _ := io.ReadAll(r.Body)
 	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
-	// Create new request for the provider
-	targetURL := prov.GetProxyURL(r.URL.RequestURI())
-	proxyReq, err := http.NewRequest(r.Method, targetURL, bytes.NewBuffer(bodyBytes))
+	// Create new request for the provider, using the routed path
+	routedURL := *r.URL
+	routedURL.Path = decision.Path
+	routedURL.RawPath = ""
+	targetURL := prov.GetProxyURL(routedURL.RequestURI())
+	proxyReq, err := http.NewRequestWithContext(r.Context(), r.Method, targetURL, bytes.NewBuffer(bodyBytes))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create proxy request: %w", err)
 	}
@@ -270,6 +557,11 @@ func (ph *ProxyHandler) prepareProxyRequest(prov provider.Provider, r *http.Requ
 	// Copy headers
 	proxyReq.Header = r.Header.Clone()
 
+	// Apply any transform from the matched routing rule
+	if err := decision.ApplyTransform(proxyReq); err != nil {
+		return nil, err
+	}
+
 	// Let provider prepare the request (validate auth, etc.)
 	if err := prov.PrepareRequest(proxyReq); err != nil {
 		return nil, err
@@ -279,8 +571,8 @@ func (ph *ProxyHandler) prepareProxyRequest(prov provider.Provider, r *http.Requ
 }
 
 // isStreamingRequest checks if this request should be streamed
-func (ph *ProxyHandler) isStreamingRequest(prov provider.Provider, r *http.Request) bool {
-	if !prov.IsStreamingEndpoint(r.URL.Path) {
+func (ph *ProxyHandler) isStreamingRequest(prov provider.Provider, decision *router.Decision, r *http.Request) bool {
+	if !prov.IsStreamingEndpoint(decision.Path) {
 		return false
 	}
 
@@ -313,70 +605,160 @@ func (ph *ProxyHandler) handleRegularResponse(
 	start time.Time,
 ) {
 	// Log outgoing request
-	fmt.Printf("[OUT] → %s %s %s\n", prov.Name(), proxyReq.Method, proxyReq.URL.String())
+	ctx := proxyReq.Context()
+	slog.InfoContext(ctx, "forwarding request", "method", proxyReq.Method, "url", proxyReq.URL.String())
 
 	// Apply shutdown context to the request for cancellation on shutdown
 	shutdownCtx := ph.GetShutdownContext()
-	proxyReq = proxyReq.WithContext(shutdownCtx)
-
-	client := &http.Client{}
-	resp, err := client.Do(proxyReq)
+	upstreamCtx, done := ph.watch(withOverridesOf(withAPIKeyOf(logging.CopyAttrs(shutdownCtx, ctx), proxyReq), proxyReq), requestID, prov.Name())
+	defer done()
+	upstreamCtx, deadline, stopTimeouts := ph.withTimeouts(upstreamCtx, prov, false)
+	defer stopTimeouts()
+	proxyReq = proxyReq.WithContext(upstreamCtx)
+
+	upstreamStart := time.Now()
+	resp, err := ph.doUpstream(w, prov, proxyReq, requestID, start)
 	if err != nil {
-		fmt.Printf("Error reaching provider: %v\n", err)
-
 		// Check if this is a context cancellation due to shutdown
 		if shutdownCtx.Err() != nil {
-			fmt.Printf("Request cancelled due to server shutdown\n")
-			ph.logAbortedResponse(requestID, start)
+			slog.WarnContext(ctx, "request cancelled due to server shutdown")
+			ph.logAbortedResponse(ctx, requestID, start)
 			// Don't return error to client since the response may have already been started
 			return
 		}
 
+		if cancelledByWatchdog(upstreamCtx) {
+			ph.logTimeoutResponse(ctx, requestID, start)
+			writeError(w, prov, provider.ErrorTypeTimeout, ph.watchdogMessage())
+			return
+		}
+
+		if timeout := ph.timedOut(upstreamCtx, prov, err); timeout != nil {
+			ph.logUpstreamTimeout(ctx, prov, requestID, timeout, start)
+			writeError(w, prov, provider.ErrorTypeTimeout, timeoutMessage(timeout))
+			return
+		}
+
+		slog.ErrorContext(ctx, "error reaching provider", "error", err)
+
 		// Log error to database
-		ph.logErrorResponse(requestID, err, start)
+		ph.logErrorResponse(ctx, requestID, err, start)
 		// Return error to client
-		http.Error(w, fmt.Sprintf("Failed to reach provider: %v", err), http.StatusBadGateway)
+		writeError(w, prov, provider.ErrorTypeUpstream, fmt.Sprintf("Failed to reach provider: %v", err))
 		return
 	}
 	defer resp.Body.Close()
-
-	// Read response body (may be compressed)
-	respBody, _ := io.ReadAll(resp.Body)
+	ph.metrics.observeUpstream(prov.Name(), time.Since(upstreamStart))
+	ph.observeConcurrency(prov, resp.StatusCode, time.Since(upstreamStart))
+
+	// Read response body (may be compressed). A body past the in-memory
+	// limit is streamed to the client and spooled to disk instead, unless the
+	// response is held for interception.
+	body := deadline.wrap(resp.Body)
+	respBody, large := ph.readBuffered(body)
+	if large && !ph.intercepts(prov) {
+		if spool, err := os.CreateTemp("", "aigw-response-*"); err != nil {
+			slog.WarnContext(ctx, "failed to create spool file, buffering the response", "error", err)
+		} else {
+			ph.spoolResponse(w, ctx, upstreamCtx, prov, proxyReq, resp, io.MultiReader(bytes.NewReader(respBody), body), spool, requestID, start)
+			return
+		}
+	}
+	if large {
+		rest, _ := io.ReadAll(body)
+		respBody = append(respBody, rest...)
+	}
 	duration := int(time.Since(start).Milliseconds())
 
+	if cancelledByWatchdog(upstreamCtx) {
+		ph.logTimeoutResponse(ctx, requestID, start)
+		writeError(w, prov, provider.ErrorTypeTimeout, ph.watchdogMessage())
+		return
+	}
+	if timeout := ph.timedOut(upstreamCtx, prov, nil); timeout != nil {
+		ph.logUpstreamTimeout(ctx, prov, requestID, timeout, start)
+		writeError(w, prov, provider.ErrorTypeTimeout, timeoutMessage(timeout))
+		return
+	}
+
 	// Log response status
-	fmt.Printf("[RESP] ← %s %d (%dms)\n", prov.Name(), resp.StatusCode, duration)
+	slog.InfoContext(ctx, "upstream response", "status", resp.StatusCode, "duration_ms", duration)
 
 	// Decompress body for storage (keep original for client)
 	contentEncoding := resp.Header.Get("Content-Encoding")
 	decompressedBody := respBody
-	if contentEncoding != "" {
+	if contentEncoding != "" && len(respBody) > 0 {
 		var err error
 		decompressedBody, err = decompressBody(respBody, contentEncoding)
 		if err != nil {
-			fmt.Printf("Warning: failed to decompress response: %v, storing compressed\n", err)
+			slog.WarnContext(ctx, "failed to decompress response, storing compressed", "error", err)
 			decompressedBody = respBody
 		}
 	}
 
-	// Check if this is a binary response
-	contentType := resp.Header.Get("Content-Type")
-	isBinary := strings.HasPrefix(contentType, "image/") ||
-		strings.HasPrefix(contentType, "audio/") ||
-		strings.HasPrefix(contentType, "video/")
-
 	// If binary, save to filesystem (use original body for binary data)
 	var binaryFilePath string
 	var binaryFileSize int64
-	if isBinary {
+	contentType := resp.Header.Get("Content-Type")
+	if isBinaryContentType(contentType) {
 		var err error
 		binaryFilePath, binaryFileSize, err = ph.storage.SaveFile(prov.Name(), contentType, bytes.NewBuffer(respBody))
 		if err != nil {
-			fmt.Printf("Warning: failed to save binary file: %v\n", err)
+			slog.WarnContext(ctx, "failed to save binary file", "error", err)
 		}
 	}
 
 	// Log the response (with decompressed body)
+	responseID := ph.storeUpstreamResponse(ctx, prov, proxyReq, resp, requestID, decompressedBody, binaryFilePath, binaryFileSize, duration)
+
+	// Answer tool calls the gateway resolves itself with a follow-up request
+	// instead of returning them to the client
+	if next, nextID := ph.toolFollowUp(ctx, proxyReq, requestID, resp.StatusCode, decompressedBody); next != nil {
+		ph.handleRegularResponse(w, prov, next, nextID, time.Now())
+		return
+	}
+
+	// Hold the response for inspection, and send it as it was edited
+	statusCode, respHeaders := resp.StatusCode, resp.Header
+	if ph.intercepts(prov) {
+		// The upstream call is over: the watchdog mustn't count the hold against it
+		done()
+		edit, ok := ph.holdResponse(ctx, prov, requestID, proxyReq.URL.Path, resp.StatusCode, resp.Header, decompressedBody)
+		if !ok {
+			return
+		}
+		if edit != nil {
+			statusCode, respHeaders, respBody = ph.applyEdit(ctx, requestID, responseID, edit, resp.StatusCode, resp.Header, decompressedBody, start)
+		}
+	}
+
+	// Write response headers
+	for key, values := range respHeaders {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(statusCode)
+
+	// Write response body (204, 304 and HEAD responses carry none)
+	if bodyAllowed(proxyReq.Method, statusCode) {
+		w.Write(respBody)
+	}
+}
+
+// isBinaryContentType reports whether responses of this content type are
+// saved to file storage
+func isBinaryContentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/") ||
+		strings.HasPrefix(contentType, "audio/") ||
+		strings.HasPrefix(contentType, "video/")
+}
+
+// storeUpstreamResponse logs a non-streamed upstream response with its
+// decompressed body and binary file, if it was saved, then runs the
+// provider's post-response processing in the background. It returns the
+// stored response's ID, or empty if it couldn't be stored.
+func (ph *ProxyHandler) storeUpstreamResponse(ctx context.Context, prov provider.Provider, proxyReq *http.Request, resp *http.Response, requestID string, body []byte, binaryFilePath string, binaryFileSize int64, duration int) string {
 	headers := make(map[string]string)
 	for key, values := range resp.Header {
 		if len(values) > 0 {
@@ -388,46 +770,49 @@ func (ph *ProxyHandler) handleRegularResponse(
 		RequestID:  requestID,
 		StatusCode: resp.StatusCode,
 		Headers:    headers,
-		Body:       string(decompressedBody),
+		Body:       string(body),
 		DurationMs: duration,
 	}
+	ph.recordUsage(prov, respInput)
+	ph.recordWarnings(ctx, prov, respInput)
 
 	responseID, err := ph.db.StoreResponse(respInput)
 	if err != nil {
-		fmt.Printf("Warning: failed to log response: %v\n", err)
-	} else {
-		// Update binary file reference with request ID
-		if binaryFilePath != "" {
-			_, err := ph.db.StoreBinaryFile(requestID, responseID, binaryFilePath, contentType, binaryFileSize)
-			if err != nil {
-				fmt.Printf("Warning: failed to store binary file reference: %v\n", err)
-			}
-		}
-
-		// Call provider's post-response processing asynchronously
-		go func() {
-			if err := prov.ProcessResponse(string(decompressedBody), requestID, responseID, ph.storage, ph.db); err != nil {
-				fmt.Printf("Warning: provider post-response processing failed: %v\n", err)
-			}
-
-			// Emit response created event
-			storedResp, err := ph.db.GetResponse(responseID)
-			if err == nil && storedResp != nil {
-				ph.apiHandler.BroadcastResponseCreated(storedResp)
-			}
-		}()
+		slog.WarnContext(ctx, "failed to log response", "error", err)
+		return ""
 	}
 
-	// Write response headers
-	for key, values := range resp.Header {
-		for _, value := range values {
-			w.Header().Add(key, value)
+	// Update binary file reference with request ID
+	if binaryFilePath != "" {
+		_, err := ph.db.StoreBinaryFile(requestID, responseID, binaryFilePath, resp.Header.Get("Content-Type"), binaryFileSize)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to store binary file reference", "error", err)
 		}
 	}
-	w.WriteHeader(resp.StatusCode)
 
-	// Write response body
-	w.Write(respBody)
+	// Call provider's post-response processing asynchronously
+	go func() {
+		// Outputs of a prediction still running are stored once it completes
+		following := len(body) > 0 && ph.followPrediction(ctx, prov, proxyReq, requestID, responseID, resp.StatusCode, body)
+		if len(body) > 0 && !following {
+			if err := prov.ProcessResponse(string(body), requestID, responseID, ph.storage, ph.db); err != nil {
+				slog.WarnContext(ctx, "provider post-response processing failed", "error", err)
+			}
+		}
+		ph.trackFineTune(ctx, prov, proxyReq, requestID, resp.StatusCode, body)
+
+		// Emit response created event; sampling of a followed prediction is
+		// settled once its outputs are stored
+		storedResp, err := ph.db.GetResponse(responseID)
+		if err == nil && storedResp != nil {
+			if following {
+				ph.announceResponse(storedResp)
+			} else {
+				ph.responseCreated(storedResp)
+			}
+		}
+	}()
+	return responseID
 }
 
 // handleStreamingResponse handles server-sent event streaming responses
@@ -436,36 +821,73 @@ func (ph *ProxyHandler) handleStreamingResponse(
 	prov provider.Provider,
 	proxyReq *http.Request,
 	requestID string,
+	dropEvent func(data []byte) bool,
+	truncateAfter int64,
 ) {
 	start := time.Now()
 
 	// Log outgoing request
-	fmt.Printf("[OUT] → %s %s %s\n", prov.Name(), proxyReq.Method, proxyReq.URL.String())
+	ctx := proxyReq.Context()
+	slog.InfoContext(ctx, "forwarding request", "method", proxyReq.Method, "url", proxyReq.URL.String())
 
-	// Apply shutdown context to the request for cancellation on shutdown
+	// Apply shutdown context to the request for cancellation on shutdown, and
+	// cancel it too when the client goes away
 	shutdownCtx := ph.GetShutdownContext()
-	proxyReq = proxyReq.WithContext(shutdownCtx)
-
-	client := &http.Client{}
-	resp, err := client.Do(proxyReq)
+	upstreamCtx, done := ph.watch(withOverridesOf(withAPIKeyOf(logging.CopyAttrs(shutdownCtx, ctx), proxyReq), proxyReq), requestID, prov.Name())
+	defer done()
+	upstreamCtx, deadline, stopTimeouts := ph.withTimeouts(upstreamCtx, prov, true)
+	defer stopTimeouts()
+	upstreamCtx, cancelUpstream, stopCancel := cancelOnDisconnect(upstreamCtx, ctx)
+	defer stopCancel()
+	proxyReq = proxyReq.WithContext(upstreamCtx)
+
+	upstreamStart := time.Now()
+	resp, err := ph.doUpstream(w, prov, proxyReq, requestID, start)
 	if err != nil {
-		fmt.Printf("Error reaching provider: %v\n", err)
-
 		// Check if this is a context cancellation due to shutdown
 		if shutdownCtx.Err() != nil {
-			fmt.Printf("Request cancelled due to server shutdown\n")
-			ph.logAbortedResponse(requestID, start)
+			slog.WarnContext(ctx, "request cancelled due to server shutdown")
+			ph.logAbortedResponse(ctx, requestID, start)
 			// Don't return error to client since the response may have already been started
 			return
 		}
 
+		if cancelledByWatchdog(upstreamCtx) {
+			ph.logTimeoutResponse(ctx, requestID, start)
+			writeError(w, prov, provider.ErrorTypeTimeout, ph.watchdogMessage())
+			return
+		}
+
+		if timeout := ph.timedOut(upstreamCtx, prov, err); timeout != nil {
+			ph.logUpstreamTimeout(ctx, prov, requestID, timeout, start)
+			writeError(w, prov, provider.ErrorTypeTimeout, timeoutMessage(timeout))
+			return
+		}
+
+		if cancelledByClient(upstreamCtx) {
+			slog.InfoContext(ctx, "client disconnected before the upstream response")
+			ph.logCancelledResponse(ctx, requestID, start)
+			return
+		}
+
+		slog.ErrorContext(ctx, "error reaching provider", "error", err)
+
 		// Log error to database
-		ph.logErrorResponse(requestID, err, start)
+		ph.logErrorResponse(ctx, requestID, err, start)
 		// Return error to client
-		http.Error(w, fmt.Sprintf("Failed to reach provider: %v", err), http.StatusBadGateway)
+		writeError(w, prov, provider.ErrorTypeUpstream, fmt.Sprintf("Failed to reach provider: %v", err))
 		return
 	}
 	defer resp.Body.Close()
+	ph.metrics.observeUpstream(prov.Name(), time.Since(upstreamStart))
+	ph.observeConcurrency(prov, resp.StatusCode, time.Since(upstreamStart))
+
+	// Use flusher to ensure data is sent immediately
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, prov, provider.ErrorTypeServerError, "Streaming not supported")
+		return
+	}
 
 	// Set up response headers for streaming
 	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
@@ -483,34 +905,51 @@ func (ph *ProxyHandler) handleStreamingResponse(
 
 	w.WriteHeader(resp.StatusCode)
 
-	// Stream the response while capturing it
+	// Stream the response while capturing it (and exporting and recording chunks, if enabled and readable)
 	var bufferedResponse bytes.Buffer
-	reader := io.TeeReader(resp.Body, &bufferedResponse)
-
-	// Use flusher to ensure data is sent immediately
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming not supported", http.StatusBadRequest)
-		return
+	var upstream io.Reader = deadline.wrap(resp.Body)
+	var truncated *truncatedReader
+	if truncateAfter > 0 {
+		truncated = &truncatedReader{r: upstream, remaining: truncateAfter}
+		upstream = truncated
+	}
+	body := &firstByteReader{r: upstream}
+	reader := io.TeeReader(body, &bufferedResponse)
+	if chunks := ph.newChunkExporter(requestID, prov.Name()); chunks != nil && resp.Header.Get("Content-Encoding") == "" {
+		reader = io.TeeReader(reader, chunks)
+	}
+	recorder := ph.newChunkRecorder(resp.Header.Get("Content-Type"), start)
+	if recorder != nil && resp.Header.Get("Content-Encoding") == "" {
+		reader = io.TeeReader(reader, recorder)
+	} else {
+		recorder = nil
 	}
 
-	// Copy the streaming data
-	_, _ = io.Copy(w, reader)
+	// Copy the streaming data, filtering events when requested (only possible
+	// uncompressed). A failed write means the client is gone: stop reading upstream.
+	client := &clientWriter{w: w, onError: cancelUpstream}
+	if dropEvent != nil && resp.Header.Get("Content-Encoding") == "" {
+		filter := newSSEFilterWriter(client, flusher, dropEvent)
+		_, _ = io.Copy(filter, reader)
+		filter.Close()
+	} else {
+		_, _ = io.Copy(client, reader)
+	}
 	flusher.Flush()
 
 	// Log the response
 	duration := int(time.Since(start).Milliseconds())
 
 	// Log response status
-	fmt.Printf("[RESP] ← %s %d (%dms)\n", prov.Name(), resp.StatusCode, duration)
+	slog.InfoContext(ctx, "upstream response", "status", resp.StatusCode, "duration_ms", duration)
 
 	// Decompress body for storage (keep original for client)
 	contentEncoding := resp.Header.Get("Content-Encoding")
 	storedBody := bufferedResponse.String()
-	if contentEncoding != "" {
+	if contentEncoding != "" && bufferedResponse.Len() > 0 {
 		decompressedBody, err := decompressBody(bufferedResponse.Bytes(), contentEncoding)
 		if err != nil {
-			fmt.Printf("Warning: failed to decompress streaming response: %v, storing compressed\n", err)
+			slog.WarnContext(ctx, "failed to decompress streaming response, storing compressed", "error", err)
 		} else {
 			storedBody = string(decompressedBody)
 		}
@@ -530,16 +969,49 @@ func (ph *ProxyHandler) handleStreamingResponse(
 		Body:       storedBody,
 		DurationMs: duration,
 	}
+	if cancelledByWatchdog(upstreamCtx) {
+		// The client already has the headers, so the stream just ends
+		respInput.IsError = true
+		respInput.ErrorMessage = ph.watchdogMessage()
+		respInput.Timeout = timeoutWatchdog
+	} else if timeout := ph.timedOut(upstreamCtx, prov, nil); timeout != nil {
+		slog.WarnContext(ctx, "upstream stream timed out", "timeout", timeout.kind, "after", timeout.after.String(), "received_bytes", bufferedResponse.Len())
+		ph.metrics.observeTimeout(prov.Name(), timeout.kind)
+		respInput.IsError = true
+		respInput.ErrorMessage = timeout.Error()
+		respInput.Timeout = timeout.kind
+	} else if cancelledByClient(upstreamCtx) {
+		slog.InfoContext(ctx, "client disconnected during the stream", "received_bytes", bufferedResponse.Len())
+		respInput.IsError = true
+		respInput.ErrorMessage = clientCancelledMessage(bufferedResponse.Len())
+		respInput.Cancelled = true
+	} else if truncated != nil && truncated.truncated() {
+		slog.WarnContext(ctx, "chaos: stream truncated", "after_bytes", truncateAfter)
+		ph.metrics.observeChaos(prov.Name(), "truncate")
+		respInput.IsError = true
+		respInput.ErrorMessage = fmt.Sprintf("chaos: stream truncated after %d bytes", truncateAfter)
+	}
+	if ttft, ok := body.timeToFirstByte(upstreamStart); ok && resp.StatusCode < 400 {
+		ttftMs := int(ttft.Milliseconds())
+		respInput.TTFTMs = &ttftMs
+		ph.metrics.observeTTFT(prov.Name(), ttft)
+	}
+	ph.recordUsage(prov, respInput)
+	ph.recordWarnings(ctx, prov, respInput)
+	recordStream(ctx, prov, respInput)
 
 	responseID, err := ph.db.StoreResponse(respInput)
 	if err != nil {
-		fmt.Printf("Warning: failed to log streaming response: %v\n", err)
+		slog.WarnContext(ctx, "failed to log streaming response", "error", err)
 	} else {
+		if recorder != nil {
+			recorder.store(ctx, ph.db, requestID, responseID)
+		}
 		// Emit response created event asynchronously
 		go func() {
 			storedResp, err := ph.db.GetResponse(responseID)
 			if err == nil && storedResp != nil {
-				ph.apiHandler.BroadcastResponseCreated(storedResp)
+				ph.responseCreated(storedResp)
 			}
 		}()
 	}
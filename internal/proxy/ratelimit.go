@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+	"github.com/ruqqq/simple-ai-gateway/internal/ratelimit"
+)
+
+// SetRateLimits configures per-provider limits and the default per-virtual-key
+// limits. Keys can override the default with their own rpm_limit/tpm_limit.
+func (ph *ProxyHandler) SetRateLimits(providerLimits map[string]ratelimit.Limits, defaultKeyLimits ratelimit.Limits) {
+	ph.rateLimiter = ratelimit.New()
+	ph.providerLimits = providerLimits
+	ph.defaultKeyLimits = defaultKeyLimits
+}
+
+// checkRateLimits charges the request against the virtual key and provider
+// limits. It returns a rejection reason and retry delay if a limit is
+// exhausted, in which case nothing is charged, and otherwise a function that
+// refunds the charges if the request is rejected later on.
+func (ph *ProxyHandler) checkRateLimits(prov provider.Provider, virtualKey *database.VirtualKey, r *http.Request) (string, time.Duration, func()) {
+	if ph.rateLimiter == nil {
+		return "", 0, func() {}
+	}
+
+	bodyBytes, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	tokens := ratelimit.EstimateTokens(bodyBytes)
+
+	refundKey := func() {}
+	if virtualKey != nil {
+		limits := ph.keyLimits(virtualKey)
+		if ok, wait, limit := ph.rateLimiter.Allow("key:"+virtualKey.ID, limits, tokens); !ok {
+			return fmt.Sprintf("Rate limit exceeded for key %s (%s)", virtualKey.Name, limit), wait, nil
+		}
+		refundKey = func() { ph.rateLimiter.Refund("key:"+virtualKey.ID, limits, tokens) }
+	}
+
+	refundProvider := func() {}
+	if limits, ok := ph.providerLimits[prov.Name()]; ok {
+		if ok, wait, limit := ph.rateLimiter.Allow("provider:"+prov.Name(), limits, tokens); !ok {
+			refundKey()
+			return fmt.Sprintf("Rate limit exceeded for provider %s (%s)", prov.Name(), limit), wait, nil
+		}
+		refundProvider = func() { ph.rateLimiter.Refund("provider:"+prov.Name(), limits, tokens) }
+	}
+
+	return "", 0, func() {
+		refundKey()
+		refundProvider()
+	}
+}
+
+// keyLimits returns the effective limits of a virtual key
+func (ph *ProxyHandler) keyLimits(key *database.VirtualKey) ratelimit.Limits {
+	limits := ph.defaultKeyLimits
+	if key.RPMLimit != nil {
+		limits.RPM = *key.RPMLimit
+	}
+	if key.TPMLimit != nil {
+		limits.TPM = *key.TPMLimit
+	}
+	return limits
+}
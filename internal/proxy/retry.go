@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// RetryPolicy configures retrying failed upstream attempts
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts per request including the first; 1 disables retries
+	Backoff     time.Duration // Delay before the first retry, doubled for each further one
+	MaxBackoff  time.Duration // Longest delay, also the longest Retry-After honored
+	Statuses    []int         // Response status codes that are retried
+}
+
+// DefaultRetryStatuses are the status codes retried unless configured otherwise
+var DefaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// SetRetryPolicy enables retrying connection errors and retryable statuses
+func (ph *ProxyHandler) SetRetryPolicy(policy RetryPolicy) {
+	if len(policy.Statuses) == 0 {
+		policy.Statuses = DefaultRetryStatuses
+	}
+	ph.retry = &policy
+}
+
+// retryable reports whether a response status should be retried
+func (p *RetryPolicy) retryable(statusCode int) bool {
+	for _, s := range p.Statuses {
+		if s == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait before retry number n (starting at 1), with
+// jitter. A Retry-After header takes precedence; ok is false if it asks for
+// longer than MaxBackoff, in which case the response is returned as-is.
+func (p *RetryPolicy) delay(n int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if after, found := parseRetryAfter(resp.Header.Get("Retry-After")); found {
+			return after, after <= p.MaxBackoff
+		}
+	}
+
+	d := p.Backoff << (n - 1)
+	if d > p.MaxBackoff || d <= 0 {
+		d = p.MaxBackoff
+	}
+	// Between half and the full delay, so concurrent retries spread out
+	return d/2 + rand.N(d/2+1), true
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// waitForRetry sleeps for d, returning false if ctx ends first
+func waitForRetry(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// rewindBody gives the request a fresh copy of its body for another attempt
+func rewindBody(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay body for retry: %w", err)
+	}
+	next := req.Clone(req.Context())
+	next.Body = body
+	return next, nil
+}
+
+// logFailedAttempt stores an attempt that failed without a response against the request
+func (ph *ProxyHandler) logFailedAttempt(ctx context.Context, requestID string, attempt int, err error, start time.Time) {
+	if requestID == "" {
+		return
+	}
+
+	_, dbErr := ph.db.StoreResponse(&database.StoreResponseInput{
+		RequestID:    requestID,
+		StatusCode:   http.StatusBadGateway,
+		Headers:      make(map[string]string),
+		DurationMs:   int(time.Since(start).Milliseconds()),
+		IsError:      true,
+		ErrorMessage: fmt.Sprintf("Attempt %d failed: %v", attempt, err),
+	})
+	if dbErr != nil {
+		slog.WarnContext(ctx, "failed to log failed attempt", "error", dbErr)
+	}
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/guardrail"
+)
+
+// Secret scanning modes
+const (
+	SecretScanOff   = "off"
+	SecretScanFlag  = "flag"  // record findings and forward the request
+	SecretScanBlock = "block" // record findings and reject the request
+)
+
+// SetSecretScanning sets how requests containing credentials are handled
+func (ph *ProxyHandler) SetSecretScanning(mode string) {
+	ph.secretScanMode = mode
+}
+
+// scanSecrets returns the credentials found in the request body, if scanning is enabled
+func (ph *ProxyHandler) scanSecrets(r *http.Request) []database.SecretFinding {
+	if ph.secretScanMode != SecretScanFlag && ph.secretScanMode != SecretScanBlock {
+		return nil
+	}
+
+	bodyBytes, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	var findings []database.SecretFinding
+	for _, f := range guardrail.ScanSecrets(bodyBytes) {
+		findings = append(findings, database.SecretFinding{Rule: f.Rule, Match: f.Match})
+	}
+	return findings
+}
+
+// secretsReason is the rejection message for a request blocked by the secret scanner
+func secretsReason(findings []database.SecretFinding) string {
+	rules := make([]string, 0, len(findings))
+	seen := make(map[string]bool)
+	for _, f := range findings {
+		if !seen[f.Rule] {
+			seen[f.Rule] = true
+			rules = append(rules, f.Rule)
+		}
+	}
+	return fmt.Sprintf("Request blocked: prompt contains credentials (%s)", strings.Join(rules, ", "))
+}
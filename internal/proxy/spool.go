@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// SetMaxBufferedBody sets the largest non-streamed response body held in
+// memory. Larger bodies are streamed through to the client and spooled to a
+// temporary file to be stored; 0 buffers every body.
+func (ph *ProxyHandler) SetMaxBufferedBody(size int64) {
+	ph.maxBufferedBody = size
+}
+
+// readBuffered reads a response body up to the in-memory limit. It reports
+// whether the body is larger, in which case the rest is left unread.
+func (ph *ProxyHandler) readBuffered(body io.Reader) ([]byte, bool) {
+	if ph.maxBufferedBody <= 0 {
+		data, _ := io.ReadAll(body)
+		return data, false
+	}
+	data, _ := io.ReadAll(io.LimitReader(body, ph.maxBufferedBody+1))
+	return data, int64(len(data)) > ph.maxBufferedBody
+}
+
+// spoolWriter writes a response body to the spool file and the client. A
+// client that goes away doesn't stop the body from being spooled.
+type spoolWriter struct {
+	file      *os.File
+	client    http.ResponseWriter
+	clientErr error
+}
+
+func (s *spoolWriter) Write(p []byte) (int, error) {
+	n, err := s.file.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if s.clientErr == nil {
+		if _, s.clientErr = s.client.Write(p); s.clientErr == nil {
+			if flusher, ok := s.client.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		}
+	}
+	return n, nil
+}
+
+// spoolResponse sends a large non-streamed response to the client as it
+// arrives, copying it to spool, then stores it from there: binary bodies as a
+// file, others as the response body. Tool calls in it aren't answered by the
+// gateway. spool is removed when done.
+func (ph *ProxyHandler) spoolResponse(w http.ResponseWriter, ctx, upstreamCtx context.Context, prov provider.Provider, proxyReq *http.Request, resp *http.Response, body io.Reader, spool *os.File, requestID string, start time.Time) {
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	out := &spoolWriter{file: spool, client: w}
+	size, err := io.Copy(out, body)
+	duration := int(time.Since(start).Milliseconds())
+
+	// The client already has the status and part of the body: a failed
+	// upstream read can only cut it short
+	if cancelledByWatchdog(upstreamCtx) {
+		ph.logTimeoutResponse(ctx, requestID, start)
+		return
+	}
+	if timeout := ph.timedOut(upstreamCtx, prov, nil); timeout != nil {
+		ph.logUpstreamTimeout(ctx, prov, requestID, timeout, start)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "error reading large upstream response", "error", err)
+		ph.logErrorResponse(ctx, requestID, err, start)
+		return
+	}
+	if out.clientErr != nil {
+		slog.InfoContext(ctx, "client went away while receiving a large response", "error", out.clientErr)
+	}
+	slog.InfoContext(ctx, "upstream response", "status", resp.StatusCode, "duration_ms", duration, "spooled_bytes", size)
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		slog.WarnContext(ctx, "failed to read spooled response", "error", err)
+		ph.storeUpstreamResponse(ctx, prov, proxyReq, resp, requestID, nil, "", 0, duration)
+		return
+	}
+
+	// Binary bodies are only kept as a file
+	contentType := resp.Header.Get("Content-Type")
+	if isBinaryContentType(contentType) {
+		filePath, fileSize, err := ph.storage.SaveFile(prov.Name(), contentType, spool)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to save binary file", "error", err)
+		}
+		ph.storeUpstreamResponse(ctx, prov, proxyReq, resp, requestID, nil, filePath, fileSize, duration)
+		return
+	}
+
+	respBody, err := io.ReadAll(spool)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to read spooled response", "error", err)
+	}
+	if contentEncoding := resp.Header.Get("Content-Encoding"); contentEncoding != "" && len(respBody) > 0 {
+		decompressed, err := decompressBody(respBody, contentEncoding)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to decompress response, storing compressed", "error", err)
+		} else {
+			respBody = decompressed
+		}
+	}
+	ph.storeUpstreamResponse(ctx, prov, proxyReq, resp, requestID, respBody, "", 0, duration)
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// sseFilterWriter forwards server-sent events to the client, dropping events
+// whose data payload matches drop. Complete events are flushed as they arrive.
+type sseFilterWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+	drop    func(data []byte) bool
+	buf     bytes.Buffer
+}
+
+func newSSEFilterWriter(w io.Writer, flusher http.Flusher, drop func(data []byte) bool) *sseFilterWriter {
+	return &sseFilterWriter{w: w, flusher: flusher, drop: drop}
+}
+
+// Write buffers p and forwards every complete event it contains
+func (f *sseFilterWriter) Write(p []byte) (int, error) {
+	f.buf.Write(p)
+
+	for {
+		end := sseEventEnd(f.buf.Bytes())
+		if end < 0 {
+			break
+		}
+
+		event := f.buf.Next(end)
+		if f.shouldDrop(event) {
+			continue
+		}
+		if _, err := f.w.Write(event); err != nil {
+			return 0, err
+		}
+		f.flusher.Flush()
+	}
+
+	return len(p), nil
+}
+
+// sseEventEnd returns the length of the first complete event in b, up to and
+// including the blank line that ends it, or -1 if it hasn't ended yet. Lines
+// may end in "\n" or "\r\n".
+func sseEventEnd(b []byte) int {
+	lf := bytes.Index(b, []byte("\n\n"))
+	crlf := bytes.Index(b, []byte("\n\r\n"))
+	switch {
+	case lf < 0 && crlf < 0:
+		return -1
+	case crlf < 0 || (lf >= 0 && lf < crlf):
+		return lf + 2
+	default:
+		return crlf + 3
+	}
+}
+
+// Close forwards any trailing partial event
+func (f *sseFilterWriter) Close() error {
+	if f.buf.Len() == 0 {
+		return nil
+	}
+	_, err := f.w.Write(f.buf.Bytes())
+	f.buf.Reset()
+	f.flusher.Flush()
+	return err
+}
+
+// shouldDrop reports whether any data line of the event matches the drop filter
+func (f *sseFilterWriter) shouldDrop(event []byte) bool {
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		if f.drop(bytes.TrimSpace(data)) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordStream fills in the message and finish reason a stored stream
+// assembles to, so they can be read without replaying the chunks
+func recordStream(ctx context.Context, prov provider.Provider, input *database.StoreResponseInput) {
+	result, ok := provider.ReconstructStream(prov, input.Body)
+	if !ok {
+		return
+	}
+	message, err := json.Marshal(result.Message)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to marshal assembled stream message", "error", err)
+		return
+	}
+	input.Message = string(message)
+	input.FinishReason = result.FinishReason
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// Request headers clients label their requests with
+const (
+	TagHeader     = "X-AIGW-Tag"     // Comma-separated tags; may be repeated
+	SessionHeader = "X-AIGW-Session" // Session the request belongs to
+)
+
+// maxSessionLength is the longest session ID accepted, in bytes
+const maxSessionLength = 200
+
+// parseTags extracts the tag and session headers from the request. They are
+// removed so they are neither stored with the headers nor forwarded upstream.
+func parseTags(r *http.Request) ([]string, string, error) {
+	var tags []string
+	for _, value := range r.Header.Values(TagHeader) {
+		tags = append(tags, strings.Split(value, ",")...)
+	}
+	session := strings.TrimSpace(r.Header.Get(SessionHeader))
+	r.Header.Del(TagHeader)
+	r.Header.Del(SessionHeader)
+
+	tags, err := database.NormalizeTags(tags)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid %s: %w", TagHeader, err)
+	}
+	if len(session) > maxSessionLength {
+		return nil, "", fmt.Errorf("invalid %s: longer than %d bytes", SessionHeader, maxSessionLength)
+	}
+	return tags, session, nil
+}
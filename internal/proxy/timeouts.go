@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// Kinds of upstream timeouts, stored in responses.timeout
+const (
+	timeoutConnect  = "connect"
+	timeoutRead     = "read"
+	timeoutTotal    = "total"
+	timeoutWatchdog = "watchdog"
+)
+
+// Timeouts limit calls to a provider (0 = no limit). Streaming requests use
+// StreamRead and StreamTotal instead of Read and Total.
+type Timeouts struct {
+	Connect     time.Duration // Connecting, including the TLS handshake
+	Read        time.Duration // Waiting for the response headers, or between reads of the body
+	Total       time.Duration // The whole call, including retries and reading the body
+	StreamRead  time.Duration
+	StreamTotal time.Duration
+}
+
+// upstreamTimeout is the cancellation cause of calls that ran into a timeout
+type upstreamTimeout struct {
+	kind  string
+	after time.Duration
+}
+
+func (e *upstreamTimeout) Error() string {
+	return fmt.Sprintf("upstream %s timeout after %s", e.kind, e.after)
+}
+
+// SetTimeouts sets the upstream timeouts per provider
+func (ph *ProxyHandler) SetTimeouts(timeouts map[string]Timeouts) {
+	ph.timeouts = timeouts
+}
+
+// readDeadline cancels a call when the provider sends nothing for too long:
+// no response headers, or no body data, within the read timeout
+type readDeadline struct {
+	mu    sync.Mutex
+	timer *time.Timer
+	after time.Duration
+}
+
+// extend restarts the wait, after progress was made
+func (d *readDeadline) extend() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Reset(d.after)
+	}
+}
+
+func (d *readDeadline) stop() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
+
+// wrap returns body with each read that returns data extending the deadline
+func (d *readDeadline) wrap(body io.ReadCloser) io.ReadCloser {
+	if d == nil {
+		return body
+	}
+	return &deadlineReader{ReadCloser: body, deadline: d}
+}
+
+type deadlineReader struct {
+	io.ReadCloser
+	deadline *readDeadline
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.deadline.extend()
+	}
+	return n, err
+}
+
+// withTimeouts applies the read and total timeouts of prov, or those of the
+// routing target's policy, to an upstream call. The returned deadline must
+// wrap the response body so reads keep the call alive; stop releases the
+// timers.
+func (ph *ProxyHandler) withTimeouts(ctx context.Context, prov provider.Provider, streaming bool) (context.Context, *readDeadline, func()) {
+	t := policyTimeouts(ph.timeouts[prov.Name()], policyOf(ctx))
+	read, total := t.Read, t.Total
+	if streaming {
+		read, total = t.StreamRead, t.StreamTotal
+	}
+	if read <= 0 && total <= 0 {
+		return ctx, nil, func() {}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	var totalTimer *time.Timer
+	if total > 0 {
+		totalTimer = time.AfterFunc(total, func() { cancel(&upstreamTimeout{kind: timeoutTotal, after: total}) })
+	}
+	var deadline *readDeadline
+	if read > 0 {
+		deadline = &readDeadline{after: read}
+		deadline.timer = time.AfterFunc(read, func() { cancel(&upstreamTimeout{kind: timeoutRead, after: read}) })
+		// The wait for the response starts once the request is sent, and
+		// again for each retried attempt
+		ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			WroteRequest:         func(httptrace.WroteRequestInfo) { deadline.extend() },
+			GotFirstResponseByte: deadline.extend,
+		})
+	}
+
+	return ctx, deadline, func() {
+		if totalTimer != nil {
+			totalTimer.Stop()
+		}
+		deadline.stop()
+		cancel(nil)
+	}
+}
+
+// timedOut returns the upstream timeout that ended a call, if any: the
+// cancellation cause of ctx, or a connect timeout reported by the transport
+func (ph *ProxyHandler) timedOut(ctx context.Context, prov provider.Provider, err error) *upstreamTimeout {
+	var timeout *upstreamTimeout
+	if errors.As(context.Cause(ctx), &timeout) {
+		return timeout
+	}
+	var netErr net.Error
+	if err != nil && ctx.Err() == nil && errors.As(err, &netErr) && netErr.Timeout() {
+		return &upstreamTimeout{kind: timeoutConnect, after: ph.timeouts[prov.Name()].Connect}
+	}
+	return nil
+}
+
+// timeoutMessage is the error message clients get for a timed out call
+func timeoutMessage(timeout *upstreamTimeout) string {
+	return fmt.Sprintf("Provider did not respond in time (%v)", timeout)
+}
+
+// logUpstreamTimeout stores the outcome of a call that timed out before its
+// response arrived
+func (ph *ProxyHandler) logUpstreamTimeout(ctx context.Context, prov provider.Provider, requestID string, timeout *upstreamTimeout, start time.Time) {
+	slog.WarnContext(ctx, "upstream call timed out", "timeout", timeout.kind, "after", timeout.after.String())
+	ph.metrics.observeTimeout(prov.Name(), timeout.kind)
+
+	responseID, err := ph.db.StoreResponse(&database.StoreResponseInput{
+		RequestID:    requestID,
+		StatusCode:   http.StatusGatewayTimeout,
+		Headers:      make(map[string]string),
+		DurationMs:   int(time.Since(start).Milliseconds()),
+		IsError:      true,
+		ErrorMessage: timeout.Error(),
+		Timeout:      timeout.kind,
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to log timeout response", "error", err)
+		return
+	}
+
+	go func() {
+		storedResp, err := ph.db.GetResponse(responseID)
+		if err == nil && storedResp != nil {
+			ph.responseCreated(storedResp)
+		}
+	}()
+}
@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/tools"
+)
+
+// toolRoundKey carries how many follow-ups the gateway already sent for a client request
+type toolRoundKey struct{}
+
+// toolCall is a function call in a chat completion message
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// SetTools resolves model calls to these tools at the gateway. Up to
+// maxRounds follow-up requests are sent per client request.
+func (ph *ProxyHandler) SetTools(set tools.Set, maxRounds int) {
+	ph.tools = set
+	ph.toolMaxRounds = maxRounds
+}
+
+// toolFollowUp resolves the tool calls of a chat completion when every call is
+// to a gateway tool. It stores and returns the follow-up request that hands
+// the results back to the model, or nil if the response goes to the client.
+func (ph *ProxyHandler) toolFollowUp(ctx context.Context, proxyReq *http.Request, requestID string, statusCode int, body []byte) (*http.Request, string) {
+	if len(ph.tools) == 0 || statusCode != http.StatusOK || proxyReq.Method != http.MethodPost ||
+		!strings.HasSuffix(proxyReq.URL.Path, "/chat/completions") {
+		return nil, ""
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message json.RawMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &completion); err != nil || len(completion.Choices) != 1 {
+		return nil, ""
+	}
+	var message struct {
+		ToolCalls []toolCall `json:"tool_calls"`
+	}
+	if err := json.Unmarshal(completion.Choices[0].Message, &message); err != nil || len(message.ToolCalls) == 0 {
+		return nil, ""
+	}
+	names := make([]string, 0, len(message.ToolCalls))
+	for _, call := range message.ToolCalls {
+		if ph.tools[call.Function.Name] == nil {
+			// The client has to answer this call, so it gets the whole response
+			return nil, ""
+		}
+		names = append(names, call.Function.Name)
+	}
+
+	round, _ := ctx.Value(toolRoundKey{}).(int)
+	if round >= ph.toolMaxRounds {
+		slog.WarnContext(ctx, "tool call rounds exhausted, returning tool calls to client", "rounds", round)
+		return nil, ""
+	}
+
+	// Continue the conversation: the model's message, then a result per call
+	if proxyReq.GetBody == nil {
+		return nil, ""
+	}
+	requestBody, err := proxyReq.GetBody()
+	if err != nil {
+		return nil, ""
+	}
+	data, _ := io.ReadAll(requestBody)
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, ""
+	}
+	var messages []json.RawMessage
+	if err := json.Unmarshal(request["messages"], &messages); err != nil {
+		return nil, ""
+	}
+	messages = append(messages, completion.Choices[0].Message)
+	for _, call := range message.ToolCalls {
+		result, err := ph.tools[call.Function.Name].Call(ctx, call.Function.Arguments)
+		if err != nil {
+			slog.WarnContext(ctx, "gateway tool call failed", "tool", call.Function.Name, "error", err)
+			result = "Error: " + err.Error()
+		}
+		ph.metrics.observeToolCall(call.Function.Name, err)
+		toolMessage, _ := json.Marshal(map[string]string{
+			"role":         "tool",
+			"tool_call_id": call.ID,
+			"content":      result,
+		})
+		messages = append(messages, toolMessage)
+	}
+	request["messages"], _ = json.Marshal(messages)
+	followUpBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, ""
+	}
+
+	followUpID := ph.logFollowUp(ctx, requestID, followUpBody)
+	slog.InfoContext(ctx, "resolved tool calls at gateway", "tools", strings.Join(names, ","), "round", round+1, "follow_up_id", followUpID)
+
+	next, err := http.NewRequestWithContext(context.WithValue(ctx, toolRoundKey{}, round+1), http.MethodPost,
+		proxyReq.URL.String(), bytes.NewReader(followUpBody))
+	if err != nil {
+		return nil, ""
+	}
+	next.Header = proxyReq.Header.Clone()
+	next.Header.Del("Content-Length")
+	return next, followUpID
+}
+
+// logFollowUp stores a follow-up request sent on behalf of the client,
+// carrying over the original request's metadata
+func (ph *ProxyHandler) logFollowUp(ctx context.Context, requestID string, body []byte) string {
+	if requestID == "" {
+		return ""
+	}
+	original, err := ph.db.GetRequest(requestID)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to load request for follow-up", "error", err)
+		return ""
+	}
+
+	id, err := ph.db.StoreRequest(&database.StoreRequestInput{
+		Provider:       original.Provider,
+		Endpoint:       original.Endpoint,
+		Query:          original.Query,
+		Method:         original.Method,
+		Headers:        original.Headers,
+		Body:           string(body),
+		RouteRule:      original.RouteRule,
+		VirtualKeyID:   original.VirtualKeyID,
+		RequestedModel: original.RequestedModel,
+		RoutedModel:    original.RoutedModel,
+		FollowUpOf:     requestID,
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to log follow-up request", "error", err)
+		return ""
+	}
+	if stored, err := ph.db.GetRequest(id); err == nil {
+		go ph.apiHandler.BroadcastRequestCreated(stored)
+	}
+	return id
+}
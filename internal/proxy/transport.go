@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// TransportOptions tune the connection pools calls to providers go through
+type TransportOptions struct {
+	MaxIdleConns        int           // Idle connections kept across all hosts (0 = no limit)
+	MaxIdleConnsPerHost int           // Idle connections kept per host
+	IdleConnTimeout     time.Duration // How long an idle connection is kept
+	HTTP2               bool          // Negotiate HTTP/2 with providers that support it
+}
+
+// defaultTransportOptions keep more idle connections per host than Go's
+// default of 2, which under load closes and redials (with a TLS handshake)
+// most connections
+var defaultTransportOptions = TransportOptions{
+	MaxIdleConns:        200,
+	MaxIdleConnsPerHost: 100,
+	IdleConnTimeout:     90 * time.Second,
+	HTTP2:               true,
+}
+
+// SetTransport sets the connection pooling of calls to providers
+func (ph *ProxyHandler) SetTransport(opts TransportOptions) {
+	ph.transportOpts = &opts
+}
+
+// Network is how calls to a provider reach it. The zero value uses the
+// proxies of HTTP_PROXY, HTTPS_PROXY and NO_PROXY and the system's CAs.
+type Network struct {
+	Proxy         *url.URL // http, https or socks5 proxy
+	Direct        bool     // Ignore the proxy environment variables
+	RootCAs       *x509.CertPool
+	TLSSkipVerify bool
+}
+
+// NewNetwork validates the network settings of a provider. proxyURL is a
+// proxy URL, "direct", or empty for the environment's proxies; caFile holds
+// PEM certificates trusted in addition to the system's.
+func NewNetwork(proxyURL, caFile string, skipVerify bool) (Network, error) {
+	network := Network{TLSSkipVerify: skipVerify}
+
+	switch proxyURL {
+	case "":
+	case "direct":
+		network.Direct = true
+	default:
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return network, fmt.Errorf("invalid proxy URL %q: %w", proxyURL, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return network, fmt.Errorf("unsupported proxy scheme %q (expected http, https or socks5)", u.Scheme)
+		}
+		if u.Host == "" {
+			return network, fmt.Errorf("invalid proxy URL %q: no host", proxyURL)
+		}
+		network.Proxy = u
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return network, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return network, fmt.Errorf("no certificates found in CA file %s", caFile)
+		}
+		network.RootCAs = pool
+	}
+	return network, nil
+}
+
+// SetNetworks sets how calls to each provider reach it
+func (ph *ProxyHandler) SetNetworks(networks map[string]Network) {
+	ph.networks = networks
+}
+
+// client returns the HTTP client shared by all calls to prov. Each provider
+// gets its own connection pool, with its connect timeout, unless it brings
+// its own transport (provider.Transporter). Redirects are returned, not
+// followed: sendFollowingRedirects follows and records them when enabled.
+func (ph *ProxyHandler) client(prov provider.Provider) *http.Client {
+	if client, ok := ph.clients.Load(prov.Name()); ok {
+		return client.(*http.Client)
+	}
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Transport: ph.transport(prov),
+	}
+	actual, _ := ph.clients.LoadOrStore(prov.Name(), client)
+	return actual.(*http.Client)
+}
+
+// transport returns a new transport for calls to prov
+func (ph *ProxyHandler) transport(prov provider.Provider) http.RoundTripper {
+	if transporter, ok := prov.(provider.Transporter); ok {
+		return transporter.Transport()
+	}
+
+	opts := defaultTransportOptions
+	if ph.transportOpts != nil {
+		opts = *ph.transportOpts
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if connect := ph.timeouts[prov.Name()].Connect; connect > 0 {
+		dialer.Timeout = connect
+		transport.TLSHandshakeTimeout = connect
+	}
+	transport.DialContext = dialer.DialContext
+	transport.MaxIdleConns = opts.MaxIdleConns
+	transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = opts.IdleConnTimeout
+	transport.ForceAttemptHTTP2 = opts.HTTP2
+
+	network := ph.networks[prov.Name()]
+	if network.Direct {
+		transport.Proxy = nil
+	} else if network.Proxy != nil {
+		transport.Proxy = http.ProxyURL(network.Proxy)
+	}
+	if network.RootCAs != nil || network.TLSSkipVerify {
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:            network.RootCAs,
+			InsecureSkipVerify: network.TLSSkipVerify,
+		}
+	}
+	if !opts.HTTP2 {
+		// A non-nil empty map turns off HTTP/2 negotiation
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
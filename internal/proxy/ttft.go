@@ -0,0 +1,29 @@
+package proxy
+
+import (
+	"io"
+	"time"
+)
+
+// firstByteReader records when the first byte of a response body is read
+type firstByteReader struct {
+	r       io.Reader
+	firstAt time.Time
+}
+
+func (f *firstByteReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if n > 0 && f.firstAt.IsZero() {
+		f.firstAt = time.Now()
+	}
+	return n, err
+}
+
+// timeToFirstByte returns how long after since the first byte was read, and
+// false if nothing was read
+func (f *firstByteReader) timeToFirstByte(since time.Time) (time.Duration, bool) {
+	if f.firstAt.IsZero() {
+		return 0, false
+	}
+	return f.firstAt.Sub(since), true
+}
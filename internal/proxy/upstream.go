@@ -0,0 +1,246 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// maxRedirectHops limits how many redirects are followed when following is enabled
+const maxRedirectHops = 10
+
+// SetFollowRedirects enables following upstream redirects instead of passing them to the client
+func (ph *ProxyHandler) SetFollowRedirects(follow bool) {
+	ph.followRedirects = follow
+}
+
+// doUpstream sends the proxy request to the provider.
+//
+// Redirects are passed through to the client unchanged unless redirect following
+// is enabled, in which case every intermediate hop is stored as a response of the
+// request before the next hop is requested. Informational (1xx) responses other
+// than 100 Continue are relayed to the client as soon as they arrive. With a
+// retry policy (or a retry override on the request), connection errors and
+// retryable statuses are retried after a backoff, each failed attempt stored
+// as a response of the request.
+func (ph *ProxyHandler) doUpstream(w http.ResponseWriter, prov provider.Provider, req *http.Request, requestID string, start time.Time) (*http.Response, error) {
+	client := ph.client(prov)
+
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			relayInformational(w, code, http.Header(header))
+			return nil
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			ph.metrics.observeConnection(prov.Name(), info.Reused)
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	ctx := req.Context()
+
+	retry := ph.retryPolicy(ctx)
+	attempts := 1
+	if retry != nil {
+		attempts = max(retry.MaxAttempts, 1)
+	}
+	for attempt := 1; ; attempt++ {
+		attemptStart := time.Now()
+		resp, err := ph.sendFollowingRedirects(client, req, requestID, start)
+		ph.observeAPIKey(prov, req, resp)
+		if attempt >= attempts || ctx.Err() != nil {
+			return resp, err
+		}
+		if err == nil && !retry.retryable(resp.StatusCode) {
+			return resp, nil
+		}
+
+		// A request rate limited on a pooled key moves on to the next key, which
+		// isn't bound by the previous key's Retry-After
+		next, rotated := req, false
+		if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+			if next, rotated, err = ph.rotateAPIKey(prov, req); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+		}
+		delayResp := resp
+		if rotated {
+			delayResp = nil
+		}
+
+		delay, ok := retry.delay(attempt, delayResp)
+		if !ok {
+			return resp, nil
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "upstream attempt failed, retrying", "attempt", attempt, "error", err, "delay_ms", delay.Milliseconds())
+			ph.logFailedAttempt(ctx, requestID, attempt, err, start)
+			ph.metrics.observeRetry(prov.Name(), "error")
+		} else {
+			slog.WarnContext(ctx, "upstream attempt failed, retrying", "attempt", attempt, "status", resp.StatusCode, "delay_ms", delay.Milliseconds())
+			ph.observeConcurrency(prov, resp.StatusCode, time.Since(attemptStart))
+			ph.logIntermediateResponse(ctx, requestID, resp, start)
+			resp.Body.Close()
+			ph.metrics.observeRetry(prov.Name(), strconv.Itoa(resp.StatusCode))
+		}
+
+		if !waitForRetry(ctx, delay) {
+			return nil, ctx.Err()
+		}
+		if req, err = rewindBody(next); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sendFollowingRedirects sends one attempt of the request, following redirects if enabled
+func (ph *ProxyHandler) sendFollowingRedirects(client *http.Client, req *http.Request, requestID string, start time.Time) (*http.Response, error) {
+	for hop := 0; ; hop++ {
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ph.followRedirects || !isRedirect(resp.StatusCode) || hop >= maxRedirectHops {
+			return resp, nil
+		}
+
+		location, err := resp.Location()
+		if err != nil {
+			// Redirect without a usable Location, hand it to the client as-is
+			return resp, nil
+		}
+
+		slog.InfoContext(req.Context(), "following redirect", "status", resp.StatusCode, "location", location.String())
+		ph.logIntermediateResponse(req.Context(), requestID, resp, start)
+		resp.Body.Close()
+
+		req, err = redirectRequest(req, resp.StatusCode, location.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// logIntermediateResponse stores a redirect hop or retried attempt against the request
+func (ph *ProxyHandler) logIntermediateResponse(ctx context.Context, requestID string, resp *http.Response, start time.Time) {
+	if requestID == "" {
+		return
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if contentEncoding := resp.Header.Get("Content-Encoding"); contentEncoding != "" && len(body) > 0 {
+		if decompressed, err := decompressBody(body, contentEncoding); err == nil {
+			body = decompressed
+		}
+	}
+
+	headers := make(map[string]string)
+	for key, values := range resp.Header {
+		if len(values) > 0 {
+			headers[key] = values[0]
+		}
+	}
+
+	_, err := ph.db.StoreResponse(&database.StoreResponseInput{
+		RequestID:  requestID,
+		StatusCode: resp.StatusCode,
+		Headers:    headers,
+		Body:       string(body),
+		DurationMs: int(time.Since(start).Milliseconds()),
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to log intermediate response", "error", err)
+	}
+}
+
+// redirectRequest builds the request for the next redirect hop, following the
+// same method rewriting rules as net/http's client
+func redirectRequest(prev *http.Request, statusCode int, location string) (*http.Request, error) {
+	method := prev.Method
+	preserveBody := statusCode == http.StatusTemporaryRedirect || statusCode == http.StatusPermanentRedirect
+	if !preserveBody && method != http.MethodGet && method != http.MethodHead {
+		method = http.MethodGet
+	}
+
+	next, err := http.NewRequestWithContext(prev.Context(), method, location, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create redirect request: %w", err)
+	}
+
+	next.Header = prev.Header.Clone()
+	if preserveBody && prev.GetBody != nil {
+		body, err := prev.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay body for redirect: %w", err)
+		}
+		next.Body = body
+		next.GetBody = prev.GetBody
+		next.ContentLength = prev.ContentLength
+	} else if !preserveBody {
+		next.Header.Del("Content-Type")
+		next.Header.Del("Content-Length")
+	}
+
+	// Don't leak credentials to a different host
+	if next.URL.Host != prev.URL.Host {
+		next.Header.Del("Authorization")
+		next.Header.Del("X-Api-Key")
+		next.Header.Del("Cookie")
+	}
+
+	return next, nil
+}
+
+// relayInformational forwards a 1xx response (e.g. 103 Early Hints) to the client.
+// 100 Continue and 101 Switching Protocols are handled by net/http and not relayed.
+func relayInformational(w http.ResponseWriter, code int, header http.Header) {
+	if code == http.StatusContinue || code == http.StatusSwitchingProtocols {
+		return
+	}
+
+	h := w.Header()
+	saved := h.Clone()
+	for key, values := range header {
+		h[key] = values
+	}
+	w.WriteHeader(code)
+
+	// Restore so informational headers don't leak into the final response
+	for key := range h {
+		delete(h, key)
+	}
+	for key, values := range saved {
+		h[key] = values
+	}
+}
+
+// isRedirect reports whether the status code is a followable redirect
+func isRedirect(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// bodyAllowed reports whether a response to the given method and status may carry a body
+func bodyAllowed(method string, statusCode int) bool {
+	if method == http.MethodHead {
+		return false
+	}
+	if statusCode >= 100 && statusCode < 200 {
+		return false
+	}
+	return statusCode != http.StatusNoContent && statusCode != http.StatusNotModified
+}
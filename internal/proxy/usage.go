@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// SetStreamUsageInjection enables adding usage reporting to streaming requests
+// that don't ask for it. When strip is true, the usage chunk the client did not
+// ask for is removed from the client stream (it is still stored).
+func (ph *ProxyHandler) SetStreamUsageInjection(inject, strip bool) {
+	ph.injectStreamUsage = inject
+	ph.stripInjectedUsage = strip
+}
+
+// applyStreamUsage enables usage reporting on a streaming proxy request if the
+// provider supports it. It returns a filter that drops the injected usage chunk
+// from the client stream, or nil if nothing should be dropped.
+func (ph *ProxyHandler) applyStreamUsage(prov provider.Provider, proxyReq *http.Request) func([]byte) bool {
+	requester, ok := prov.(provider.StreamUsageRequester)
+	if !ph.injectStreamUsage || !ok || proxyReq.Body == nil {
+		return nil
+	}
+
+	bodyBytes, _ := io.ReadAll(proxyReq.Body)
+	rewritten, injected := requester.RequestStreamUsage(bodyBytes)
+	proxyReq.Body = io.NopCloser(bytes.NewBuffer(rewritten))
+	proxyReq.ContentLength = int64(len(rewritten))
+	proxyReq.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(rewritten)), nil
+	}
+
+	if !injected || !ph.stripInjectedUsage {
+		return nil
+	}
+	return requester.IsStreamUsageChunk
+}
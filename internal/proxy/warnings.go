@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// recordWarnings fills in the deprecation notices and warnings the provider
+// attached to a response. Each distinct warning is logged the first time it
+// is seen.
+func (ph *ProxyHandler) recordWarnings(ctx context.Context, prov provider.Provider, input *database.StoreResponseInput) {
+	warnings := provider.ExtractWarnings(prov, input.Headers, input.Body)
+	if len(warnings) == 0 {
+		return
+	}
+	data, err := json.Marshal(warnings)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to marshal provider warnings", "error", err)
+		return
+	}
+	input.Warnings = string(data)
+
+	for _, w := range warnings {
+		key := prov.Name() + "\x00" + input.Model + "\x00" + w.Kind + "\x00" + w.Message
+		if _, seen := ph.seenWarnings.LoadOrStore(key, true); !seen {
+			slog.WarnContext(ctx, "provider warning", "provider", prov.Name(), "model", input.Model, "kind", w.Kind, "source", w.Source, "message", w.Message)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+)
+
+// WatchdogOptions configures the long-running request watchdog
+type WatchdogOptions struct {
+	Threshold   time.Duration // Flag upstream calls running longer than this
+	CancelAfter time.Duration // Cancel them once they run this long (0 = never)
+	WebhookURL  string        // Receives a JSON alert for each flagged or cancelled call (optional)
+}
+
+// errWatchdogTimeout is the cancellation cause of calls aborted by the watchdog
+var errWatchdogTimeout = errors.New("cancelled by watchdog")
+
+// watchedCall is an upstream call tracked by the watchdog
+type watchedCall struct {
+	requestID string
+	provider  string
+	start     time.Time
+	cancel    context.CancelCauseFunc
+	flagged   bool
+}
+
+// watchdog flags and optionally cancels upstream calls that run too long
+type watchdog struct {
+	opts   WatchdogOptions
+	client *http.Client
+
+	mu    sync.Mutex
+	calls map[*watchedCall]struct{}
+}
+
+// SetWatchdog enables the long-running request watchdog; start it with RunWatchdog
+func (ph *ProxyHandler) SetWatchdog(opts WatchdogOptions) {
+	if opts.Threshold <= 0 {
+		opts.Threshold = opts.CancelAfter
+	}
+	ph.watchdog = &watchdog{
+		opts:   opts,
+		client: &http.Client{Timeout: 10 * time.Second},
+		calls:  make(map[*watchedCall]struct{}),
+	}
+}
+
+// RunWatchdog checks in-flight upstream calls every second until ctx is cancelled
+func (ph *ProxyHandler) RunWatchdog(ctx context.Context) {
+	if ph.watchdog == nil {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			ph.checkWatchedCalls(now)
+		}
+	}
+}
+
+// watch registers an upstream call. The returned context is cancelled with
+// errWatchdogTimeout if the call runs past CancelAfter; done must be called
+// when the call (including reading the body) finishes.
+func (ph *ProxyHandler) watch(ctx context.Context, requestID, providerName string) (context.Context, func()) {
+	if ph.watchdog == nil {
+		return ctx, func() {}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	call := &watchedCall{requestID: requestID, provider: providerName, start: time.Now(), cancel: cancel}
+
+	wd := ph.watchdog
+	wd.mu.Lock()
+	wd.calls[call] = struct{}{}
+	wd.mu.Unlock()
+
+	return ctx, func() {
+		wd.mu.Lock()
+		delete(wd.calls, call)
+		wd.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// checkWatchedCalls flags calls past the threshold and cancels calls past CancelAfter
+func (ph *ProxyHandler) checkWatchedCalls(now time.Time) {
+	wd := ph.watchdog
+
+	type alert struct {
+		call    *watchedCall
+		action  string
+		elapsed time.Duration
+	}
+	var alerts []alert
+
+	wd.mu.Lock()
+	for call := range wd.calls {
+		elapsed := now.Sub(call.start)
+		if wd.opts.CancelAfter > 0 && elapsed >= wd.opts.CancelAfter {
+			call.cancel(errWatchdogTimeout)
+			delete(wd.calls, call)
+			alerts = append(alerts, alert{call, "cancelled", elapsed})
+		} else if !call.flagged && elapsed >= wd.opts.Threshold {
+			call.flagged = true
+			alerts = append(alerts, alert{call, "flagged", elapsed})
+		}
+	}
+	wd.mu.Unlock()
+
+	for _, a := range alerts {
+		slog.Warn("long-running request", "request_id", a.call.requestID, "provider", a.call.provider,
+			"action", a.action, "elapsed", a.elapsed.Round(time.Second).String())
+		go ph.apiHandler.BroadcastRequestSlow(a.call.requestID, a.call.provider, a.action, a.elapsed)
+		if a.action == "cancelled" {
+			ph.metrics.observeTimeout(a.call.provider, timeoutWatchdog)
+		}
+		if wd.opts.WebhookURL != "" {
+			go wd.sendAlert(a.call, a.action, a.elapsed)
+		}
+	}
+}
+
+// sendAlert posts a watchdog alert to the configured webhook
+func (wd *watchdog) sendAlert(call *watchedCall, action string, elapsed time.Duration) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"event":           "request_slow",
+		"action":          action,
+		"request_id":      call.requestID,
+		"provider":        call.provider,
+		"started_at":      call.start.UTC(),
+		"elapsed_seconds": int(elapsed.Seconds()),
+	})
+
+	resp, err := wd.client.Post(wd.opts.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("failed to send watchdog alert", "request_id", call.requestID, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Warn("watchdog webhook rejected alert", "request_id", call.requestID, "status", resp.StatusCode)
+	}
+}
+
+// cancelledByWatchdog reports whether an upstream call's context was cancelled by the watchdog
+func cancelledByWatchdog(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errWatchdogTimeout)
+}
+
+// watchdogMessage describes a watchdog cancellation
+func (ph *ProxyHandler) watchdogMessage() string {
+	return fmt.Sprintf("Upstream call cancelled by watchdog after %s", ph.watchdog.opts.CancelAfter)
+}
+
+// logTimeoutResponse stores the outcome of a call cancelled by the watchdog
+func (ph *ProxyHandler) logTimeoutResponse(ctx context.Context, requestID string, start time.Time) {
+	if requestID == "" {
+		return
+	}
+
+	responseID, err := ph.db.StoreResponse(&database.StoreResponseInput{
+		RequestID:    requestID,
+		StatusCode:   http.StatusGatewayTimeout,
+		Headers:      make(map[string]string),
+		DurationMs:   int(time.Since(start).Milliseconds()),
+		IsError:      true,
+		ErrorMessage: ph.watchdogMessage(),
+		Timeout:      timeoutWatchdog,
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to log timeout response", "error", err)
+		return
+	}
+
+	go func() {
+		storedResp, err := ph.db.GetResponse(responseID)
+		if err == nil && storedResp != nil {
+			ph.responseCreated(storedResp)
+		}
+	}()
+}
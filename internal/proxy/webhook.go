@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// webhookTolerance is how far a webhook's timestamp may be from the
+// gateway's clock, limiting replays of captured deliveries
+const webhookTolerance = 5 * time.Minute
+
+// SetReplicateWebhookSecret enables HandleReplicateWebhook, which verifies
+// deliveries with the given signing secret ("whsec_..." as shown by
+// Replicate's API)
+func (ph *ProxyHandler) SetReplicateWebhookSecret(secret string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil || len(key) == 0 {
+		return fmt.Errorf("invalid webhook signing secret")
+	}
+	ph.webhookKey = key
+	return nil
+}
+
+// HandleReplicateWebhook receives the webhooks Replicate sends for
+// predictions created through the gateway with a "webhook" pointing back to
+// it. Once a prediction has finished, its outputs are stored with the
+// logged request that created it, as if it had been polled.
+func (ph *ProxyHandler) HandleReplicateWebhook(w http.ResponseWriter, r *http.Request) {
+	if ph.webhookKey == nil {
+		http.Error(w, "Replicate webhooks are not enabled", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPredictionBody))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if err := verifyWebhook(ph.webhookKey, r.Header, body, time.Now()); err != nil {
+		slog.Warn("rejected Replicate webhook", "error", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var prediction struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &prediction); err != nil || prediction.ID == "" {
+		http.Error(w, "body is not a prediction", http.StatusBadRequest)
+		return
+	}
+
+	// Replicate also sends start, output and logs events; only the final
+	// one has every output
+	switch prediction.Status {
+	case "succeeded", "failed", "canceled":
+	default:
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Predictions being polled are completed by whichever arrives first
+	if ph.completePrediction(prediction.ID, body) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	prov := ph.router.Provider("replicate")
+	if prov == nil {
+		http.Error(w, "replicate provider is not configured", http.StatusNotFound)
+		return
+	}
+	found, err := ph.db.FindPredictionRequest(prov.Name(), prediction.ID)
+	if err != nil {
+		slog.Warn("failed to find prediction request", "prediction_id", prediction.ID, "error", err)
+		http.Error(w, "failed to find prediction", http.StatusInternalServerError)
+		return
+	}
+	if found == nil {
+		// Acknowledged so Replicate doesn't retry a prediction the gateway
+		// never logged
+		slog.Info("ignoring webhook for unknown prediction", "prediction_id", prediction.ID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if found.Files > 0 {
+		// A retried delivery, or already completed by polling
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ph.storePrediction(prov, prediction.ID, found.RequestID, found.ResponseID, body)
+	w.WriteHeader(http.StatusOK)
+}
+
+// verifyWebhook checks the Standard Webhooks signature Replicate sends: an
+// HMAC-SHA256 of "{webhook-id}.{webhook-timestamp}.{body}", base64 encoded
+// in a space separated list of "v1,<signature>" entries
+func verifyWebhook(key []byte, header http.Header, body []byte, now time.Time) error {
+	id := header.Get("webhook-id")
+	timestamp := header.Get("webhook-timestamp")
+	signatures := header.Get("webhook-signature")
+	if id == "" || timestamp == "" || signatures == "" {
+		return fmt.Errorf("missing webhook signature headers")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp")
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > webhookTolerance || skew < -webhookTolerance {
+		return fmt.Errorf("webhook timestamp is too far from the current time")
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	for _, entry := range strings.Fields(signatures) {
+		version, signature, ok := strings.Cut(entry, ",")
+		if !ok || version != "v1" {
+			continue
+		}
+		if decoded, err := base64.StdEncoding.DecodeString(signature); err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid webhook signature")
+}
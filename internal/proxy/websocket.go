@@ -0,0 +1,337 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/logging"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// maxWebSocketFrame caps the payload of a relayed frame; a larger frame ends the session
+const maxWebSocketFrame = 64 << 20
+
+// WebSocket frame opcodes (RFC 6455)
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+)
+
+// Which side of a WebSocket session sent a message
+const (
+	wsFromClient = "client"
+	wsFromServer = "server"
+)
+
+// isWebSocketUpgrade reports whether the client asks to switch the connection to WebSocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleWebSocket relays a WebSocket session, such as one with the OpenAI
+// Realtime API, between the client and the provider. Frames are passed through
+// as they are while the messages are collected into a transcript, stored as
+// the body of the request's 101 response (one JSON object per line) when the
+// session ends. A handshake the provider refuses is answered like any other
+// response.
+func (ph *ProxyHandler) handleWebSocket(w http.ResponseWriter, prov provider.Provider, proxyReq *http.Request, requestID string, start time.Time) {
+	ctx := proxyReq.Context()
+	slog.InfoContext(ctx, "forwarding websocket upgrade", "url", proxyReq.URL.String())
+
+	// Providers drop hop-by-hop headers, which the handshake needs. Extensions
+	// aren't negotiated so frames stay uncompressed and readable.
+	proxyReq.Header.Set("Connection", "Upgrade")
+	proxyReq.Header.Set("Upgrade", "websocket")
+	proxyReq.Header.Del("Sec-WebSocket-Extensions")
+
+	// Sessions are long-lived by design, so they're not watched, but they end on shutdown
+	shutdownCtx := ph.GetShutdownContext()
+	proxyReq = proxyReq.WithContext(withOverridesOf(withAPIKeyOf(logging.CopyAttrs(shutdownCtx, ctx), proxyReq), proxyReq))
+
+	upstreamStart := time.Now()
+	resp, err := ph.doUpstream(w, prov, proxyReq, requestID, start)
+	if err != nil {
+		if shutdownCtx.Err() != nil {
+			slog.WarnContext(ctx, "request cancelled due to server shutdown")
+			ph.logAbortedResponse(ctx, requestID, start)
+			return
+		}
+		slog.ErrorContext(ctx, "error reaching provider", "error", err)
+		ph.logErrorResponse(ctx, requestID, err, start)
+		writeError(w, prov, provider.ErrorTypeUpstream, fmt.Sprintf("Failed to reach provider: %v", err))
+		return
+	}
+	ph.metrics.observeUpstream(prov.Name(), time.Since(upstreamStart))
+
+	headers := make(map[string]string)
+	for key, values := range resp.Header {
+		if len(values) > 0 {
+			headers[key] = values[0]
+		}
+	}
+	respInput := &database.StoreResponseInput{RequestID: requestID, StatusCode: resp.StatusCode, Headers: headers}
+
+	upstream, ok := resp.Body.(io.ReadWriteCloser)
+	if resp.StatusCode != http.StatusSwitchingProtocols || !ok {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slog.InfoContext(ctx, "websocket upgrade refused", "status", resp.StatusCode)
+
+		respInput.Body = string(body)
+		respInput.DurationMs = int(time.Since(start).Milliseconds())
+		ph.storeWebSocketResponse(ctx, respInput)
+
+		for key, values := range resp.Header {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+		w.WriteHeader(resp.StatusCode)
+		w.Write(body)
+		return
+	}
+	defer upstream.Close()
+
+	conn, client, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to take over client connection", "error", err)
+		ph.logErrorResponse(ctx, requestID, err, start)
+		writeError(w, prov, provider.ErrorTypeServerError, "WebSocket not supported")
+		return
+	}
+	defer conn.Close()
+	if recorder, ok := w.(*statusRecorder); ok {
+		recorder.status = http.StatusSwitchingProtocols
+	}
+
+	// Complete the handshake with the provider's response and the gateway's headers
+	handshake := resp.Header.Clone()
+	for key, values := range w.Header() {
+		handshake[key] = values
+	}
+	client.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	handshake.Write(client)
+	client.WriteString("\r\n")
+	if err := client.Flush(); err != nil {
+		slog.WarnContext(ctx, "failed to complete websocket handshake", "error", err)
+	}
+
+	transcript := &wsTranscript{start: time.Now(), model: proxyReq.URL.Query().Get("model")}
+	var wg sync.WaitGroup
+	ended := make(chan struct{})
+	var endOnce sync.Once
+	relay := func(dst io.Writer, src *bufio.Reader, from string) {
+		defer wg.Done()
+		defer endOnce.Do(func() { close(ended) })
+		relayWebSocket(dst, src, from, transcript)
+	}
+	wg.Add(2)
+	go relay(upstream, client.Reader, wsFromClient)
+	go relay(conn, bufio.NewReader(upstream), wsFromServer)
+
+	// Either side closing ends the session
+	select {
+	case <-ended:
+	case <-shutdownCtx.Done():
+		slog.WarnContext(ctx, "websocket session closed due to server shutdown")
+	}
+	conn.Close()
+	upstream.Close()
+	wg.Wait()
+
+	respInput.Body = transcript.buf.String()
+	respInput.DurationMs = int(time.Since(start).Milliseconds())
+	if transcript.usage != nil {
+		ph.applyUsage(respInput, transcript.usage)
+	}
+	slog.InfoContext(ctx, "websocket session ended", "messages", transcript.messages, "duration_ms", respInput.DurationMs)
+	ph.storeWebSocketResponse(ctx, respInput)
+}
+
+// storeWebSocketResponse stores the response of a WebSocket request and announces it
+func (ph *ProxyHandler) storeWebSocketResponse(ctx context.Context, input *database.StoreResponseInput) {
+	responseID, err := ph.db.StoreResponse(input)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to log websocket response", "error", err)
+		return
+	}
+	go func() {
+		storedResp, err := ph.db.GetResponse(responseID)
+		if err == nil && storedResp != nil {
+			ph.responseCreated(storedResp)
+		}
+	}()
+}
+
+// relayWebSocket copies frames from src to dst until either fails, adding
+// each complete text or binary message to the transcript
+func relayWebSocket(dst io.Writer, src *bufio.Reader, from string, transcript *wsTranscript) {
+	var opcode byte
+	var message []byte
+	for {
+		frame, err := readWebSocketFrame(src)
+		if err != nil {
+			return
+		}
+		if _, err := dst.Write(frame.raw); err != nil {
+			return
+		}
+
+		switch frame.opcode {
+		case wsText, wsBinary:
+			opcode, message = frame.opcode, frame.payload
+		case wsContinuation:
+			message = append(message, frame.payload...)
+		default:
+			// Control frames (close, ping, pong) aren't part of the transcript
+			continue
+		}
+		if frame.fin {
+			transcript.add(from, opcode, message)
+			message = nil
+		}
+	}
+}
+
+// wsFrame is a WebSocket frame as read from the wire
+type wsFrame struct {
+	fin     bool
+	opcode  byte
+	payload []byte // Unmasked
+	raw     []byte // As received, to relay unchanged
+}
+
+// readWebSocketFrame reads one frame
+func readWebSocketFrame(r *bufio.Reader) (*wsFrame, error) {
+	raw := make([]byte, 2, 14)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, err
+	}
+	frame := &wsFrame{fin: raw[0]&0x80 != 0, opcode: raw[0] & 0x0F}
+	masked := raw[1]&0x80 != 0
+
+	size := uint64(raw[1] & 0x7F)
+	switch size {
+	case 126:
+		raw = append(raw, make([]byte, 2)...)
+		if _, err := io.ReadFull(r, raw[2:4]); err != nil {
+			return nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(raw[2:4]))
+	case 127:
+		raw = append(raw, make([]byte, 8)...)
+		if _, err := io.ReadFull(r, raw[2:10]); err != nil {
+			return nil, err
+		}
+		size = binary.BigEndian.Uint64(raw[2:10])
+	}
+	if size > maxWebSocketFrame {
+		return nil, fmt.Errorf("websocket frame of %d bytes exceeds the limit", size)
+	}
+
+	var mask []byte
+	if masked {
+		n := len(raw)
+		raw = append(raw, make([]byte, 4)...)
+		if _, err := io.ReadFull(r, raw[n:]); err != nil {
+			return nil, err
+		}
+		mask = raw[n:]
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	frame.raw = append(raw, payload...)
+	if masked {
+		payload = bytes.Clone(payload)
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	frame.payload = payload
+	return frame, nil
+}
+
+// wsMessage is one line of a WebSocket session transcript
+type wsMessage struct {
+	AtMs   int64           `json:"at_ms"` // Since the session opened
+	From   string          `json:"from"`  // "client" or "server"
+	Data   json.RawMessage `json:"data,omitempty"`
+	Text   string          `json:"text,omitempty"`   // Text messages that aren't JSON
+	Binary int             `json:"binary,omitempty"` // Size of a binary message, whose content isn't kept
+}
+
+// wsTranscript collects the messages of a WebSocket session and the token
+// usage the server reports (e.g. in Realtime API response.done events)
+type wsTranscript struct {
+	mu       sync.Mutex
+	start    time.Time
+	model    string // Model named in the session URL
+	buf      bytes.Buffer
+	messages int
+	usage    *provider.Usage
+}
+
+func (t *wsTranscript) add(from string, opcode byte, payload []byte) {
+	entry := &wsMessage{AtMs: time.Since(t.start).Milliseconds(), From: from}
+	switch {
+	case opcode == wsBinary:
+		entry.Binary = len(payload)
+	case json.Valid(payload):
+		entry.Data = payload
+	default:
+		entry.Text = string(payload)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf.Write(line)
+	t.buf.WriteByte('\n')
+	t.messages++
+
+	if from != wsFromServer || entry.Data == nil {
+		return
+	}
+	usage, ok := provider.ParseUsage(string(payload))
+	if !ok {
+		return
+	}
+	if t.usage == nil {
+		t.usage = &provider.Usage{Model: t.model}
+	}
+	if usage.Model != "" {
+		t.usage.Model = usage.Model
+	}
+	t.usage.InputTokens += usage.InputTokens
+	t.usage.OutputTokens += usage.OutputTokens
+	t.usage.CachedTokens += usage.CachedTokens
+	t.usage.ReasoningTokens += usage.ReasoningTokens
+}
@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// AdaptiveOptions configures an AdaptiveLimiter
+type AdaptiveOptions struct {
+	Initial   int     // Starting concurrency limit per key
+	Min       int     // Lowest the limit can shrink to
+	Max       int     // Highest the limit can grow to
+	Tolerance float64 // Latency, as a multiple of the baseline, above which the limit shrinks
+}
+
+// Multiplicative decrease factors: throttling halves the limit, high latency trims it
+const (
+	throttleBackoff = 0.5
+	latencyBackoff  = 0.9
+)
+
+// baselineDrift is how fast the latency baseline follows higher latencies, so
+// a baseline measured during a quiet period doesn't shrink the limit forever
+const baselineDrift = 0.01
+
+// concurrency is the adaptive state of one key
+type concurrency struct {
+	limit    float64
+	inflight int
+	baseline time.Duration
+	wake     chan struct{} // Closed and replaced when a slot frees up or the limit grows
+}
+
+// AdaptiveLimiter limits concurrent requests per key (e.g. per provider) with
+// a limit that adapts to the upstream: additive increase while latency stays
+// close to the lowest latency seen, multiplicative decrease on throttling
+// (429) or when latency climbs past Tolerance times that baseline.
+type AdaptiveLimiter struct {
+	opts AdaptiveOptions
+
+	mu   sync.Mutex
+	keys map[string]*concurrency
+}
+
+// NewAdaptive creates an adaptive concurrency limiter
+func NewAdaptive(opts AdaptiveOptions) *AdaptiveLimiter {
+	if opts.Min < 1 {
+		opts.Min = 1
+	}
+	if opts.Max < opts.Min {
+		opts.Max = opts.Min
+	}
+	if opts.Initial < opts.Min || opts.Initial > opts.Max {
+		opts.Initial = opts.Min
+	}
+	if opts.Tolerance <= 1 {
+		opts.Tolerance = 2
+	}
+	return &AdaptiveLimiter{opts: opts, keys: make(map[string]*concurrency)}
+}
+
+// Acquire waits for a free slot for key until ctx is done. The returned
+// function releases the slot and must be called exactly once.
+func (l *AdaptiveLimiter) Acquire(ctx context.Context, key string) (func(), error) {
+	for {
+		l.mu.Lock()
+		c := l.state(key)
+		if c.inflight < int(c.limit) {
+			c.inflight++
+			l.mu.Unlock()
+
+			var once sync.Once
+			return func() { once.Do(func() { l.release(key) }) }, nil
+		}
+		wake := c.wake
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+// Observe adjusts the key's limit after an upstream response. latency is the
+// time to the response headers; throttled reports a rate limit response.
+func (l *AdaptiveLimiter) Observe(key string, latency time.Duration, throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.state(key)
+	previous := int(c.limit)
+
+	switch {
+	case throttled:
+		c.limit = math.Max(float64(l.opts.Min), c.limit*throttleBackoff)
+	case c.baseline == 0 || latency < c.baseline:
+		c.baseline = latency
+		c.limit = math.Min(float64(l.opts.Max), c.limit+1/c.limit)
+	case float64(latency) > float64(c.baseline)*l.opts.Tolerance:
+		c.limit = math.Max(float64(l.opts.Min), c.limit*latencyBackoff)
+		c.baseline += time.Duration(float64(latency-c.baseline) * baselineDrift)
+	default:
+		c.limit = math.Min(float64(l.opts.Max), c.limit+1/c.limit)
+		c.baseline += time.Duration(float64(latency-c.baseline) * baselineDrift)
+	}
+
+	if int(c.limit) > previous {
+		l.wakeWaiters(c)
+	}
+}
+
+// Limits returns the current concurrency limit of every key seen so far
+func (l *AdaptiveLimiter) Limits() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limits := make(map[string]int, len(l.keys))
+	for key, c := range l.keys {
+		limits[key] = int(c.limit)
+	}
+	return limits
+}
+
+// Limit returns the current concurrency limit of key
+func (l *AdaptiveLimiter) Limit(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.state(key).limit)
+}
+
+func (l *AdaptiveLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.state(key)
+	c.inflight--
+	l.wakeWaiters(c)
+}
+
+func (l *AdaptiveLimiter) wakeWaiters(c *concurrency) {
+	close(c.wake)
+	c.wake = make(chan struct{})
+}
+
+// state returns the key's state, creating it at the initial limit; l.mu must be held
+func (l *AdaptiveLimiter) state(key string) *concurrency {
+	c, ok := l.keys[key]
+	if !ok {
+		c = &concurrency{limit: float64(l.opts.Initial), wake: make(chan struct{})}
+		l.keys[key] = c
+	}
+	return c
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limits holds per-minute request and token limits. Zero means unlimited.
+type Limits struct {
+	RPM int `json:"rpm"`
+	TPM int `json:"tpm"`
+}
+
+// IsZero reports whether no limit is configured
+func (l Limits) IsZero() bool {
+	return l.RPM <= 0 && l.TPM <= 0
+}
+
+// bucket is a token bucket refilled continuously at capacity per minute
+type bucket struct {
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// Limiter tracks token buckets for arbitrary keys (e.g. "provider:openai")
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// New creates a new limiter
+func New() *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow charges one request and the given number of tokens against the key's
+// limits. Either both are charged or neither is. When the request is rejected,
+// the returned duration is how long until it would be allowed and the string
+// names the exhausted limit ("rpm" or "tpm").
+func (l *Limiter) Allow(key string, limits Limits, tokens int) (bool, time.Duration, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	var rpm, tpm *bucket
+	if limits.RPM > 0 {
+		rpm = l.bucket(key+":rpm", limits.RPM, now)
+		if rpm.tokens < 1 {
+			return false, rpm.wait(1), "rpm"
+		}
+	}
+	if limits.TPM > 0 {
+		tpm = l.bucket(key+":tpm", limits.TPM, now)
+		// A single request larger than the whole budget can only ever run on a full bucket
+		need := math.Min(float64(tokens), tpm.capacity)
+		if tpm.tokens < need {
+			return false, tpm.wait(need), "tpm"
+		}
+		tpm.tokens -= need
+	}
+	if rpm != nil {
+		rpm.tokens--
+	}
+
+	return true, 0, ""
+}
+
+// Refund gives back a request and tokens charged by Allow, for a request that
+// was rejected by a later check and never sent
+func (l *Limiter) Refund(key string, limits Limits, tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	if limits.RPM > 0 {
+		rpm := l.bucket(key+":rpm", limits.RPM, now)
+		rpm.tokens = math.Min(rpm.capacity, rpm.tokens+1)
+	}
+	if limits.TPM > 0 {
+		tpm := l.bucket(key+":tpm", limits.TPM, now)
+		tpm.tokens = math.Min(tpm.capacity, tpm.tokens+math.Min(float64(tokens), tpm.capacity))
+	}
+}
+
+// bucket returns the refilled bucket for key, creating or resizing it as needed
+func (l *Limiter) bucket(key string, perMinute int, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{capacity: float64(perMinute), tokens: float64(perMinute), last: now}
+		l.buckets[key] = b
+		return b
+	}
+
+	b.capacity = float64(perMinute)
+	elapsed := now.Sub(b.last).Minutes()
+	b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.capacity)
+	b.last = now
+	return b
+}
+
+// wait returns how long until the bucket holds n tokens
+func (b *bucket) wait(n float64) time.Duration {
+	missing := n - b.tokens
+	if missing <= 0 || b.capacity <= 0 {
+		return 0
+	}
+	return time.Duration(missing / b.capacity * float64(time.Minute))
+}
+
+// EstimateTokens roughly estimates the tokens a request will consume: about
+// four bytes per prompt token plus any requested completion budget
+func EstimateTokens(body []byte) int {
+	tokens := len(body) / 4
+
+	var payload struct {
+		MaxTokens           int `json:"max_tokens"`
+		MaxCompletionTokens int `json:"max_completion_tokens"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil {
+		if payload.MaxCompletionTokens > 0 {
+			tokens += payload.MaxCompletionTokens
+		} else {
+			tokens += payload.MaxTokens
+		}
+	}
+
+	return tokens
+}
@@ -0,0 +1,234 @@
+// Package restart hands the gateway's listening sockets to a newly started
+// copy of its binary, so it can be upgraded in place without refusing
+// connections while the old process drains its in-flight requests
+package restart
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Environment through which a parent describes the files it passes: the names
+// of the listeners, in order from file descriptor 3, and the descriptor of
+// the pipe the child reports readiness on
+const (
+	envListeners = "AIGW_LISTENERS"
+	envReadyFD   = "AIGW_READY_FD"
+)
+
+// firstFD is the descriptor of the first of exec.Cmd's ExtraFiles
+const firstFD = 3
+
+// Handover tracks the listeners of a process: those it inherited from the
+// process it replaces, and those it will pass on when it is replaced
+type Handover struct {
+	mu        sync.Mutex
+	inherited map[string]net.Listener
+	listeners []namedListener
+	ready     *os.File // Pipe to the parent, until Ready
+}
+
+type namedListener struct {
+	name     string
+	listener *net.TCPListener
+}
+
+// New picks up the listeners and readiness pipe passed by a parent process,
+// if any. Unused inherited listeners are closed by Ready.
+func New() (*Handover, error) {
+	h := &Handover{inherited: make(map[string]net.Listener)}
+	names := os.Getenv(envListeners)
+	readyFD := os.Getenv(envReadyFD)
+	os.Unsetenv(envListeners)
+	os.Unsetenv(envReadyFD)
+
+	if names != "" {
+		for i, name := range strings.Split(names, ",") {
+			file := os.NewFile(uintptr(firstFD+i), name)
+			listener, err := net.FileListener(file)
+			file.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to inherit %s listener: %w", name, err)
+			}
+			h.inherited[name] = listener
+		}
+	}
+	if readyFD != "" {
+		fd, err := strconv.Atoi(readyFD)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %q", envReadyFD, readyFD)
+		}
+		h.ready = os.NewFile(uintptr(fd), "ready")
+	}
+	return h, nil
+}
+
+// Inherited reports whether the process was started by an upgrade
+func (h *Handover) Inherited() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.ready != nil || len(h.inherited) > 0
+}
+
+// Listen returns the listener named name, inherited from the parent process
+// if it passed one, or else a new TCP listener on addr. Either way it is
+// passed on by Upgrade.
+func (h *Handover) Listen(name, addr string) (net.Listener, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	listener, ok := h.inherited[name]
+	if ok {
+		delete(h.inherited, name)
+		// The address may have changed with the new configuration
+		if !sameAddr(listener.Addr(), addr) {
+			slog.Warn("not reusing inherited listener for a different address", "listener", name, "inherited", listener.Addr().String(), "addr", addr)
+			listener.Close()
+			ok = false
+		}
+	}
+	if !ok {
+		var err error
+		if listener, err = net.Listen("tcp", addr); err != nil {
+			return nil, err
+		}
+	}
+
+	tcp, isTCP := listener.(*net.TCPListener)
+	if !isTCP {
+		listener.Close()
+		return nil, fmt.Errorf("%s listener is not a TCP listener", name)
+	}
+	h.listeners = append(h.listeners, namedListener{name: name, listener: tcp})
+	return listener, nil
+}
+
+// Ready tells the parent process, if there is one, that this process has
+// started and serves its listeners, so the parent can stop accepting
+// connections and drain. Inherited listeners that weren't claimed are closed.
+func (h *Handover) Ready() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for name, listener := range h.inherited {
+		slog.Warn("closing unused inherited listener", "listener", name)
+		listener.Close()
+	}
+	h.inherited = make(map[string]net.Listener)
+
+	if h.ready != nil {
+		h.ready.Write([]byte{1})
+		h.ready.Close()
+		h.ready = nil
+	}
+}
+
+// Upgrade starts a new copy of the running binary, with the same arguments
+// and environment, and hands it the listeners. It returns once the new
+// process reports it is ready; if it exits first, or ctx is done, it is
+// stopped and an error returned, leaving this process serving as before.
+func (h *Handover) Upgrade(ctx context.Context) (*os.Process, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find executable: %w", err)
+	}
+
+	var files []*os.File
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	names := make([]string, 0, len(h.listeners))
+	for _, l := range h.listeners {
+		// A duplicate of the socket: closing it, or the listener, doesn't
+		// affect the other
+		file, err := l.listener.File()
+		if err != nil {
+			return nil, fmt.Errorf("failed to pass %s listener: %w", l.name, err)
+		}
+		files = append(files, file)
+		names = append(names, l.name)
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create readiness pipe: %w", err)
+	}
+	defer readyR.Close()
+	files = append(files, readyW)
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(withoutHandoverEnv(os.Environ()),
+		envListeners+"="+strings.Join(names, ","),
+		envReadyFD+"="+strconv.Itoa(firstFD+len(names)),
+	)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start new process: %w", err)
+	}
+	// Only the child holds the write end now, so a read sees EOF if it exits
+	readyW.Close()
+	files = files[:len(files)-1]
+
+	ready := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		if _, err := readyR.Read(buf); err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("new process exited before it was ready")
+			}
+			ready <- err
+			return
+		}
+		ready <- nil
+	}()
+
+	select {
+	case err = <-ready:
+	case <-ctx.Done():
+		err = fmt.Errorf("new process was not ready in time: %w", ctx.Err())
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	return cmd.Process, nil
+}
+
+// withoutHandoverEnv removes the variables of an earlier handover
+func withoutHandoverEnv(env []string) []string {
+	kept := env[:0:0]
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, envListeners+"=") && !strings.HasPrefix(kv, envReadyFD+"=") {
+			kept = append(kept, kv)
+		}
+	}
+	return kept
+}
+
+// sameAddr reports whether an inherited listener listens on addr
+func sameAddr(listening net.Addr, addr string) bool {
+	tcp, ok := listening.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	resolved, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return false
+	}
+	return tcp.Port == resolved.Port && (resolved.IP == nil || resolved.IP.IsUnspecified() || resolved.IP.Equal(tcp.IP))
+}
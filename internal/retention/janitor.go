@@ -0,0 +1,255 @@
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/storage"
+)
+
+const (
+	// batchSize is how many of the oldest requests are removed at a time
+	// while the database or the stored files are over their limit
+	batchSize = 100
+
+	// orphanGrace is how old a stored file without a record must be before
+	// it is removed; files are saved just before their record is stored
+	orphanGrace = time.Hour
+)
+
+// Options configures a Janitor. Zero limits are off.
+type Options struct {
+	Interval       time.Duration        // Time between scheduled purges
+	MaxAge         time.Duration        // Requests older than this are removed
+	MaxDBBytes     int64                // Oldest requests are removed while the database uses more
+	MaxFilesBytes  int64                // Oldest requests with files are removed while stored files take more
+	FileQuotaBytes int64                // Least recently used files are removed, keeping their requests, while stored files take more
+	OnPurge        func(report *Report) // Called after a purge that removed requests (optional)
+}
+
+// Enabled reports whether any limit is set
+func (o *Options) Enabled() bool {
+	return o.MaxAge > 0 || o.MaxDBBytes > 0 || o.MaxFilesBytes > 0 || o.FileQuotaBytes > 0
+}
+
+// Report describes what a purge removed
+type Report struct {
+	Trigger       string         `json:"trigger"` // "schedule" or "manual"
+	StartedAt     time.Time      `json:"started_at"`
+	DurationMs    int64          `json:"duration_ms"`
+	Requests      int            `json:"requests"`
+	Responses     int            `json:"responses"`
+	Files         int            `json:"files"`
+	FileBytes     int64          `json:"file_bytes"`
+	ByLimit       map[string]int `json:"by_limit"`       // Requests removed for max_age, max_db_size and max_files_size
+	OrphanedFiles int            `json:"orphaned_files"` // Stored files without a record or of requests that no longer exist
+	EvictedFiles  int            `json:"evicted_files"`  // Files removed for the file quota
+	EvictedBytes  int64          `json:"evicted_bytes"`
+	DBBytes       int64          `json:"db_bytes"`    // Database pages in use after the purge
+	FilesBytes    int64          `json:"files_bytes"` // Stored files after the purge
+	Error         string         `json:"error,omitempty"`
+}
+
+// Janitor removes requests past the retention limits with their responses
+// and stored files, and stored files left without a record
+type Janitor struct {
+	db   *database.DB
+	fs   storage.FileStorage
+	opts Options
+
+	mu   sync.Mutex // Held while purging
+	last *Report
+}
+
+// New creates a janitor
+func New(db *database.DB, fs storage.FileStorage, opts Options) *Janitor {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	return &Janitor{db: db, fs: fs, opts: opts}
+}
+
+// Options returns the janitor's limits
+func (j *Janitor) Options() Options {
+	return j.opts
+}
+
+// LastReport returns the report of the latest purge, or nil before the first
+func (j *Janitor) LastReport() *Report {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.last
+}
+
+// Run purges on every interval until ctx is done, starting right away
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		j.Purge("schedule")
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge applies the retention limits and removes orphaned files now. A purge
+// that fails part way reports what it removed before the error.
+func (j *Janitor) Purge(trigger string) *Report {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	report := &Report{Trigger: trigger, StartedAt: time.Now().UTC(), ByLimit: map[string]int{}}
+	if err := j.purge(report); err != nil {
+		slog.Error("retention purge failed", "error", err)
+		report.Error = err.Error()
+	}
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+	j.last = report
+
+	if report.Requests > 0 || report.OrphanedFiles > 0 || report.EvictedFiles > 0 {
+		slog.Info("retention purge", "trigger", trigger, "requests", report.Requests, "responses", report.Responses,
+			"files", report.Files, "orphaned_files", report.OrphanedFiles, "evicted_files", report.EvictedFiles)
+	}
+	if report.Requests > 0 && j.opts.OnPurge != nil {
+		j.opts.OnPurge(report)
+	}
+	return report
+}
+
+func (j *Janitor) purge(report *Report) error {
+	if j.opts.MaxAge > 0 {
+		if _, err := j.remove(report, "max_age", &database.PurgeFilter{Before: time.Now().Add(-j.opts.MaxAge)}); err != nil {
+			return err
+		}
+	}
+
+	orphans, err := j.db.PurgeOrphans()
+	if err != nil {
+		return err
+	}
+	j.deleteFiles(orphans.FilePaths)
+	report.Responses += orphans.Responses
+	report.OrphanedFiles += orphans.Files
+
+	if report.FilesBytes, err = j.removeOrphanedFiles(report); err != nil {
+		return err
+	}
+	for j.opts.MaxFilesBytes > 0 && report.FilesBytes > j.opts.MaxFilesBytes {
+		result, err := j.remove(report, "max_files_size", &database.PurgeFilter{HasFiles: true, Oldest: batchSize})
+		if err != nil {
+			return err
+		}
+		report.FilesBytes -= result.FileBytes
+		if result.Requests == 0 {
+			break
+		}
+	}
+	for j.opts.FileQuotaBytes > 0 && report.FilesBytes > j.opts.FileQuotaBytes {
+		result, err := j.db.EvictBinaryFiles(batchSize)
+		if err != nil {
+			return err
+		}
+		j.deleteFiles(result.FilePaths)
+		report.EvictedFiles += result.Files
+		report.EvictedBytes += result.FileBytes
+		report.FilesBytes -= result.FileBytes
+		if result.Files == 0 {
+			break
+		}
+	}
+
+	if report.DBBytes, err = j.dbBytes(report.Requests > 0); err != nil {
+		return err
+	}
+	for j.opts.MaxDBBytes > 0 && report.DBBytes > j.opts.MaxDBBytes {
+		result, err := j.remove(report, "max_db_size", &database.PurgeFilter{Oldest: batchSize})
+		if err != nil {
+			return err
+		}
+		if report.DBBytes, err = j.dbBytes(result.Requests > 0); err != nil {
+			return err
+		}
+		if result.Requests == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// dbBytes returns the size of the database in use, first freeing the search
+// index space of removed bodies if requests were removed
+func (j *Janitor) dbBytes(removed bool) (int64, error) {
+	if removed {
+		if err := j.db.OptimizeSearch(); err != nil {
+			return 0, err
+		}
+	}
+	return j.db.UsedBytes()
+}
+
+// remove purges the requests selected by filter and their files, counting
+// them for limit
+func (j *Janitor) remove(report *Report, limit string, filter *database.PurgeFilter) (*database.PurgeResult, error) {
+	result, err := j.db.PurgeRequests(filter)
+	if err != nil {
+		return nil, err
+	}
+	j.deleteFiles(result.FilePaths)
+	report.Requests += result.Requests
+	report.Responses += result.Responses
+	report.Files += result.Files
+	report.FileBytes += result.FileBytes
+	if result.Requests > 0 {
+		report.ByLimit[limit] += result.Requests
+	}
+	return result, nil
+}
+
+// removeOrphanedFiles deletes stored files that have no record, returning
+// the size of the remaining files
+func (j *Janitor) removeOrphanedFiles(report *Report) (int64, error) {
+	files, err := j.fs.ListFiles()
+	if err != nil {
+		return 0, err
+	}
+	recorded, err := j.db.StoredFilePaths()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, file := range files {
+		// Thumbnails belong to their image's record
+		owner := file.Path
+		if image, ok := storage.ThumbnailOf(file.Path); ok {
+			owner = image
+		}
+		if recorded[owner] || time.Since(file.ModTime) < orphanGrace {
+			total += file.Size
+			continue
+		}
+		if err := j.fs.DeleteFile(file.Path); err != nil {
+			slog.Warn("failed to delete orphaned file", "file", file.Path, "error", err)
+			total += file.Size
+			continue
+		}
+		report.OrphanedFiles++
+	}
+	return total, nil
+}
+
+// deleteFiles deletes stored files whose records were removed
+func (j *Janitor) deleteFiles(paths []string) {
+	for _, path := range paths {
+		if err := j.fs.DeleteFile(path); err != nil {
+			slog.Warn("failed to delete purged file", "file", path, "error", err)
+		}
+	}
+}
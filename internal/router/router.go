@@ -0,0 +1,281 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+)
+
+// ErrNoProvider is returned when neither a rule nor a provider matches the request
+var ErrNoProvider = errors.New("no provider found for this request")
+
+// Router selects the provider that handles an incoming proxy request
+type Router struct {
+	mu        sync.RWMutex
+	providers []provider.Provider
+	rules     []*Rule
+}
+
+// Decision is the outcome of routing a request
+type Decision struct {
+	Provider provider.Provider `json:"-"`
+	Rule     *Rule             `json:"rule,omitempty"`
+	Target   *Target           `json:"target,omitempty"`
+	Path     string            `json:"path"`
+	Model    string            `json:"model,omitempty"`  // Model named in the request
+	Mirror   *Decision         `json:"mirror,omitempty"` // Where a copy of the request is sent, if mirrored
+}
+
+// RuleName returns the name of the matched rule, or empty for default routing
+func (d *Decision) RuleName() string {
+	if d.Rule == nil {
+		return ""
+	}
+	return d.Rule.Name
+}
+
+// RoutedModel returns the model the target transform rewrites the request
+// to, or empty if the model is left as requested
+func (d *Decision) RoutedModel() string {
+	if d.Target == nil || d.Target.Transform == nil || d.Target.Transform.Model == d.Model {
+		return ""
+	}
+	return d.Target.Transform.Model
+}
+
+// Policy returns the policy of the chosen target, or nil when the request is
+// handled with the gateway's settings
+func (d *Decision) Policy() *Policy {
+	if d.Target == nil {
+		return nil
+	}
+	return d.Target.Policy
+}
+
+// New creates a router over the given providers and rules.
+// Providers are consulted in order when no rule matches.
+func New(providers []provider.Provider, rules []*Rule) *Router {
+	return &Router{
+		providers: providers,
+		rules:     rules,
+	}
+}
+
+// Rules returns the configured routing rules
+func (rt *Router) Rules() []*Rule {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	return rt.rules
+}
+
+// Providers returns the registered providers in evaluation order
+func (rt *Router) Providers() []provider.Provider {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	return rt.providers
+}
+
+// Provider returns the provider with the given name, or nil
+func (rt *Router) Provider(name string) provider.Provider {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	for _, p := range rt.providers {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Route evaluates the routing rules for the request. The request body is
+// read and restored so downstream handlers can consume it.
+func (rt *Router) Route(r *http.Request) (*Decision, error) {
+	bodyBytes, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	return rt.Match(&MatchInput{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Model:   extractModel(bodyBytes),
+		Key:     extractKey(r.Header),
+		Headers: r.Header,
+	})
+}
+
+// Match evaluates the routing rules against the given request attributes
+func (rt *Router) Match(input *MatchInput) (*Decision, error) {
+	if input.Headers == nil {
+		input.Headers = http.Header{}
+	}
+
+	for _, rule := range rt.Rules() {
+		if !rule.Match.matches(input) {
+			continue
+		}
+
+		target := pickTarget(rule.Targets)
+		prov := rt.Provider(target.Provider)
+		if prov == nil {
+			return nil, fmt.Errorf("routing rule %q targets unknown provider %q", rule.Name, target.Provider)
+		}
+
+		decision := &Decision{
+			Provider: prov,
+			Rule:     rule,
+			Target:   target,
+			Path:     rt.rewritePath(input.Path, prov.Name()),
+			Model:    input.Model,
+		}
+		if rule.Mirror != nil && mirrorSampled(rule.Mirror) {
+			mirrorProv := rt.Provider(rule.Mirror.Provider)
+			if mirrorProv == nil {
+				return nil, fmt.Errorf("routing rule %q mirrors to unknown provider %q", rule.Name, rule.Mirror.Provider)
+			}
+			decision.Mirror = &Decision{
+				Provider: mirrorProv,
+				Rule:     rule,
+				Target:   &Target{Provider: mirrorProv.Name(), Transform: rule.Mirror.Transform},
+				Path:     rt.rewritePath(input.Path, mirrorProv.Name()),
+				Model:    input.Model,
+			}
+		}
+		return decision, nil
+	}
+
+	// Default routing: first provider whose ShouldProxy matches
+	for _, p := range rt.Providers() {
+		if p.ShouldProxy(input.Path) {
+			return &Decision{Provider: p, Path: input.Path, Model: input.Model}, nil
+		}
+	}
+
+	return nil, ErrNoProvider
+}
+
+// RouteTo sends the request to the named provider, bypassing the routing
+// rules along with their target policies. The path's provider prefix is
+// rewritten as for a rule target.
+func (rt *Router) RouteTo(r *http.Request, providerName string) (*Decision, error) {
+	prov := rt.Provider(providerName)
+	if prov == nil {
+		return nil, fmt.Errorf("unknown provider %q", providerName)
+	}
+
+	bodyBytes, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	return &Decision{
+		Provider: prov,
+		Path:     rt.rewritePath(r.URL.Path, prov.Name()),
+		Model:    extractModel(bodyBytes),
+	}, nil
+}
+
+// ApplyTransform applies the decision's target transform to the proxy request
+func (d *Decision) ApplyTransform(req *http.Request) error {
+	if d.Target == nil || d.Target.Transform == nil {
+		return nil
+	}
+	t := d.Target.Transform
+
+	for _, key := range t.RemoveHeaders {
+		req.Header.Del(key)
+	}
+	for key, value := range t.SetHeaders {
+		req.Header.Set(key, value)
+	}
+
+	if t.Model != "" && req.Body != nil {
+		bodyBytes, _ := io.ReadAll(req.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(bodyBytes, &body); err == nil {
+			body["model"] = t.Model
+			if rewritten, err := json.Marshal(body); err == nil {
+				bodyBytes = rewritten
+			}
+		}
+		req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+		req.ContentLength = int64(len(bodyBytes))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(bodyBytes)), nil
+		}
+	}
+
+	return nil
+}
+
+// pickTarget chooses a target by weight; zero weights count as 1
+func pickTarget(targets []*Target) *Target {
+	if len(targets) == 1 {
+		return targets[0]
+	}
+
+	total := 0
+	for _, t := range targets {
+		total += weightOf(t)
+	}
+
+	n := rand.Intn(total)
+	for _, t := range targets {
+		n -= weightOf(t)
+		if n < 0 {
+			return t
+		}
+	}
+	return targets[len(targets)-1]
+}
+
+// mirrorSampled decides whether a matched request is mirrored; without a
+// percent every one is, while 0 mirrors none
+func mirrorSampled(m *Mirror) bool {
+	return m.Percent == nil || rand.Float64()*100 < *m.Percent
+}
+
+func weightOf(t *Target) int {
+	if t.Weight <= 0 {
+		return 1
+	}
+	return t.Weight
+}
+
+// rewritePath swaps the provider prefix of the path for the target provider's
+// prefix: /openai/v1/chat/completions -> /azure/v1/chat/completions.
+// Paths without a known provider prefix get the target prefix prepended.
+func (rt *Router) rewritePath(p, providerName string) string {
+	trimmed := strings.TrimPrefix(p, "/")
+	first, rest, _ := strings.Cut(trimmed, "/")
+	if rt.Provider(first) != nil {
+		return "/" + providerName + "/" + rest
+	}
+	return "/" + providerName + p
+}
+
+// extractModel returns the "model" field of a JSON request body
+func extractModel(body []byte) string {
+	var payload struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	return payload.Model
+}
+
+// extractKey returns the client credential from Authorization or x-api-key
+func extractKey(h http.Header) string {
+	if auth := h.Get("Authorization"); auth != "" {
+		if idx := strings.Index(auth, " "); idx >= 0 {
+			return auth[idx+1:]
+		}
+		return auth
+	}
+	return h.Get("X-Api-Key")
+}
@@ -0,0 +1,179 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/match"
+)
+
+// RulesFile is the on-disk routing rules document
+type RulesFile struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Rule describes a single routing rule. Rules are evaluated in order and the
+// first rule whose Match is satisfied wins.
+type Rule struct {
+	Name    string    `json:"name"`
+	Match   Match     `json:"match"`
+	Targets []*Target `json:"targets"`
+	Mirror  *Mirror   `json:"mirror,omitempty"`
+}
+
+// Match holds the conditions a request must satisfy for a rule to apply.
+// Empty fields match anything. String values support path.Match globs.
+type Match struct {
+	Path    string            `json:"path,omitempty"`
+	Methods []string          `json:"methods,omitempty"`
+	Model   string            `json:"model,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Key     string            `json:"key,omitempty"`
+}
+
+// Target is a weighted destination for a matched request
+type Target struct {
+	Provider  string     `json:"provider"`
+	Weight    int        `json:"weight,omitempty"`
+	Transform *Transform `json:"transform,omitempty"`
+	Policy    *Policy    `json:"policy,omitempty"`
+}
+
+// MaxRetries is the most upstream attempts a target policy or a client's
+// retry override may ask for
+const MaxRetries = 10
+
+// Policy replaces gateway-wide settings for the requests sent to a target.
+// Zero fields keep the gateway's settings, and a client's override headers
+// still take precedence.
+type Policy struct {
+	Retries           int    `json:"retries,omitempty"`             // Upstream attempts including the first (1 = no retries)
+	Cache             string `json:"cache,omitempty"`               // "bypass" skips the response cache lookup
+	Timeout           int    `json:"timeout,omitempty"`             // Seconds for the whole upstream call
+	ReadTimeout       int    `json:"read_timeout,omitempty"`        // Seconds to wait for the response headers or the next piece of the body
+	StreamTimeout     int    `json:"stream_timeout,omitempty"`      // Timeout for streaming requests
+	StreamReadTimeout int    `json:"stream_read_timeout,omitempty"` // ReadTimeout for streaming requests
+}
+
+// Mirror sends a copy of matched requests to a second provider in the
+// background. The client only ever sees the target's response.
+type Mirror struct {
+	Provider  string     `json:"provider"`
+	Transform *Transform `json:"transform,omitempty"`
+	Percent   *float64   `json:"percent,omitempty"` // Share of matched requests mirrored (default: 100)
+}
+
+// Transform describes modifications applied to a request before forwarding
+type Transform struct {
+	Model         string            `json:"model,omitempty"`
+	SetHeaders    map[string]string `json:"set_headers,omitempty"`
+	RemoveHeaders []string          `json:"remove_headers,omitempty"`
+}
+
+// LoadRules reads and validates a routing rules file
+func LoadRules(filePath string) ([]*Rule, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routing rules file %s: %w", filePath, err)
+	}
+
+	var file RulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse routing rules file %s: %w", filePath, err)
+	}
+
+	for i, rule := range file.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if len(rule.Targets) == 0 {
+			return nil, fmt.Errorf("routing rule %q has no targets", rule.Name)
+		}
+		for _, target := range rule.Targets {
+			if target.Provider == "" {
+				return nil, fmt.Errorf("routing rule %q has a target without a provider", rule.Name)
+			}
+			if target.Weight < 0 {
+				return nil, fmt.Errorf("routing rule %q has a target with negative weight", rule.Name)
+			}
+			if err := target.Policy.validate(); err != nil {
+				return nil, fmt.Errorf("routing rule %q has a target with an invalid policy: %w", rule.Name, err)
+			}
+		}
+		if rule.Mirror != nil {
+			if rule.Mirror.Provider == "" {
+				return nil, fmt.Errorf("routing rule %q has a mirror without a provider", rule.Name)
+			}
+			if p := rule.Mirror.Percent; p != nil && (*p < 0 || *p > 100) {
+				return nil, fmt.Errorf("routing rule %q has a mirror percent outside 0-100", rule.Name)
+			}
+		}
+	}
+
+	return file.Rules, nil
+}
+
+// validate checks the policy's values; a nil policy is valid
+func (p *Policy) validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.Retries < 0 || p.Retries > MaxRetries {
+		return fmt.Errorf("retries must be 1 to %d attempts", MaxRetries)
+	}
+	if p.Cache != "" && p.Cache != "bypass" {
+		return fmt.Errorf("invalid cache %q (expected bypass)", p.Cache)
+	}
+	if p.Timeout < 0 || p.ReadTimeout < 0 || p.StreamTimeout < 0 || p.StreamReadTimeout < 0 {
+		return fmt.Errorf("timeouts can't be negative")
+	}
+	return nil
+}
+
+// matches checks whether the rule applies to the given request attributes
+func (m *Match) matches(req *MatchInput) bool {
+	if m.Path != "" && !match.Path(m.Path, req.Path) {
+		return false
+	}
+
+	if len(m.Methods) > 0 {
+		found := false
+		for _, method := range m.Methods {
+			if strings.EqualFold(method, req.Method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if m.Model != "" && !match.Glob(m.Model, req.Model) {
+		return false
+	}
+
+	for key, pattern := range m.Headers {
+		if !match.Glob(pattern, req.Headers.Get(key)) {
+			return false
+		}
+	}
+
+	if m.Key != "" && !match.Glob(m.Key, req.Key) {
+		return false
+	}
+
+	return true
+}
+
+// MatchInput contains the request attributes rules are evaluated against
+type MatchInput struct {
+	Method  string
+	Path    string
+	Model   string
+	Key     string
+	Headers http.Header
+}
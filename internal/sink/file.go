@@ -0,0 +1,51 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File appends each payload as a line to a file (JSON Lines)
+type File struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenFile opens a file sink, creating the file and its directory if needed
+func OpenFile(path string) (*File, error) {
+	if path == "" {
+		return nil, fmt.Errorf("file sink needs a path")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create sink directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sink file: %w", err)
+	}
+	return &File{file: f}, nil
+}
+
+// Publish appends the payload and a newline
+func (s *File) Publish(ctx context.Context, topic string, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line := make([]byte, 0, len(payload)+1)
+	line = append(append(line, payload...), '\n')
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write sink file: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file
+func (s *File) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
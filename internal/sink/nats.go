@@ -0,0 +1,161 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsTimeout bounds connecting to the server and writing a message
+const natsTimeout = 5 * time.Second
+
+// NATS publishes payloads to NATS subjects using the core NATS text protocol.
+// It connects on first use and reconnects after connection errors. TLS is not
+// supported.
+type NATS struct {
+	addr   string
+	prefix string
+	user   *url.Userinfo
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewNATS creates a NATS sink publishing to {prefix}.{topic} (just {topic}
+// without a prefix). user, if set, authenticates with user and password, or
+// with a token when there is no password.
+func NewNATS(addr, prefix string, user *url.Userinfo) *NATS {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "4222")
+	}
+	return &NATS{addr: addr, prefix: prefix, user: user}
+}
+
+// Publish sends the payload, reconnecting once if the connection was lost
+func (s *NATS) Publish(ctx context.Context, topic string, payload []byte) error {
+	subject := topic
+	if s.prefix != "" {
+		subject = s.prefix + "." + topic
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if err := s.connect(); err != nil {
+				return err
+			}
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(natsTimeout))
+		_, err := fmt.Fprintf(s.conn, "PUB %s %d\r\n%s\r\n", subject, len(payload), payload)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		s.conn.Close()
+		s.conn = nil
+	}
+	return fmt.Errorf("failed to publish to NATS: %w", lastErr)
+}
+
+// connect dials the server and completes the CONNECT handshake; s.mu must be held
+func (s *NATS) connect() error {
+	conn, err := net.DialTimeout("tcp", s.addr, natsTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(natsTimeout))
+	r := bufio.NewReader(conn)
+
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO") {
+		conn.Close()
+		return fmt.Errorf("unexpected NATS greeting %q: %v", strings.TrimSpace(line), err)
+	}
+
+	options := map[string]interface{}{"verbose": false, "pedantic": false, "name": "aigw", "lang": "go"}
+	if s.user != nil {
+		if pass, ok := s.user.Password(); ok {
+			options["user"], options["pass"] = s.user.Username(), pass
+		} else {
+			options["auth_token"] = s.user.Username()
+		}
+	}
+	data, _ := json.Marshal(options)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", data); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to send NATS CONNECT: %w", err)
+	}
+
+	// The server answers the PING once it accepted the connection
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("NATS handshake failed: %w", err)
+		}
+		switch {
+		case strings.HasPrefix(line, "PONG"):
+			conn.SetDeadline(time.Time{})
+			s.conn = conn
+			go s.readLoop(conn, r)
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			conn.Close()
+			return fmt.Errorf("NATS rejected connection: %s", strings.TrimSpace(line))
+		case strings.HasPrefix(line, "PING"):
+			fmt.Fprint(conn, "PONG\r\n")
+		}
+	}
+}
+
+// readLoop answers server PINGs and drops the connection when it fails
+func (s *NATS) readLoop(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		switch {
+		case strings.HasPrefix(line, "PING"):
+			s.mu.Lock()
+			if s.conn == conn {
+				conn.SetWriteDeadline(time.Now().Add(natsTimeout))
+				fmt.Fprint(conn, "PONG\r\n")
+			}
+			s.mu.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			slog.Warn("NATS server error", "error", strings.TrimSpace(line))
+		}
+	}
+
+	s.mu.Lock()
+	if s.conn == conn {
+		s.conn = nil
+	}
+	s.mu.Unlock()
+	conn.Close()
+}
+
+// Close closes the connection
+func (s *NATS) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
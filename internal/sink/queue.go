@@ -0,0 +1,109 @@
+package sink
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// publishTimeout bounds a single publish from a Queue
+const publishTimeout = 10 * time.Second
+
+// Queue publishes payloads to a sink from a background goroutine, so a slow
+// or unreachable sink never blocks the caller. Payloads are dropped while the
+// queue is full; failed publishes are logged and not retried.
+type Queue struct {
+	name  string
+	sink  Sink
+	items chan queued
+	done  chan struct{}
+
+	mu     sync.RWMutex // Guards closed against concurrent Enqueue
+	closed bool
+
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+type queued struct {
+	topic   string
+	payload []byte
+}
+
+// NewQueue starts publishing to s, holding up to size pending payloads. name
+// identifies the sink in logs.
+func NewQueue(name string, s Sink, size int) *Queue {
+	q := &Queue{
+		name:  name,
+		sink:  s,
+		items: make(chan queued, size),
+		done:  make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Enqueue queues a payload without blocking. It returns false if the payload
+// was dropped because the queue is full or closed.
+func (q *Queue) Enqueue(topic string, payload []byte) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		q.dropped.Add(1)
+		return false
+	}
+	select {
+	case q.items <- queued{topic: topic, payload: payload}:
+		return true
+	default:
+		q.dropped.Add(1)
+		return false
+	}
+}
+
+// run publishes queued payloads until the queue is closed
+func (q *Queue) run() {
+	defer close(q.done)
+	for item := range q.items {
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		err := q.sink.Publish(ctx, item.topic, item.payload)
+		cancel()
+		if err != nil {
+			q.failed.Add(1)
+			slog.Warn("failed to publish to sink", "sink", q.name, "topic", item.topic, "error", err)
+		}
+	}
+}
+
+// Dropped returns how many payloads were dropped because the queue was full
+func (q *Queue) Dropped() int64 {
+	return q.dropped.Load()
+}
+
+// Failed returns how many payloads the sink failed to publish
+func (q *Queue) Failed() int64 {
+	return q.failed.Load()
+}
+
+// Close stops accepting payloads, waits until the queued ones are published
+// or ctx is done, and closes the sink
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.items)
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+	case <-ctx.Done():
+		slog.Warn("sink did not drain before shutdown", "sink", q.name, "pending", len(q.items))
+	}
+	return q.sink.Close()
+}
@@ -0,0 +1,64 @@
+// Package sink publishes JSON payloads to external systems: webhooks, log
+// files, NATS subjects and Kafka topics. Sinks are opened from URLs so they
+// can be listed in a single environment variable.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Sink publishes JSON payloads. topic classifies the payload (e.g. the event
+// type); sinks map it to a subject, message key or header.
+type Sink interface {
+	// Publish sends one payload, returning once it was handed to the destination
+	Publish(ctx context.Context, topic string, payload []byte) error
+
+	// Close flushes and releases the sink's resources
+	Close() error
+}
+
+// Open creates a sink from a URL:
+//
+//	http://host/path, https://host/path   webhook receiving a POST per payload
+//	file:///var/log/aigw/events.jsonl      file with one payload per line
+//	nats://[user:pass@]host:4222/prefix   NATS subject {prefix}.{topic}
+//	kafka://restproxy:8082/topic          Kafka topic via the Kafka REST Proxy (kafka+https for TLS)
+func Open(rawURL string) (Sink, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid sink URL %q: %w", rawURL, err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return NewWebhook(u.String()), nil
+	case "file":
+		return OpenFile(u.Path)
+	case "nats":
+		return NewNATS(u.Host, strings.Trim(u.Path, "/"), u.User), nil
+	case "kafka", "kafka+http", "kafka+https":
+		scheme := "http"
+		if u.Scheme == "kafka+https" {
+			scheme = "https"
+		}
+		topic := strings.Trim(u.Path, "/")
+		if topic == "" {
+			return nil, fmt.Errorf("kafka sink URL %q names no topic", rawURL)
+		}
+		return NewKafkaREST(scheme+"://"+u.Host, topic), nil
+	default:
+		return nil, fmt.Errorf("unsupported sink URL scheme %q (expected http, https, file, nats or kafka)", u.Scheme)
+	}
+}
+
+// Redact returns the sink URL without credentials, for logging
+func Redact(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "invalid URL"
+	}
+	return u.Redacted()
+}
@@ -3,7 +3,10 @@ package storage
 import (
 	"fmt"
 	"io"
+	"log/slog"
+	"mime"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -11,36 +14,77 @@ import (
 	"github.com/google/uuid"
 )
 
-type FileStorage struct {
+// FileStorage stores binary files: responses like generated images, and
+// uploaded request bodies. Files are addressed by the relative path SaveFile
+// returns; missing files are reported with an error wrapping os.ErrNotExist.
+type FileStorage interface {
+	// SaveFile saves a file and returns its relative path and size
+	SaveFile(provider string, contentType string, data io.Reader) (string, int64, error)
+	// Open opens a stored file for reading
+	Open(relativePath string) (File, error)
+	// DeleteFile deletes a stored file
+	DeleteFile(relativePath string) error
+	// ListFiles returns all files in storage
+	ListFiles() ([]StoredFile, error)
+}
+
+// File is an open stored file
+type File interface {
+	io.ReadSeekCloser
+	ModTime() time.Time
+}
+
+// URLSigner is implemented by storage whose files can be downloaded directly
+// with a signed URL. SignedURL returns "" when signed URLs are off; with a
+// downloadName, the URL saves the file under that name instead of showing it.
+type URLSigner interface {
+	SignedURL(relativePath, downloadName string) string
+}
+
+// ContentDisposition returns the Content-Disposition header value that saves
+// a download under name
+func ContentDisposition(name string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": name})
+}
+
+// ReadFile returns the content of a stored file
+func ReadFile(fs FileStorage, relativePath string) ([]byte, error) {
+	file, err := fs.Open(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// LocalStorage stores files in a directory
+type LocalStorage struct {
+	thumbnails
 	basePath string
 }
 
 // New creates a new file storage with the given base path
-func New(basePath string) (*FileStorage, error) {
+func New(basePath string) (*LocalStorage, error) {
 	// Create base directory if it doesn't exist
 	if err := os.MkdirAll(basePath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
-	return &FileStorage{basePath: basePath}, nil
+	return &LocalStorage{basePath: basePath}, nil
 }
 
 // SaveFile saves a file and returns the relative path
-func (fs *FileStorage) SaveFile(provider string, contentType string, data io.Reader) (string, int64, error) {
-	// Create provider-specific directory structure
-	now := time.Now()
-	dateDir := now.Format("2006-01-02")
+func (fs *LocalStorage) SaveFile(provider string, contentType string, data io.Reader) (string, int64, error) {
+	if err := CheckProvider(provider); err != nil {
+		return "", 0, err
+	}
 
-	providerPath := filepath.Join(fs.basePath, provider, dateDir)
-	if err := os.MkdirAll(providerPath, 0755); err != nil {
+	// Create provider-specific directory structure
+	filePath := filepath.Join(fs.basePath, filepath.FromSlash(newFilePath(provider, contentType)))
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
 		return "", 0, fmt.Errorf("failed to create storage subdirectory: %w", err)
 	}
 
-	// Generate unique filename
-	ext := getExtensionFromContentType(contentType)
-	filename := uuid.New().String() + ext
-	filePath := filepath.Join(providerPath, filename)
-
 	// Create the file
 	file, err := os.Create(filePath)
 	if err != nil {
@@ -55,6 +99,17 @@ func (fs *FileStorage) SaveFile(provider string, contentType string, data io.Rea
 		return "", 0, fmt.Errorf("failed to write file: %w", err)
 	}
 
+	// A missing thumbnail only means previews fall back to the image
+	if fs.wants(contentType) {
+		thumb, err := fs.generate(file)
+		if err == nil && thumb != nil {
+			err = os.WriteFile(filePath+thumbnailSuffix, thumb, 0644)
+		}
+		if err != nil {
+			slog.Warn("failed to create thumbnail", "file", filePath, "error", err)
+		}
+	}
+
 	// Return relative path
 	relPath, err := filepath.Rel(fs.basePath, filePath)
 	if err != nil {
@@ -65,19 +120,100 @@ func (fs *FileStorage) SaveFile(provider string, contentType string, data io.Rea
 }
 
 // GetFullPath returns the full filesystem path for a stored file
-func (fs *FileStorage) GetFullPath(relativePath string) string {
+func (fs *LocalStorage) GetFullPath(relativePath string) string {
 	return filepath.Join(fs.basePath, relativePath)
 }
 
-// DeleteFile deletes a stored file
-func (fs *FileStorage) DeleteFile(relativePath string) error {
+// localFile is a stored file opened from disk
+type localFile struct {
+	*os.File
+	modTime time.Time
+}
+
+func (f *localFile) ModTime() time.Time {
+	return f.modTime
+}
+
+// Open opens a stored file for reading
+func (fs *LocalStorage) Open(relativePath string) (File, error) {
+	file, err := os.Open(fs.GetFullPath(relativePath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, fmt.Errorf("failed to open file: %s: %w", relativePath, os.ErrNotExist)
+	}
+	return &localFile{File: file, modTime: info.ModTime()}, nil
+}
+
+// DeleteFile deletes a stored file and its thumbnail
+func (fs *LocalStorage) DeleteFile(relativePath string) error {
 	fullPath := fs.GetFullPath(relativePath)
 	if err := os.Remove(fullPath); err != nil {
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
+	if _, ok := ThumbnailOf(relativePath); !ok {
+		os.Remove(fullPath + thumbnailSuffix)
+	}
 	return nil
 }
 
+// StoredFile is a file found in storage
+type StoredFile struct {
+	Path    string // Relative to the storage directory, as returned by SaveFile
+	Size    int64
+	ModTime time.Time
+}
+
+// ListFiles returns all files in storage
+func (fs *LocalStorage) ListFiles() ([]StoredFile, error) {
+	var files []StoredFile
+	err := filepath.WalkDir(fs.basePath, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(fs.basePath, filePath)
+		if err != nil {
+			return err
+		}
+		files = append(files, StoredFile{Path: relPath, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stored files: %w", err)
+	}
+	return files, nil
+}
+
+// CheckProvider reports an error if a provider name can't be used as the
+// directory of its files, e.g. one from an imported record that would
+// escape the storage directory
+func CheckProvider(provider string) error {
+	if provider == "" || provider == "." || provider == ".." || strings.ContainsAny(provider, "/\\") {
+		return fmt.Errorf("invalid provider name %q", provider)
+	}
+	return nil
+}
+
+// newFilePath returns a unique slash-separated relative path for a new file:
+// provider/date/uuid.ext
+func newFilePath(provider, contentType string) string {
+	return path.Join(provider, time.Now().Format("2006-01-02"), uuid.New().String()+getExtensionFromContentType(contentType))
+}
+
 // getExtensionFromContentType returns file extension based on content type
 func getExtensionFromContentType(contentType string) string {
 	// Remove parameters from content type (e.g., "image/png; charset=utf-8" -> "image/png")
@@ -86,19 +222,26 @@ func getExtensionFromContentType(contentType string) string {
 
 	// Map common content types to extensions
 	extensionMap := map[string]string{
-		"image/png":       ".png",
-		"image/jpeg":      ".jpg",
-		"image/jpg":       ".jpg",
-		"image/gif":       ".gif",
-		"image/webp":      ".webp",
-		"image/svg+xml":   ".svg",
-		"application/pdf": ".pdf",
-		"audio/mpeg":      ".mp3",
-		"audio/wav":       ".wav",
-		"video/mp4":       ".mp4",
-		"video/mpeg":      ".mpeg",
-		"text/plain":      ".txt",
-		"application/json":".json",
+		"image/png":                ".png",
+		"image/jpeg":               ".jpg",
+		"image/jpg":                ".jpg",
+		"image/gif":                ".gif",
+		"image/webp":               ".webp",
+		"image/svg+xml":            ".svg",
+		"application/pdf":          ".pdf",
+		"audio/mpeg":               ".mp3",
+		"audio/wav":                ".wav",
+		"audio/x-wav":              ".wav",
+		"audio/ogg":                ".ogg",
+		"audio/flac":               ".flac",
+		"video/mp4":                ".mp4",
+		"video/mpeg":               ".mpeg",
+		"video/webm":               ".webm",
+		"video/quicktime":          ".mov",
+		"application/zip":          ".zip",
+		"application/octet-stream": ".bin",
+		"text/plain":               ".txt",
+		"application/json":         ".json",
 	}
 
 	if ext, exists := extensionMap[contentType]; exists {
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/s3"
+)
+
+// S3Options configures S3Storage
+type S3Options struct {
+	Prefix string // Key prefix of stored files, e.g. "files/"
+	// SignedURLExpiry is how long signed download URLs are valid; 0 serves
+	// files through the gateway instead
+	SignedURLExpiry time.Duration
+}
+
+// S3Storage stores files in an S3-compatible bucket, so they outlive the
+// container and can be shared by several gateway instances. Files are read
+// whole into memory.
+type S3Storage struct {
+	thumbnails
+	client *s3.Client
+	opts   S3Options
+}
+
+// NewS3 creates file storage in the client's bucket
+func NewS3(client *s3.Client, opts S3Options) *S3Storage {
+	if opts.Prefix != "" && !strings.HasSuffix(opts.Prefix, "/") {
+		opts.Prefix += "/"
+	}
+	return &S3Storage{client: client, opts: opts}
+}
+
+// SaveFile saves a file and returns the relative path
+func (fs *S3Storage) SaveFile(provider string, contentType string, data io.Reader) (string, int64, error) {
+	if err := CheckProvider(provider); err != nil {
+		return "", 0, err
+	}
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	relPath := newFilePath(provider, contentType)
+	if err := fs.client.Put(context.Background(), fs.opts.Prefix+relPath, contentType, content); err != nil {
+		return "", 0, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	// A missing thumbnail only means previews fall back to the image
+	if fs.wants(contentType) {
+		thumb, err := fs.generate(bytes.NewReader(content))
+		if err == nil && thumb != nil {
+			err = fs.client.Put(context.Background(), fs.opts.Prefix+ThumbnailPath(relPath), http.DetectContentType(thumb), thumb)
+		}
+		if err != nil {
+			slog.Warn("failed to create thumbnail", "file", relPath, "error", err)
+		}
+	}
+	return relPath, int64(len(content)), nil
+}
+
+// s3File is a stored file downloaded from the bucket
+type s3File struct {
+	*bytes.Reader
+}
+
+func (f *s3File) Close() error {
+	return nil
+}
+
+func (f *s3File) ModTime() time.Time {
+	return time.Time{}
+}
+
+// Open downloads a stored file
+func (fs *S3Storage) Open(relativePath string) (File, error) {
+	content, err := fs.client.Get(context.Background(), fs.opts.Prefix+relativePath)
+	if errors.Is(err, s3.ErrNotFound) {
+		return nil, fmt.Errorf("failed to open file: %s: %w", relativePath, os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return &s3File{Reader: bytes.NewReader(content)}, nil
+}
+
+// DeleteFile deletes a stored file and its thumbnail
+func (fs *S3Storage) DeleteFile(relativePath string) error {
+	if err := fs.client.Delete(context.Background(), fs.opts.Prefix+relativePath); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	if _, ok := ThumbnailOf(relativePath); !ok {
+		if err := fs.client.Delete(context.Background(), fs.opts.Prefix+ThumbnailPath(relativePath)); err != nil {
+			return fmt.Errorf("failed to delete thumbnail: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListFiles returns all files in storage
+func (fs *S3Storage) ListFiles() ([]StoredFile, error) {
+	objects, err := fs.client.List(context.Background(), fs.opts.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stored files: %w", err)
+	}
+	files := make([]StoredFile, 0, len(objects))
+	for _, object := range objects {
+		files = append(files, StoredFile{
+			Path:    strings.TrimPrefix(object.Key, fs.opts.Prefix),
+			Size:    object.Size,
+			ModTime: object.LastModified,
+		})
+	}
+	return files, nil
+}
+
+// SignedURL returns a URL that downloads a stored file straight from the
+// bucket, or "" if signed URLs are off
+func (fs *S3Storage) SignedURL(relativePath, downloadName string) string {
+	if fs.opts.SignedURLExpiry <= 0 {
+		return ""
+	}
+	var params url.Values
+	if downloadName != "" {
+		params = url.Values{"response-content-disposition": {ContentDisposition(downloadName)}}
+	}
+	return fs.client.PresignGet(fs.opts.Prefix+relativePath, fs.opts.SignedURLExpiry, params)
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // Register decoders for the images thumbnailed
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+)
+
+const (
+	// thumbnailSuffix is appended to a stored image's path for its thumbnail
+	thumbnailSuffix = ".thumb"
+
+	// maxThumbnailSource is the most pixels an image may have to be
+	// thumbnailed, so a small file can't expand into gigabytes when decoded
+	maxThumbnailSource = 40_000_000
+)
+
+// ThumbnailPath returns the path of a stored image's thumbnail
+func ThumbnailPath(relativePath string) string {
+	return relativePath + thumbnailSuffix
+}
+
+// ThumbnailOf returns the path of the image a thumbnail belongs to, and
+// whether the path is a thumbnail at all
+func ThumbnailOf(relativePath string) (string, bool) {
+	return strings.CutSuffix(relativePath, thumbnailSuffix)
+}
+
+// thumbnails generates thumbnails of saved images for the storage backends
+type thumbnails struct {
+	size int
+}
+
+// SetThumbnailSize makes images saved from now on get a thumbnail at most
+// size pixels wide and high; 0 turns thumbnails off
+func (t *thumbnails) SetThumbnailSize(size int) {
+	t.size = size
+}
+
+// wants reports whether files of this content type get a thumbnail: PNG,
+// JPEG and GIF images, the formats the standard library decodes
+func (t *thumbnails) wants(contentType string) bool {
+	if t.size <= 0 {
+		return false
+	}
+	switch strings.TrimSpace(strings.Split(contentType, ";")[0]) {
+	case "image/png", "image/jpeg", "image/jpg", "image/gif":
+		return true
+	}
+	return false
+}
+
+// generate returns the thumbnail of an image: JPEG if it is opaque, PNG if
+// not. Images no larger than a thumbnail get none (nil).
+func (t *thumbnails) generate(data io.ReadSeeker) ([]byte, error) {
+	if _, err := data.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if config.Width <= t.size && config.Height <= t.size {
+		return nil, nil
+	}
+	if config.Width*config.Height > maxThumbnailSource {
+		return nil, fmt.Errorf("image of %dx%d is too large to thumbnail", config.Width, config.Height)
+	}
+
+	if _, err := data.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	thumb := downscale(img, t.size)
+
+	var buf bytes.Buffer
+	if thumb.Opaque() {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(&buf, thumb)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// downscale shrinks an image to fit in a size by size square, keeping its
+// aspect ratio, averaging the source pixels that make up each one
+func downscale(src image.Image, size int) *image.RGBA {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := size, size
+	if w > h {
+		th = max(1, h*size/w)
+	} else {
+		tw = max(1, w*size/h)
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, max((x+1)*w/tw, x*w/tw+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride+x0*4 : sy*rgba.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			offset := y*dst.Stride + x*4
+			for i := range sum {
+				dst.Pix[offset+i] = uint8(sum[i] / n)
+			}
+		}
+	}
+	return dst
+}
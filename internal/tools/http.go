@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// fetch GETs a URL on an allowed host and returns the body
+type fetch struct {
+	allowHosts []string
+	maxBytes   int
+	client     *http.Client
+}
+
+// Call fetches {"url": "..."}
+func (f *fetch) Call(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	target, err := url.Parse(args.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return "", fmt.Errorf("invalid url %q", args.URL)
+	}
+	if !f.allowed(target.Hostname()) {
+		return "", fmt.Errorf("host %q is not allowed", target.Hostname())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	// Redirects must stay on allowed hosts too
+	client := *f.client
+	client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return fmt.Errorf("too many redirects")
+		}
+		if !f.allowed(next.URL.Hostname()) {
+			return fmt.Errorf("redirect to host %q is not allowed", next.URL.Hostname())
+		}
+		return nil
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	return readResult(resp, f.maxBytes)
+}
+
+// allowed reports whether host matches one of the allowed host globs
+func (f *fetch) allowed(host string) bool {
+	for _, pattern := range f.allowHosts {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// webhook POSTs the call's arguments to an internal API and returns its answer
+type webhook struct {
+	url      string
+	headers  map[string]string
+	maxBytes int
+	client   *http.Client
+}
+
+// Call posts the arguments as the JSON request body
+func (wh *webhook) Call(ctx context.Context, arguments string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader([]byte(arguments)))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range wh.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	return readResult(resp, wh.maxBytes)
+}
+
+// readResult reads up to maxBytes of a tool's HTTP response, failing on
+// error statuses
+func readResult(resp *http.Response, maxBytes int) (string, error) {
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("status %d: %s", resp.StatusCode, body)
+	}
+	return string(body), nil
+}
@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Tool types a ToolsFile entry can have
+const (
+	TypeCalculator = "calculator" // Evaluates {"expression": "..."}
+	TypeFetch      = "fetch"      // GETs {"url": "..."} from an allowed host
+	TypeWebhook    = "webhook"    // POSTs the call's arguments to a fixed URL
+)
+
+// defaultMaxBytes caps tool results handed back to the model
+const defaultMaxBytes = 64 * 1024
+
+// ToolsFile is the on-disk gateway tools document
+type ToolsFile struct {
+	Tools []*Config `json:"tools"`
+}
+
+// Config describes a tool the gateway resolves itself when a model calls it
+type Config struct {
+	Name       string            `json:"name"` // Function name as declared in the client's tools
+	Type       string            `json:"type"`
+	URL        string            `json:"url,omitempty"`         // webhook: endpoint receiving the arguments
+	Headers    map[string]string `json:"headers,omitempty"`     // webhook: headers sent with each call
+	AllowHosts []string          `json:"allow_hosts,omitempty"` // fetch: host globs that may be fetched
+	MaxBytes   int               `json:"max_bytes,omitempty"`   // fetch and webhook: result size limit
+	Timeout    int               `json:"timeout,omitempty"`     // fetch and webhook: seconds (default 10)
+}
+
+// Tool resolves a function call. Arguments are the JSON arguments the model
+// produced; the result is handed back to the model as the tool message content.
+type Tool interface {
+	Call(ctx context.Context, arguments string) (string, error)
+}
+
+// Set is the gateway-resolved tools, by function name
+type Set map[string]Tool
+
+// Load reads and validates a gateway tools file
+func Load(filePath string) (Set, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tools file %s: %w", filePath, err)
+	}
+
+	var file ToolsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tools file %s: %w", filePath, err)
+	}
+
+	set := make(Set, len(file.Tools))
+	for _, cfg := range file.Tools {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("tools file %s has a tool without a name", filePath)
+		}
+		if _, exists := set[cfg.Name]; exists {
+			return nil, fmt.Errorf("tool %q is defined twice", cfg.Name)
+		}
+		tool, err := New(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("tool %q: %w", cfg.Name, err)
+		}
+		set[cfg.Name] = tool
+	}
+	return set, nil
+}
+
+// New creates the tool described by cfg
+func New(cfg *Config) (Tool, error) {
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+
+	switch cfg.Type {
+	case TypeCalculator:
+		return calculator{}, nil
+	case TypeFetch:
+		if len(cfg.AllowHosts) == 0 {
+			return nil, fmt.Errorf("fetch tool needs allow_hosts")
+		}
+		return &fetch{allowHosts: cfg.AllowHosts, maxBytes: maxBytes, client: client}, nil
+	case TypeWebhook:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("webhook tool needs a url")
+		}
+		return &webhook{url: cfg.URL, headers: cfg.Headers, maxBytes: maxBytes, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown tool type %q (expected calculator, fetch or webhook)", cfg.Type)
+	}
+}
@@ -7,6 +7,7 @@ import (
 )
 
 // embedFS contains the embedded web files
+//
 //go:embed all:web
 var embedFS embed.FS
 
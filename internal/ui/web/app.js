@@ -1,6 +1,7 @@
 // Global state
 const app = {
     requests: [],
+    nextCursor: null,
     selectedRequestId: null,
     eventSource: null,
     filters: {
@@ -23,6 +24,7 @@ const app = {
 document.addEventListener('DOMContentLoaded', () => {
     initializeEventListeners();
     loadRequests();
+    loadVersion();
     connectSSE();
 });
 
@@ -50,12 +52,13 @@ function initializeEventListeners() {
     });
 }
 
-// Load requests from API
-async function loadRequests() {
+// Load requests from API, or the next page of them with more set
+async function loadRequests(more = false) {
     if (app.isLoadingRequests) return;
+    if (more && !app.nextCursor) return;
     app.isLoadingRequests = true;
 
-    showRequestsLoading(true);
+    if (!more) showRequestsLoading(true);
 
     try {
         const params = new URLSearchParams();
@@ -64,12 +67,14 @@ async function loadRequests() {
         if (app.filters.dateFrom) params.append('date_from', Math.floor(app.filters.dateFrom.getTime() / 1000));
         if (app.filters.dateTo) params.append('date_to', Math.floor(app.filters.dateTo.getTime() / 1000));
         params.append('limit', '100');
+        if (more) params.append('cursor', app.nextCursor);
 
         const response = await fetch(`/api/requests?${params}`);
         if (!response.ok) throw new Error('Failed to load requests');
 
         const data = await response.json();
-        app.requests = data.requests || [];
+        app.requests = more ? app.requests.concat(data.requests || []) : (data.requests || []);
+        app.nextCursor = data.next_cursor || null;
         renderRequestsList();
     } catch (error) {
         console.error('Error loading requests:', error);
@@ -96,6 +101,14 @@ function renderRequestsList() {
         item.addEventListener('click', () => selectRequest(request.id));
     });
 
+    if (app.nextCursor) {
+        const loadMore = document.createElement('button');
+        loadMore.className = 'btn btn-secondary load-more-btn';
+        loadMore.textContent = 'Load more';
+        loadMore.addEventListener('click', () => loadRequests(true));
+        container.appendChild(loadMore);
+    }
+
     // Re-select current request if it exists
     if (app.selectedRequestId) {
         const selected = container.querySelector(`[data-id="${app.selectedRequestId}"]`);
@@ -142,6 +155,17 @@ function createRequestElement(request) {
         item.insertBefore(errorBadge, item.querySelector('.request-timestamp'));
     }
 
+    // Preview of the first stored image, from its thumbnail
+    if (request.image_path) {
+        const thumb = document.createElement('img');
+        thumb.className = 'request-thumb';
+        thumb.loading = 'lazy';
+        thumb.alt = '';
+        thumb.src = `/api/files/${request.image_path}?thumb=1`;
+        thumb.onerror = () => thumb.remove();
+        item.insertBefore(thumb, item.querySelector('.request-endpoint'));
+    }
+
     const endpointEl = clone.querySelector('.request-endpoint');
     endpointEl.querySelector('span').textContent = request.endpoint;
     endpointEl.title = request.endpoint;
@@ -243,13 +267,87 @@ function renderRequestDetails(detail) {
     // Find media items from request/response bodies
     const mediaItems = findBase64Media(detail);
 
+    clone.getElementById('detail-bundle').href = `/api/requests/${detail.request.id}/bundle`;
+
     // Request tab
     clone.getElementById('detail-provider').textContent = detail.request.provider;
-    clone.getElementById('detail-endpoint').textContent = detail.request.endpoint;
+    clone.getElementById('detail-endpoint').textContent = detail.request.query
+        ? `${detail.request.endpoint}?${detail.request.query}`
+        : detail.request.endpoint;
     clone.getElementById('detail-method').textContent = detail.request.method;
+
+    // Who sent the request; requests the gateway sent itself have no client address
+    if (detail.request.client_ip || detail.request.user_agent) {
+        const client = [];
+        if (detail.request.client_ip) {
+            client.push(detail.request.forwarded_for
+                ? `${detail.request.client_ip} (forwarded for ${detail.request.forwarded_for})`
+                : detail.request.client_ip);
+        }
+        if (detail.request.user_agent) {
+            client.push(detail.request.user_agent);
+        }
+        clone.getElementById('detail-client').textContent = client.join(' · ');
+        clone.querySelector('.detail-client-group').style.display = 'block';
+    }
+    if (detail.request.requested_model) {
+        // Show the rewrite when a routing rule changed the model
+        clone.getElementById('detail-model').textContent = detail.request.routed_model
+            ? `${detail.request.requested_model} → ${detail.request.routed_model}`
+            : detail.request.requested_model;
+        clone.querySelector('.detail-model-group').style.display = 'block';
+    }
+
+    // Policy override headers the client sent for this request
+    if (detail.request.overrides) {
+        clone.getElementById('detail-overrides').textContent = Object.entries(detail.request.overrides)
+            .map(([header, value]) => `${header}: ${value}`)
+            .join(', ');
+        clone.querySelector('.detail-overrides-group').style.display = 'block';
+    }
+
+    if (detail.request.sampled_out) {
+        clone.querySelector('.detail-sampled-group').style.display = 'block';
+    }
+
+    // Requests the gateway sent on its own: follow-ups (e.g. answering tool calls), mirrored copies
+    // and background refreshes of stale cached responses
+    const linked = [];
+    if (detail.request.follow_up_of) {
+        linked.push({ label: 'Follow-up of', id: detail.request.follow_up_of });
+    }
+    (detail.follow_ups || []).forEach(id => linked.push({ label: 'Follow-up', id }));
+    if (detail.request.mirror_of) {
+        linked.push({ label: 'Mirror of', id: detail.request.mirror_of });
+    }
+    (detail.mirrors || []).forEach(id => linked.push({ label: 'Mirror', id }));
+    if (detail.request.revalidation_of) {
+        linked.push({ label: 'Revalidation of', id: detail.request.revalidation_of });
+    }
+    (detail.revalidations || []).forEach(id => linked.push({ label: 'Revalidation', id }));
+    if (linked.length > 0) {
+        const linkedList = clone.getElementById('detail-linked');
+        linked.forEach(link => {
+            const item = document.createElement('li');
+            item.appendChild(document.createTextNode(`${link.label}: `));
+            const anchor = document.createElement('a');
+            anchor.href = '#';
+            anchor.textContent = link.id;
+            anchor.addEventListener('click', (e) => {
+                e.preventDefault();
+                selectRequest(link.id);
+            });
+            item.appendChild(anchor);
+            linkedList.appendChild(item);
+        });
+        clone.querySelector('.detail-linked-group').style.display = 'block';
+    }
     clone.getElementById('detail-created-at').textContent = formatTime(new Date(detail.request.created_at));
 
-    const requestBody = detail.request.body || '';
+    // Multipart and binary bodies are stored as files, summarized in the payload
+    const requestBody = detail.request.payload
+        ? JSON.stringify(detail.request.payload)
+        : detail.request.body || '';
     const requestMediaItems = mediaItems.filter(m => m.source === 'request');
     const displayRequestBody = requestMediaItems.length > 0
         ? redactBase64FromJSON(requestBody, requestMediaItems)
@@ -263,6 +361,53 @@ function renderRequestDetails(detail) {
     if (detail.response) {
         clone.getElementById('detail-status-code').textContent = `${detail.response.status_code} ${getStatusText(detail.response.status_code)}`;
         clone.getElementById('detail-duration').textContent = `${detail.response.duration_ms}ms`;
+        if (detail.response.ttft_ms != null) {
+            clone.getElementById('detail-duration').textContent += ` (first token after ${detail.response.ttft_ms}ms)`;
+        }
+
+        // Redirect hops and retried attempts before the final response
+        if (detail.hops && detail.hops.length > 0) {
+            const hopsList = clone.getElementById('detail-hops');
+            detail.hops.forEach(hop => {
+                const item = document.createElement('li');
+                const status = document.createElement('span');
+                status.className = `status-badge ${getStatusClass(hop.status_code)}`;
+                status.textContent = hop.status_code;
+                item.appendChild(status);
+                const note = hop.is_error ? (hop.error_message || 'Error') : getStatusText(hop.status_code);
+                item.appendChild(document.createTextNode(` ${note} (after ${hop.duration_ms}ms)`));
+                hopsList.appendChild(item);
+            });
+            clone.querySelector('.detail-hops-group').style.display = 'block';
+        }
+
+        // Streamed responses: the message assembled from the chunks
+        if (detail.response.message) {
+            const message = detail.response.message;
+            const lines = [message.content || ''];
+            if (message.refusal) {
+                lines.push(`[refusal] ${message.refusal}`);
+            }
+            (message.tool_calls || []).forEach(call => {
+                lines.push(`[tool call] ${call.function.name}(${call.function.arguments})`);
+            });
+            clone.getElementById('detail-message').querySelector('code').textContent = lines.join('\n').trim() || '(empty)';
+            if (detail.response.finish_reason) {
+                clone.getElementById('detail-finish-reason').textContent = `Finish reason: ${detail.response.finish_reason}`;
+            }
+            clone.querySelector('.detail-message-group').style.display = 'block';
+        }
+
+        // Deprecation notices and warnings the provider attached
+        if (detail.response.warnings && detail.response.warnings.length > 0) {
+            const list = clone.getElementById('detail-warnings');
+            detail.response.warnings.forEach(warning => {
+                const item = document.createElement('li');
+                item.textContent = `[${warning.kind}] ${warning.message} (${warning.source})`;
+                list.appendChild(item);
+            });
+            clone.querySelector('.detail-warnings-group').style.display = 'block';
+        }
 
         // Show error information if this is an error response
         if (detail.response.is_error) {
@@ -376,6 +521,13 @@ function createFileElement(file) {
     clone.querySelector('.file-type').textContent = file.content_type;
     clone.querySelector('.file-size').textContent = `${formatSize(file.size)}`;
 
+    // Save under a name derived from the request instead of the storage path
+    const download = document.createElement('a');
+    download.href = `/api/files/${file.file_path}?download=1`;
+    download.className = 'file-download';
+    download.textContent = 'Download';
+    clone.querySelector('.file-meta').appendChild(download);
+
     // Hide preview section - just show file info
     const preview = clone.querySelector('.file-preview');
     if (preview) {
@@ -512,6 +664,10 @@ function connectSSE() {
             addRequestToList(request);
         });
 
+        app.eventSource.addEventListener('update_available', (event) => {
+            showUpdateAvailable(JSON.parse(event.data).data);
+        });
+
         app.eventSource.addEventListener('response_created', (event) => {
             const data = JSON.parse(event.data).data;
             updateRequestStatus(data.request_id, data.status_code, data.is_error || false, data.error_message || '');
@@ -522,6 +678,30 @@ function connectSSE() {
             }
         });
 
+        app.eventSource.addEventListener('requests_deleted', () => {
+            loadRequests();
+        });
+
+        app.eventSource.addEventListener('requests_imported', () => {
+            loadRequests();
+        });
+
+        // Events were dropped while this tab was behind; refetch to fill the gap
+        app.eventSource.addEventListener('events_missed', (event) => {
+            const data = JSON.parse(event.data).data;
+            console.warn(`Missed ${data.count} live event(s), reloading requests`);
+            loadRequests();
+        });
+
+        // The server disconnected this tab for falling behind; reconnect and refetch
+        app.eventSource.addEventListener('slow_consumer', (event) => {
+            const data = JSON.parse(event.data).data;
+            console.warn(`SSE ${data.message}`);
+            closeSSEConnection();
+            scheduleReconnect();
+            loadRequests();
+        });
+
         app.eventSource.onerror = () => {
             console.warn('SSE error occurred, attempting to reconnect...');
             closeSSEConnection();
@@ -655,6 +835,27 @@ function updateConnectionStatus(connected, maxRetriesExceeded = false, reconnect
     }
 }
 
+// Show a link to a newer gateway release in the header
+function showUpdateAvailable(release) {
+    const link = document.getElementById('update-available');
+    link.href = release.url;
+    link.textContent = `Update available: ${release.version}`;
+    link.hidden = false;
+}
+
+// Check whether the last update check found a newer release
+async function loadVersion() {
+    try {
+        const response = await fetch('/api/version');
+        const data = await response.json();
+        if (data.update && data.update.available) {
+            showUpdateAvailable(data.update.latest);
+        }
+    } catch (error) {
+        console.error('Error loading version:', error);
+    }
+}
+
 // Copy to clipboard helper function
 async function copyToClipboard(text, buttonElement) {
     try {
@@ -747,10 +948,9 @@ function isImageContentType(contentType) {
 }
 
 function getBinaryFilePath(files) {
-    if (files && files.length > 0) {
-        return files[0].file_path;
-    }
-    return '';
+    // Files without a response hold the request body
+    const file = (files || []).find(f => f.response_id);
+    return file ? file.file_path : '';
 }
 
 function showError(message) {
@@ -836,7 +1036,7 @@ function findBase64Media(detail) {
             mediaItems.push({
                 url: `/api/files/${file.file_path}`,
                 field: `(Local file: ${file.file_path})`,
-                source: 'response',  // Binary files are from response
+                source: file.response_id ? 'response' : 'request',  // Request body files have no response
                 mediaType: file.content_type,
                 isUrl: true,
                 isLocalFile: true
@@ -0,0 +1,305 @@
+// Package verify evaluates declarative assertions about the traffic recorded
+// in the database, such as "no request used model X" or "the error rate of
+// provider Y stays under 2%", for governance checks in pipelines
+package verify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/guardrail"
+)
+
+// Checks a rule can make
+const (
+	CheckForbiddenModel = "forbidden_model" // No request used a model matching models
+	CheckErrorRate      = "error_rate"      // At most max_percent of requests failed
+	CheckNoPII          = "no_pii"          // No prompt contained personal data
+	CheckNoSecrets      = "no_secrets"      // No prompt contained credentials
+)
+
+// DefaultWindow is how far back rules look unless they say otherwise
+const DefaultWindow = "24h"
+
+// maxViolations caps the request IDs listed per failed rule
+const maxViolations = 10
+
+// File is a rules file
+type File struct {
+	Window string `json:"window"` // Default window of the rules, e.g. 24h or 7d
+	Rules  []Rule `json:"rules"`
+}
+
+// Rule is one assertion about recorded traffic
+type Rule struct {
+	Name     string `json:"name"`
+	Check    string `json:"check"`
+	Window   string `json:"window"`   // Overrides the file's window
+	Provider string `json:"provider"` // Only requests to this provider (optional)
+
+	Models      []string `json:"models"`       // forbidden_model: model names or glob patterns
+	MaxPercent  *float64 `json:"max_percent"`  // error_rate: highest acceptable percentage
+	MinRequests int      `json:"min_requests"` // error_rate: fewer requests always pass
+	Detectors   []string `json:"detectors"`    // no_pii: kinds of personal data (default: all)
+
+	window     time.Duration
+	windowText string // As written in the rules file
+}
+
+// Result is the outcome of one rule
+type Result struct {
+	Rule       string   `json:"rule"`
+	Check      string   `json:"check"`
+	Passed     bool     `json:"passed"`
+	Detail     string   `json:"detail"`
+	Violations []string `json:"violations,omitempty"` // IDs of offending requests, at most 10
+}
+
+// Load reads and validates a rules file in YAML or JSON
+func Load(filename string) (*File, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse reads and validates rules in YAML or JSON
+func Parse(data []byte) (*File, error) {
+	var file File
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) {
+		if err := json.Unmarshal(trimmed, &file); err != nil {
+			return nil, fmt.Errorf("invalid rules file: %w", err)
+		}
+	} else {
+		value, err := parseYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rules file: %w", err)
+		}
+		// Round-trip through JSON to fill the structs
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rules file: %w", err)
+		}
+		if err := json.Unmarshal(encoded, &file); err != nil {
+			return nil, fmt.Errorf("invalid rules file: %w", err)
+		}
+	}
+
+	if len(file.Rules) == 0 {
+		return nil, fmt.Errorf("rules file has no rules")
+	}
+	if file.Window == "" {
+		file.Window = DefaultWindow
+	}
+	defaultWindow, err := parseWindow(file.Window)
+	if err != nil {
+		return nil, err
+	}
+	for i := range file.Rules {
+		if err := file.Rules[i].validate(i, defaultWindow, file.Window); err != nil {
+			return nil, err
+		}
+	}
+	return &file, nil
+}
+
+// validate checks a rule's options and fills in its name and window
+func (r *Rule) validate(index int, defaultWindow time.Duration, defaultText string) error {
+	if r.Name == "" {
+		r.Name = fmt.Sprintf("rule %d (%s)", index+1, r.Check)
+	}
+	r.window, r.windowText = defaultWindow, defaultText
+	if r.Window != "" {
+		window, err := parseWindow(r.Window)
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.Name, err)
+		}
+		r.window, r.windowText = window, r.Window
+	}
+
+	switch r.Check {
+	case CheckForbiddenModel:
+		if len(r.Models) == 0 {
+			return fmt.Errorf("%s: forbidden_model needs models", r.Name)
+		}
+		for _, pattern := range r.Models {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%s: invalid model pattern %q", r.Name, pattern)
+			}
+		}
+	case CheckErrorRate:
+		if r.MaxPercent == nil || *r.MaxPercent < 0 || *r.MaxPercent > 100 {
+			return fmt.Errorf("%s: error_rate needs max_percent between 0 and 100", r.Name)
+		}
+	case CheckNoPII:
+		kinds := guardrail.PIIKinds()
+		for _, detector := range r.Detectors {
+			if !contains(kinds, detector) {
+				return fmt.Errorf("%s: unknown detector %q (one of %s)", r.Name, detector, strings.Join(kinds, ", "))
+			}
+		}
+	case CheckNoSecrets:
+	case "":
+		return fmt.Errorf("%s: check is required", r.Name)
+	default:
+		return fmt.Errorf("%s: unknown check %q", r.Name, r.Check)
+	}
+	return nil
+}
+
+// Run evaluates every rule against the traffic recorded up to now
+func Run(db *database.DB, file *File, now time.Time) ([]*Result, error) {
+	results := make([]*Result, 0, len(file.Rules))
+	for i := range file.Rules {
+		rule := &file.Rules[i]
+		params := &database.TrafficParams{Provider: rule.Provider, Since: now.Add(-rule.window)}
+
+		var result *Result
+		var err error
+		switch rule.Check {
+		case CheckForbiddenModel:
+			result, err = checkForbiddenModel(db, rule, params)
+		case CheckErrorRate:
+			result, err = checkErrorRate(db, rule, params)
+		case CheckNoPII:
+			result, err = checkBodies(db, rule, params, "personal data", func(body []byte) []guardrail.Finding {
+				return guardrail.ScanPII(body, rule.Detectors...)
+			})
+		case CheckNoSecrets:
+			result, err = checkBodies(db, rule, params, "credentials", guardrail.ScanSecrets)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rule.Name, err)
+		}
+		result.Rule, result.Check = rule.Name, rule.Check
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func checkForbiddenModel(db *database.DB, rule *Rule, params *database.TrafficParams) (*Result, error) {
+	usage, err := db.ModelUsage(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var used []string
+	result := &Result{}
+	for _, row := range usage {
+		for _, pattern := range rule.Models {
+			if matched, _ := path.Match(pattern, row.Model); matched || pattern == row.Model {
+				used = append(used, fmt.Sprintf("%s (%d)", row.Model, row.Requests))
+				if len(result.Violations) < maxViolations {
+					result.Violations = append(result.Violations, row.LastRequestID)
+				}
+				break
+			}
+		}
+	}
+
+	result.Passed = len(used) == 0
+	if result.Passed {
+		result.Detail = fmt.Sprintf("no request in the last %s used %s", rule.windowText, strings.Join(rule.Models, ", "))
+	} else {
+		result.Detail = fmt.Sprintf("forbidden models used in the last %s: %s", rule.windowText, strings.Join(used, ", "))
+	}
+	return result, nil
+}
+
+func checkErrorRate(db *database.DB, rule *Rule, params *database.TrafficParams) (*Result, error) {
+	requests, errors, failedIDs, err := db.RequestOutcomes(params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Passed: true}
+	if requests == 0 || requests < rule.MinRequests {
+		result.Detail = fmt.Sprintf("%d requests in the last %s, too few to judge", requests, rule.windowText)
+		return result, nil
+	}
+
+	rate := float64(errors) / float64(requests) * 100
+	result.Passed = rate <= *rule.MaxPercent
+	result.Detail = fmt.Sprintf("%d of %d requests in the last %s failed (%s%%, at most %s%% allowed)",
+		errors, requests, rule.windowText, formatPercent(rate), formatPercent(*rule.MaxPercent))
+	if !result.Passed {
+		result.Violations = failedIDs[:min(len(failedIDs), maxViolations)]
+	}
+	return result, nil
+}
+
+// checkBodies scans the prompts of the selected requests
+func checkBodies(db *database.DB, rule *Rule, params *database.TrafficParams, what string, scan func([]byte) []guardrail.Finding) (*Result, error) {
+	scanned, offending := 0, 0
+	kinds := map[string]int{}
+	result := &Result{}
+	err := db.EachRequestBody(params, func(id, body string) bool {
+		scanned++
+		findings := scan([]byte(body))
+		if len(findings) == 0 {
+			return true
+		}
+		offending++
+		for _, finding := range findings {
+			kinds[finding.Rule]++
+		}
+		if len(result.Violations) < maxViolations {
+			result.Violations = append(result.Violations, id)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Passed = offending == 0
+	if result.Passed {
+		result.Detail = fmt.Sprintf("no %s in %d prompts in the last %s", what, scanned, rule.windowText)
+		return result, nil
+	}
+	found := make([]string, 0, len(kinds))
+	for kind, n := range kinds {
+		found = append(found, fmt.Sprintf("%s (%d)", kind, n))
+	}
+	sort.Strings(found)
+	result.Detail = fmt.Sprintf("%d of %d prompts in the last %s contained %s: %s",
+		offending, scanned, rule.windowText, what, strings.Join(found, ", "))
+	return result, nil
+}
+
+// parseWindow reads a duration, also accepting whole days (7d)
+func parseWindow(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	window, err := time.ParseDuration(s)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("invalid window %q: use a duration like 24h or 7d", s)
+	}
+	return window, nil
+}
+
+func formatPercent(p float64) string {
+	return strconv.FormatFloat(math.Round(p*100)/100, 'f', -1, 64)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+// Package version reports the gateway's build information and checks GitHub
+// releases for newer versions.
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRepository is the GitHub repository releases are checked in
+const DefaultRepository = "ruqqq/simple-ai-gateway"
+
+// Info is the gateway's build information
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"` // Commit time of the build
+	Modified  bool   `json:"modified,omitempty"`   // Built from a working tree with uncommitted changes
+	GoVersion string `json:"go_version"`
+}
+
+// Build returns the build information of the running binary. version is the
+// one set at link time (e.g. -X main.Version=v1.2.0); VCS details come from
+// the Go toolchain when the binary was built in a git checkout.
+func Build(version string) Info {
+	info := Info{Version: version}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.GoVersion = bi.GoVersion
+	// Installed with `go install ...@vX.Y.Z`: the module version is the
+	// release. Pseudo-versions of local builds (v0.0.0-2024...) aren't.
+	if info.Version == "" || info.Version == "dev" {
+		if v := bi.Main.Version; !strings.ContainsAny(v, "-+") {
+			if _, ok := parseVersion(v); ok {
+				info.Version = v
+			}
+		}
+	}
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Commit = setting.Value
+		case "vcs.time":
+			info.BuildTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// Release is the latest release found by an update check
+type Release struct {
+	Version     string    `json:"version"`
+	URL         string    `json:"url"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// Update is the outcome of the most recent update check
+type Update struct {
+	Latest    *Release  `json:"latest,omitempty"`
+	Available bool      `json:"available"` // Latest is newer than the running version
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// CheckerOptions configures a Checker
+type CheckerOptions struct {
+	Repository string                 // owner/name on GitHub (default: DefaultRepository)
+	Interval   time.Duration          // Time between checks (default: a day)
+	OnUpdate   func(release *Release) // Called once per newer version found (optional)
+}
+
+// Checker periodically looks up the latest GitHub release and compares it
+// with the running version
+type Checker struct {
+	current string
+	opts    CheckerOptions
+	client  *http.Client
+	apiURL  string
+
+	mu       sync.RWMutex
+	last     *Update
+	notified string // Newer version OnUpdate was last called for
+}
+
+// NewChecker creates a checker for the running version
+func NewChecker(current string, opts CheckerOptions) *Checker {
+	if opts.Repository == "" {
+		opts.Repository = DefaultRepository
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 24 * time.Hour
+	}
+	return &Checker{
+		current: current,
+		opts:    opts,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		apiURL:  "https://api.github.com/repos/" + opts.Repository + "/releases/latest",
+	}
+}
+
+// Run checks for updates now and then every interval until ctx is cancelled
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.CheckOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Last returns the outcome of the most recent check, or nil before the first
+func (c *Checker) Last() *Update {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.last
+}
+
+// CheckOnce looks up the latest release and records the outcome
+func (c *Checker) CheckOnce(ctx context.Context) *Update {
+	update := &Update{CheckedAt: time.Now().UTC()}
+	release, err := c.latest(ctx)
+	if err != nil {
+		slog.Warn("update check failed", "error", err)
+		update.Error = err.Error()
+	} else {
+		update.Latest = release
+		update.Available = newer(release.Version, c.current)
+	}
+
+	c.mu.Lock()
+	c.last = update
+	notify := update.Available && c.notified != release.Version
+	if notify {
+		c.notified = release.Version
+	}
+	c.mu.Unlock()
+
+	if notify {
+		slog.Info("a newer gateway version is available", "current", c.current, "latest", release.Version, "url", release.URL)
+		if c.opts.OnUpdate != nil {
+			c.opts.OnUpdate(release)
+		}
+	}
+	return update
+}
+
+// latest fetches the latest published release
+func (c *Checker) latest(ctx context.Context) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch latest release: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch latest release: status %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		TagName     string    `json:"tag_name"`
+		HTMLURL     string    `json:"html_url"`
+		PublishedAt time.Time `json:"published_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to parse latest release: %w", err)
+	}
+	if payload.TagName == "" {
+		return nil, fmt.Errorf("latest release has no tag")
+	}
+	return &Release{Version: payload.TagName, URL: payload.HTMLURL, PublishedAt: payload.PublishedAt}, nil
+}
+
+// newer reports whether version a is a later release than b. Versions that
+// aren't vMAJOR.MINOR.PATCH (such as dev builds) are never considered older.
+func newer(a, b string) bool {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	if !okA || !okB {
+		return false
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			return va[i] > vb[i]
+		}
+	}
+	return false
+}
+
+// parseVersion reads the numeric part of a release tag like v1.2.3. Suffixes
+// from git describe (v1.2.3-4-gabcdef) or pre-releases are ignored.
+func parseVersion(v string) ([3]int, bool) {
+	var parsed [3]int
+	v = strings.TrimPrefix(v, "v")
+	if end := strings.IndexAny(v, "-+"); end >= 0 {
+		v = v[:end]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return parsed, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return parsed, false
+		}
+		parsed[i] = n
+	}
+	return parsed, true
+}
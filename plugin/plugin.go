@@ -0,0 +1,116 @@
+// Package plugin is the public extension point for compiling additional
+// providers into the gateway without modifying internal/provider.
+//
+// A provider package implements Provider and registers itself from init:
+//
+//	package anthropic
+//
+//	import "github.com/ruqqq/simple-ai-gateway/plugin"
+//
+//	func init() {
+//		plugin.Register(&Provider{})
+//	}
+//
+// and is then blank-imported from cmd/aigw/plugins.go.
+package plugin
+
+import (
+	"net/http"
+
+	"github.com/ruqqq/simple-ai-gateway/internal/database"
+	"github.com/ruqqq/simple-ai-gateway/internal/provider"
+	"github.com/ruqqq/simple-ai-gateway/internal/storage"
+)
+
+// Provider is the interface all gateway providers implement
+type Provider = provider.Provider
+
+// APIKeyInjector is optionally implemented by providers that can inject a
+// gateway-configured API key ({PROVIDER}_API_KEY) into unauthenticated requests
+type APIKeyInjector = provider.APIKeyInjector
+
+// InjectedAPIKey returns the gateway-side key an APIKeyInjector should inject
+// into req: one the gateway picked for this request from a key pool, or else fallback
+func InjectedAPIKey(req *http.Request, fallback string) string {
+	return provider.InjectedAPIKey(req, fallback)
+}
+
+// Transporter is optionally implemented by providers that send requests through
+// their own http.RoundTripper instead of the gateway's HTTP client
+type Transporter = provider.Transporter
+
+// UsageExtractor is optionally implemented by providers whose responses report
+// token usage in a format ParseUsage doesn't understand
+type UsageExtractor = provider.UsageExtractor
+
+// Usage is the normalized token usage returned by a UsageExtractor
+type Usage = provider.Usage
+
+// ParseUsage reads OpenAI, Anthropic or Gemini style usage from a response body
+func ParseUsage(body string) (*Usage, bool) {
+	return provider.ParseUsage(body)
+}
+
+// WarningExtractor is optionally implemented by providers that attach
+// deprecation notices or warnings in a form ParseWarnings doesn't understand
+type WarningExtractor = provider.WarningExtractor
+
+// Warning is a provider warning returned by a WarningExtractor
+type Warning = provider.Warning
+
+// StreamReconstructor is optionally implemented by providers whose streams
+// aren't in OpenAI's chat completion chunk format
+type StreamReconstructor = provider.StreamReconstructor
+
+// StreamResult is the assembled stream returned by a StreamReconstructor
+type StreamResult = provider.StreamResult
+
+// StreamMessage is the message a StreamResult assembles a stream into
+type StreamMessage = provider.StreamMessage
+
+// StreamToolCall is a tool call of a StreamMessage
+type StreamToolCall = provider.StreamToolCall
+
+// StreamUsageRequester is optionally implemented by providers whose streaming
+// responses only report token usage when the request asks for it
+type StreamUsageRequester = provider.StreamUsageRequester
+
+// PredictionPoller is optionally implemented by providers whose responses can
+// describe a prediction still running upstream, so the gateway follows it
+type PredictionPoller = provider.PredictionPoller
+
+// CannedErrorProvider is optionally implemented by providers that shape
+// gateway-generated errors like their own API errors
+type CannedErrorProvider = provider.CannedErrorProvider
+
+// Error types passed to CannedErrorProvider.GetCannedError
+const (
+	ErrorTypeRateLimit        = provider.ErrorTypeRateLimit
+	ErrorTypeServerError      = provider.ErrorTypeServerError
+	ErrorTypeContentSensitive = provider.ErrorTypeContentSensitive
+	ErrorTypeQuotaExceeded    = provider.ErrorTypeQuotaExceeded
+	ErrorTypeAuthentication   = provider.ErrorTypeAuthentication
+	ErrorTypePermission       = provider.ErrorTypePermission
+	ErrorTypeInvalidRequest   = provider.ErrorTypeInvalidRequest
+	ErrorTypeUpstream         = provider.ErrorTypeUpstream
+	ErrorTypeTimeout          = provider.ErrorTypeTimeout
+	ErrorTypeNotFound         = provider.ErrorTypeNotFound
+	ErrorTypeUnavailable      = provider.ErrorTypeUnavailable
+)
+
+// FileStorage is the binary file store passed to Provider.ProcessResponse
+type FileStorage = storage.FileStorage
+
+// DB is the database handle passed to Provider.ProcessResponse
+type DB = database.DB
+
+// Register makes a provider available to the gateway.
+// It panics if a provider with the same name is already registered.
+func Register(p Provider) {
+	provider.Register(p)
+}
+
+// Registered returns all registered providers in registration order
+func Registered() []Provider {
+	return provider.Registered()
+}